@@ -1,8 +1,10 @@
 package btcscanner
 
 import (
+	"errors"
 	"fmt"
 
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	notifier "github.com/lightningnetwork/lnd/chainntnfs"
 	"go.uber.org/zap"
 
@@ -123,23 +125,35 @@ func (bs *BtcPoller) HandleNewBlock(blockEpoch *notifier.BlockEpoch) error {
 	// try to extract confirmed blocks
 	confirmedBlocks := bs.unconfirmedBlockCache.TrimConfirmedBlocks(int(bs.confirmationDepth) - 1)
 
-	bs.commitChainUpdate(confirmedBlocks)
-
-	return nil
+	return bs.commitChainUpdate(confirmedBlocks)
 }
 
-func (bs *BtcPoller) commitChainUpdate(confirmedBlocks []*types.IndexedBlock) {
+// commitChainUpdate sends the confirmed blocks and the current unconfirmed
+// blocks to the chain update channel. If the confirmed blocks do not connect
+// to the last confirmed block, it rolls back to the fork point and returns
+// ErrMajorReorg, in which case the scanner needs to bootstrap again
+func (bs *BtcPoller) commitChainUpdate(confirmedBlocks []*types.IndexedBlock) error {
 	if len(confirmedBlocks) != 0 {
-		if bs.confirmedTipBlock != nil {
-			confirmedTipHash := bs.confirmedTipBlock.BlockHash()
-			if !confirmedTipHash.IsEqual(&confirmedBlocks[0].Header.PrevBlock) {
-				// this indicates either programmatic error or the confirmation
-				// depth is not large enough to cover re-orgs
-				majorReorgsCounter.Inc()
-				panic(fmt.Errorf("major reorgs happened at height %d", confirmedBlocks[0].Height))
-			}
+		// after a restart, the confirmed tip is only known by the store
+		prevHeight := uint64(confirmedBlocks[0].Height) - 1
+		prevHash, err := bs.confirmedBlockHash(prevHeight)
+		if err != nil && !errors.Is(err, ErrBlockHashNotFound) {
+			return fmt.Errorf("failed to get the confirmed block hash at height %d: %w", prevHeight, err)
+		}
+		if prevHash != nil && !prevHash.IsEqual(&confirmedBlocks[0].Header.PrevBlock) {
+			// this indicates the confirmation depth is not large
+			// enough to cover re-orgs
+			majorReorgsCounter.Inc()
+			return bs.rollbackConfirmedChain(confirmedBlocks[0].Height)
 		}
 		bs.confirmedTipBlock = confirmedBlocks[len(confirmedBlocks)-1]
+
+		for _, b := range confirmedBlocks {
+			// only the header is needed for finding fork points
+			if err := bs.confirmedBlockHistory.Add(types.NewIndexedBlock(b.Height, b.Header, nil)); err != nil {
+				return fmt.Errorf("failed to add the block %d to the confirmed history: %w", b.Height, err)
+			}
+		}
 	}
 
 	chainUpdateInfo := &ChainUpdateInfo{
@@ -147,6 +161,80 @@ func (bs *BtcPoller) commitChainUpdate(confirmedBlocks []*types.IndexedBlock) {
 		UnconfirmedBlocks: bs.getUnconfirmedBlocks(),
 	}
 
+	bs.sendChainUpdate(chainUpdateInfo)
+
+	return nil
+}
+
+// rollbackConfirmedChain finds the last confirmed block that is still on the
+// canonical chain, resets the confirmed tip to it and notifies the consumer
+// of the chain update to roll back the state above it. Nothing is changed if
+// the fork point cannot be found, e.g., the BTC node is not reachable, so the
+// chain update can be retried
+func (bs *BtcPoller) rollbackConfirmedChain(reorgHeight int32) error {
+	forkBlock, err := bs.findForkBlock(uint64(reorgHeight) - 1)
+	if err != nil {
+		return fmt.Errorf("failed to find the fork point of the major reorg at height %d: %w", reorgHeight, err)
+	}
+
+	forkHeight := uint64(forkBlock.Height)
+	bs.logger.Warn("major reorg happened, rolling back the confirmed chain",
+		zap.Int32("reorg_height", reorgHeight),
+		zap.Uint64("fork_height", forkHeight),
+		zap.Uint64("previous_confirmed_height", bs.LastConfirmedHeight()))
+
+	bs.confirmedBlockHistory.RemoveBlocksAfterHeight(forkBlock.Height)
+	bs.confirmedTipBlock = forkBlock
+	bs.unconfirmedBlockCache.RemoveAll()
+
+	bs.sendChainUpdate(&ChainUpdateInfo{ForkHeight: &forkHeight})
+
+	return fmt.Errorf("%w at height %d, rolled back to height %d", ErrMajorReorg, reorgHeight, forkHeight)
+}
+
+// findForkBlock walks back the confirmed blocks from the given height and
+// returns the latest one that is still on the canonical chain
+func (bs *BtcPoller) findForkBlock(fromHeight uint64) (*types.IndexedBlock, error) {
+	for height := fromHeight; ; height-- {
+		confirmedHash, err := bs.confirmedBlockHash(height)
+		if errors.Is(err, ErrBlockHashNotFound) {
+			return nil, fmt.Errorf("the fork point is deeper than the known confirmed blocks, the lowest is at height %d", height+1)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the confirmed block hash at height %d: %w", height, err)
+		}
+
+		header, err := bs.btcClient.GetBlockHeaderByHeight(height)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the block header at height %d: %w", height, err)
+		}
+		if header.BlockHash() == *confirmedHash {
+			return types.NewIndexedBlock(int32(height), header, nil), nil
+		}
+
+		if height == 0 {
+			return nil, fmt.Errorf("no confirmed block is on the canonical chain")
+		}
+	}
+}
+
+// confirmedBlockHash returns the hash of the confirmed block at the given
+// height from the confirmed block history, or from the store if the height
+// is below the history
+// it returns ErrBlockHashNotFound if the block is not known
+func (bs *BtcPoller) confirmedBlockHash(height uint64) (*chainhash.Hash, error) {
+	if b := bs.confirmedBlockHistory.FindBlock(height); b != nil {
+		blockHash := b.BlockHash()
+		return &blockHash, nil
+	}
+	if bs.blockHashStore == nil {
+		return nil, ErrBlockHashNotFound
+	}
+
+	return bs.blockHashStore.GetBlockHash(height)
+}
+
+func (bs *BtcPoller) sendChainUpdate(chainUpdateInfo *ChainUpdateInfo) {
 	select {
 	case bs.chainUpdateInfoChan <- chainUpdateInfo:
 	case <-bs.quit:
@@ -110,6 +110,20 @@ func (b *BTCCache) RemoveAll() {
 	b.blocks = []*types.IndexedBlock{}
 }
 
+// RemoveBlocksAfterHeight deletes all the blocks with a height higher than
+// the given height. Thread-safe.
+func (b *BTCCache) RemoveBlocksAfterHeight(height int32) {
+	b.Lock()
+	defer b.Unlock()
+
+	l := len(b.blocks)
+	for l > 0 && b.blocks[l-1].Height > height {
+		b.blocks[l-1] = nil
+		l--
+	}
+	b.blocks = b.blocks[:l]
+}
+
 // Size returns the size of the cache. Thread-safe.
 func (b *BTCCache) Size() uint64 {
 	b.RLock()
@@ -123,6 +137,21 @@ func (b *BTCCache) size() uint64 {
 	return uint64(len(b.blocks))
 }
 
+// FindBlock returns the block at the given height, or nil if it is not in
+// the cache. Thread-safe.
+func (b *BTCCache) FindBlock(height uint64) *types.IndexedBlock {
+	b.RLock()
+	defer b.RUnlock()
+
+	for i := len(b.blocks) - 1; i >= 0; i-- {
+		if uint64(b.blocks[i].Height) == height {
+			return b.blocks[i]
+		}
+	}
+
+	return nil
+}
+
 func (b *BTCCache) GetAllBlocks() []*types.IndexedBlock {
 	b.RLock()
 	defer b.RUnlock()
@@ -1,10 +1,12 @@
 package btcscanner
 
 import (
+	"errors"
 	"fmt"
 	"sync"
 	"time"
 
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	notifier "github.com/lightningnetwork/lnd/chainntnfs"
 	"go.uber.org/atomic"
 	"go.uber.org/zap"
@@ -28,7 +30,21 @@ type BtcScanner interface {
 	Stop() error
 }
 
+// BlockHashStore gives the hashes of the confirmed blocks already processed,
+// which are persisted so that the fork point of a major reorg can be found
+// after a restart or below the in-memory history of the scanner
+type BlockHashStore interface {
+	// GetBlockHash returns the hash of the processed block at the given
+	// height, or ErrBlockHashNotFound if it is not stored
+	GetBlockHash(height uint64) (*chainhash.Hash, error)
+}
+
 type ChainUpdateInfo struct {
+	// ForkHeight is set if a reorg deeper than the confirmation depth
+	// happened, in which case the state derived from the confirmed blocks
+	// above ForkHeight should be rolled back. It is the height of the last
+	// confirmed block that is still on the canonical chain
+	ForkHeight        *uint64
 	ConfirmedBlocks   []*types.IndexedBlock
 	UnconfirmedBlocks []*types.IndexedBlock
 }
@@ -48,6 +64,15 @@ type BtcPoller struct {
 	// cache of a sequence of unconfirmed blocks
 	unconfirmedBlockCache *BTCCache
 
+	// headers of the last confirmed blocks, used to find the fork
+	// point when a major reorg happens
+	confirmedBlockHistory *BTCCache
+
+	// hashes of the processed confirmed blocks, used to find the fork
+	// point below the confirmed block history. It can be nil, in which
+	// case only the confirmed block history is used
+	blockHashStore BlockHashStore
+
 	// receives chain update info
 	chainUpdateInfoChan chan *ChainUpdateInfo
 
@@ -61,12 +86,18 @@ func NewBTCScanner(
 	logger *zap.Logger,
 	btcClient Client,
 	btcNotifier notifier.ChainNotifier,
+	blockHashStore BlockHashStore,
 ) (*BtcPoller, error) {
 	unconfirmedBlockCache, err := NewBTCCache(defaultMaxEntries)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create BTC cache for tail blocks: %w", err)
 	}
 
+	confirmedBlockHistory, err := NewBTCCache(defaultMaxEntries)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create BTC cache for confirmed blocks: %w", err)
+	}
+
 	return &BtcPoller{
 		logger:                logger.With(zap.String("module", "btcscanner")),
 		btcClient:             btcClient,
@@ -74,6 +105,8 @@ func NewBTCScanner(
 		confirmationDepth:     confirmationDepth,
 		chainUpdateInfoChan:   make(chan *ChainUpdateInfo),
 		unconfirmedBlockCache: unconfirmedBlockCache,
+		confirmedBlockHistory: confirmedBlockHistory,
+		blockHashStore:        blockHashStore,
 		isStarted:             atomic.NewBool(false),
 		quit:                  make(chan struct{}),
 	}, nil
@@ -168,14 +201,18 @@ func (bs *BtcPoller) Bootstrap(startHeight uint64) error {
 			// deep copy so that the copy will not be affected by memory release
 			blocksCopy := make([]*types.IndexedBlock, len(confirmedBlocks))
 			copy(blocksCopy, confirmedBlocks)
-			bs.commitChainUpdate(blocksCopy)
+			if err := bs.commitChainUpdate(blocksCopy); err != nil {
+				return bs.handleCommitError(err)
+			}
 
 			confirmedBlocks = nil
 		}
 	}
 
 	if len(confirmedBlocks) != 0 || len(bs.getUnconfirmedBlocks()) != 0 {
-		bs.commitChainUpdate(confirmedBlocks)
+		if err := bs.commitChainUpdate(confirmedBlocks); err != nil {
+			return bs.handleCommitError(err)
+		}
 	}
 
 	bs.logger.Info("bootstrapping is finished",
@@ -184,6 +221,20 @@ func (bs *BtcPoller) Bootstrap(startHeight uint64) error {
 	return nil
 }
 
+// handleCommitError re-bootstraps from the fork point if the commit failed
+// due to a major reorg, which has already been rolled back
+func (bs *BtcPoller) handleCommitError(err error) error {
+	if !errors.Is(err, ErrMajorReorg) {
+		return err
+	}
+
+	bs.logger.Warn("re-bootstrapping after a major reorg",
+		zap.Uint64("fork_height", bs.LastConfirmedHeight()),
+		zap.Error(err))
+
+	return bs.Bootstrap(bs.LastConfirmedHeight() + 1)
+}
+
 func (bs *BtcPoller) getUnconfirmedBlocks() []*types.IndexedBlock {
 	tipBlock := bs.unconfirmedBlockCache.Tip()
 	if tipBlock == nil {
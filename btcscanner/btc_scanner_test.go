@@ -1,11 +1,13 @@
 package btcscanner_test
 
 import (
+	"fmt"
 	"math/rand"
 	"sync"
 	"testing"
 
 	bbndatagen "github.com/babylonlabs-io/babylon/testutil/datagen"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/golang/mock/gomock"
 	"github.com/lightningnetwork/lnd/chainntnfs"
 	"github.com/lightningnetwork/lnd/lntest/mock"
@@ -45,7 +47,7 @@ func FuzzBootstrap(f *testing.F) {
 				Return(chainIndexedBlocks[i], nil).AnyTimes()
 		}
 
-		btcScanner, err := btcscanner.NewBTCScanner(uint16(k), zap.NewNop(), mockBtcClient, &mock.ChainNotifier{})
+		btcScanner, err := btcscanner.NewBTCScanner(uint16(k), zap.NewNop(), mockBtcClient, &mock.ChainNotifier{}, nil)
 		require.NoError(t, err)
 
 		var wg sync.WaitGroup
@@ -117,7 +119,7 @@ func FuzzHandleNewBlock(f *testing.F) {
 		secondChainedIndexedBlocks := datagen.GetRandomIndexedBlocksFromHeight(r, numBlocks2, bestHeight, bestBlockHash)
 		secondChainedBlockEpochs := indexedBlocksToBlockEpochs(secondChainedIndexedBlocks)
 
-		btcScanner, err := btcscanner.NewBTCScanner(uint16(k), zap.NewNop(), mockBtcClient, &mock.ChainNotifier{})
+		btcScanner, err := btcscanner.NewBTCScanner(uint16(k), zap.NewNop(), mockBtcClient, &mock.ChainNotifier{}, nil)
 		require.NoError(t, err)
 
 		// receive confirmed blocks
@@ -155,7 +157,7 @@ func FuzzHandleNewBlock(f *testing.F) {
 }
 
 // FuzzBootstrapMajorReorg tests the case when a major reorg is happening
-// this should cause panic
+// the scanner should roll back to the fork point and continue on the new chain
 func FuzzBootstrapMajorReorg(f *testing.F) {
 	bbndatagen.AddRandomSeedsToFuzzer(f, 100)
 
@@ -171,52 +173,233 @@ func FuzzBootstrapMajorReorg(f *testing.F) {
 		confirmedBlocks := chainIndexedBlocks[:numBlocks-k+1]
 		lastConfirmedBlock := confirmedBlocks[len(confirmedBlocks)-1]
 
-		// major reorg chain is created from the last confirmed height but does not point
-		// to the last confirmed block
-		secondChain := datagen.GetRandomIndexedBlocks(r, uint64(lastConfirmedBlock.Height+1), numBlocks)
+		// major reorg chain forks from a confirmed block lower than
+		// the last confirmed block and grows higher than the first chain
+		forkBlock := confirmedBlocks[r.Intn(len(confirmedBlocks)-1)]
+		numSecondChainBlocks := uint64(bestHeight-forkBlock.Height) + uint64(r.Intn(20)) + 1
+		secondChain := datagen.GetRandomIndexedBlocksFromHeight(r, numSecondChainBlocks, forkBlock.Height, forkBlock.BlockHash())
 		secondBestHeight := secondChain[len(secondChain)-1].Height
 
 		ctl := gomock.NewController(t)
 		mockBtcClient := mocks.NewMockClient(ctl)
-		tipHeightCall1 := mockBtcClient.EXPECT().GetTipHeight().Return(uint64(bestHeight), nil)
-		mockBtcClient.EXPECT().GetTipHeight().Return(uint64(secondBestHeight), nil).After(tipHeightCall1)
-		for i := chainIndexedBlocks[0].Height; i <= secondChain[len(secondChain)-1].Height; i++ {
-			if i < secondChain[0].Height {
-				firstBlock := chainIndexedBlocks[int(i-chainIndexedBlocks[0].Height)]
+		firstTipCall := mockBtcClient.EXPECT().GetTipHeight().Return(uint64(bestHeight), nil)
+		mockBtcClient.EXPECT().GetTipHeight().Return(uint64(secondBestHeight), nil).After(firstTipCall).AnyTimes()
+		for i := chainIndexedBlocks[0].Height; i <= secondBestHeight; i++ {
+			if i <= forkBlock.Height {
+				b := chainIndexedBlocks[i-chainIndexedBlocks[0].Height]
 				mockBtcClient.EXPECT().GetBlockByHeight(gomock.Eq(uint64(i))).
-					Return(firstBlock, nil)
-			} else if i <= bestHeight {
-				firstBlock := chainIndexedBlocks[int(i-chainIndexedBlocks[0].Height)]
-				c1 := mockBtcClient.EXPECT().GetBlockByHeight(gomock.Eq(uint64(i))).
-					Return(firstBlock, nil)
-				secondBlock := secondChain[int(i-secondChain[0].Height)]
+					Return(b, nil).AnyTimes()
+				mockBtcClient.EXPECT().GetBlockHeaderByHeight(gomock.Eq(uint64(i))).
+					Return(b.Header, nil).AnyTimes()
+				continue
+			}
+
+			// blocks above the fork height are replaced by the second chain
+			// after being fetched once
+			secondBlock := secondChain[i-secondChain[0].Height]
+			if i <= bestHeight {
+				firstCall := mockBtcClient.EXPECT().GetBlockByHeight(gomock.Eq(uint64(i))).
+					Return(chainIndexedBlocks[i-chainIndexedBlocks[0].Height], nil)
 				mockBtcClient.EXPECT().GetBlockByHeight(gomock.Eq(uint64(i))).
-					Return(secondBlock, nil).After(c1)
+					Return(secondBlock, nil).After(firstCall).AnyTimes()
 			} else {
-				secondBlock := secondChain[int(i-secondChain[0].Height)]
 				mockBtcClient.EXPECT().GetBlockByHeight(gomock.Eq(uint64(i))).
-					Return(secondBlock, nil)
+					Return(secondBlock, nil).AnyTimes()
 			}
+			mockBtcClient.EXPECT().GetBlockHeaderByHeight(gomock.Eq(uint64(i))).
+				Return(secondBlock.Header, nil).AnyTimes()
 		}
 
-		btcScanner, err := btcscanner.NewBTCScanner(uint16(k), zap.NewNop(), mockBtcClient, &mock.ChainNotifier{})
+		btcScanner, err := btcscanner.NewBTCScanner(uint16(k), zap.NewNop(), mockBtcClient, &mock.ChainNotifier{}, nil)
 		require.NoError(t, err)
 
-		// receive confirmed blocks
+		// bootstrap in the background as it blocks on sending updates
+		errChan := make(chan error, 1)
 		go func() {
-			for {
-				<-btcScanner.ChainUpdateInfoChan()
+			errChan <- btcScanner.Bootstrap(startHeight)
+		}()
+		drainUpdates(t, btcScanner, errChan)
+		require.Equal(t, uint64(lastConfirmedBlock.Height), btcScanner.LastConfirmedHeight())
+
+		// the second bootstrap detects the major reorg and rolls back to the fork point
+		go func() {
+			errChan <- btcScanner.Bootstrap(uint64(lastConfirmedBlock.Height) + 1)
+		}()
+		updates := drainUpdates(t, btcScanner, errChan)
+
+		// the first update should notify the fork height
+		require.NotEmpty(t, updates)
+		require.NotNil(t, updates[0].ForkHeight)
+		require.Equal(t, uint64(forkBlock.Height), *updates[0].ForkHeight)
+
+		// the rest should be confirmed blocks of the second chain from the fork height
+		expectedHeight := forkBlock.Height + 1
+		for _, u := range updates[1:] {
+			require.Nil(t, u.ForkHeight)
+			for _, b := range u.ConfirmedBlocks {
+				require.Equal(t, expectedHeight, b.Height)
+				require.Equal(t, secondChain[b.Height-secondChain[0].Height].BlockHash(), b.BlockHash())
+				expectedHeight++
+			}
+		}
+		require.Equal(t, uint64(secondBestHeight)-k+1, btcScanner.LastConfirmedHeight())
+	})
+}
+
+// FuzzMajorReorgAfterRestart tests the case when a major reorg happens
+// below the start height after a restart, in which case the confirmed block
+// history is empty and the fork point is found from the stored block hashes
+func FuzzMajorReorgAfterRestart(f *testing.F) {
+	bbndatagen.AddRandomSeedsToFuzzer(f, 100)
+
+	f.Fuzz(func(t *testing.T, seed int64) {
+		r := rand.New(rand.NewSource(seed))
+		versionedParams := datagen.GenerateGlobalParamsVersions(r, t)
+		k := uint64(10)
+		startHeight := versionedParams.Versions[0].ActivationHeight
+		numBlocks := bbndatagen.RandomIntOtherThan(r, 0, 50) + k
+		firstChain := datagen.GetRandomIndexedBlocks(r, startHeight, numBlocks)
+		lastConfirmedBlock := firstChain[numBlocks-k]
+
+		// the blocks up to the last confirmed one were processed before the restart
+		store := newMockBlockHashStore()
+		for _, b := range firstChain[:numBlocks-k+1] {
+			store.addBlock(b)
+		}
+
+		// the new chain forks from a processed block and replaces the rest
+		forkBlock := firstChain[r.Intn(int(numBlocks-k))]
+		numSecondChainBlocks := uint64(lastConfirmedBlock.Height-forkBlock.Height) + k + uint64(r.Intn(20))
+		secondChain := datagen.GetRandomIndexedBlocksFromHeight(r, numSecondChainBlocks, forkBlock.Height, forkBlock.BlockHash())
+		secondBestHeight := secondChain[len(secondChain)-1].Height
+
+		ctl := gomock.NewController(t)
+		mockBtcClient := mocks.NewMockClient(ctl)
+		mockBtcClient.EXPECT().GetTipHeight().Return(uint64(secondBestHeight), nil).AnyTimes()
+		for _, b := range firstChain {
+			if b.Height > forkBlock.Height {
+				break
 			}
+			mockBtcClient.EXPECT().GetBlockHeaderByHeight(gomock.Eq(uint64(b.Height))).
+				Return(b.Header, nil).AnyTimes()
+		}
+		for _, b := range secondChain {
+			mockBtcClient.EXPECT().GetBlockByHeight(gomock.Eq(uint64(b.Height))).
+				Return(b, nil).AnyTimes()
+			mockBtcClient.EXPECT().GetBlockHeaderByHeight(gomock.Eq(uint64(b.Height))).
+				Return(b.Header, nil).AnyTimes()
+		}
+
+		btcScanner, err := btcscanner.NewBTCScanner(uint16(k), zap.NewNop(), mockBtcClient, &mock.ChainNotifier{}, store)
+		require.NoError(t, err)
+
+		errChan := make(chan error, 1)
+		go func() {
+			errChan <- btcScanner.Bootstrap(uint64(lastConfirmedBlock.Height) + 1)
 		}()
+		updates := drainUpdates(t, btcScanner, errChan)
+
+		// the first update should notify the fork height
+		require.NotEmpty(t, updates)
+		require.NotNil(t, updates[0].ForkHeight)
+		require.Equal(t, uint64(forkBlock.Height), *updates[0].ForkHeight)
+
+		// the rest should be confirmed blocks of the second chain from the fork height
+		expectedHeight := forkBlock.Height + 1
+		for _, u := range updates[1:] {
+			require.Nil(t, u.ForkHeight)
+			for _, b := range u.ConfirmedBlocks {
+				require.Equal(t, expectedHeight, b.Height)
+				require.Equal(t, secondChain[b.Height-secondChain[0].Height].BlockHash(), b.BlockHash())
+				expectedHeight++
+			}
+		}
+		require.Equal(t, uint64(secondBestHeight)-k+1, btcScanner.LastConfirmedHeight())
+	})
+}
 
-		err = btcScanner.Bootstrap(startHeight)
+// FuzzMajorReorgForkNotFound tests that the scanner returns an error without
+// rolling back when the fork point of a major reorg cannot be found
+func FuzzMajorReorgForkNotFound(f *testing.F) {
+	bbndatagen.AddRandomSeedsToFuzzer(f, 100)
+
+	f.Fuzz(func(t *testing.T, seed int64) {
+		r := rand.New(rand.NewSource(seed))
+		versionedParams := datagen.GenerateGlobalParamsVersions(r, t)
+		k := uint64(10)
+		startHeight := versionedParams.Versions[0].ActivationHeight
+		lastProcessedBlock := datagen.GetRandomIndexedBlocks(r, startHeight, 1)[0]
+
+		store := newMockBlockHashStore()
+		store.addBlock(lastProcessedBlock)
+
+		// the new chain replaces the last processed block
+		secondChain := datagen.GetRandomIndexedBlocksFromHeight(r, k+1, lastProcessedBlock.Height-1, lastProcessedBlock.Header.PrevBlock)
+		secondBestHeight := secondChain[len(secondChain)-1].Height
+
+		ctl := gomock.NewController(t)
+		mockBtcClient := mocks.NewMockClient(ctl)
+		mockBtcClient.EXPECT().GetTipHeight().Return(uint64(secondBestHeight), nil).AnyTimes()
+		for _, b := range secondChain {
+			mockBtcClient.EXPECT().GetBlockByHeight(gomock.Eq(uint64(b.Height))).
+				Return(b, nil).AnyTimes()
+		}
+
+		btcScanner, err := btcscanner.NewBTCScanner(uint16(k), zap.NewNop(), mockBtcClient, &mock.ChainNotifier{}, store)
 		require.NoError(t, err)
-		require.Panics(t, func() {
-			_ = btcScanner.Bootstrap(uint64(lastConfirmedBlock.Height) + 1)
-		})
+
+		// the header of the last processed block cannot be fetched
+		mockBtcClient.EXPECT().GetBlockHeaderByHeight(gomock.Eq(uint64(lastProcessedBlock.Height))).
+			Return(nil, fmt.Errorf("connection refused")).Times(1)
+		err = btcScanner.Bootstrap(uint64(lastProcessedBlock.Height) + 1)
+		require.ErrorContains(t, err, "failed to find the fork point")
+		require.Zero(t, btcScanner.LastConfirmedHeight())
+
+		// the fork point is deeper than the processed blocks
+		mockBtcClient.EXPECT().GetBlockHeaderByHeight(gomock.Eq(uint64(lastProcessedBlock.Height))).
+			Return(secondChain[1].Header, nil).Times(1)
+		err = btcScanner.Bootstrap(uint64(lastProcessedBlock.Height) + 1)
+		require.ErrorContains(t, err, "the fork point is deeper than the known confirmed blocks")
+		require.Zero(t, btcScanner.LastConfirmedHeight())
 	})
 }
 
+// mockBlockHashStore is a BlockHashStore keeping the block hashes in memory
+type mockBlockHashStore struct {
+	hashes map[uint64]chainhash.Hash
+}
+
+func newMockBlockHashStore() *mockBlockHashStore {
+	return &mockBlockHashStore{hashes: make(map[uint64]chainhash.Hash)}
+}
+
+func (s *mockBlockHashStore) addBlock(b *types.IndexedBlock) {
+	s.hashes[uint64(b.Height)] = b.BlockHash()
+}
+
+func (s *mockBlockHashStore) GetBlockHash(height uint64) (*chainhash.Hash, error) {
+	h, ok := s.hashes[height]
+	if !ok {
+		return nil, btcscanner.ErrBlockHashNotFound
+	}
+	return &h, nil
+}
+
+// drainUpdates collects the chain updates sent by the scanner until the
+// bootstrapping, whose result is sent to errChan, is finished
+func drainUpdates(t *testing.T, bs *btcscanner.BtcPoller, errChan chan error) []*btcscanner.ChainUpdateInfo {
+	updates := make([]*btcscanner.ChainUpdateInfo, 0)
+	for {
+		select {
+		case u := <-bs.ChainUpdateInfoChan():
+			updates = append(updates, u)
+		case err := <-errChan:
+			require.NoError(t, err)
+			return updates
+		}
+	}
+}
+
 func indexedBlocksToBlockEpochs(ibs []*types.IndexedBlock) []*chainntnfs.BlockEpoch {
 	blockEpochs := make([]*chainntnfs.BlockEpoch, 0)
 	for _, ib := range ibs {
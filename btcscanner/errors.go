@@ -7,4 +7,6 @@ var (
 	ErrInvalidMaxEntries = errors.New("invalid max entries")
 	ErrTooManyEntries    = errors.New("the number of blocks is more than maxEntries")
 	ErrUnsortedBlocks    = errors.New("blocks are not sorted by height")
+	ErrMajorReorg        = errors.New("major reorg happened")
+	ErrBlockHashNotFound = errors.New("the block hash is not found")
 )
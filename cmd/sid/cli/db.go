@@ -0,0 +1,164 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+
+	"github.com/lightningnetwork/lnd/kvdb"
+	"github.com/urfave/cli"
+
+	"github.com/babylonlabs-io/staking-indexer/config"
+	"github.com/babylonlabs-io/staking-indexer/indexerstore"
+	"github.com/babylonlabs-io/staking-indexer/utils"
+)
+
+var DbCommand = cli.Command{
+	Name:  "db",
+	Usage: "Manage the indexer database.",
+	Subcommands: []cli.Command{
+		dbMigrateCommand,
+		dbVerifyCommand,
+	},
+}
+
+var dbMigrateCommand = cli.Command{
+	Name:  "migrate",
+	Usage: "Migrate the indexer database to the latest schema version.",
+	Description: "Apply the pending migrations of the indexer database in a single transaction. " +
+		"The indexer also applies them when it starts, so this is to migrate the database ahead of time " +
+		"or to inspect the pending migrations. Only the bolt backend is supported, " +
+		"the Postgres tables are migrated when the indexer starts.",
+	UsageText: fmt.Sprintf("db migrate [--%s]", dryRunFlag),
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  homeFlag,
+			Usage: "The path to the staking indexer home directory",
+			Value: config.DefaultHomeDir,
+		},
+		cli.BoolFlag{
+			Name:  dryRunFlag,
+			Usage: "Print the pending migrations without applying them",
+		},
+	},
+	Action: migrateDb,
+}
+
+var dbVerifyCommand = cli.Command{
+	Name:  "verify",
+	Usage: "Check the integrity of the indexer database.",
+	Description: "Recompute the confirmed TVL from the stored transactions and compare it with the stored one, " +
+		"check that the transactions referenced by the unbonding, slashing, and withdrawal transactions are stored, " +
+		"and that no transaction is included above the last processed height. The database is not changed, " +
+		"so the indexer should be stopped. The report is printed as JSON, and the command fails if any issue is found. " +
+		"Only the bolt backend is supported.",
+	UsageText: "db verify",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  homeFlag,
+			Usage: "The path to the staking indexer home directory",
+			Value: config.DefaultHomeDir,
+		},
+	},
+	Action: verifyDb,
+}
+
+func migrateDb(ctx *cli.Context) error {
+	dbBackend, err := openDbBackend(ctx)
+	if err != nil {
+		return err
+	}
+	defer dbBackend.Close()
+
+	version, err := indexerstore.GetSchemaVersion(dbBackend)
+	if err != nil {
+		return fmt.Errorf("failed to get the schema version: %w", err)
+	}
+	fmt.Printf("Schema version %d, the latest one is %d\n", version, indexerstore.LatestSchemaVersion())
+
+	if ctx.Bool(dryRunFlag) {
+		pending, err := indexerstore.PendingMigrations(dbBackend)
+		if err != nil {
+			return err
+		}
+		for _, m := range pending {
+			fmt.Printf("Pending migration to version %d: %s\n", m.Version, m.Description)
+		}
+		fmt.Printf("Found %d pending migrations\n", len(pending))
+		return nil
+	}
+
+	applied, err := indexerstore.Migrate(dbBackend)
+	if err != nil {
+		return fmt.Errorf("failed to migrate the database: %w", err)
+	}
+	for _, m := range applied {
+		fmt.Printf("Applied migration to version %d: %s\n", m.Version, m.Description)
+	}
+	fmt.Printf("Applied %d migrations\n", len(applied))
+
+	return nil
+}
+
+func verifyDb(ctx *cli.Context) error {
+	dbBackend, err := openDbBackend(ctx)
+	if err != nil {
+		return err
+	}
+	defer dbBackend.Close()
+
+	report, err := indexerstore.VerifyDb(dbBackend)
+	if err != nil {
+		return fmt.Errorf("failed to verify the database: %w", err)
+	}
+
+	reportBytes, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(reportBytes))
+
+	if !report.OK() {
+		return fmt.Errorf("found %d issues in the database", len(report.Issues))
+	}
+
+	return nil
+}
+
+// loadBoltDbConfig loads the database config in the home directory
+// it returns an error if the indexer state is not stored in a bolt database,
+// as the db and snapshot commands work on the bolt database directly
+func loadBoltDbConfig(ctx *cli.Context) (*config.DBConfig, error) {
+	homePath, err := filepath.Abs(ctx.String(homeFlag))
+	if err != nil {
+		return nil, err
+	}
+	homePath = utils.CleanAndExpandPath(homePath)
+
+	cfg, err := config.LoadConfig(homePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	if cfg.DatabaseConfig.IsPostgres() {
+		return nil, fmt.Errorf("the db and snapshot commands only support the %s backend, but the configured backend is %s",
+			config.DBBackendBolt, cfg.DatabaseConfig.Backend)
+	}
+
+	return cfg.DatabaseConfig, nil
+}
+
+// openDbBackend opens the bolt database configured in the home directory
+func openDbBackend(ctx *cli.Context) (kvdb.Backend, error) {
+	dbCfg, err := loadBoltDbConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	dbBackend, err := dbCfg.GetDbBackend()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create db backend: %w", err)
+	}
+
+	return dbBackend, nil
+}
@@ -76,16 +76,11 @@ func exportTransactions(c *cli.Context) error {
 	}
 
 	// Initialize IndexerStore
-	dbBackend, err := cfg.DatabaseConfig.GetDbBackend()
-	if err != nil {
-		return fmt.Errorf("failed to create db backend: %w", err)
-	}
-	defer dbBackend.Close()
-
-	indexerStore, err := indexerstore.NewIndexerStore(dbBackend)
+	indexerStore, db, err := newIndexerStore(cfg.DatabaseConfig)
 	if err != nil {
 		return fmt.Errorf("failed to initialize IndexerStore: %w", err)
 	}
+	defer db.Close()
 
 	// Open output file
 	file, err := os.Create(outputPath)
@@ -107,11 +102,11 @@ func exportTransactions(c *cli.Context) error {
 
 	fmt.Printf("Exporting transactions from height %d to %d\n", startHeight, endHeight)
 
-	// Export data using ScanStoredStakingTransactions method
-	err = indexerStore.ScanStoredStakingTransactions(func(tx *indexerstore.StoredStakingTransaction) error {
-
-		// Filter based on height parameters
-		if tx.InclusionHeight >= startHeight && tx.InclusionHeight < endHeight {
+	// the end height is exclusive, so there is nothing to export if both
+	// heights are the same
+	if startHeight < endHeight {
+		// Export data using the inclusion height index
+		err = indexerStore.ScanStakingTransactionsByHeightRange(startHeight, endHeight-1, func(tx *indexerstore.StoredStakingTransaction) error {
 			fmt.Printf("Exporting transaction %s, InclusionHeight %d\n", tx.Tx.TxHash().String(), tx.InclusionHeight)
 			record := []string{
 				tx.Tx.TxHash().String(),
@@ -124,10 +119,8 @@ func exportTransactions(c *cli.Context) error {
 				fmt.Sprintf("%d", tx.StakingValue),
 			}
 			return writer.Write(record)
-		}
-		return nil
-
-	})
+		})
+	}
 
 	if err != nil {
 		return fmt.Errorf("failed to export transactions: %w", err)
@@ -0,0 +1,149 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strconv"
+
+	"github.com/babylonlabs-io/staking-queue-client/client"
+	"github.com/urfave/cli"
+
+	"github.com/babylonlabs-io/staking-indexer/btcclient"
+	"github.com/babylonlabs-io/staking-indexer/config"
+	"github.com/babylonlabs-io/staking-indexer/consumer"
+	"github.com/babylonlabs-io/staking-indexer/indexer"
+	"github.com/babylonlabs-io/staking-indexer/log"
+	"github.com/babylonlabs-io/staking-indexer/utils"
+)
+
+const (
+	dryRunFlag    = "dry-run"
+	eventTypeFlag = "event-type"
+)
+
+var ReplayEventsCommand = cli.Command{
+	Name:  "replay-events",
+	Usage: "Re-publish the events of the transactions stored for a range of BTC heights.",
+	Description: "Rebuild the staking, unbonding, and withdraw events of the transactions included in " +
+		"[start-height, end-height] from the indexer store, and publish them through the configured event consumer. " +
+		"The state of the indexer is not changed.",
+	UsageText: fmt.Sprintf("replay-events [start-height] [end-height] [--%s] [--%s=active_staking]", dryRunFlag, eventTypeFlag),
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  homeFlag,
+			Usage: "The path to the staking indexer home directory",
+			Value: config.DefaultHomeDir,
+		},
+		cli.BoolFlag{
+			Name:  dryRunFlag,
+			Usage: "Print the events as JSON lines instead of publishing them",
+		},
+		cli.StringSliceFlag{
+			Name:  eventTypeFlag,
+			Usage: "The type of the events to replay, one of active_staking, unbonding, and withdraw. All of them are replayed if not set",
+		},
+	},
+	Action: replayEvents,
+}
+
+func replayEvents(ctx *cli.Context) error {
+	args := ctx.Args()
+	if len(args) != 2 {
+		return fmt.Errorf("not enough params, please specify [start-height] and [end-height]")
+	}
+
+	startHeightStr, endHeightStr := args[0], args[1]
+	startHeight, err := strconv.ParseUint(startHeightStr, 10, 64)
+	if err != nil {
+		return fmt.Errorf("unable to parse %s: %w", startHeightStr, err)
+	}
+
+	endHeight, err := strconv.ParseUint(endHeightStr, 10, 64)
+	if err != nil {
+		return fmt.Errorf("unable to parse %s: %w", endHeightStr, err)
+	}
+
+	if startHeight > endHeight {
+		return fmt.Errorf("the [start-height] %d should not be greater than the [end-height] %d", startHeight, endHeight)
+	}
+
+	eventTypes := make([]client.EventType, 0, len(ctx.StringSlice(eventTypeFlag)))
+	for _, name := range ctx.StringSlice(eventTypeFlag) {
+		eventType, err := consumer.ParseEventTypeName(name)
+		if err != nil {
+			return err
+		}
+		eventTypes = append(eventTypes, eventType)
+	}
+
+	homePath, err := filepath.Abs(ctx.String(homeFlag))
+	if err != nil {
+		return err
+	}
+	homePath = utils.CleanAndExpandPath(homePath)
+
+	cfg, err := config.LoadConfig(homePath)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	logger, err := log.NewRootLoggerWithFile(config.LogFile(homePath), cfg.LogLevel)
+	if err != nil {
+		return fmt.Errorf("failed to initialize the logger: %w", err)
+	}
+
+	btcClient, err := btcclient.NewBTCClient(cfg.BTCConfig, logger)
+	if err != nil {
+		return fmt.Errorf("failed to initialize the BTC client: %w", err)
+	}
+
+	indexerStore, db, err := newIndexerStore(cfg.DatabaseConfig)
+	if err != nil {
+		return fmt.Errorf("failed to initialize IndexerStore: %w", err)
+	}
+	defer db.Close()
+
+	replayer := indexer.NewEventReplayer(indexerStore, btcClient, logger)
+
+	if ctx.Bool(dryRunFlag) {
+		numEvents, err := replayer.ReplayEvents(startHeight, endHeight, eventTypes, func(env *consumer.EventEnvelope) error {
+			envBytes, err := json.Marshal(env)
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(envBytes))
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to replay the events: %w", err)
+		}
+
+		fmt.Printf("Found %d events from height %d to %d\n", numEvents, startHeight, endHeight)
+		return nil
+	}
+
+	eventConsumer, err := newEventConsumer(cfg, logger)
+	if err != nil {
+		return fmt.Errorf("failed to initialize event consumer: %w", err)
+	}
+	if err := eventConsumer.Start(); err != nil {
+		return fmt.Errorf("failed to start event consumer: %w", err)
+	}
+	defer func() {
+		if err := eventConsumer.Stop(); err != nil {
+			fmt.Printf("failed to stop event consumer: %v\n", err)
+		}
+	}()
+
+	numEvents, err := replayer.ReplayEvents(startHeight, endHeight, eventTypes, func(env *consumer.EventEnvelope) error {
+		return consumer.PushEventEnvelope(eventConsumer, env)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to replay the events: %w", err)
+	}
+
+	fmt.Printf("Replayed %d events from height %d to %d\n", numEvents, startHeight, endHeight)
+
+	return nil
+}
@@ -0,0 +1,197 @@
+package cli
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/urfave/cli"
+
+	"github.com/babylonlabs-io/staking-indexer/config"
+	"github.com/babylonlabs-io/staking-indexer/indexerstore"
+	"github.com/babylonlabs-io/staking-indexer/utils"
+)
+
+const (
+	heightFlag = "height"
+
+	defaultSnapshotFileName = "snapshot.sidsnap"
+)
+
+var SnapshotCommand = cli.Command{
+	Name:  "snapshot",
+	Usage: "Create and restore snapshots of the indexer database, only the bolt backend is supported.",
+	Subcommands: []cli.Command{
+		snapshotCreateCommand,
+		snapshotRestoreCommand,
+	},
+}
+
+var snapshotCreateCommand = cli.Command{
+	Name:  "create",
+	Usage: "Write a snapshot of the indexer database at its last processed height.",
+	Description: "Write a versioned and checksummed snapshot of all the data of the indexer database, " +
+		"which can be restored to bootstrap a new indexer. The snapshot is at the last processed height, " +
+		"so the indexer should be stopped at the height to snapshot. " +
+		"The snapshot is refused while events are waiting in the outbox, as the restored indexer would publish them again, " +
+		"so the indexer should be stopped once all the events are delivered.",
+	UsageText: fmt.Sprintf("snapshot create [--%s=path/to/%s] [--%s=height]", outputFileFlag, defaultSnapshotFileName, heightFlag),
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  homeFlag,
+			Usage: "The path to the staking indexer home directory",
+			Value: config.DefaultHomeDir,
+		},
+		cli.StringFlag{
+			Name:  paramsPathFlag,
+			Usage: "The path to the global params file",
+			Value: config.DefaultParamsPath,
+		},
+		cli.StringFlag{
+			Name:  outputFileFlag,
+			Usage: "Path to the snapshot file",
+			Value: filepath.Join(config.DefaultHomeDir, defaultSnapshotFileName),
+		},
+		cli.Uint64Flag{
+			Name:  heightFlag,
+			Usage: "The expected last processed height, the snapshot is not created if the database is at another height",
+		},
+	},
+	Action: createSnapshot,
+}
+
+var snapshotRestoreCommand = cli.Command{
+	Name:  "restore",
+	Usage: "Install a snapshot into an empty indexer data directory.",
+	Description: "Validate the checksum of the snapshot and that it is created with the same global params file, " +
+		"and install it as the indexer database. The database must not exist yet. " +
+		"The indexer then starts from the height following the last processed height of the snapshot.",
+	UsageText: "snapshot restore [snapshot-file]",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  homeFlag,
+			Usage: "The path to the staking indexer home directory",
+			Value: config.DefaultHomeDir,
+		},
+		cli.StringFlag{
+			Name:  paramsPathFlag,
+			Usage: "The path to the global params file",
+			Value: config.DefaultParamsPath,
+		},
+	},
+	Action: restoreSnapshot,
+}
+
+func createSnapshot(ctx *cli.Context) error {
+	dbBackend, err := openDbBackend(ctx)
+	if err != nil {
+		return err
+	}
+	defer dbBackend.Close()
+
+	paramsHash, err := fileHash(ctx.String(paramsPathFlag))
+	if err != nil {
+		return fmt.Errorf("failed to hash the params file: %w", err)
+	}
+
+	outputPath := utils.CleanAndExpandPath(ctx.String(outputFileFlag))
+
+	// the snapshot is written to a temporary file first, so that a failure
+	// does not leave a partial snapshot
+	tmpPath := outputPath + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("failed to create the snapshot file: %w", err)
+	}
+	defer os.Remove(tmpPath)
+	defer file.Close()
+
+	header, err := indexerstore.CreateSnapshot(dbBackend, file, paramsHash)
+	if err != nil {
+		return fmt.Errorf("failed to create the snapshot: %w", err)
+	}
+	if ctx.IsSet(heightFlag) && header.LastProcessedHeight != ctx.Uint64(heightFlag) {
+		return fmt.Errorf("the last processed height %d is not the expected height %d",
+			header.LastProcessedHeight, ctx.Uint64(heightFlag))
+	}
+
+	if err := file.Sync(); err != nil {
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, outputPath); err != nil {
+		return err
+	}
+
+	fmt.Printf("Created the snapshot %s at height %d with schema version %d\n",
+		outputPath, header.LastProcessedHeight, header.SchemaVersion)
+
+	return nil
+}
+
+func restoreSnapshot(ctx *cli.Context) error {
+	args := ctx.Args()
+	if len(args) != 1 {
+		return fmt.Errorf("not enough params, please specify [snapshot-file]")
+	}
+	snapshotPath := utils.CleanAndExpandPath(args[0])
+
+	dbCfg, err := loadBoltDbConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	paramsHash, err := fileHash(ctx.String(paramsPathFlag))
+	if err != nil {
+		return fmt.Errorf("failed to hash the params file: %w", err)
+	}
+
+	dbFilePath := filepath.Join(dbCfg.DBPath, dbCfg.DBFileName)
+	if _, err := os.Stat(dbFilePath); err == nil {
+		return fmt.Errorf("the database %s already exists", dbFilePath)
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	file, err := os.Open(snapshotPath)
+	if err != nil {
+		return fmt.Errorf("failed to open the snapshot file: %w", err)
+	}
+	defer file.Close()
+
+	dbBackend, err := dbCfg.GetDbBackend()
+	if err != nil {
+		return fmt.Errorf("failed to create db backend: %w", err)
+	}
+
+	header, err := indexerstore.RestoreSnapshot(dbBackend, file, paramsHash)
+	if closeErr := dbBackend.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		// the data directory is left as it was before the restore
+		if rmErr := os.Remove(dbFilePath); rmErr != nil {
+			fmt.Printf("failed to remove the database %s: %v\n", dbFilePath, rmErr)
+		}
+		return fmt.Errorf("failed to restore the snapshot: %w", err)
+	}
+
+	fmt.Printf("Restored the snapshot %s at height %d with schema version %d\n",
+		snapshotPath, header.LastProcessedHeight, header.SchemaVersion)
+
+	return nil
+}
+
+// fileHash returns the sha256 hash of the file content
+func fileHash(path string) ([]byte, error) {
+	content, err := os.ReadFile(utils.CleanAndExpandPath(path))
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(content)
+
+	return hash[:], nil
+}
@@ -1,17 +1,23 @@
 package cli
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"path/filepath"
 
-	"github.com/babylonlabs-io/staking-queue-client/queuemngr"
+	"github.com/babylonlabs-io/staking-queue-client/client"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/lightningnetwork/lnd/signal"
 	"github.com/urfave/cli"
+	"go.uber.org/zap"
 
 	"github.com/babylonlabs-io/staking-indexer/btcclient"
 	"github.com/babylonlabs-io/staking-indexer/btcscanner"
 	"github.com/babylonlabs-io/staking-indexer/config"
+	"github.com/babylonlabs-io/staking-indexer/consumer"
 	"github.com/babylonlabs-io/staking-indexer/indexer"
+	"github.com/babylonlabs-io/staking-indexer/indexerstore"
 	"github.com/babylonlabs-io/staking-indexer/log"
 	"github.com/babylonlabs-io/staking-indexer/params"
 	service "github.com/babylonlabs-io/staking-indexer/server"
@@ -82,40 +88,35 @@ func start(ctx *cli.Context) error {
 		return fmt.Errorf("failed to initialize the BTC notifier: %w", err)
 	}
 
-	dbBackend, err := cfg.DatabaseConfig.GetDbBackend()
-	if err != nil {
-		return fmt.Errorf("failed to create db backend: %w", err)
-	}
-
 	paramsRetriever, err := params.NewGlobalParamsRetriever(ctx.String(paramsPathFlag))
 	if err != nil {
 		return fmt.Errorf("failed to initialize params retriever: %w", err)
 	}
 	versionedParams := paramsRetriever.VersionedParams()
 
-	// create BTC scanner
+	// create the store of the indexer state
+	is, db, err := newIndexerStore(cfg.DatabaseConfig)
+	if err != nil {
+		return fmt.Errorf("failed to initialize the indexer store: %w", err)
+	}
+
+	// create BTC scanner, which finds the fork point of major reorgs from
+	// the hashes of the processed blocks in the store
 	// we don't expect the confirmation depth to change across different versions
 	// so we can always use the first one
-	scanner, err := btcscanner.NewBTCScanner(versionedParams.Versions[0].ConfirmationDepth, logger, btcClient, btcNotifier)
+	scanner, err := btcscanner.NewBTCScanner(versionedParams.Versions[0].ConfirmationDepth, logger, btcClient, btcNotifier, &blockHashStore{is: is})
 	if err != nil {
 		return fmt.Errorf("failed to initialize the BTC scanner: %w", err)
 	}
 
 	// create event consumer
-	validQueueCfg, err := cfg.QueueConfig.ToQueueClientConfig()
-	if err != nil {
-		return fmt.Errorf("invalid queue config: %w", err)
-	}
-	queueConsumer, err := queuemngr.NewQueueManager(validQueueCfg, logger)
+	eventConsumer, err := newEventConsumer(cfg, logger)
 	if err != nil {
 		return fmt.Errorf("failed to initialize event consumer: %w", err)
 	}
 
 	// create the staking indexer app
-	si, err := indexer.NewStakingIndexer(cfg, logger, queueConsumer, dbBackend, versionedParams, scanner)
-	if err != nil {
-		return fmt.Errorf("failed to initialize the staking indexer app: %w", err)
-	}
+	si := indexer.NewStakingIndexerWithStore(cfg, logger, eventConsumer, is, versionedParams, scanner)
 
 	// get start height
 	var startHeight uint64
@@ -132,8 +133,113 @@ func start(ctx *cli.Context) error {
 	}
 
 	// create the server
-	indexerServer := service.NewStakingIndexerServer(cfg, queueConsumer, dbBackend, btcNotifier, si, logger, shutdownInterceptor)
+	indexerServer := service.NewStakingIndexerServer(cfg, eventConsumer, db, btcNotifier, si, logger, shutdownInterceptor)
 
 	// run all the services until shutdown
 	return indexerServer.RunUntilShutdown(startHeight)
 }
+
+// newIndexerStore opens the database of the backend selected in the config,
+// and returns the store on top of it together with the database to close
+func newIndexerStore(dbCfg *config.DBConfig) (indexerstore.Store, io.Closer, error) {
+	if dbCfg.IsPostgres() {
+		db, err := dbCfg.GetPostgresDb()
+		if err != nil {
+			return nil, nil, err
+		}
+		ps, err := indexerstore.NewPostgresStore(db)
+		if err != nil {
+			db.Close()
+			return nil, nil, err
+		}
+		return ps, db, nil
+	}
+
+	dbBackend, err := dbCfg.GetDbBackend()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create db backend: %w", err)
+	}
+	is, err := indexerstore.NewIndexerStore(dbBackend)
+	if err != nil {
+		dbBackend.Close()
+		return nil, nil, err
+	}
+
+	return is, dbBackend, nil
+}
+
+// blockHashStore gives the BTC scanner the hashes of the processed blocks in
+// the indexer store
+type blockHashStore struct {
+	is indexerstore.Store
+}
+
+func (s *blockHashStore) GetBlockHash(height uint64) (*chainhash.Hash, error) {
+	blockHash, err := s.is.GetBlockHash(height)
+	if errors.Is(err, indexerstore.ErrBlockJournalNotFound) {
+		return nil, btcscanner.ErrBlockHashNotFound
+	}
+
+	return blockHash, err
+}
+
+// newEventConsumer creates the event consumer of the messaging system
+// selected in the config
+func newEventConsumer(cfg *config.Config, logger *zap.Logger) (consumer.EventConsumer, error) {
+	if cfg.EventConsumer != config.EventConsumerFanout {
+		return newSinkConsumer(cfg, cfg.EventConsumer, logger)
+	}
+
+	sinkCfgs, err := cfg.FanoutConfig.ParseSinks()
+	if err != nil {
+		return nil, err
+	}
+
+	sinks := make([]*consumer.Sink, 0, len(sinkCfgs))
+	for _, sinkCfg := range sinkCfgs {
+		eventTypes := make([]client.EventType, 0, len(sinkCfg.EventTypes))
+		for _, name := range sinkCfg.EventTypes {
+			eventType, err := consumer.ParseEventTypeName(name)
+			if err != nil {
+				return nil, fmt.Errorf("invalid filter of sink %s: %w", sinkCfg.Type, err)
+			}
+			eventTypes = append(eventTypes, eventType)
+		}
+
+		sinkConsumer, err := newSinkConsumer(cfg, sinkCfg.Type, logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize sink %s: %w", sinkCfg.Type, err)
+		}
+
+		sinks = append(sinks, &consumer.Sink{
+			Name:          sinkCfg.Type,
+			Consumer:      sinkConsumer,
+			EventTypes:    eventTypes,
+			FailurePolicy: sinkCfg.FailurePolicy,
+		})
+	}
+
+	return consumer.NewFanoutConsumer(sinks, cfg.FanoutConfig, logger)
+}
+
+// newSinkConsumer creates the event consumer of the given type
+func newSinkConsumer(cfg *config.Config, sinkType string, logger *zap.Logger) (consumer.EventConsumer, error) {
+	switch sinkType {
+	case config.EventConsumerKafka:
+		return consumer.NewKafkaConsumer(cfg.KafkaConfig, logger)
+	case config.EventConsumerWebhook:
+		return consumer.NewWebhookConsumer(cfg.WebhookConfig, logger)
+	case config.EventConsumerFile:
+		return consumer.NewFileConsumer(cfg.FileSinkConfig, logger)
+	case config.SinkMetrics:
+		return consumer.NewMetricsConsumer(), nil
+	case config.EventConsumerRabbitMQ:
+		validQueueCfg, err := cfg.QueueConfig.ToQueueClientConfig()
+		if err != nil {
+			return nil, fmt.Errorf("invalid queue config: %w", err)
+		}
+		return consumer.NewQueueConsumer(validQueueCfg, cfg.QueueConfig.BareEvents, logger)
+	default:
+		return nil, fmt.Errorf("unknown event consumer %s", sinkType)
+	}
+}
@@ -17,7 +17,7 @@ func main() {
 	app := cli.NewApp()
 	app.Name = "sid"
 	app.Usage = "Staking Indexer Daemon (sid)."
-	app.Commands = append(app.Commands, sidcli.StartCommand, sidcli.InitCommand, sidcli.BtcHeaderCommand, sidcli.ExportCommand)
+	app.Commands = append(app.Commands, sidcli.StartCommand, sidcli.InitCommand, sidcli.BtcHeaderCommand, sidcli.ExportCommand, sidcli.ReplayEventsCommand, sidcli.DbCommand, sidcli.SnapshotCommand)
 
 	if err := app.Run(os.Args); err != nil {
 		fatal(err)
@@ -0,0 +1,50 @@
+package config
+
+import (
+	"fmt"
+	"net"
+)
+
+const (
+	defaultAPIPort = 2136
+	defaultAPIHost = "127.0.0.1"
+)
+
+// APIConfig defines the configuration of the HTTP server answering the
+// read-only queries of the indexed data
+type APIConfig struct {
+	Enabled bool   `long:"enabled" description:"Whether the HTTP query API is enabled"`
+	Host    string `long:"host" description:"IP of the HTTP query API server"`
+	Port    int    `long:"port" description:"Port of the HTTP query API server"`
+}
+
+func (cfg *APIConfig) Validate() error {
+	if !cfg.Enabled {
+		return nil
+	}
+
+	if cfg.Port < 0 || cfg.Port > 65535 {
+		return fmt.Errorf("invalid API port: %d", cfg.Port)
+	}
+
+	ip := net.ParseIP(cfg.Host)
+	if ip == nil {
+		return fmt.Errorf("invalid API host: %v", cfg.Host)
+	}
+
+	return nil
+}
+
+func (cfg *APIConfig) Address() (string, error) {
+	if err := cfg.Validate(); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s:%d", cfg.Host, cfg.Port), nil
+}
+
+func DefaultAPIConfig() *APIConfig {
+	return &APIConfig{
+		Port: defaultAPIPort,
+		Host: defaultAPIHost,
+	}
+}
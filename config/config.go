@@ -19,6 +19,18 @@ const (
 	defaultParamsFileName = "global-params.json"
 	defaultBitcoinNetwork = "signet"
 	defaultDataDirname    = "data"
+	defaultEventConsumer  = EventConsumerRabbitMQ
+
+	// EventConsumerRabbitMQ publishes the events to RabbitMQ queues
+	EventConsumerRabbitMQ = "rabbitmq"
+	// EventConsumerKafka publishes the events to Kafka topics
+	EventConsumerKafka = "kafka"
+	// EventConsumerWebhook posts the events to HTTP endpoints
+	EventConsumerWebhook = "webhook"
+	// EventConsumerFile appends the events to JSON lines files
+	EventConsumerFile = "file"
+	// EventConsumerFanout sends the events to several sinks
+	EventConsumerFanout = "fanout"
 )
 
 var (
@@ -31,13 +43,22 @@ var (
 
 // Config is the main config for the fpd cli command
 type Config struct {
-	LogLevel          string         `long:"loglevel" description:"Logging level for all subsystems" choice:"trace" choice:"debug" choice:"info" choice:"warn" choice:"error" choice:"fatal"`
-	BitcoinNetwork    string         `long:"bitcoinnetwork" description:"Bitcoin network to run on" choice:"mainnet" choice:"regtest" choice:"testnet" choice:"simnet" choice:"signet"`
-	ExtraEventEnabled bool           `long:"extraeventenabled" description:"Whether emitting non-default events is allowed"`
-	BTCConfig         *BTCConfig     `group:"btcconfig" namespace:"btcconfig"`
-	DatabaseConfig    *DBConfig      `group:"dbconfig" namespace:"dbconfig"`
-	QueueConfig       *QueueConfig   `group:"queueconfig" namespace:"queueconfig"`
-	MetricsConfig     *MetricsConfig `group:"metricsconfig" namespace:"metricsconfig"`
+	LogLevel                     string          `long:"loglevel" description:"Logging level for all subsystems" choice:"trace" choice:"debug" choice:"info" choice:"warn" choice:"error" choice:"fatal"`
+	BitcoinNetwork               string          `long:"bitcoinnetwork" description:"Bitcoin network to run on" choice:"mainnet" choice:"regtest" choice:"testnet" choice:"simnet" choice:"signet"`
+	ExtraEventEnabled            bool            `long:"extraeventenabled" description:"Whether emitting non-default events is allowed"`
+	FinalityProviderEventEnabled bool            `long:"finalityprovidereventenabled" description:"Whether a finality provider info event is emitted for every finality provider whose delegation totals change in a confirmed block"`
+	EventConsumer                string          `long:"eventconsumer" description:"The messaging system the events are published to" choice:"rabbitmq" choice:"kafka" choice:"webhook" choice:"file" choice:"fanout"`
+	BTCConfig                    *BTCConfig      `group:"btcconfig" namespace:"btcconfig"`
+	DatabaseConfig               *DBConfig       `group:"dbconfig" namespace:"dbconfig"`
+	QueueConfig                  *QueueConfig    `group:"queueconfig" namespace:"queueconfig"`
+	KafkaConfig                  *KafkaConfig    `group:"kafkaconfig" namespace:"kafkaconfig"`
+	WebhookConfig                *WebhookConfig  `group:"webhookconfig" namespace:"webhookconfig"`
+	FileSinkConfig               *FileSinkConfig `group:"filesinkconfig" namespace:"filesinkconfig"`
+	FanoutConfig                 *FanoutConfig   `group:"fanoutconfig" namespace:"fanoutconfig"`
+	OutboxConfig                 *OutboxConfig   `group:"outboxconfig" namespace:"outboxconfig"`
+	MetricsConfig                *MetricsConfig  `group:"metricsconfig" namespace:"metricsconfig"`
+	GRPCConfig                   *GRPCConfig     `group:"grpcconfig" namespace:"grpcconfig"`
+	APIConfig                    *APIConfig      `group:"apiconfig" namespace:"apiconfig"`
 
 	BTCNetParams chaincfg.Params
 }
@@ -46,10 +67,18 @@ func DefaultConfigWithHome(homePath string) *Config {
 	cfg := &Config{
 		LogLevel:       defaultLogLevel,
 		BitcoinNetwork: defaultBitcoinNetwork,
+		EventConsumer:  defaultEventConsumer,
 		BTCConfig:      DefaultBTCConfig(),
 		DatabaseConfig: DefaultDBConfigWithHomePath(homePath),
 		QueueConfig:    DefaultQueueConfig(),
+		KafkaConfig:    DefaultKafkaConfig(),
+		WebhookConfig:  DefaultWebhookConfigWithHomePath(homePath),
+		FileSinkConfig: DefaultFileSinkConfigWithHomePath(homePath),
+		FanoutConfig:   DefaultFanoutConfig(),
+		OutboxConfig:   DefaultOutboxConfig(),
 		MetricsConfig:  DefaultMetricsConfig(),
+		GRPCConfig:     DefaultGRPCConfig(),
+		APIConfig:      DefaultAPIConfig(),
 	}
 
 	if err := cfg.Validate(); err != nil {
@@ -146,7 +175,33 @@ func (cfg *Config) Validate() error {
 		return err
 	}
 
-	if err := cfg.QueueConfig.Validate(); err != nil {
+	if err := cfg.GRPCConfig.Validate(); err != nil {
+		return err
+	}
+
+	if err := cfg.APIConfig.Validate(); err != nil {
+		return err
+	}
+
+	// config files created before the event consumer was selectable
+	// do not set it, and they use RabbitMQ
+	if cfg.EventConsumer == "" {
+		cfg.EventConsumer = EventConsumerRabbitMQ
+	}
+	if cfg.EventConsumer == EventConsumerFanout {
+		if err := cfg.FanoutConfig.Validate(); err != nil {
+			return err
+		}
+		sinks, err := cfg.FanoutConfig.ParseSinks()
+		if err != nil {
+			return err
+		}
+		for _, sink := range sinks {
+			if err := cfg.validateSink(sink.Type); err != nil {
+				return err
+			}
+		}
+	} else if err := cfg.validateSink(cfg.EventConsumer); err != nil {
 		return err
 	}
 
@@ -157,3 +212,21 @@ func (cfg *Config) Validate() error {
 	// All good, return the sanitized result.
 	return nil
 }
+
+// validateSink validates the config section of the given event consumer type
+func (cfg *Config) validateSink(sinkType string) error {
+	switch sinkType {
+	case EventConsumerRabbitMQ:
+		return cfg.QueueConfig.Validate()
+	case EventConsumerKafka:
+		return cfg.KafkaConfig.Validate()
+	case EventConsumerWebhook:
+		return cfg.WebhookConfig.Validate()
+	case EventConsumerFile:
+		return cfg.FileSinkConfig.Validate()
+	case SinkMetrics:
+		return nil
+	default:
+		return fmt.Errorf("invalid event consumer: %v", sinkType)
+	}
+}
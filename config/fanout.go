@@ -0,0 +1,123 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	defaultFanoutBufferSize    = 10000
+	defaultFanoutRetryInterval = 5 * time.Second
+
+	// SinkMetrics records the events in the Prometheus metrics, it can only
+	// be used as a sink of the fan-out consumer
+	SinkMetrics = "metrics"
+
+	// SinkFailurePolicyBlock returns the failure of the sink, so that the
+	// event is retried and the following events wait for it
+	SinkFailurePolicyBlock = "block"
+	// SinkFailurePolicyDrop discards the events the sink fails to take
+	SinkFailurePolicyDrop = "drop"
+	// SinkFailurePolicyBuffer keeps the events the sink fails to take and
+	// retries them in order in the background
+	SinkFailurePolicyBuffer = "buffer"
+
+	// SinkAllEvents is the event filter accepting all the events
+	SinkAllEvents = "*"
+)
+
+// FanoutConfig defines the configuration of the event consumer sending the
+// events to several sinks
+type FanoutConfig struct {
+	Sinks         []string      `long:"sink" description:"a sink in the format <type>:<event types>:<failure policy>, where type is one of rabbitmq, kafka, webhook, file, metrics and is configured in its own section, event types is * or a comma separated list of active_staking, unbonding, withdraw, btc_info, confirmed_info, rollback, invalid_staking, slashing, timelock_expired, finality_provider_info, and failure policy is one of block, drop, buffer"`
+	BufferSize    int           `long:"buffersize" description:"the maximum number of events buffered for a sink with the buffer failure policy"`
+	RetryInterval time.Duration `long:"retryinterval" description:"the interval of retrying the buffered events"`
+}
+
+// SinkConfig is a parsed sink of the fan-out consumer
+type SinkConfig struct {
+	Type string
+	// EventTypes are the names of the types of the events sent to the sink,
+	// all the events are sent if it is empty
+	EventTypes    []string
+	FailurePolicy string
+}
+
+func (cfg *FanoutConfig) Validate() error {
+	if _, err := cfg.ParseSinks(); err != nil {
+		return err
+	}
+
+	if cfg.BufferSize <= 0 {
+		return fmt.Errorf("invalid fan-out buffer size")
+	}
+
+	if cfg.RetryInterval <= 0 {
+		return fmt.Errorf("invalid fan-out retry interval")
+	}
+
+	return nil
+}
+
+// ParseSinks parses the configured sinks
+func (cfg *FanoutConfig) ParseSinks() ([]*SinkConfig, error) {
+	if len(cfg.Sinks) == 0 {
+		return nil, fmt.Errorf("missing fan-out sinks")
+	}
+
+	sinks := make([]*SinkConfig, 0, len(cfg.Sinks))
+	seen := make(map[string]bool)
+	for _, s := range cfg.Sinks {
+		parts := strings.Split(s, ":")
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("invalid fan-out sink %s: the format should be <type>:<event types>:<failure policy>", s)
+		}
+
+		sinkType := strings.TrimSpace(parts[0])
+		switch sinkType {
+		case EventConsumerRabbitMQ, EventConsumerKafka, EventConsumerWebhook, EventConsumerFile, SinkMetrics:
+		default:
+			return nil, fmt.Errorf("invalid fan-out sink %s: unknown sink type %s", s, sinkType)
+		}
+		// a sink type is configured by its own section, so it can only
+		// be used once
+		if seen[sinkType] {
+			return nil, fmt.Errorf("invalid fan-out sinks: duplicate sink type %s", sinkType)
+		}
+		seen[sinkType] = true
+
+		var eventTypes []string
+		if filter := strings.TrimSpace(parts[1]); filter != SinkAllEvents {
+			for _, eventType := range strings.Split(filter, ",") {
+				eventType = strings.TrimSpace(eventType)
+				if eventType == "" {
+					return nil, fmt.Errorf("invalid fan-out sink %s: empty event type", s)
+				}
+				eventTypes = append(eventTypes, eventType)
+			}
+		}
+
+		failurePolicy := strings.TrimSpace(parts[2])
+		switch failurePolicy {
+		case SinkFailurePolicyBlock, SinkFailurePolicyDrop, SinkFailurePolicyBuffer:
+		default:
+			return nil, fmt.Errorf("invalid fan-out sink %s: unknown failure policy %s", s, failurePolicy)
+		}
+
+		sinks = append(sinks, &SinkConfig{
+			Type:          sinkType,
+			EventTypes:    eventTypes,
+			FailurePolicy: failurePolicy,
+		})
+	}
+
+	return sinks, nil
+}
+
+func DefaultFanoutConfig() *FanoutConfig {
+	return &FanoutConfig{
+		BufferSize:    defaultFanoutBufferSize,
+		RetryInterval: defaultFanoutRetryInterval,
+	}
+}
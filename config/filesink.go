@@ -0,0 +1,40 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+const (
+	defaultFileSinkDirname        = "events"
+	defaultFileSinkMaxSegmentSize = 100 * 1024 * 1024
+)
+
+// FileSinkConfig defines the configuration of the event consumer appending
+// the events to JSON lines files
+type FileSinkConfig struct {
+	Dir               string `long:"dir" description:"the directory the event files are written to"`
+	MaxSegmentSize    int64  `long:"maxsegmentsize" description:"the size in bytes at which the current event file is closed and a new one is started, 0 means no limit"`
+	MaxSegmentHeights uint64 `long:"maxsegmentheights" description:"the number of BTC heights covered by an event file before a new one is started, 0 means no limit"`
+	Compress          bool   `long:"compress" description:"whether the closed event files are compressed with gzip"`
+}
+
+func (cfg *FileSinkConfig) Validate() error {
+	if cfg.Dir == "" {
+		return fmt.Errorf("missing file sink dir")
+	}
+
+	if cfg.MaxSegmentSize < 0 {
+		return fmt.Errorf("invalid file sink max segment size")
+	}
+
+	return nil
+}
+
+func DefaultFileSinkConfigWithHomePath(homePath string) *FileSinkConfig {
+	return &FileSinkConfig{
+		Dir:            filepath.Join(DataDir(homePath), defaultFileSinkDirname),
+		MaxSegmentSize: defaultFileSinkMaxSegmentSize,
+		Compress:       true,
+	}
+}
@@ -0,0 +1,50 @@
+package config
+
+import (
+	"fmt"
+	"net"
+)
+
+const (
+	defaultGRPCPort = 2135
+	defaultGRPCHost = "127.0.0.1"
+)
+
+// GRPCConfig defines the configuration of the gRPC server streaming the
+// events to the subscribers
+type GRPCConfig struct {
+	Enabled bool   `long:"enabled" description:"Whether the gRPC server streaming the events is enabled"`
+	Host    string `long:"host" description:"IP of the gRPC server"`
+	Port    int    `long:"port" description:"Port of the gRPC server"`
+}
+
+func (cfg *GRPCConfig) Validate() error {
+	if !cfg.Enabled {
+		return nil
+	}
+
+	if cfg.Port < 0 || cfg.Port > 65535 {
+		return fmt.Errorf("invalid gRPC port: %d", cfg.Port)
+	}
+
+	ip := net.ParseIP(cfg.Host)
+	if ip == nil {
+		return fmt.Errorf("invalid gRPC host: %v", cfg.Host)
+	}
+
+	return nil
+}
+
+func (cfg *GRPCConfig) Address() (string, error) {
+	if err := cfg.Validate(); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s:%d", cfg.Host, cfg.Port), nil
+}
+
+func DefaultGRPCConfig() *GRPCConfig {
+	return &GRPCConfig{
+		Port: defaultGRPCPort,
+		Host: defaultGRPCHost,
+	}
+}
@@ -0,0 +1,72 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	defaultKafkaBrokers        = "localhost:9092"
+	defaultKafkaClientID       = "staking-indexer"
+	defaultKafkaAcks           = KafkaAcksAll
+	defaultKafkaProduceTimeout = 10 * time.Second
+	defaultKafkaCompression    = "none"
+
+	// KafkaAcksAll waits for all the in-sync replicas to ack the records
+	KafkaAcksAll = "all"
+	// KafkaAcksLeader waits for the partition leader to ack the records
+	KafkaAcksLeader = "leader"
+	// KafkaAcksNone does not wait for any ack
+	KafkaAcksNone = "none"
+)
+
+// KafkaConfig defines the configuration of the Kafka event consumer
+type KafkaConfig struct {
+	Brokers              []string      `long:"brokers" description:"the addresses of the Kafka brokers to bootstrap from"`
+	ClientID             string        `long:"clientid" description:"the client id sent to the Kafka brokers"`
+	TopicPrefix          string        `long:"topicprefix" description:"the prefix of the topic names, each event type is published to its own topic"`
+	Acks                 string        `long:"acks" description:"the acks required for a record to be considered produced" choice:"all" choice:"leader" choice:"none"`
+	IdempotentWrite      bool          `long:"idempotentwrite" description:"whether the producer is idempotent, so that retried records are not duplicated in the topics, requires acks to be all"`
+	ProduceTimeout       time.Duration `long:"producetimeout" description:"the timeout of producing an event"`
+	Compression          string        `long:"compression" description:"the compression codec of the records" choice:"none" choice:"gzip" choice:"snappy" choice:"lz4" choice:"zstd"`
+	AllowAutoTopicCreate bool          `long:"allowautotopiccreate" description:"whether the brokers are allowed to create the topics that do not exist"`
+}
+
+func (cfg *KafkaConfig) Validate() error {
+	if len(cfg.Brokers) == 0 {
+		return fmt.Errorf("missing kafka brokers")
+	}
+
+	switch cfg.Acks {
+	case KafkaAcksAll:
+	case KafkaAcksLeader, KafkaAcksNone:
+		if cfg.IdempotentWrite {
+			return fmt.Errorf("idempotent write requires kafka acks to be %s", KafkaAcksAll)
+		}
+	default:
+		return fmt.Errorf("invalid kafka acks %s", cfg.Acks)
+	}
+
+	if cfg.ProduceTimeout <= 0 {
+		return fmt.Errorf("invalid kafka produce timeout")
+	}
+
+	switch cfg.Compression {
+	case "none", "gzip", "snappy", "lz4", "zstd":
+	default:
+		return fmt.Errorf("invalid kafka compression %s", cfg.Compression)
+	}
+
+	return nil
+}
+
+func DefaultKafkaConfig() *KafkaConfig {
+	return &KafkaConfig{
+		Brokers:         []string{defaultKafkaBrokers},
+		ClientID:        defaultKafkaClientID,
+		Acks:            defaultKafkaAcks,
+		IdempotentWrite: true,
+		ProduceTimeout:  defaultKafkaProduceTimeout,
+		Compression:     defaultKafkaCompression,
+	}
+}
@@ -0,0 +1,21 @@
+package config
+
+const (
+	defaultOutboxMaxPendingEvents = 1000000
+	// about 30 days of BTC blocks
+	defaultOutboxRetentionBlocks = 4320
+)
+
+// OutboxConfig defines the configuration of the outbox buffering the events
+// on disk until they are published to the consumer
+type OutboxConfig struct {
+	MaxPendingEvents uint64 `long:"maxpendingevents" description:"the number of events waiting in the outbox at which the indexing of new blocks pauses until the consumer catches up, 0 means no limit"`
+	RetentionBlocks  uint64 `long:"retentionblocks" description:"the number of the latest processed blocks whose delivered events are kept in the outbox for the gRPC subscriptions, the older delivered events are deleted, 0 means the delivered events are never deleted"`
+}
+
+func DefaultOutboxConfig() *OutboxConfig {
+	return &OutboxConfig{
+		MaxPendingEvents: defaultOutboxMaxPendingEvents,
+		RetentionBlocks:  defaultOutboxRetentionBlocks,
+	}
+}
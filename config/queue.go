@@ -25,6 +25,7 @@ type QueueConfig struct {
 	MsgMaxRetryAttempts int32         `long:"msgmaxretryattempts" description:"the maximum number of times a message will be retried"`
 	ReQueueDelayTime    time.Duration `long:"requeuedelaytime" description:"the time a message will be hold in delay queue before sent to main queue again"`
 	QueueType           string        `long:"queuetype" description:"the rabbitmq queue type, either classic or quorum"`
+	BareEvents          bool          `long:"bareevents" description:"whether the events are published without the envelopes, as defined by the staking queue client, for the consumers not supporting the envelopes yet"`
 }
 
 func (cfg *QueueConfig) Validate() error {
@@ -0,0 +1,76 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"time"
+)
+
+const (
+	defaultWebhookTimeout        = 10 * time.Second
+	defaultWebhookMaxRetries     = 5
+	defaultWebhookInitialBackoff = time.Second
+	defaultWebhookMaxBackoff     = time.Minute
+	defaultWebhookDeadLetterName = "webhook-dead-letters.jsonl"
+)
+
+// WebhookConfig defines the configuration of the HTTP webhook event consumer
+type WebhookConfig struct {
+	Endpoints      []string      `long:"endpoints" description:"the urls the events are posted to"`
+	Secret         string        `long:"secret" description:"the secret the payloads are signed with using HMAC-SHA256"`
+	Timeout        time.Duration `long:"timeout" description:"the timeout of a single request"`
+	MaxRetries     int           `long:"maxretries" description:"the maximum number of times a failed request is retried"`
+	InitialBackoff time.Duration `long:"initialbackoff" description:"the delay before the first retry, doubled after each retry"`
+	MaxBackoff     time.Duration `long:"maxbackoff" description:"the maximum delay between retries"`
+	DeadLetterFile string        `long:"deadletterfile" description:"the file the undeliverable events are appended to"`
+}
+
+func (cfg *WebhookConfig) Validate() error {
+	if len(cfg.Endpoints) == 0 {
+		return fmt.Errorf("missing webhook endpoints")
+	}
+
+	for _, endpoint := range cfg.Endpoints {
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			return fmt.Errorf("invalid webhook endpoint %s: %w", endpoint, err)
+		}
+		if u.Scheme != "https" && u.Scheme != "http" {
+			return fmt.Errorf("invalid webhook endpoint %s: the scheme should be https or http", endpoint)
+		}
+	}
+
+	if cfg.Secret == "" {
+		return fmt.Errorf("missing webhook secret")
+	}
+
+	if cfg.Timeout <= 0 {
+		return fmt.Errorf("invalid webhook timeout")
+	}
+
+	if cfg.MaxRetries < 0 {
+		return fmt.Errorf("invalid webhook max retries")
+	}
+
+	if cfg.InitialBackoff <= 0 || cfg.MaxBackoff < cfg.InitialBackoff {
+		return fmt.Errorf("invalid webhook backoff, the initial backoff should be positive " +
+			"and not greater than the max backoff")
+	}
+
+	if cfg.DeadLetterFile == "" {
+		return fmt.Errorf("missing webhook dead letter file")
+	}
+
+	return nil
+}
+
+func DefaultWebhookConfigWithHomePath(homePath string) *WebhookConfig {
+	return &WebhookConfig{
+		Timeout:        defaultWebhookTimeout,
+		MaxRetries:     defaultWebhookMaxRetries,
+		InitialBackoff: defaultWebhookInitialBackoff,
+		MaxBackoff:     defaultWebhookMaxBackoff,
+		DeadLetterFile: filepath.Join(DataDir(homePath), defaultWebhookDeadLetterName),
+	}
+}
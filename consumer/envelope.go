@@ -0,0 +1,218 @@
+package consumer
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/babylonlabs-io/staking-queue-client/client"
+
+	"github.com/babylonlabs-io/staking-indexer/proto"
+)
+
+// EventSchemaVersion is the version of the schemas of the envelope and the
+// events. It is bumped on every incompatible change of them
+const EventSchemaVersion uint32 = 1
+
+// EventEnvelope wraps an event with the info needed by the consumers to order
+// and deduplicate the events and to detect reorgs. It is the JSON form of
+// proto.EventEnvelope
+type EventEnvelope struct {
+	SchemaVersion uint32 `json:"schema_version"`
+	// Sequence is the position of the event among all the events of the
+	// indexer, it increases monotonically. It is 0 for the unsequenced
+	// events
+	Sequence uint64 `json:"sequence"`
+	// Unsequenced is true for the events not written to the outbox, i.e.,
+	// the btc info events, which describe the unconfirmed tip, and the
+	// replayed events. They are not ordered with the sequenced events
+	Unsequenced bool             `json:"unsequenced"`
+	EventType   client.EventType `json:"event_type"`
+	BlockHeight uint64           `json:"block_height"`
+	// BlockHash is the hex encoded hash of the block whose processing caused
+	// the event
+	BlockHash string `json:"block_hash"`
+	// IdempotencyKey is derived from the event and the block, so it is the
+	// same if the event is pushed again
+	IdempotencyKey string          `json:"idempotency_key"`
+	Event          json.RawMessage `json:"event"`
+}
+
+func NewEventEnvelope(
+	sequence uint64,
+	eventType client.EventType,
+	blockHeight uint64,
+	blockHash string,
+	event []byte,
+) *EventEnvelope {
+	return &EventEnvelope{
+		SchemaVersion:  EventSchemaVersion,
+		Sequence:       sequence,
+		EventType:      eventType,
+		BlockHeight:    blockHeight,
+		BlockHash:      blockHash,
+		IdempotencyKey: EventIdempotencyKey(eventType, blockHash, event),
+		Event:          event,
+	}
+}
+
+// WrapEvent marshals the event and wraps it in an envelope
+func WrapEvent(sequence uint64, blockHeight uint64, blockHash string, ev client.EventMessage) (*EventEnvelope, error) {
+	event, err := json.Marshal(ev)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal the event: %w", err)
+	}
+
+	return NewEventEnvelope(sequence, ev.GetEventType(), blockHeight, blockHash, event), nil
+}
+
+// WrapUnsequencedEvent marshals the event not written to the outbox and wraps
+// it in an unsequenced envelope
+func WrapUnsequencedEvent(blockHeight uint64, blockHash string, ev client.EventMessage) (*EventEnvelope, error) {
+	env, err := WrapEvent(0, blockHeight, blockHash, ev)
+	if err != nil {
+		return nil, err
+	}
+	env.Unsequenced = true
+
+	return env, nil
+}
+
+// EventIdempotencyKey returns the hex encoded sha256 hash of the event type,
+// the block hash, and the JSON encoded event. The sequence is not part of it
+// as an event written again after a crash gets a new sequence
+func EventIdempotencyKey(eventType client.EventType, blockHash string, event []byte) string {
+	h := sha256.New()
+
+	var eventTypeBytes [4]byte
+	binary.BigEndian.PutUint32(eventTypeBytes[:], uint32(eventType))
+	h.Write(eventTypeBytes[:])
+	h.Write([]byte(blockHash))
+	h.Write(event)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// ToProto returns the protobuf form of the envelope
+func (e *EventEnvelope) ToProto() *proto.EventEnvelope {
+	return &proto.EventEnvelope{
+		SchemaVersion:  e.SchemaVersion,
+		Sequence:       e.Sequence,
+		Unsequenced:    e.Unsequenced,
+		EventType:      uint32(e.EventType),
+		BlockHeight:    e.BlockHeight,
+		BlockHash:      e.BlockHash,
+		IdempotencyKey: e.IdempotencyKey,
+		Event:          e.Event,
+	}
+}
+
+func EventEnvelopeFromProto(p *proto.EventEnvelope) *EventEnvelope {
+	return &EventEnvelope{
+		SchemaVersion:  p.SchemaVersion,
+		Sequence:       p.Sequence,
+		Unsequenced:    p.Unsequenced,
+		EventType:      client.EventType(p.EventType),
+		BlockHeight:    p.BlockHeight,
+		BlockHash:      p.BlockHash,
+		IdempotencyKey: p.IdempotencyKey,
+		Event:          p.Event,
+	}
+}
+
+// GetEventType returns the type of the wrapped event
+func (e *EventEnvelope) GetEventType() client.EventType {
+	return e.EventType
+}
+
+// GetStakingTxHashHex returns the staking tx hash of the wrapped event, or
+// an empty string if the event does not have one
+func (e *EventEnvelope) GetStakingTxHashHex() string {
+	ev, err := e.DecodeEvent()
+	if err != nil {
+		return ""
+	}
+
+	return ev.GetStakingTxHashHex()
+}
+
+// DecodeEvent returns the wrapped event
+func (e *EventEnvelope) DecodeEvent() (client.EventMessage, error) {
+	var ev client.EventMessage
+	switch e.EventType {
+	case client.ActiveStakingEventType:
+		ev = &client.ActiveStakingEvent{}
+	case client.UnbondingStakingEventType:
+		ev = &client.UnbondingStakingEvent{}
+	case client.WithdrawStakingEventType:
+		ev = &client.WithdrawStakingEvent{}
+	case client.BtcInfoEventType:
+		ev = &BtcInfoEvent{}
+	case client.ConfirmedInfoEventType:
+		ev = &client.ConfirmedInfoEvent{}
+	case RollbackEventType:
+		ev = &RollbackEvent{}
+	case InvalidStakingEventType:
+		ev = &InvalidStakingEvent{}
+	case SlashingEventType:
+		ev = &SlashingEvent{}
+	case TimelockExpiredEventType:
+		ev = &TimelockExpiredEvent{}
+	case FinalityProviderInfoEventType:
+		ev = &FinalityProviderInfoEvent{}
+	default:
+		return nil, fmt.Errorf("unknown event type %d", e.EventType)
+	}
+
+	if err := json.Unmarshal(e.Event, ev); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal the %s event: %w", EventTypeName(e.EventType), err)
+	}
+
+	return ev, nil
+}
+
+// PushEventEnvelope pushes the envelope to the consumer. The envelope is
+// pushed as it is if the consumer is an EnvelopeConsumer, otherwise the
+// wrapped event is pushed through the method of its type
+func PushEventEnvelope(ec EventConsumer, env *EventEnvelope) error {
+	if envelopeConsumer, ok := ec.(EnvelopeConsumer); ok {
+		return envelopeConsumer.PushEventEnvelope(env)
+	}
+
+	ev, err := env.DecodeEvent()
+	if err != nil {
+		return err
+	}
+
+	return PushEvent(ec, ev)
+}
+
+// PushEvent pushes the event to the consumer through the method of its type
+func PushEvent(ec EventConsumer, ev client.EventMessage) error {
+	switch ev := ev.(type) {
+	case *client.ActiveStakingEvent:
+		return ec.PushStakingEvent(ev)
+	case *client.UnbondingStakingEvent:
+		return ec.PushUnbondingEvent(ev)
+	case *client.WithdrawStakingEvent:
+		return ec.PushWithdrawEvent(ev)
+	case *BtcInfoEvent:
+		return ec.PushBtcInfoEvent(ev)
+	case *client.ConfirmedInfoEvent:
+		return ec.PushConfirmedInfoEvent(ev)
+	case *RollbackEvent:
+		return ec.PushRollbackEvent(ev)
+	case *InvalidStakingEvent:
+		return ec.PushInvalidStakingEvent(ev)
+	case *SlashingEvent:
+		return ec.PushSlashingEvent(ev)
+	case *TimelockExpiredEvent:
+		return ec.PushTimelockExpiredEvent(ev)
+	case *FinalityProviderInfoEvent:
+		return ec.PushFinalityProviderInfoEvent(ev)
+	default:
+		return fmt.Errorf("unknown event type %d", ev.GetEventType())
+	}
+}
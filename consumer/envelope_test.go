@@ -0,0 +1,84 @@
+package consumer_test
+
+import (
+	"encoding/json"
+	"math/rand"
+	"testing"
+
+	bbndatagen "github.com/babylonlabs-io/babylon/testutil/datagen"
+	"github.com/babylonlabs-io/staking-queue-client/client"
+	"github.com/stretchr/testify/require"
+	pm "google.golang.org/protobuf/proto"
+
+	"github.com/babylonlabs-io/staking-indexer/consumer"
+	"github.com/babylonlabs-io/staking-indexer/proto"
+)
+
+// FuzzEventEnvelope tests that the envelopes survive the JSON and protobuf
+// encodings, and the wrapped events are pushed to the consumers not
+// publishing envelopes
+func FuzzEventEnvelope(f *testing.F) {
+	bbndatagen.AddRandomSeedsToFuzzer(f, 10)
+
+	f.Fuzz(func(t *testing.T, seed int64) {
+		r := rand.New(rand.NewSource(seed))
+
+		// record a random event
+		recorder := &fakeSink{}
+		height := uint64(r.Int63n(1000) + 1)
+		ev := pushRandomEvent(t, r, recorder, height)
+
+		blockHash := bbndatagen.GenRandomBtcdHash(r).String()
+		sequence := uint64(r.Int63())
+		env, err := consumer.WrapEvent(sequence, height, blockHash, ev)
+		require.NoError(t, err)
+		require.Equal(t, consumer.EventSchemaVersion, env.SchemaVersion)
+		require.Equal(t, ev.GetEventType(), env.GetEventType())
+		require.Equal(t, ev.GetStakingTxHashHex(), env.GetStakingTxHashHex())
+		require.False(t, env.Unsequenced)
+
+		// an unsequenced envelope has the same idempotency key
+		unsequencedEnv, err := consumer.WrapUnsequencedEvent(height, blockHash, ev)
+		require.NoError(t, err)
+		require.True(t, unsequencedEnv.Unsequenced)
+		require.Zero(t, unsequencedEnv.Sequence)
+		require.Equal(t, env.IdempotencyKey, unsequencedEnv.IdempotencyKey)
+		if r.Intn(2) == 0 {
+			env = unsequencedEnv
+		}
+
+		// the idempotency key does not depend on the sequence
+		otherEnv, err := consumer.WrapEvent(sequence+1, height, blockHash, ev)
+		require.NoError(t, err)
+		require.Equal(t, env.IdempotencyKey, otherEnv.IdempotencyKey)
+		otherBlockHash := bbndatagen.GenRandomBtcdHash(r).String()
+		otherEnv, err = consumer.WrapEvent(sequence, height, otherBlockHash, ev)
+		require.NoError(t, err)
+		require.NotEqual(t, env.IdempotencyKey, otherEnv.IdempotencyKey)
+
+		jsonBytes, err := json.Marshal(env)
+		require.NoError(t, err)
+		var decodedEnv consumer.EventEnvelope
+		err = json.Unmarshal(jsonBytes, &decodedEnv)
+		require.NoError(t, err)
+		require.Equal(t, env.IdempotencyKey, decodedEnv.IdempotencyKey)
+		require.JSONEq(t, string(env.Event), string(decodedEnv.Event))
+
+		protoBytes, err := pm.Marshal(env.ToProto())
+		require.NoError(t, err)
+		var envProto proto.EventEnvelope
+		err = pm.Unmarshal(protoBytes, &envProto)
+		require.NoError(t, err)
+		require.Equal(t, env, consumer.EventEnvelopeFromProto(&envProto))
+
+		decodedEv, err := env.DecodeEvent()
+		require.NoError(t, err)
+		require.Equal(t, ev, decodedEv)
+
+		// the bare event is pushed to the consumers not publishing envelopes
+		sink := &fakeSink{}
+		err = consumer.PushEventEnvelope(sink, env)
+		require.NoError(t, err)
+		require.Equal(t, []client.EventMessage{ev}, sink.pushedEvents())
+	})
+}
@@ -9,7 +9,31 @@ type EventConsumer interface {
 	PushStakingEvent(ev *client.ActiveStakingEvent) error
 	PushUnbondingEvent(ev *client.UnbondingStakingEvent) error
 	PushWithdrawEvent(ev *client.WithdrawStakingEvent) error
-	PushBtcInfoEvent(ev *client.BtcInfoEvent) error
+	PushBtcInfoEvent(ev *BtcInfoEvent) error
 	PushConfirmedInfoEvent(ev *client.ConfirmedInfoEvent) error
+	PushRollbackEvent(ev *RollbackEvent) error
+	PushInvalidStakingEvent(ev *InvalidStakingEvent) error
+	PushSlashingEvent(ev *SlashingEvent) error
+	PushTimelockExpiredEvent(ev *TimelockExpiredEvent) error
+	PushFinalityProviderInfoEvent(ev *FinalityProviderInfoEvent) error
 	Stop() error
 }
+
+// EnvelopeConsumer is implemented by the event consumers publishing the
+// events wrapped in envelopes. The indexer pushes the envelopes to them
+// instead of the bare events
+type EnvelopeConsumer interface {
+	EventConsumer
+	PushEventEnvelope(env *EventEnvelope) error
+}
+
+// AckingConsumer is implemented by the envelope consumers that may take an
+// envelope before it is delivered, e.g., by buffering it in memory. The
+// indexer only marks the events in the outbox delivered once they are acked,
+// so that the events taken but not delivered are pushed again on restart
+type AckingConsumer interface {
+	EnvelopeConsumer
+	// DeliveredSequence returns the sequence up to which all the pushed
+	// envelopes are delivered
+	DeliveredSequence() uint64
+}
@@ -0,0 +1,306 @@
+package consumer
+
+import (
+	"fmt"
+
+	"github.com/babylonlabs-io/staking-queue-client/client"
+)
+
+// The events below extend the ones defined in the staking queue client,
+// so their types continue the numbering of client.EventType
+const (
+	RollbackQueueName             string = "rollback_queue"
+	InvalidStakingQueueName       string = "invalid_staking_queue"
+	SlashingQueueName             string = "slashing_queue"
+	TimelockExpiredQueueName      string = "timelock_expired_queue"
+	FinalityProviderInfoQueueName string = "finality_provider_info_queue"
+)
+
+const (
+	RollbackEventType             client.EventType = 8
+	InvalidStakingEventType       client.EventType = 9
+	SlashingEventType             client.EventType = 10
+	TimelockExpiredEventType      client.EventType = 11
+	FinalityProviderInfoEventType client.EventType = 12
+)
+
+// eventTypeNames are the names of the event types used in the config
+var eventTypeNames = map[client.EventType]string{
+	client.ActiveStakingEventType:    "active_staking",
+	client.UnbondingStakingEventType: "unbonding",
+	client.WithdrawStakingEventType:  "withdraw",
+	client.BtcInfoEventType:          "btc_info",
+	client.ConfirmedInfoEventType:    "confirmed_info",
+	RollbackEventType:                "rollback",
+	InvalidStakingEventType:          "invalid_staking",
+	SlashingEventType:                "slashing",
+	TimelockExpiredEventType:         "timelock_expired",
+	FinalityProviderInfoEventType:    "finality_provider_info",
+}
+
+// eventQueueNames are the names of the queues of the event types
+var eventQueueNames = map[client.EventType]string{
+	client.ActiveStakingEventType:    client.ActiveStakingQueueName,
+	client.UnbondingStakingEventType: client.UnbondingStakingQueueName,
+	client.WithdrawStakingEventType:  client.WithdrawStakingQueueName,
+	client.BtcInfoEventType:          client.BtcInfoQueueName,
+	client.ConfirmedInfoEventType:    client.ConfirmedInfoQueueName,
+	RollbackEventType:                RollbackQueueName,
+	InvalidStakingEventType:          InvalidStakingQueueName,
+	SlashingEventType:                SlashingQueueName,
+	TimelockExpiredEventType:         TimelockExpiredQueueName,
+	FinalityProviderInfoEventType:    FinalityProviderInfoQueueName,
+}
+
+// EventQueueName returns the name of the queue of the event type
+func EventQueueName(eventType client.EventType) (string, error) {
+	if name, ok := eventQueueNames[eventType]; ok {
+		return name, nil
+	}
+
+	return "", fmt.Errorf("unknown event type %d", eventType)
+}
+
+// EventTypeName returns the name of the event type
+func EventTypeName(eventType client.EventType) string {
+	if name, ok := eventTypeNames[eventType]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("unknown(%d)", eventType)
+}
+
+// ParseEventTypeName returns the event type of the given name
+func ParseEventTypeName(name string) (client.EventType, error) {
+	for eventType, n := range eventTypeNames {
+		if n == name {
+			return eventType, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown event type %s", name)
+}
+
+// RollbackEvent is emitted for every stored transaction whose effect on the
+// confirmed state is undone because its block is reorged out
+type RollbackEvent struct {
+	EventType        client.EventType `json:"event_type"` // always 8. RollbackEventType
+	StakingTxHashHex string           `json:"staking_tx_hash_hex"`
+	TxHashHex        string           `json:"tx_hash_hex"`
+	TxType           string           `json:"tx_type"`
+	Height           uint64           `json:"height"`
+}
+
+func (e RollbackEvent) GetEventType() client.EventType {
+	return RollbackEventType
+}
+
+func (e RollbackEvent) GetStakingTxHashHex() string {
+	return e.StakingTxHashHex
+}
+
+func NewRollbackEvent(
+	stakingTxHashHex string,
+	txHashHex string,
+	txType string,
+	height uint64,
+) RollbackEvent {
+	return RollbackEvent{
+		EventType:        RollbackEventType,
+		StakingTxHashHex: stakingTxHashHex,
+		TxHashHex:        txHashHex,
+		TxType:           txType,
+		Height:           height,
+	}
+}
+
+// InvalidStakingEvent is emitted for a staking tx that does not follow the
+// global parameters, the reason is the code of the violated rule
+type InvalidStakingEvent struct {
+	EventType             client.EventType `json:"event_type"` // always 9. InvalidStakingEventType
+	StakingTxHashHex      string           `json:"staking_tx_hash_hex"`
+	StakerPkHex           string           `json:"staker_pk_hex"`
+	FinalityProviderPkHex string           `json:"finality_provider_pk_hex"`
+	StakingValue          uint64           `json:"staking_value"`
+	StakingStartHeight    uint64           `json:"staking_start_height"`
+	StakingStartTimestamp int64            `json:"staking_start_timestamp"`
+	StakingTimeLock       uint64           `json:"staking_timelock"`
+	StakingOutputIndex    uint64           `json:"staking_output_index"`
+	StakingTxHex          string           `json:"staking_tx_hex"`
+	Reason                string           `json:"reason"`
+}
+
+func (e InvalidStakingEvent) GetEventType() client.EventType {
+	return InvalidStakingEventType
+}
+
+func (e InvalidStakingEvent) GetStakingTxHashHex() string {
+	return e.StakingTxHashHex
+}
+
+func NewInvalidStakingEvent(
+	stakingTxHashHex string,
+	stakerPkHex string,
+	finalityProviderPkHex string,
+	stakingValue uint64,
+	stakingStartHeight uint64,
+	stakingStartTimestamp int64,
+	stakingTimeLock uint64,
+	stakingOutputIndex uint64,
+	stakingTxHex string,
+	reason string,
+) InvalidStakingEvent {
+	return InvalidStakingEvent{
+		EventType:             InvalidStakingEventType,
+		StakingTxHashHex:      stakingTxHashHex,
+		StakerPkHex:           stakerPkHex,
+		FinalityProviderPkHex: finalityProviderPkHex,
+		StakingValue:          stakingValue,
+		StakingStartHeight:    stakingStartHeight,
+		StakingStartTimestamp: stakingStartTimestamp,
+		StakingTimeLock:       stakingTimeLock,
+		StakingOutputIndex:    stakingOutputIndex,
+		StakingTxHex:          stakingTxHex,
+		Reason:                reason,
+	}
+}
+
+// SlashingEvent is emitted for a slashing tx that spends the output of a
+// staking tx, or of its unbonding tx in which case UnbondingTxHashHex is set
+type SlashingEvent struct {
+	EventType          client.EventType `json:"event_type"` // always 10. SlashingEventType
+	StakingTxHashHex   string           `json:"staking_tx_hash_hex"`
+	UnbondingTxHashHex string           `json:"unbonding_tx_hash_hex,omitempty"`
+	SlashingTxHashHex  string           `json:"slashing_tx_hash_hex"`
+	SlashingTxHex      string           `json:"slashing_tx_hex"`
+	SlashingHeight     uint64           `json:"slashing_height"`
+	SlashingTimestamp  int64            `json:"slashing_timestamp"`
+}
+
+func (e SlashingEvent) GetEventType() client.EventType {
+	return SlashingEventType
+}
+
+func (e SlashingEvent) GetStakingTxHashHex() string {
+	return e.StakingTxHashHex
+}
+
+func NewSlashingEvent(
+	stakingTxHashHex string,
+	unbondingTxHashHex string,
+	slashingTxHashHex string,
+	slashingTxHex string,
+	slashingHeight uint64,
+	slashingTimestamp int64,
+) SlashingEvent {
+	return SlashingEvent{
+		EventType:          SlashingEventType,
+		StakingTxHashHex:   stakingTxHashHex,
+		UnbondingTxHashHex: unbondingTxHashHex,
+		SlashingTxHashHex:  slashingTxHashHex,
+		SlashingTxHex:      slashingTxHex,
+		SlashingHeight:     slashingHeight,
+		SlashingTimestamp:  slashingTimestamp,
+	}
+}
+
+// TimelockExpiredEvent is emitted when the timelock of the staking output,
+// or of the unbonding output in which case UnbondingTxHashHex is set,
+// expires and the output becomes withdrawable
+type TimelockExpiredEvent struct {
+	EventType          client.EventType `json:"event_type"` // always 11. TimelockExpiredEventType
+	StakingTxHashHex   string           `json:"staking_tx_hash_hex"`
+	UnbondingTxHashHex string           `json:"unbonding_tx_hash_hex,omitempty"`
+	ExpiryHeight       uint64           `json:"expiry_height"`
+	ExpiryTimestamp    int64            `json:"expiry_timestamp"`
+}
+
+func (e TimelockExpiredEvent) GetEventType() client.EventType {
+	return TimelockExpiredEventType
+}
+
+func (e TimelockExpiredEvent) GetStakingTxHashHex() string {
+	return e.StakingTxHashHex
+}
+
+func NewTimelockExpiredEvent(
+	stakingTxHashHex string,
+	unbondingTxHashHex string,
+	expiryHeight uint64,
+	expiryTimestamp int64,
+) TimelockExpiredEvent {
+	return TimelockExpiredEvent{
+		EventType:          TimelockExpiredEventType,
+		StakingTxHashHex:   stakingTxHashHex,
+		UnbondingTxHashHex: unbondingTxHashHex,
+		ExpiryHeight:       expiryHeight,
+		ExpiryTimestamp:    expiryTimestamp,
+	}
+}
+
+// FinalityProviderInfoEvent carries the totals of the delegations to a
+// finality provider after the confirmed block at the given height. It is
+// emitted for every finality provider whose totals are changed by the block,
+// or by the rollback of the blocks above the height
+type FinalityProviderInfoEvent struct {
+	EventType             client.EventType `json:"event_type"` // always 12. FinalityProviderInfoEventType
+	FinalityProviderPkHex string           `json:"finality_provider_pk_hex"`
+	Height                uint64           `json:"height"`
+	ActiveTvl             uint64           `json:"active_tvl"`
+	OverflowTvl           uint64           `json:"overflow_tvl"`
+	ActiveDelegations     uint64           `json:"active_delegations"`
+	UnbondedDelegations   uint64           `json:"unbonded_delegations"`
+}
+
+func (e FinalityProviderInfoEvent) GetEventType() client.EventType {
+	return FinalityProviderInfoEventType
+}
+
+func (e FinalityProviderInfoEvent) GetStakingTxHashHex() string {
+	return ""
+}
+
+func NewFinalityProviderInfoEvent(
+	finalityProviderPkHex string,
+	height uint64,
+	activeTvl uint64,
+	overflowTvl uint64,
+	activeDelegations uint64,
+	unbondedDelegations uint64,
+) FinalityProviderInfoEvent {
+	return FinalityProviderInfoEvent{
+		EventType:             FinalityProviderInfoEventType,
+		FinalityProviderPkHex: finalityProviderPkHex,
+		Height:                height,
+		ActiveTvl:             activeTvl,
+		OverflowTvl:           overflowTvl,
+		ActiveDelegations:     activeDelegations,
+		UnbondedDelegations:   unbondedDelegations,
+	}
+}
+
+// BtcInfoEvent extends the btc info event of the staking queue client with
+// the numbers of the staking, unbonding, and withdrawal txs that are pending
+// in the unconfirmed blocks. It is pushed to the same queue
+type BtcInfoEvent struct {
+	client.BtcInfoEvent
+	PendingStakingTxs    uint64 `json:"pending_staking_txs"`
+	PendingUnbondingTxs  uint64 `json:"pending_unbonding_txs"`
+	PendingWithdrawalTxs uint64 `json:"pending_withdrawal_txs"`
+}
+
+func NewBtcInfoEvent(
+	height uint64,
+	confirmedTvl uint64,
+	unconfirmedTvl uint64,
+	pendingStakingTxs uint64,
+	pendingUnbondingTxs uint64,
+	pendingWithdrawalTxs uint64,
+) BtcInfoEvent {
+	return BtcInfoEvent{
+		BtcInfoEvent:         client.NewBtcInfoEvent(height, confirmedTvl, unconfirmedTvl),
+		PendingStakingTxs:    pendingStakingTxs,
+		PendingUnbondingTxs:  pendingUnbondingTxs,
+		PendingWithdrawalTxs: pendingWithdrawalTxs,
+	}
+}
@@ -0,0 +1,385 @@
+package consumer
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/babylonlabs-io/staking-queue-client/client"
+	"go.uber.org/zap"
+
+	"github.com/babylonlabs-io/staking-indexer/config"
+)
+
+var _ AckingConsumer = (*FanoutConsumer)(nil)
+
+// ErrSinkBufferFull is returned when an event cannot be buffered for a sink
+// with the buffer failure policy because the buffer is full
+var ErrSinkBufferFull = errors.New("the buffer of the sink is full")
+
+// Sink is an event consumer the fan-out consumer sends events to
+type Sink struct {
+	Name     string
+	Consumer EventConsumer
+	// EventTypes are the types of the events sent to the sink, all the
+	// events are sent if it is empty
+	EventTypes []client.EventType
+	// FailurePolicy is how the failures of the sink are handled, one of
+	// the sink failure policies in config
+	FailurePolicy string
+}
+
+// pushFunc pushes an event to the given consumer
+type pushFunc func(ec EventConsumer) error
+
+type bufferedEvent struct {
+	ev client.EventMessage
+	// seq is the sequence of the buffered envelope, it is 0 for the bare
+	// events and the unsequenced envelopes
+	seq  uint64
+	push pushFunc
+}
+
+type fanoutSink struct {
+	*Sink
+	accepted map[client.EventType]bool
+
+	// mu serializes the pushes to the sink, as the events are pushed from
+	// the goroutines of the indexer and the retries of the buffered events
+	mu sync.Mutex
+	// buffer is the events the sink failed to take, in the order they
+	// were pushed. It is only used with the buffer failure policy
+	buffer []*bufferedEvent
+}
+
+func (s *fanoutSink) accepts(eventType client.EventType) bool {
+	return len(s.accepted) == 0 || s.accepted[eventType]
+}
+
+// FanoutConsumer is the implementation of EventConsumer that sends every
+// event to the sinks accepting its type. A failure of a sink is handled
+// according to the failure policy of the sink:
+//   - block returns the failure, so that the event is retried, and is
+//     delivered again to the other sinks as well
+//   - drop discards the event for the sink
+//   - buffer keeps the event for the sink and retries it in the background.
+//     The following events to the sink are queued after it so that the
+//     order is kept, and the failure is returned once the buffer is full.
+//     The sequenced envelopes are not acked until they leave the buffer, so
+//     the buffered ones are pushed again on restart
+type FanoutConsumer struct {
+	sinks         []*fanoutSink
+	bufferSize    int
+	retryInterval time.Duration
+
+	// lastSequence is the sequence of the last envelope dispatched without
+	// a failure
+	lastSequence atomic.Uint64
+
+	logger *zap.Logger
+
+	startOnce sync.Once
+	stopOnce  sync.Once
+	wg        sync.WaitGroup
+	quit      chan struct{}
+}
+
+func NewFanoutConsumer(sinks []*Sink, cfg *config.FanoutConfig, logger *zap.Logger) (*FanoutConsumer, error) {
+	if len(sinks) == 0 {
+		return nil, fmt.Errorf("no sink for the fan-out consumer")
+	}
+	if cfg.BufferSize <= 0 || cfg.RetryInterval <= 0 {
+		return nil, fmt.Errorf("invalid fan-out config")
+	}
+
+	fanoutSinks := make([]*fanoutSink, len(sinks))
+	for i, sink := range sinks {
+		switch sink.FailurePolicy {
+		case config.SinkFailurePolicyBlock, config.SinkFailurePolicyDrop, config.SinkFailurePolicyBuffer:
+		default:
+			return nil, fmt.Errorf("unknown failure policy %s of sink %s", sink.FailurePolicy, sink.Name)
+		}
+
+		accepted := make(map[client.EventType]bool)
+		for _, eventType := range sink.EventTypes {
+			accepted[eventType] = true
+		}
+		fanoutSinks[i] = &fanoutSink{Sink: sink, accepted: accepted}
+	}
+
+	return &FanoutConsumer{
+		sinks:         fanoutSinks,
+		bufferSize:    cfg.BufferSize,
+		retryInterval: cfg.RetryInterval,
+		logger:        logger.With(zap.String("module", "fanout consumer")),
+		quit:          make(chan struct{}),
+	}, nil
+}
+
+// Start starts all the sinks, and the started sinks are stopped if any of
+// them fails to start
+func (fc *FanoutConsumer) Start() error {
+	var startErr error
+	fc.startOnce.Do(func() {
+		for i, sink := range fc.sinks {
+			if err := sink.Consumer.Start(); err != nil {
+				for _, started := range fc.sinks[:i] {
+					if err := started.Consumer.Stop(); err != nil {
+						fc.logger.Error("failed to stop the sink",
+							zap.String("sink", started.Name), zap.Error(err))
+					}
+				}
+				startErr = fmt.Errorf("failed to start the sink %s: %w", sink.Name, err)
+				return
+			}
+		}
+
+		fc.wg.Add(1)
+		go fc.retryLoop()
+	})
+
+	return startErr
+}
+
+func (fc *FanoutConsumer) PushStakingEvent(ev *client.ActiveStakingEvent) error {
+	return fc.dispatch(ev, func(ec EventConsumer) error { return ec.PushStakingEvent(ev) })
+}
+
+func (fc *FanoutConsumer) PushUnbondingEvent(ev *client.UnbondingStakingEvent) error {
+	return fc.dispatch(ev, func(ec EventConsumer) error { return ec.PushUnbondingEvent(ev) })
+}
+
+func (fc *FanoutConsumer) PushWithdrawEvent(ev *client.WithdrawStakingEvent) error {
+	return fc.dispatch(ev, func(ec EventConsumer) error { return ec.PushWithdrawEvent(ev) })
+}
+
+func (fc *FanoutConsumer) PushBtcInfoEvent(ev *BtcInfoEvent) error {
+	return fc.dispatch(ev, func(ec EventConsumer) error { return ec.PushBtcInfoEvent(ev) })
+}
+
+func (fc *FanoutConsumer) PushConfirmedInfoEvent(ev *client.ConfirmedInfoEvent) error {
+	return fc.dispatch(ev, func(ec EventConsumer) error { return ec.PushConfirmedInfoEvent(ev) })
+}
+
+func (fc *FanoutConsumer) PushRollbackEvent(ev *RollbackEvent) error {
+	return fc.dispatch(ev, func(ec EventConsumer) error { return ec.PushRollbackEvent(ev) })
+}
+
+func (fc *FanoutConsumer) PushInvalidStakingEvent(ev *InvalidStakingEvent) error {
+	return fc.dispatch(ev, func(ec EventConsumer) error { return ec.PushInvalidStakingEvent(ev) })
+}
+
+func (fc *FanoutConsumer) PushSlashingEvent(ev *SlashingEvent) error {
+	return fc.dispatch(ev, func(ec EventConsumer) error { return ec.PushSlashingEvent(ev) })
+}
+
+func (fc *FanoutConsumer) PushTimelockExpiredEvent(ev *TimelockExpiredEvent) error {
+	return fc.dispatch(ev, func(ec EventConsumer) error { return ec.PushTimelockExpiredEvent(ev) })
+}
+
+func (fc *FanoutConsumer) PushFinalityProviderInfoEvent(ev *FinalityProviderInfoEvent) error {
+	return fc.dispatch(ev, func(ec EventConsumer) error { return ec.PushFinalityProviderInfoEvent(ev) })
+}
+
+// PushEventEnvelope pushes the envelope to the sinks accepting the wrapped
+// event. The sinks not publishing envelopes get the bare event
+func (fc *FanoutConsumer) PushEventEnvelope(env *EventEnvelope) error {
+	var seq uint64
+	if !env.Unsequenced {
+		seq = env.Sequence
+	}
+
+	if err := fc.dispatchSequenced(env, seq, func(ec EventConsumer) error { return PushEventEnvelope(ec, env) }); err != nil {
+		return err
+	}
+
+	if seq != 0 {
+		fc.lastSequence.Store(seq)
+	}
+
+	return nil
+}
+
+// DeliveredSequence returns the sequence up to which all the pushed envelopes
+// are delivered to the sinks, i.e., the sequence of the last dispatched
+// envelope if no sink buffers a sequenced envelope, otherwise the one before
+// the first buffered envelope
+func (fc *FanoutConsumer) DeliveredSequence() uint64 {
+	delivered := fc.lastSequence.Load()
+	for _, sink := range fc.sinks {
+		sink.mu.Lock()
+		// the envelopes are buffered in the order of their sequences
+		for _, buffered := range sink.buffer {
+			if buffered.seq != 0 {
+				delivered = min(delivered, buffered.seq-1)
+				break
+			}
+		}
+		sink.mu.Unlock()
+	}
+
+	return delivered
+}
+
+// Stop stops retrying the buffered events and stops all the sinks
+func (fc *FanoutConsumer) Stop() error {
+	var errs []error
+	fc.stopOnce.Do(func() {
+		close(fc.quit)
+		fc.wg.Wait()
+
+		for _, sink := range fc.sinks {
+			sink.mu.Lock()
+			if len(sink.buffer) > 0 {
+				// the buffered envelopes are not acked, so they are
+				// pushed again on restart
+				fc.logger.Warn("discarding the buffered events of the sink",
+					zap.String("sink", sink.Name),
+					zap.Int("num_events", len(sink.buffer)))
+			}
+			sink.mu.Unlock()
+
+			if err := sink.Consumer.Stop(); err != nil {
+				errs = append(errs, fmt.Errorf("failed to stop the sink %s: %w", sink.Name, err))
+			}
+		}
+	})
+
+	return errors.Join(errs...)
+}
+
+// dispatch pushes the event to all the sinks accepting it, and returns the
+// failures of the sinks with the block failure policy
+func (fc *FanoutConsumer) dispatch(ev client.EventMessage, push pushFunc) error {
+	return fc.dispatchSequenced(ev, 0, push)
+}
+
+// dispatchSequenced is dispatch of the envelope with the given sequence
+func (fc *FanoutConsumer) dispatchSequenced(ev client.EventMessage, seq uint64, push pushFunc) error {
+	var errs []error
+	for _, sink := range fc.sinks {
+		if !sink.accepts(ev.GetEventType()) {
+			continue
+		}
+
+		if err := fc.pushToSink(sink, ev, seq, push); err != nil {
+			errs = append(errs, fmt.Errorf("sink %s: %w", sink.Name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (fc *FanoutConsumer) pushToSink(sink *fanoutSink, ev client.EventMessage, seq uint64, push pushFunc) error {
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+
+	switch sink.FailurePolicy {
+	case config.SinkFailurePolicyDrop:
+		if err := push(sink.Consumer); err != nil {
+			// record metrics
+			failedSinkEventsCounter.WithLabelValues(sink.Name, sink.FailurePolicy).Inc()
+			droppedSinkEventsCounter.WithLabelValues(sink.Name).Inc()
+			fc.logger.Warn("dropping the event the sink failed to take",
+				zap.String("sink", sink.Name),
+				zap.String("event_type", EventTypeName(ev.GetEventType())),
+				zap.String("staking_tx_hash", ev.GetStakingTxHashHex()),
+				zap.Error(err))
+		}
+		return nil
+
+	case config.SinkFailurePolicyBuffer:
+		// the event is pushed only after the buffered events
+		if err := fc.flushBuffer(sink); err == nil {
+			err := push(sink.Consumer)
+			if err == nil {
+				return nil
+			}
+			// record metrics
+			failedSinkEventsCounter.WithLabelValues(sink.Name, sink.FailurePolicy).Inc()
+			fc.logger.Warn("buffering the event the sink failed to take",
+				zap.String("sink", sink.Name),
+				zap.String("event_type", EventTypeName(ev.GetEventType())),
+				zap.String("staking_tx_hash", ev.GetStakingTxHashHex()),
+				zap.Error(err))
+		}
+
+		if len(sink.buffer) >= fc.bufferSize {
+			return ErrSinkBufferFull
+		}
+		sink.buffer = append(sink.buffer, &bufferedEvent{ev: ev, seq: seq, push: push})
+		// record metrics
+		bufferedSinkEvents.WithLabelValues(sink.Name).Set(float64(len(sink.buffer)))
+		return nil
+
+	default:
+		if err := push(sink.Consumer); err != nil {
+			// record metrics
+			failedSinkEventsCounter.WithLabelValues(sink.Name, sink.FailurePolicy).Inc()
+			return err
+		}
+		return nil
+	}
+}
+
+// flushBuffer pushes the buffered events of the sink in order until one of
+// them fails. It should be called with the lock of the sink held
+func (fc *FanoutConsumer) flushBuffer(sink *fanoutSink) error {
+	for len(sink.buffer) > 0 {
+		if err := sink.buffer[0].push(sink.Consumer); err != nil {
+			return err
+		}
+		sink.buffer[0] = nil
+		sink.buffer = sink.buffer[1:]
+		// record metrics
+		bufferedSinkEvents.WithLabelValues(sink.Name).Set(float64(len(sink.buffer)))
+	}
+
+	return nil
+}
+
+// retryLoop retries the buffered events of the sinks periodically
+func (fc *FanoutConsumer) retryLoop() {
+	defer fc.wg.Done()
+
+	ticker := time.NewTicker(fc.retryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			for _, sink := range fc.sinks {
+				if sink.FailurePolicy != config.SinkFailurePolicyBuffer {
+					continue
+				}
+
+				sink.mu.Lock()
+				if err := fc.flushBuffer(sink); err != nil {
+					fc.logger.Warn("failed to push the buffered events to the sink",
+						zap.String("sink", sink.Name),
+						zap.Int("num_buffered_events", len(sink.buffer)),
+						zap.Error(err))
+				}
+				sink.mu.Unlock()
+			}
+		case <-fc.quit:
+			return
+		}
+	}
+}
+
+// BufferedEvents returns the number of the events buffered for the sink
+// with the given name
+func (fc *FanoutConsumer) BufferedEvents(name string) int {
+	for _, sink := range fc.sinks {
+		if sink.Name == name {
+			sink.mu.Lock()
+			defer sink.mu.Unlock()
+			return len(sink.buffer)
+		}
+	}
+
+	return 0
+}
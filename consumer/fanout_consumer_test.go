@@ -0,0 +1,308 @@
+package consumer_test
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	bbndatagen "github.com/babylonlabs-io/babylon/testutil/datagen"
+	"github.com/babylonlabs-io/staking-queue-client/client"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/babylonlabs-io/staking-indexer/config"
+	"github.com/babylonlabs-io/staking-indexer/consumer"
+)
+
+var errSinkDown = errors.New("the sink is down")
+
+// fakeSink records the pushed events, and fails the pushes while it is down
+type fakeSink struct {
+	mu      sync.Mutex
+	down    bool
+	events  []client.EventMessage
+	started bool
+}
+
+func (s *fakeSink) setDown(down bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.down = down
+}
+
+func (s *fakeSink) pushedEvents() []client.EventMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]client.EventMessage(nil), s.events...)
+}
+
+func (s *fakeSink) push(ev client.EventMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.down {
+		return errSinkDown
+	}
+	s.events = append(s.events, ev)
+	return nil
+}
+
+func (s *fakeSink) Start() error {
+	s.started = true
+	return nil
+}
+
+func (s *fakeSink) PushStakingEvent(ev *client.ActiveStakingEvent) error { return s.push(ev) }
+
+func (s *fakeSink) PushUnbondingEvent(ev *client.UnbondingStakingEvent) error { return s.push(ev) }
+
+func (s *fakeSink) PushWithdrawEvent(ev *client.WithdrawStakingEvent) error { return s.push(ev) }
+
+func (s *fakeSink) PushBtcInfoEvent(ev *consumer.BtcInfoEvent) error { return s.push(ev) }
+
+func (s *fakeSink) PushConfirmedInfoEvent(ev *client.ConfirmedInfoEvent) error { return s.push(ev) }
+
+func (s *fakeSink) PushRollbackEvent(ev *consumer.RollbackEvent) error { return s.push(ev) }
+
+func (s *fakeSink) PushInvalidStakingEvent(ev *consumer.InvalidStakingEvent) error {
+	return s.push(ev)
+}
+
+func (s *fakeSink) PushSlashingEvent(ev *consumer.SlashingEvent) error { return s.push(ev) }
+
+func (s *fakeSink) PushTimelockExpiredEvent(ev *consumer.TimelockExpiredEvent) error {
+	return s.push(ev)
+}
+
+func (s *fakeSink) PushFinalityProviderInfoEvent(ev *consumer.FinalityProviderInfoEvent) error {
+	return s.push(ev)
+}
+
+func (s *fakeSink) Stop() error {
+	s.started = false
+	return nil
+}
+
+// FuzzFanoutConsumer tests that every sink gets the events accepted by its
+// filter, and that its failures are handled by its failure policy
+func FuzzFanoutConsumer(f *testing.F) {
+	bbndatagen.AddRandomSeedsToFuzzer(f, 10)
+
+	f.Fuzz(func(t *testing.T, seed int64) {
+		r := rand.New(rand.NewSource(seed))
+
+		allTypes := []client.EventType{
+			client.ActiveStakingEventType,
+			client.UnbondingStakingEventType,
+			client.WithdrawStakingEventType,
+			client.ConfirmedInfoEventType,
+			consumer.TimelockExpiredEventType,
+			consumer.FinalityProviderInfoEventType,
+		}
+		policies := []string{
+			config.SinkFailurePolicyBlock,
+			config.SinkFailurePolicyDrop,
+			config.SinkFailurePolicyBuffer,
+		}
+
+		var sinks []*consumer.Sink
+		fakeSinks := make(map[string]*fakeSink)
+		for i, policy := range policies {
+			var eventTypes []client.EventType
+			if r.Intn(2) == 0 {
+				for _, eventType := range allTypes {
+					if r.Intn(2) == 0 {
+						eventTypes = append(eventTypes, eventType)
+					}
+				}
+			}
+			fs := &fakeSink{}
+			name := string(rune('a' + i))
+			fakeSinks[name] = fs
+			sinks = append(sinks, &consumer.Sink{
+				Name:          name,
+				Consumer:      fs,
+				EventTypes:    eventTypes,
+				FailurePolicy: policy,
+			})
+		}
+
+		cfg := config.DefaultFanoutConfig()
+		cfg.BufferSize = r.Intn(20) + 1
+		// the buffered events are only retried by the following pushes
+		cfg.RetryInterval = time.Hour
+		fanoutConsumer, err := consumer.NewFanoutConsumer(sinks, cfg, zap.NewNop())
+		require.NoError(t, err)
+		err = fanoutConsumer.Start()
+		require.NoError(t, err)
+		for _, fs := range fakeSinks {
+			require.True(t, fs.started)
+		}
+
+		accepts := func(sink *consumer.Sink, eventType client.EventType) bool {
+			if len(sink.EventTypes) == 0 {
+				return true
+			}
+			for _, t := range sink.EventTypes {
+				if t == eventType {
+					return true
+				}
+			}
+			return false
+		}
+
+		expectedEvents := make(map[string][]client.EventMessage)
+		var buffered []client.EventMessage
+		numEvents := r.Intn(50) + 1
+		for i := 0; i < numEvents; i++ {
+			for _, fs := range fakeSinks {
+				fs.setDown(r.Intn(4) == 0)
+			}
+			blockSink, dropSink, bufferSink := sinks[0], sinks[1], sinks[2]
+			blockDown := fakeSinks[blockSink.Name].down
+			dropDown := fakeSinks[dropSink.Name].down
+			bufferDown := fakeSinks[bufferSink.Name].down
+
+			ev, err := pushRandomEventWithErr(r, fanoutConsumer, uint64(i+1))
+
+			bufferFull := false
+			if accepts(bufferSink, ev.GetEventType()) {
+				if !bufferDown {
+					expectedEvents[bufferSink.Name] = append(expectedEvents[bufferSink.Name], buffered...)
+					expectedEvents[bufferSink.Name] = append(expectedEvents[bufferSink.Name], ev)
+					buffered = nil
+				} else if len(buffered) < cfg.BufferSize {
+					buffered = append(buffered, ev)
+				} else {
+					bufferFull = true
+				}
+			}
+			require.Equal(t, len(buffered), fanoutConsumer.BufferedEvents(bufferSink.Name))
+
+			if accepts(dropSink, ev.GetEventType()) && !dropDown {
+				expectedEvents[dropSink.Name] = append(expectedEvents[dropSink.Name], ev)
+			}
+
+			blockFailed := accepts(blockSink, ev.GetEventType()) && blockDown
+			if !blockFailed && accepts(blockSink, ev.GetEventType()) {
+				expectedEvents[blockSink.Name] = append(expectedEvents[blockSink.Name], ev)
+			}
+
+			if blockFailed || bufferFull {
+				require.Error(t, err)
+				if blockFailed {
+					require.ErrorIs(t, err, errSinkDown)
+				}
+				if bufferFull {
+					require.ErrorIs(t, err, consumer.ErrSinkBufferFull)
+				}
+			} else {
+				require.NoError(t, err)
+			}
+		}
+
+		for name, fs := range fakeSinks {
+			require.Equal(t, expectedEvents[name], fs.pushedEvents(), name)
+		}
+
+		err = fanoutConsumer.Stop()
+		require.NoError(t, err)
+		for _, fs := range fakeSinks {
+			require.False(t, fs.started)
+		}
+	})
+}
+
+// TestFanoutConsumerRetryBuffer tests that the buffered events are retried
+// in the background once the sink recovers
+func TestFanoutConsumerRetryBuffer(t *testing.T) {
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	fs := &fakeSink{}
+	cfg := config.DefaultFanoutConfig()
+	cfg.RetryInterval = 10 * time.Millisecond
+	fanoutConsumer, err := consumer.NewFanoutConsumer([]*consumer.Sink{{
+		Name:          config.SinkMetrics,
+		Consumer:      fs,
+		FailurePolicy: config.SinkFailurePolicyBuffer,
+	}}, cfg, zap.NewNop())
+	require.NoError(t, err)
+	require.NoError(t, fanoutConsumer.Start())
+	defer func() {
+		require.NoError(t, fanoutConsumer.Stop())
+	}()
+
+	fs.setDown(true)
+	var pushedEvents []client.EventMessage
+	for i := 0; i < 10; i++ {
+		pushedEvents = append(pushedEvents, pushRandomEvent(t, r, fanoutConsumer, uint64(i+1)))
+	}
+	require.Equal(t, len(pushedEvents), fanoutConsumer.BufferedEvents(config.SinkMetrics))
+	require.Empty(t, fs.pushedEvents())
+
+	fs.setDown(false)
+	require.Eventually(t, func() bool {
+		return fanoutConsumer.BufferedEvents(config.SinkMetrics) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, pushedEvents, fs.pushedEvents())
+}
+
+// TestFanoutConsumerDeliveredSequence tests that the envelopes buffered for a
+// sink are not acked until they are delivered
+func TestFanoutConsumerDeliveredSequence(t *testing.T) {
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	fs := &fakeSink{}
+	cfg := config.DefaultFanoutConfig()
+	cfg.RetryInterval = 10 * time.Millisecond
+	fanoutConsumer, err := consumer.NewFanoutConsumer([]*consumer.Sink{{
+		Name:          config.SinkMetrics,
+		Consumer:      fs,
+		FailurePolicy: config.SinkFailurePolicyBuffer,
+	}}, cfg, zap.NewNop())
+	require.NoError(t, err)
+	require.NoError(t, fanoutConsumer.Start())
+	defer func() {
+		require.NoError(t, fanoutConsumer.Stop())
+	}()
+
+	pushEnvelope := func(seq uint64, unsequenced bool) {
+		ev := pushRandomEvent(t, r, &fakeSink{}, seq)
+		var env *consumer.EventEnvelope
+		var err error
+		if unsequenced {
+			env, err = consumer.WrapUnsequencedEvent(seq, bbndatagen.GenRandomBtcdHash(r).String(), ev)
+		} else {
+			env, err = consumer.WrapEvent(seq, seq, bbndatagen.GenRandomBtcdHash(r).String(), ev)
+		}
+		require.NoError(t, err)
+		err = fanoutConsumer.PushEventEnvelope(env)
+		require.NoError(t, err)
+	}
+
+	numDelivered := uint64(r.Intn(5) + 1)
+	for seq := uint64(1); seq <= numDelivered; seq++ {
+		pushEnvelope(seq, false)
+	}
+	require.Equal(t, numDelivered, fanoutConsumer.DeliveredSequence())
+
+	// the buffered envelopes are not acked, and the unsequenced ones do
+	// not affect the ack
+	fs.setDown(true)
+	pushEnvelope(0, true)
+	numBuffered := uint64(r.Intn(5) + 1)
+	for seq := numDelivered + 1; seq <= numDelivered+numBuffered; seq++ {
+		pushEnvelope(seq, false)
+		pushEnvelope(0, true)
+	}
+	require.Equal(t, int(2*numBuffered+1), fanoutConsumer.BufferedEvents(config.SinkMetrics))
+	require.Equal(t, numDelivered, fanoutConsumer.DeliveredSequence())
+
+	// the envelopes are acked once they leave the buffer
+	fs.setDown(false)
+	require.Eventually(t, func() bool {
+		return fanoutConsumer.DeliveredSequence() == numDelivered+numBuffered
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Zero(t, fanoutConsumer.BufferedEvents(config.SinkMetrics))
+}
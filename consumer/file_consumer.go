@@ -0,0 +1,549 @@
+package consumer
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/babylonlabs-io/staking-queue-client/client"
+	"go.uber.org/zap"
+
+	"github.com/babylonlabs-io/staking-indexer/config"
+)
+
+const (
+	fileSegmentPrefix        = "events-"
+	fileSegmentExt           = ".jsonl"
+	fileCompressedSegmentExt = ".jsonl.gz"
+	fileTmpExt               = ".tmp"
+)
+
+var _ EnvelopeConsumer = (*FileConsumer)(nil)
+
+// FileEventRecord is a line of the event files
+type FileEventRecord struct {
+	// Sequence is the position of the event in the stream of all the events
+	// appended to the directory, starting from 1
+	Sequence  uint64           `json:"sequence"`
+	EventType client.EventType `json:"event_type"`
+	// Height is the BTC height the event refers to, it is omitted for the
+	// events without a height, i.e., the withdraw events not wrapped in
+	// envelopes
+	Height uint64 `json:"height,omitempty"`
+	// Event is the JSON encoded event, or the JSON encoded envelope if the
+	// event is pushed by the indexer
+	Event json.RawMessage `json:"event"`
+}
+
+// FileConsumer is the implementation of EventConsumer that appends every
+// event to JSON lines files in a directory. The files are segments of the
+// event stream, named after the sequence number of their first event. A new
+// segment is started when the current one reaches the max size or covers the
+// max number of heights, and the closed segments are compressed with gzip if
+// configured. The sequence numbers continue across restarts.
+type FileConsumer struct {
+	cfg *config.FileSinkConfig
+
+	mu sync.Mutex
+	// lastSeq is the sequence number of the last appended event
+	lastSeq uint64
+	// segment is the file of the current segment, nil if no event is
+	// appended since the last segment was closed
+	segment     *os.File
+	segmentSize int64
+	// segmentStartHeight is the height of the first event with a height
+	// in the current segment, 0 if there is none
+	segmentStartHeight uint64
+
+	logger *zap.Logger
+}
+
+func NewFileConsumer(cfg *config.FileSinkConfig, logger *zap.Logger) (*FileConsumer, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid file sink config: %w", err)
+	}
+
+	return &FileConsumer{
+		cfg:    cfg,
+		logger: logger.With(zap.String("module", "file consumer")),
+	}, nil
+}
+
+// Start recovers the sequence number of the last appended event, and resumes
+// appending to the last segment if it is not closed
+func (fc *FileConsumer) Start() error {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	if err := os.MkdirAll(fc.cfg.Dir, 0700); err != nil {
+		return fmt.Errorf("failed to create the event files dir: %w", err)
+	}
+
+	segments, err := fc.recoverSegments()
+	if err != nil {
+		return fmt.Errorf("failed to recover the event files: %w", err)
+	}
+	if len(segments) == 0 {
+		return nil
+	}
+
+	last := segments[len(segments)-1]
+	if last.compressed {
+		records, err := readSegment(last.path, true)
+		if err != nil {
+			return err
+		}
+		fc.lastSeq = last.startSeq - 1
+		if len(records) > 0 {
+			fc.lastSeq = records[len(records)-1].Sequence
+		}
+		return nil
+	}
+
+	return fc.resumeSegment(last)
+}
+
+func (fc *FileConsumer) PushStakingEvent(ev *client.ActiveStakingEvent) error {
+	return fc.append(ev, ev.StakingStartHeight)
+}
+
+func (fc *FileConsumer) PushUnbondingEvent(ev *client.UnbondingStakingEvent) error {
+	return fc.append(ev, ev.UnbondingStartHeight)
+}
+
+func (fc *FileConsumer) PushWithdrawEvent(ev *client.WithdrawStakingEvent) error {
+	return fc.append(ev, 0)
+}
+
+func (fc *FileConsumer) PushBtcInfoEvent(ev *BtcInfoEvent) error {
+	return fc.append(ev, ev.Height)
+}
+
+func (fc *FileConsumer) PushConfirmedInfoEvent(ev *client.ConfirmedInfoEvent) error {
+	return fc.append(ev, ev.Height)
+}
+
+func (fc *FileConsumer) PushRollbackEvent(ev *RollbackEvent) error {
+	return fc.append(ev, ev.Height)
+}
+
+func (fc *FileConsumer) PushInvalidStakingEvent(ev *InvalidStakingEvent) error {
+	return fc.append(ev, ev.StakingStartHeight)
+}
+
+func (fc *FileConsumer) PushSlashingEvent(ev *SlashingEvent) error {
+	return fc.append(ev, ev.SlashingHeight)
+}
+
+func (fc *FileConsumer) PushTimelockExpiredEvent(ev *TimelockExpiredEvent) error {
+	return fc.append(ev, ev.ExpiryHeight)
+}
+
+func (fc *FileConsumer) PushFinalityProviderInfoEvent(ev *FinalityProviderInfoEvent) error {
+	return fc.append(ev, ev.Height)
+}
+
+// PushEventEnvelope appends the envelope at the height of its block
+func (fc *FileConsumer) PushEventEnvelope(env *EventEnvelope) error {
+	return fc.append(env, env.BlockHeight)
+}
+
+// Stop closes the current segment without compressing it, so that appending
+// to it is resumed at the next start
+func (fc *FileConsumer) Stop() error {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	if fc.segment == nil {
+		return nil
+	}
+
+	err := fc.segment.Close()
+	fc.segment = nil
+
+	return err
+}
+
+// append writes the event with the next sequence number to the current
+// segment, and starts a new segment first if the current one is full
+func (fc *FileConsumer) append(ev client.EventMessage, height uint64) error {
+	eventBytes, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	record := &FileEventRecord{
+		Sequence:  fc.lastSeq + 1,
+		EventType: ev.GetEventType(),
+		Height:    height,
+		Event:     eventBytes,
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	if fc.segment != nil && fc.isSegmentFull(int64(len(line)), height) {
+		if err := fc.closeSegment(); err != nil {
+			return fmt.Errorf("failed to rotate the event file: %w", err)
+		}
+	}
+
+	if fc.segment == nil {
+		if err := fc.openSegment(record.Sequence); err != nil {
+			return err
+		}
+	}
+
+	if _, err := fc.segment.Write(line); err != nil {
+		return fmt.Errorf("failed to append the event: %w", err)
+	}
+	if err := fc.segment.Sync(); err != nil {
+		return fmt.Errorf("failed to sync the event file: %w", err)
+	}
+
+	fc.lastSeq = record.Sequence
+	fc.segmentSize += int64(len(line))
+	if fc.segmentStartHeight == 0 {
+		fc.segmentStartHeight = height
+	}
+
+	return nil
+}
+
+// isSegmentFull returns whether the current segment cannot take a line of
+// the given size for an event at the given height
+func (fc *FileConsumer) isSegmentFull(lineSize int64, height uint64) bool {
+	if fc.cfg.MaxSegmentSize > 0 && fc.segmentSize > 0 &&
+		fc.segmentSize+lineSize > fc.cfg.MaxSegmentSize {
+		return true
+	}
+
+	return fc.cfg.MaxSegmentHeights > 0 && height > 0 && fc.segmentStartHeight > 0 &&
+		height >= fc.segmentStartHeight+fc.cfg.MaxSegmentHeights
+}
+
+func (fc *FileConsumer) openSegment(startSeq uint64) error {
+	f, err := os.OpenFile(segmentPath(fc.cfg.Dir, startSeq, false), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create the event file: %w", err)
+	}
+
+	fc.segment = f
+	fc.segmentSize = 0
+	fc.segmentStartHeight = 0
+
+	return nil
+}
+
+// closeSegment closes the current segment and compresses it if configured
+func (fc *FileConsumer) closeSegment() error {
+	path := fc.segment.Name()
+	if err := fc.segment.Close(); err != nil {
+		return err
+	}
+	fc.segment = nil
+
+	fc.logger.Info("closed the event file",
+		zap.String("path", path),
+		zap.Uint64("last_sequence", fc.lastSeq))
+
+	if !fc.cfg.Compress {
+		return nil
+	}
+
+	return compressSegment(path)
+}
+
+// resumeSegment opens the last segment for appending. A line partially
+// written before a crash is truncated
+func (fc *FileConsumer) resumeSegment(last *segmentFile) error {
+	f, err := os.OpenFile(last.path, os.O_RDWR, 0600)
+	if err != nil {
+		return err
+	}
+
+	lastSeq := last.startSeq - 1
+	var size int64
+	var startHeight uint64
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			// the line without a newline is incomplete
+			break
+		}
+		if err != nil {
+			f.Close()
+			return err
+		}
+
+		var record FileEventRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			f.Close()
+			return fmt.Errorf("corrupted event file %s: %w", last.path, err)
+		}
+		lastSeq = record.Sequence
+		size += int64(len(line))
+		if startHeight == 0 {
+			startHeight = record.Height
+		}
+	}
+
+	if err := f.Truncate(size); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Seek(size, io.SeekStart); err != nil {
+		f.Close()
+		return err
+	}
+
+	fc.segment = f
+	fc.segmentSize = size
+	fc.segmentStartHeight = startHeight
+	fc.lastSeq = lastSeq
+
+	return nil
+}
+
+// recoverSegments finishes the rotations interrupted by a crash, and returns
+// the segments ordered by their start sequence numbers
+func (fc *FileConsumer) recoverSegments() ([]*segmentFile, error) {
+	entries, err := os.ReadDir(fc.cfg.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	// the temporary files are the compressions that did not complete
+	for _, entry := range entries {
+		if strings.HasSuffix(entry.Name(), fileTmpExt) {
+			if err := os.Remove(filepath.Join(fc.cfg.Dir, entry.Name())); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	segments, err := listSegments(fc.cfg.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var recovered []*segmentFile
+	for i, segment := range segments {
+		isLast := i == len(segments)-1
+		if segment.compressed {
+			recovered = append(recovered, segment)
+			continue
+		}
+
+		// the compressed segment is renamed into place before the
+		// uncompressed one is removed
+		compressedPath := segmentPath(fc.cfg.Dir, segment.startSeq, true)
+		if _, err := os.Stat(compressedPath); err == nil {
+			if err := os.Remove(segment.path); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if !isLast && fc.cfg.Compress {
+			if err := compressSegment(segment.path); err != nil {
+				return nil, err
+			}
+			segment = &segmentFile{path: compressedPath, startSeq: segment.startSeq, compressed: true}
+		}
+		recovered = append(recovered, segment)
+	}
+
+	return recovered, nil
+}
+
+// ReadFileEventRecords returns the events appended to the event files in the
+// given directory, in the order of their sequence numbers
+func ReadFileEventRecords(dir string) ([]*FileEventRecord, error) {
+	segments, err := listSegments(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []*FileEventRecord
+	for _, segment := range segments {
+		if !segment.compressed {
+			// skip the uncompressed copy of a compressed segment
+			if _, err := os.Stat(segmentPath(dir, segment.startSeq, true)); err == nil {
+				continue
+			}
+		}
+
+		segmentRecords, err := readSegment(segment.path, segment.compressed)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, segmentRecords...)
+	}
+
+	return records, nil
+}
+
+type segmentFile struct {
+	path       string
+	startSeq   uint64
+	compressed bool
+}
+
+func segmentPath(dir string, startSeq uint64, compressed bool) string {
+	ext := fileSegmentExt
+	if compressed {
+		ext = fileCompressedSegmentExt
+	}
+
+	return filepath.Join(dir, fmt.Sprintf("%s%020d%s", fileSegmentPrefix, startSeq, ext))
+}
+
+// listSegments returns the segment files in the dir ordered by their start
+// sequence numbers, with the compressed file after the uncompressed one of
+// the same segment
+func listSegments(dir string) ([]*segmentFile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var segments []*segmentFile
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, fileSegmentPrefix) {
+			continue
+		}
+
+		var compressed bool
+		var seqStr string
+		switch {
+		case strings.HasSuffix(name, fileCompressedSegmentExt):
+			compressed = true
+			seqStr = strings.TrimSuffix(strings.TrimPrefix(name, fileSegmentPrefix), fileCompressedSegmentExt)
+		case strings.HasSuffix(name, fileSegmentExt):
+			seqStr = strings.TrimSuffix(strings.TrimPrefix(name, fileSegmentPrefix), fileSegmentExt)
+		default:
+			continue
+		}
+
+		startSeq, err := strconv.ParseUint(seqStr, 10, 64)
+		if err != nil {
+			continue
+		}
+
+		segments = append(segments, &segmentFile{
+			path:       filepath.Join(dir, name),
+			startSeq:   startSeq,
+			compressed: compressed,
+		})
+	}
+
+	sort.Slice(segments, func(i, j int) bool {
+		if segments[i].startSeq != segments[j].startSeq {
+			return segments[i].startSeq < segments[j].startSeq
+		}
+		return !segments[i].compressed
+	})
+
+	return segments, nil
+}
+
+func readSegment(path string, compressed bool) ([]*FileEventRecord, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if compressed {
+		gr, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, fmt.Errorf("corrupted event file %s: %w", path, err)
+		}
+		defer gr.Close()
+		r = gr
+	}
+
+	var records []*FileEventRecord
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(bytes.TrimSpace(line)) > 0 {
+				// a line partially written before a crash
+				break
+			}
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var record FileEventRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			return nil, fmt.Errorf("corrupted event file %s: %w", path, err)
+		}
+		records = append(records, &record)
+	}
+
+	return records, nil
+}
+
+// compressSegment replaces the segment file with its gzip compressed copy.
+// The copy is written to a temporary file and renamed into place before the
+// segment file is removed, so that a crash leaves one complete copy
+func compressSegment(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	compressedPath := strings.TrimSuffix(path, fileSegmentExt) + fileCompressedSegmentExt
+	tmpPath := compressedPath + fileTmpExt
+	dst, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	gw := gzip.NewWriter(dst)
+	if _, err := io.Copy(gw, src); err != nil {
+		dst.Close()
+		return err
+	}
+	if err := gw.Close(); err != nil {
+		dst.Close()
+		return err
+	}
+	if err := dst.Sync(); err != nil {
+		dst.Close()
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmpPath, compressedPath); err != nil {
+		return err
+	}
+
+	return os.Remove(path)
+}
@@ -0,0 +1,186 @@
+package consumer_test
+
+import (
+	"encoding/json"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	bbndatagen "github.com/babylonlabs-io/babylon/testutil/datagen"
+	"github.com/babylonlabs-io/staking-queue-client/client"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/babylonlabs-io/staking-indexer/config"
+	"github.com/babylonlabs-io/staking-indexer/consumer"
+)
+
+// pushRandomEvent pushes a random event at the given height and returns it
+func pushRandomEvent(t *testing.T, r *rand.Rand, ec consumer.EventConsumer, height uint64) client.EventMessage {
+	ev, err := pushRandomEventWithErr(r, ec, height)
+	require.NoError(t, err)
+
+	return ev
+}
+
+// pushRandomEventWithErr pushes a random event at the given height and
+// returns it with the error of the push
+func pushRandomEventWithErr(r *rand.Rand, ec consumer.EventConsumer, height uint64) (client.EventMessage, error) {
+	stakingTxHash := bbndatagen.GenRandomBtcdHash(r).String()
+
+	var ev client.EventMessage
+	var err error
+	switch r.Intn(6) {
+	case 0:
+		stakingEv := client.NewActiveStakingEvent(
+			stakingTxHash, "", "", uint64(r.Int63()), height,
+			r.Int63(), uint64(r.Int63()), 0, "", false)
+		ev = &stakingEv
+		err = ec.PushStakingEvent(&stakingEv)
+	case 1:
+		unbondingEv := client.NewUnbondingStakingEvent(stakingTxHash, height, r.Int63(), 0, 0, "", "")
+		ev = &unbondingEv
+		err = ec.PushUnbondingEvent(&unbondingEv)
+	case 2:
+		withdrawEv := client.NewWithdrawStakingEvent(stakingTxHash)
+		ev = &withdrawEv
+		err = ec.PushWithdrawEvent(&withdrawEv)
+	case 3:
+		confirmedInfoEv := client.NewConfirmedInfoEvent(height, uint64(r.Int63()))
+		ev = &confirmedInfoEv
+		err = ec.PushConfirmedInfoEvent(&confirmedInfoEv)
+	case 4:
+		fpInfoEv := consumer.NewFinalityProviderInfoEvent(
+			bbndatagen.GenRandomHexStr(r, 32), height, uint64(r.Int63()), uint64(r.Int63()),
+			uint64(r.Int63()), uint64(r.Int63()))
+		ev = &fpInfoEv
+		err = ec.PushFinalityProviderInfoEvent(&fpInfoEv)
+	default:
+		expiredEv := consumer.NewTimelockExpiredEvent(stakingTxHash, "", height, r.Int63())
+		ev = &expiredEv
+		err = ec.PushTimelockExpiredEvent(&expiredEv)
+	}
+
+	return ev, err
+}
+
+// FuzzFileConsumer tests that the events are appended with contiguous
+// sequence numbers across rotations and restarts, and the closed segments
+// respect the limits and are compressed
+func FuzzFileConsumer(f *testing.F) {
+	bbndatagen.AddRandomSeedsToFuzzer(f, 10)
+
+	f.Fuzz(func(t *testing.T, seed int64) {
+		r := rand.New(rand.NewSource(seed))
+
+		cfg := config.DefaultFileSinkConfigWithHomePath(t.TempDir())
+		cfg.MaxSegmentSize = r.Int63n(2000) + 500
+		cfg.MaxSegmentHeights = uint64(r.Intn(5))
+		cfg.Compress = r.Intn(2) == 0
+
+		fileConsumer, err := consumer.NewFileConsumer(cfg, zap.NewNop())
+		require.NoError(t, err)
+		err = fileConsumer.Start()
+		require.NoError(t, err)
+
+		numEvents := r.Intn(100) + 1
+		restartAt := r.Intn(numEvents)
+		height := uint64(r.Int63n(1000) + 1)
+		var pushedEvents []client.EventMessage
+		for i := 0; i < numEvents; i++ {
+			if i == restartAt {
+				err := fileConsumer.Stop()
+				require.NoError(t, err)
+
+				// simulate a line partially written before a crash
+				files, err := filepath.Glob(filepath.Join(cfg.Dir, "*.jsonl"))
+				require.NoError(t, err)
+				if len(files) > 0 {
+					f, err := os.OpenFile(files[len(files)-1], os.O_APPEND|os.O_WRONLY, 0600)
+					require.NoError(t, err)
+					_, err = f.WriteString(`{"sequence":`)
+					require.NoError(t, err)
+					require.NoError(t, f.Close())
+				}
+
+				fileConsumer, err = consumer.NewFileConsumer(cfg, zap.NewNop())
+				require.NoError(t, err)
+				err = fileConsumer.Start()
+				require.NoError(t, err)
+			}
+
+			height += uint64(r.Intn(3))
+			pushedEvents = append(pushedEvents, pushRandomEvent(t, r, fileConsumer, height))
+		}
+		err = fileConsumer.Stop()
+		require.NoError(t, err)
+
+		records, err := consumer.ReadFileEventRecords(cfg.Dir)
+		require.NoError(t, err)
+		require.Len(t, records, numEvents)
+		for i, record := range records {
+			require.Equal(t, uint64(i+1), record.Sequence)
+			require.Equal(t, pushedEvents[i].GetEventType(), record.EventType)
+			expectedEvent, err := json.Marshal(pushedEvents[i])
+			require.NoError(t, err)
+			require.JSONEq(t, string(expectedEvent), string(record.Event))
+		}
+
+		// all the segments but the last one are closed
+		entries, err := os.ReadDir(cfg.Dir)
+		require.NoError(t, err)
+		require.NotEmpty(t, entries)
+		for i, entry := range entries {
+			isLast := i == len(entries)-1
+			name := entry.Name()
+			if isLast {
+				require.True(t, strings.HasSuffix(name, ".jsonl"))
+				continue
+			}
+			if cfg.Compress {
+				require.True(t, strings.HasSuffix(name, ".jsonl.gz"))
+			} else {
+				require.True(t, strings.HasSuffix(name, ".jsonl"))
+			}
+		}
+
+		// the closed segments respect the limits, a segment may exceed the
+		// max size only if it has a single event
+		var segmentSize int64
+		var segmentLen int
+		var segmentStartHeight uint64
+		segmentStartSeq := uint64(1)
+		startSeqs := make(map[uint64]bool)
+		for _, entry := range entries {
+			name := strings.TrimPrefix(entry.Name(), "events-")
+			name = strings.TrimSuffix(strings.TrimSuffix(name, ".gz"), ".jsonl")
+			startSeq, err := strconv.ParseUint(name, 10, 64)
+			require.NoError(t, err)
+			startSeqs[startSeq] = true
+		}
+		for _, record := range records {
+			line, err := json.Marshal(record)
+			require.NoError(t, err)
+			if startSeqs[record.Sequence] && record.Sequence != segmentStartSeq {
+				// a new segment started at this record
+				require.True(t, segmentLen == 1 || segmentSize <= cfg.MaxSegmentSize)
+				segmentSize, segmentLen, segmentStartHeight = 0, 0, 0
+				segmentStartSeq = record.Sequence
+			}
+			segmentSize += int64(len(line) + 1)
+			segmentLen++
+			if record.Height == 0 {
+				continue
+			}
+			if segmentStartHeight == 0 {
+				segmentStartHeight = record.Height
+			}
+			if cfg.MaxSegmentHeights > 0 {
+				require.Less(t, record.Height, segmentStartHeight+cfg.MaxSegmentHeights)
+			}
+		}
+	})
+}
@@ -0,0 +1,194 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/babylonlabs-io/staking-queue-client/client"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"go.uber.org/zap"
+
+	"github.com/babylonlabs-io/staking-indexer/config"
+)
+
+var _ EnvelopeConsumer = (*KafkaConsumer)(nil)
+
+// KafkaConsumer is the Kafka implementation of EventConsumer. Each event type
+// is published to its own topic, named after the queue of the event type in
+// the RabbitMQ implementation. The events pushed by the indexer are wrapped
+// in envelopes. The records are keyed by the staking tx hash
+// so that the events of a delegation land in the same partition and stay
+// ordered. The events not related to a delegation, i.e., the btc info and the
+// confirmed info events, are not keyed.
+type KafkaConsumer struct {
+	client *kgo.Client
+	cfg    *config.KafkaConfig
+
+	logger *zap.Logger
+}
+
+func NewKafkaConsumer(cfg *config.KafkaConfig, logger *zap.Logger) (*KafkaConsumer, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid kafka config: %w", err)
+	}
+
+	opts := []kgo.Opt{
+		kgo.SeedBrokers(cfg.Brokers...),
+		kgo.ClientID(cfg.ClientID),
+		// the records are partitioned by their keys, i.e., the staking tx hashes
+		kgo.RecordPartitioner(kgo.StickyKeyPartitioner(nil)),
+		kgo.ProduceRequestTimeout(cfg.ProduceTimeout),
+		kgo.RecordDeliveryTimeout(cfg.ProduceTimeout),
+	}
+
+	switch cfg.Acks {
+	case config.KafkaAcksAll:
+		opts = append(opts, kgo.RequiredAcks(kgo.AllISRAcks()))
+	case config.KafkaAcksLeader:
+		opts = append(opts, kgo.RequiredAcks(kgo.LeaderAck()))
+	case config.KafkaAcksNone:
+		opts = append(opts, kgo.RequiredAcks(kgo.NoAck()))
+	}
+
+	if !cfg.IdempotentWrite {
+		opts = append(opts, kgo.DisableIdempotentWrite())
+	}
+
+	switch cfg.Compression {
+	case "gzip":
+		opts = append(opts, kgo.ProducerBatchCompression(kgo.GzipCompression()))
+	case "snappy":
+		opts = append(opts, kgo.ProducerBatchCompression(kgo.SnappyCompression()))
+	case "lz4":
+		opts = append(opts, kgo.ProducerBatchCompression(kgo.Lz4Compression()))
+	case "zstd":
+		opts = append(opts, kgo.ProducerBatchCompression(kgo.ZstdCompression()))
+	default:
+		opts = append(opts, kgo.ProducerBatchCompression(kgo.NoCompression()))
+	}
+
+	if cfg.AllowAutoTopicCreate {
+		opts = append(opts, kgo.AllowAutoTopicCreation())
+	}
+
+	kafkaClient, err := kgo.NewClient(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kafka client: %w", err)
+	}
+
+	return &KafkaConsumer{
+		client: kafkaClient,
+		cfg:    cfg,
+		logger: logger.With(zap.String("module", "kafka consumer")),
+	}, nil
+}
+
+// Start checks that the brokers are reachable
+func (kc *KafkaConsumer) Start() error {
+	ctx, cancel := context.WithTimeout(context.Background(), kc.cfg.ProduceTimeout)
+	defer cancel()
+
+	if err := kc.client.Ping(ctx); err != nil {
+		return fmt.Errorf("failed to reach the kafka brokers: %w", err)
+	}
+
+	return nil
+}
+
+// Topic returns the topic the events of the given queue are published to
+func (kc *KafkaConsumer) Topic(queueName string) string {
+	return kc.cfg.TopicPrefix + queueName
+}
+
+func (kc *KafkaConsumer) PushStakingEvent(ev *client.ActiveStakingEvent) error {
+	return kc.produce(client.ActiveStakingQueueName, "active staking", ev)
+}
+
+func (kc *KafkaConsumer) PushUnbondingEvent(ev *client.UnbondingStakingEvent) error {
+	return kc.produce(client.UnbondingStakingQueueName, "unbonding", ev)
+}
+
+func (kc *KafkaConsumer) PushWithdrawEvent(ev *client.WithdrawStakingEvent) error {
+	return kc.produce(client.WithdrawStakingQueueName, "withdraw", ev)
+}
+
+func (kc *KafkaConsumer) PushBtcInfoEvent(ev *BtcInfoEvent) error {
+	return kc.produce(client.BtcInfoQueueName, "btc info", ev)
+}
+
+func (kc *KafkaConsumer) PushConfirmedInfoEvent(ev *client.ConfirmedInfoEvent) error {
+	return kc.produce(client.ConfirmedInfoQueueName, "confirmed info", ev)
+}
+
+func (kc *KafkaConsumer) PushRollbackEvent(ev *RollbackEvent) error {
+	return kc.produce(RollbackQueueName, "rollback", ev)
+}
+
+func (kc *KafkaConsumer) PushInvalidStakingEvent(ev *InvalidStakingEvent) error {
+	return kc.produce(InvalidStakingQueueName, "invalid staking", ev)
+}
+
+func (kc *KafkaConsumer) PushSlashingEvent(ev *SlashingEvent) error {
+	return kc.produce(SlashingQueueName, "slashing", ev)
+}
+
+func (kc *KafkaConsumer) PushTimelockExpiredEvent(ev *TimelockExpiredEvent) error {
+	return kc.produce(TimelockExpiredQueueName, "timelock expired", ev)
+}
+
+func (kc *KafkaConsumer) PushFinalityProviderInfoEvent(ev *FinalityProviderInfoEvent) error {
+	return kc.produce(FinalityProviderInfoQueueName, "finality provider info", ev)
+}
+
+// PushEventEnvelope publishes the envelope to the topic of the wrapped event
+func (kc *KafkaConsumer) PushEventEnvelope(env *EventEnvelope) error {
+	queueName, err := EventQueueName(env.EventType)
+	if err != nil {
+		return err
+	}
+
+	return kc.produce(queueName, EventTypeName(env.EventType), env)
+}
+
+// Stop flushes the buffered records and closes the client
+func (kc *KafkaConsumer) Stop() error {
+	ctx, cancel := context.WithTimeout(context.Background(), kc.cfg.ProduceTimeout)
+	defer cancel()
+
+	err := kc.client.Flush(ctx)
+	kc.client.Close()
+
+	return err
+}
+
+// produce publishes the event to the topic of the given queue and waits
+// until the record is acked according to the configured acks
+func (kc *KafkaConsumer) produce(queueName string, eventName string, ev client.EventMessage) error {
+	jsonBytes, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+
+	record := &kgo.Record{
+		Topic: kc.Topic(queueName),
+		Value: jsonBytes,
+	}
+	if stakingTxHashHex := ev.GetStakingTxHashHex(); stakingTxHashHex != "" {
+		record.Key = []byte(stakingTxHashHex)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), kc.cfg.ProduceTimeout)
+	defer cancel()
+
+	kc.logger.Info(fmt.Sprintf("pushing %s event", eventName),
+		zap.String("topic", record.Topic),
+		zap.String("staking_tx_hash", ev.GetStakingTxHashHex()))
+	if err := kc.client.ProduceSync(ctx, record).FirstErr(); err != nil {
+		return fmt.Errorf("failed to push %s event: %w", eventName, err)
+	}
+	kc.logger.Info(fmt.Sprintf("successfully pushed %s event", eventName),
+		zap.String("staking_tx_hash", ev.GetStakingTxHashHex()))
+
+	return nil
+}
@@ -0,0 +1,150 @@
+package consumer_test
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand"
+	"testing"
+	"time"
+
+	bbndatagen "github.com/babylonlabs-io/babylon/testutil/datagen"
+	"github.com/babylonlabs-io/staking-queue-client/client"
+	"github.com/stretchr/testify/require"
+	"github.com/twmb/franz-go/pkg/kfake"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"go.uber.org/zap"
+
+	"github.com/babylonlabs-io/staking-indexer/config"
+	"github.com/babylonlabs-io/staking-indexer/consumer"
+)
+
+// FuzzKafkaConsumer tests that the events are published to the topics of
+// their types, and the events of a delegation are kept in order in a single
+// partition
+func FuzzKafkaConsumer(f *testing.F) {
+	bbndatagen.AddRandomSeedsToFuzzer(f, 5)
+
+	f.Fuzz(func(t *testing.T, seed int64) {
+		r := rand.New(rand.NewSource(seed))
+
+		cfg := config.DefaultKafkaConfig()
+		cfg.TopicPrefix = "test_"
+		topics := []string{
+			cfg.TopicPrefix + client.ActiveStakingQueueName,
+			cfg.TopicPrefix + client.UnbondingStakingQueueName,
+			cfg.TopicPrefix + client.WithdrawStakingQueueName,
+			cfg.TopicPrefix + client.ConfirmedInfoQueueName,
+			cfg.TopicPrefix + consumer.TimelockExpiredQueueName,
+		}
+		cluster, err := kfake.NewCluster(kfake.NumBrokers(3), kfake.SeedTopics(4, topics...))
+		require.NoError(t, err)
+		defer cluster.Close()
+		cfg.Brokers = cluster.ListenAddrs()
+
+		kafkaConsumer, err := consumer.NewKafkaConsumer(cfg, zap.NewNop())
+		require.NoError(t, err)
+		err = kafkaConsumer.Start()
+		require.NoError(t, err)
+
+		// push the events of the lifecycles of random delegations,
+		// interleaved with each other
+		numDelegations := r.Intn(10) + 1
+		stakingTxHashes := make([]string, numDelegations)
+		for i := range stakingTxHashes {
+			stakingTxHashes[i] = bbndatagen.GenRandomBtcdHash(r).String()
+			ev := client.NewActiveStakingEvent(
+				stakingTxHashes[i], "", "", uint64(r.Int63()), uint64(r.Int63()),
+				r.Int63(), uint64(r.Int63()), 0, "", false)
+			err := kafkaConsumer.PushStakingEvent(&ev)
+			require.NoError(t, err)
+		}
+		expectedTopicsOfDelegation := make(map[string][]string)
+		for _, stakingTxHash := range stakingTxHashes {
+			expectedTopicsOfDelegation[stakingTxHash] = []string{topics[0]}
+		}
+		for _, i := range r.Perm(numDelegations) {
+			stakingTxHash := stakingTxHashes[i]
+			unbondingEv := client.NewUnbondingStakingEvent(stakingTxHash, uint64(r.Int63()), r.Int63(), 0, 0, "", "")
+			err := kafkaConsumer.PushUnbondingEvent(&unbondingEv)
+			require.NoError(t, err)
+			expiredEv := consumer.NewTimelockExpiredEvent(stakingTxHash, "", uint64(r.Int63()), r.Int63())
+			err = kafkaConsumer.PushTimelockExpiredEvent(&expiredEv)
+			require.NoError(t, err)
+			withdrawEv := client.NewWithdrawStakingEvent(stakingTxHash)
+			err = kafkaConsumer.PushWithdrawEvent(&withdrawEv)
+			require.NoError(t, err)
+			expectedTopicsOfDelegation[stakingTxHash] = append(expectedTopicsOfDelegation[stakingTxHash],
+				topics[1], topics[4], topics[2])
+		}
+		confirmedInfoEv := client.NewConfirmedInfoEvent(uint64(r.Int63()), uint64(r.Int63()))
+		err = kafkaConsumer.PushConfirmedInfoEvent(&confirmedInfoEv)
+		require.NoError(t, err)
+
+		err = kafkaConsumer.Stop()
+		require.NoError(t, err)
+
+		// consume all the published records
+		numRecords := numDelegations*4 + 1
+		reader, err := kgo.NewClient(
+			kgo.SeedBrokers(cfg.Brokers...),
+			kgo.ConsumeTopics(topics...),
+		)
+		require.NoError(t, err)
+		defer reader.Close()
+		var records []*kgo.Record
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		for len(records) < numRecords {
+			fetches := reader.PollFetches(ctx)
+			require.NoError(t, fetches.Err())
+			records = append(records, fetches.Records()...)
+		}
+		require.Len(t, records, numRecords)
+
+		// the records of a delegation are keyed by the staking tx hash, so
+		// they are in the same partition of each topic
+		partitionOfDelegation := make(map[string]int32)
+		topicsOfDelegation := make(map[string][]string)
+		stakingTxHashesInPartition := make(map[int32][]string)
+		for _, record := range records {
+			if record.Topic == topics[3] {
+				require.Nil(t, record.Key)
+				var ev client.ConfirmedInfoEvent
+				err := json.Unmarshal(record.Value, &ev)
+				require.NoError(t, err)
+				require.Equal(t, confirmedInfoEv, ev)
+				continue
+			}
+
+			var ev struct {
+				StakingTxHashHex string `json:"staking_tx_hash_hex"`
+			}
+			err := json.Unmarshal(record.Value, &ev)
+			require.NoError(t, err)
+			require.Equal(t, ev.StakingTxHashHex, string(record.Key))
+
+			if partition, ok := partitionOfDelegation[ev.StakingTxHashHex]; ok {
+				require.Equal(t, partition, record.Partition)
+			}
+			partitionOfDelegation[ev.StakingTxHashHex] = record.Partition
+			topicsOfDelegation[ev.StakingTxHashHex] = append(topicsOfDelegation[ev.StakingTxHashHex], record.Topic)
+			if record.Topic == topics[0] {
+				stakingTxHashesInPartition[record.Partition] = append(stakingTxHashesInPartition[record.Partition], ev.StakingTxHashHex)
+			}
+		}
+		for stakingTxHash, expectedTopics := range expectedTopicsOfDelegation {
+			require.ElementsMatch(t, expectedTopics, topicsOfDelegation[stakingTxHash])
+		}
+
+		// the records are in the order they were pushed within a partition
+		pushOrder := make(map[string]int)
+		for i, stakingTxHash := range stakingTxHashes {
+			pushOrder[stakingTxHash] = i
+		}
+		for _, hashes := range stakingTxHashesInPartition {
+			for i := 1; i < len(hashes); i++ {
+				require.Less(t, pushOrder[hashes[i-1]], pushOrder[hashes[i]])
+			}
+		}
+	})
+}
@@ -0,0 +1,88 @@
+package consumer
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	/* metrics sink */
+
+	totalReceivedEvents = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "si_sink_total_events",
+			Help: "Total number of events received by the metrics sink",
+		},
+		[]string{
+			"event_type",
+		},
+	)
+
+	lastBtcInfoHeight = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "si_sink_btc_info_height",
+		Help: "The BTC tip height of the last btc info event",
+	})
+
+	lastBtcInfoConfirmedTvl = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "si_sink_btc_info_confirmed_tvl",
+		Help: "The confirmed TVL in satoshis of the last btc info event",
+	})
+
+	lastBtcInfoUnconfirmedTvl = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "si_sink_btc_info_unconfirmed_tvl",
+		Help: "The unconfirmed TVL in satoshis of the last btc info event",
+	})
+
+	lastBtcInfoPendingTxs = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "si_sink_btc_info_pending_txs",
+			Help: "The number of txs pending in the unconfirmed blocks of the last btc info event",
+		},
+		[]string{
+			"tx_type",
+		},
+	)
+
+	lastConfirmedInfoHeight = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "si_sink_confirmed_info_height",
+		Help: "The confirmed BTC height of the last confirmed info event",
+	})
+
+	lastConfirmedInfoTvl = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "si_sink_confirmed_info_tvl",
+		Help: "The confirmed TVL in satoshis of the last confirmed info event",
+	})
+
+	/* fan-out */
+
+	failedSinkEventsCounter = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "si_fanout_failed_sink_events_counter",
+			Help: "Total number of events a sink of the fan-out consumer failed to take",
+		},
+		[]string{
+			"sink",
+			"failure_policy",
+		},
+	)
+
+	droppedSinkEventsCounter = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "si_fanout_dropped_sink_events_counter",
+			Help: "Total number of events dropped for a sink of the fan-out consumer",
+		},
+		[]string{
+			"sink",
+		},
+	)
+
+	bufferedSinkEvents = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "si_fanout_buffered_sink_events",
+			Help: "The number of events buffered for a sink of the fan-out consumer",
+		},
+		[]string{
+			"sink",
+		},
+	)
+)
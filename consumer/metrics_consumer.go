@@ -0,0 +1,87 @@
+package consumer
+
+import (
+	"github.com/babylonlabs-io/staking-queue-client/client"
+)
+
+var _ EventConsumer = (*MetricsConsumer)(nil)
+
+// MetricsConsumer is the implementation of EventConsumer that records the
+// events in the Prometheus metrics. It counts the events by type, and
+// exposes the heights, the tvl, and the pending tx numbers carried by the
+// btc info and the confirmed info events
+type MetricsConsumer struct{}
+
+func NewMetricsConsumer() *MetricsConsumer {
+	return &MetricsConsumer{}
+}
+
+func (mc *MetricsConsumer) Start() error {
+	return nil
+}
+
+func (mc *MetricsConsumer) PushStakingEvent(ev *client.ActiveStakingEvent) error {
+	countEvent(ev)
+	return nil
+}
+
+func (mc *MetricsConsumer) PushUnbondingEvent(ev *client.UnbondingStakingEvent) error {
+	countEvent(ev)
+	return nil
+}
+
+func (mc *MetricsConsumer) PushWithdrawEvent(ev *client.WithdrawStakingEvent) error {
+	countEvent(ev)
+	return nil
+}
+
+func (mc *MetricsConsumer) PushBtcInfoEvent(ev *BtcInfoEvent) error {
+	countEvent(ev)
+	lastBtcInfoHeight.Set(float64(ev.Height))
+	lastBtcInfoConfirmedTvl.Set(float64(ev.ConfirmedTvl))
+	lastBtcInfoUnconfirmedTvl.Set(float64(ev.UnconfirmedTvl))
+	lastBtcInfoPendingTxs.WithLabelValues("staking").Set(float64(ev.PendingStakingTxs))
+	lastBtcInfoPendingTxs.WithLabelValues("unbonding").Set(float64(ev.PendingUnbondingTxs))
+	lastBtcInfoPendingTxs.WithLabelValues("withdrawal").Set(float64(ev.PendingWithdrawalTxs))
+	return nil
+}
+
+func (mc *MetricsConsumer) PushConfirmedInfoEvent(ev *client.ConfirmedInfoEvent) error {
+	countEvent(ev)
+	lastConfirmedInfoHeight.Set(float64(ev.Height))
+	lastConfirmedInfoTvl.Set(float64(ev.Tvl))
+	return nil
+}
+
+func (mc *MetricsConsumer) PushRollbackEvent(ev *RollbackEvent) error {
+	countEvent(ev)
+	return nil
+}
+
+func (mc *MetricsConsumer) PushInvalidStakingEvent(ev *InvalidStakingEvent) error {
+	countEvent(ev)
+	return nil
+}
+
+func (mc *MetricsConsumer) PushSlashingEvent(ev *SlashingEvent) error {
+	countEvent(ev)
+	return nil
+}
+
+func (mc *MetricsConsumer) PushTimelockExpiredEvent(ev *TimelockExpiredEvent) error {
+	countEvent(ev)
+	return nil
+}
+
+func (mc *MetricsConsumer) PushFinalityProviderInfoEvent(ev *FinalityProviderInfoEvent) error {
+	countEvent(ev)
+	return nil
+}
+
+func (mc *MetricsConsumer) Stop() error {
+	return nil
+}
+
+func countEvent(ev client.EventMessage) {
+	totalReceivedEvents.WithLabelValues(EventTypeName(ev.GetEventType())).Inc()
+}
@@ -0,0 +1,280 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/babylonlabs-io/staking-queue-client/client"
+	"github.com/babylonlabs-io/staking-queue-client/config"
+	"github.com/babylonlabs-io/staking-queue-client/queuemngr"
+	"go.uber.org/zap"
+)
+
+var _ EnvelopeConsumer = (*QueueConsumer)(nil)
+
+// QueueConsumer is the RabbitMQ implementation of EventConsumer. It relies on
+// the queue manager of the staking queue client for the events defined there
+// and manages the queues of the events defined in this package. The events
+// pushed by the indexer are wrapped in envelopes, unless bare events are
+// configured for the consumers not supporting envelopes yet.
+type QueueConsumer struct {
+	*queuemngr.QueueManager
+
+	RollbackQueue             client.QueueClient
+	InvalidStakingQueue       client.QueueClient
+	SlashingQueue             client.QueueClient
+	TimelockExpiredQueue      client.QueueClient
+	FinalityProviderInfoQueue client.QueueClient
+
+	// bareEvents is whether the events are published without the envelopes
+	bareEvents bool
+
+	logger *zap.Logger
+}
+
+func NewQueueConsumer(cfg *config.QueueConfig, bareEvents bool, logger *zap.Logger) (*QueueConsumer, error) {
+	queueManager, err := queuemngr.NewQueueManager(cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	rollbackQueue, err := client.NewQueueClient(cfg, RollbackQueueName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create rollback queue: %w", err)
+	}
+
+	invalidStakingQueue, err := client.NewQueueClient(cfg, InvalidStakingQueueName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create invalid staking queue: %w", err)
+	}
+
+	slashingQueue, err := client.NewQueueClient(cfg, SlashingQueueName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create slashing queue: %w", err)
+	}
+
+	timelockExpiredQueue, err := client.NewQueueClient(cfg, TimelockExpiredQueueName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create timelock expired queue: %w", err)
+	}
+
+	finalityProviderInfoQueue, err := client.NewQueueClient(cfg, FinalityProviderInfoQueueName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create finality provider info queue: %w", err)
+	}
+
+	return &QueueConsumer{
+		QueueManager:              queueManager,
+		RollbackQueue:             rollbackQueue,
+		InvalidStakingQueue:       invalidStakingQueue,
+		SlashingQueue:             slashingQueue,
+		TimelockExpiredQueue:      timelockExpiredQueue,
+		FinalityProviderInfoQueue: finalityProviderInfoQueue,
+		bareEvents:                bareEvents,
+		logger:                    logger.With(zap.String("module", "queue consumer")),
+	}, nil
+}
+
+// PushBtcInfoEvent pushes the btc info event with the pending tx numbers to
+// the btc info queue of the queue manager
+func (qc *QueueConsumer) PushBtcInfoEvent(ev *BtcInfoEvent) error {
+	jsonBytes, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	messageBody := string(jsonBytes)
+
+	qc.logger.Info("pushing btc info event", zap.Uint64("height", ev.Height))
+	err = qc.BtcInfoQueue.SendMessage(context.TODO(), messageBody)
+	if err != nil {
+		return fmt.Errorf("failed to push btc info event: %w", err)
+	}
+	qc.logger.Info("successfully pushed btc info event", zap.Uint64("height", ev.Height))
+
+	return nil
+}
+
+func (qc *QueueConsumer) PushRollbackEvent(ev *RollbackEvent) error {
+	jsonBytes, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	messageBody := string(jsonBytes)
+
+	qc.logger.Info("pushing rollback event",
+		zap.String("tx_hash", ev.TxHashHex),
+		zap.String("tx_type", ev.TxType))
+	err = qc.RollbackQueue.SendMessage(context.TODO(), messageBody)
+	if err != nil {
+		return fmt.Errorf("failed to push rollback event: %w", err)
+	}
+	qc.logger.Info("successfully pushed rollback event", zap.String("tx_hash", ev.TxHashHex))
+
+	return nil
+}
+
+func (qc *QueueConsumer) PushInvalidStakingEvent(ev *InvalidStakingEvent) error {
+	jsonBytes, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	messageBody := string(jsonBytes)
+
+	qc.logger.Info("pushing invalid staking event",
+		zap.String("tx_hash", ev.StakingTxHashHex),
+		zap.String("reason", ev.Reason))
+	err = qc.InvalidStakingQueue.SendMessage(context.TODO(), messageBody)
+	if err != nil {
+		return fmt.Errorf("failed to push invalid staking event: %w", err)
+	}
+	qc.logger.Info("successfully pushed invalid staking event", zap.String("tx_hash", ev.StakingTxHashHex))
+
+	return nil
+}
+
+func (qc *QueueConsumer) PushSlashingEvent(ev *SlashingEvent) error {
+	jsonBytes, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	messageBody := string(jsonBytes)
+
+	qc.logger.Info("pushing slashing event",
+		zap.String("tx_hash", ev.SlashingTxHashHex),
+		zap.String("staking_tx_hash", ev.StakingTxHashHex))
+	err = qc.SlashingQueue.SendMessage(context.TODO(), messageBody)
+	if err != nil {
+		return fmt.Errorf("failed to push slashing event: %w", err)
+	}
+	qc.logger.Info("successfully pushed slashing event", zap.String("tx_hash", ev.SlashingTxHashHex))
+
+	return nil
+}
+
+func (qc *QueueConsumer) PushTimelockExpiredEvent(ev *TimelockExpiredEvent) error {
+	jsonBytes, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	messageBody := string(jsonBytes)
+
+	qc.logger.Info("pushing timelock expired event",
+		zap.String("staking_tx_hash", ev.StakingTxHashHex),
+		zap.Uint64("expiry_height", ev.ExpiryHeight))
+	err = qc.TimelockExpiredQueue.SendMessage(context.TODO(), messageBody)
+	if err != nil {
+		return fmt.Errorf("failed to push timelock expired event: %w", err)
+	}
+	qc.logger.Info("successfully pushed timelock expired event", zap.String("staking_tx_hash", ev.StakingTxHashHex))
+
+	return nil
+}
+
+func (qc *QueueConsumer) PushFinalityProviderInfoEvent(ev *FinalityProviderInfoEvent) error {
+	jsonBytes, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	messageBody := string(jsonBytes)
+
+	qc.logger.Info("pushing finality provider info event",
+		zap.String("finality_provider_pk", ev.FinalityProviderPkHex),
+		zap.Uint64("height", ev.Height))
+	err = qc.FinalityProviderInfoQueue.SendMessage(context.TODO(), messageBody)
+	if err != nil {
+		return fmt.Errorf("failed to push finality provider info event: %w", err)
+	}
+	qc.logger.Info("successfully pushed finality provider info event", zap.String("finality_provider_pk", ev.FinalityProviderPkHex))
+
+	return nil
+}
+
+// PushEventEnvelope publishes the envelope to the queue of the wrapped event.
+// If bare events are configured, the wrapped event is published instead, as
+// defined by the staking queue client
+func (qc *QueueConsumer) PushEventEnvelope(env *EventEnvelope) error {
+	if qc.bareEvents {
+		ev, err := env.DecodeEvent()
+		if err != nil {
+			return err
+		}
+		return PushEvent(qc, ev)
+	}
+
+	queue, err := qc.eventQueue(env.EventType)
+	if err != nil {
+		return err
+	}
+
+	jsonBytes, err := json.Marshal(env)
+	if err != nil {
+		return err
+	}
+	messageBody := string(jsonBytes)
+
+	eventName := EventTypeName(env.EventType)
+	qc.logger.Info(fmt.Sprintf("pushing %s event", eventName),
+		zap.Uint64("sequence", env.Sequence),
+		zap.String("idempotency_key", env.IdempotencyKey))
+	err = queue.SendMessage(context.TODO(), messageBody)
+	if err != nil {
+		return fmt.Errorf("failed to push %s event: %w", eventName, err)
+	}
+	qc.logger.Info(fmt.Sprintf("successfully pushed %s event", eventName),
+		zap.Uint64("sequence", env.Sequence))
+
+	return nil
+}
+
+// eventQueue returns the queue the events of the given type are published to
+func (qc *QueueConsumer) eventQueue(eventType client.EventType) (client.QueueClient, error) {
+	switch eventType {
+	case client.ActiveStakingEventType:
+		return qc.StakingQueue, nil
+	case client.UnbondingStakingEventType:
+		return qc.UnbondingQueue, nil
+	case client.WithdrawStakingEventType:
+		return qc.WithdrawQueue, nil
+	case client.BtcInfoEventType:
+		return qc.BtcInfoQueue, nil
+	case client.ConfirmedInfoEventType:
+		return qc.ConfirmedInfoQueue, nil
+	case RollbackEventType:
+		return qc.RollbackQueue, nil
+	case InvalidStakingEventType:
+		return qc.InvalidStakingQueue, nil
+	case SlashingEventType:
+		return qc.SlashingQueue, nil
+	case TimelockExpiredEventType:
+		return qc.TimelockExpiredQueue, nil
+	case FinalityProviderInfoEventType:
+		return qc.FinalityProviderInfoQueue, nil
+	default:
+		return nil, fmt.Errorf("unknown event type %d", eventType)
+	}
+}
+
+func (qc *QueueConsumer) Stop() error {
+	if err := qc.QueueManager.Stop(); err != nil {
+		return err
+	}
+
+	if err := qc.RollbackQueue.Stop(); err != nil {
+		return err
+	}
+
+	if err := qc.InvalidStakingQueue.Stop(); err != nil {
+		return err
+	}
+
+	if err := qc.SlashingQueue.Stop(); err != nil {
+		return err
+	}
+
+	if err := qc.TimelockExpiredQueue.Stop(); err != nil {
+		return err
+	}
+
+	return qc.FinalityProviderInfoQueue.Stop()
+}
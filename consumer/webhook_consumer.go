@@ -0,0 +1,381 @@
+package consumer
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/babylonlabs-io/staking-queue-client/client"
+	"go.uber.org/zap"
+
+	"github.com/babylonlabs-io/staking-indexer/config"
+)
+
+const (
+	// WebhookSignatureHeader carries the hex encoded HMAC-SHA256 of the
+	// timestamp and the payload, prefixed with "sha256="
+	WebhookSignatureHeader = "X-Sid-Signature"
+	// WebhookTimestampHeader carries the unix time at which the request was
+	// signed, so that the receivers can reject replayed requests
+	WebhookTimestampHeader = "X-Sid-Timestamp"
+	// WebhookEventTypeHeader carries the type of the posted event
+	WebhookEventTypeHeader = "X-Sid-Event-Type"
+	// WebhookIdempotencyKeyHeader carries the idempotency key of the posted
+	// envelope
+	WebhookIdempotencyKeyHeader = "Idempotency-Key"
+)
+
+var _ EnvelopeConsumer = (*WebhookConsumer)(nil)
+
+// errWebhookStopped is returned when the consumer stops while retrying
+var errWebhookStopped = errors.New("webhook consumer is stopped")
+
+// WebhookConsumer is the HTTP webhook implementation of EventConsumer. Each
+// event is posted as JSON to every configured endpoint, wrapped in an
+// envelope if it is pushed by the indexer, and the failed
+// requests are retried with exponential backoff. The events that cannot be
+// delivered to an endpoint are appended to the dead letter file.
+// The btc info events are pushed for every new block by the indexer, so they
+// are posted in the background without retries, and only the latest one is
+// posted if an endpoint is slow, as it supersedes the previous ones.
+type WebhookConsumer struct {
+	cfg        *config.WebhookConfig
+	httpClient *http.Client
+
+	// deadLetterMu serializes the writes to the dead letter file
+	deadLetterMu sync.Mutex
+
+	// btcInfoMu guards the latest btc info event waiting to be posted
+	btcInfoMu      sync.Mutex
+	pendingBtcInfo client.EventMessage
+	// btcInfoNotify wakes up the btc info poster when an event is pushed
+	btcInfoNotify chan struct{}
+
+	logger *zap.Logger
+
+	startOnce sync.Once
+	stopOnce  sync.Once
+	wg        sync.WaitGroup
+	quit      chan struct{}
+}
+
+// WebhookDeadLetter is an event that could not be delivered to an endpoint,
+// as appended to the dead letter file, one per line
+type WebhookDeadLetter struct {
+	Endpoint  string           `json:"endpoint"`
+	EventType client.EventType `json:"event_type"`
+	Payload   json.RawMessage  `json:"payload"`
+	Error     string           `json:"error"`
+	FailedAt  time.Time        `json:"failed_at"`
+}
+
+func NewWebhookConsumer(cfg *config.WebhookConfig, logger *zap.Logger) (*WebhookConsumer, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid webhook config: %w", err)
+	}
+
+	return &WebhookConsumer{
+		cfg:           cfg,
+		httpClient:    &http.Client{Timeout: cfg.Timeout},
+		btcInfoNotify: make(chan struct{}, 1),
+		logger:        logger.With(zap.String("module", "webhook consumer")),
+		quit:          make(chan struct{}),
+	}, nil
+}
+
+// SignWebhookPayload returns the value of the signature header of a request
+// posting the payload at the given unix timestamp
+func SignWebhookPayload(secret string, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Start starts posting the btc info events in the background
+func (wc *WebhookConsumer) Start() error {
+	wc.startOnce.Do(func() {
+		wc.wg.Add(1)
+		go wc.btcInfoLoop()
+	})
+
+	return nil
+}
+
+func (wc *WebhookConsumer) PushStakingEvent(ev *client.ActiveStakingEvent) error {
+	return wc.post("active staking", ev)
+}
+
+func (wc *WebhookConsumer) PushUnbondingEvent(ev *client.UnbondingStakingEvent) error {
+	return wc.post("unbonding", ev)
+}
+
+func (wc *WebhookConsumer) PushWithdrawEvent(ev *client.WithdrawStakingEvent) error {
+	return wc.post("withdraw", ev)
+}
+
+// PushBtcInfoEvent queues the event to be posted in the background, replacing
+// the one not posted yet
+func (wc *WebhookConsumer) PushBtcInfoEvent(ev *BtcInfoEvent) error {
+	wc.queueBtcInfo(ev)
+	return nil
+}
+
+func (wc *WebhookConsumer) PushConfirmedInfoEvent(ev *client.ConfirmedInfoEvent) error {
+	return wc.post("confirmed info", ev)
+}
+
+func (wc *WebhookConsumer) PushRollbackEvent(ev *RollbackEvent) error {
+	return wc.post("rollback", ev)
+}
+
+func (wc *WebhookConsumer) PushInvalidStakingEvent(ev *InvalidStakingEvent) error {
+	return wc.post("invalid staking", ev)
+}
+
+func (wc *WebhookConsumer) PushSlashingEvent(ev *SlashingEvent) error {
+	return wc.post("slashing", ev)
+}
+
+func (wc *WebhookConsumer) PushTimelockExpiredEvent(ev *TimelockExpiredEvent) error {
+	return wc.post("timelock expired", ev)
+}
+
+func (wc *WebhookConsumer) PushFinalityProviderInfoEvent(ev *FinalityProviderInfoEvent) error {
+	return wc.post("finality provider info", ev)
+}
+
+// PushEventEnvelope posts the envelope to all the endpoints. The envelope of
+// a btc info event is queued to be posted in the background
+func (wc *WebhookConsumer) PushEventEnvelope(env *EventEnvelope) error {
+	if env.EventType == client.BtcInfoEventType {
+		wc.queueBtcInfo(env)
+		return nil
+	}
+
+	return wc.post(EventTypeName(env.EventType), env)
+}
+
+// Stop aborts the pending retries and the btc info event being posted
+func (wc *WebhookConsumer) Stop() error {
+	wc.stopOnce.Do(func() {
+		close(wc.quit)
+		wc.wg.Wait()
+	})
+
+	return nil
+}
+
+// queueBtcInfo replaces the btc info event waiting to be posted, and wakes up
+// the btc info poster without blocking
+func (wc *WebhookConsumer) queueBtcInfo(ev client.EventMessage) {
+	wc.btcInfoMu.Lock()
+	wc.pendingBtcInfo = ev
+	wc.btcInfoMu.Unlock()
+
+	select {
+	case wc.btcInfoNotify <- struct{}{}:
+	default:
+	}
+}
+
+// btcInfoLoop posts the latest btc info event to all the endpoints whenever
+// one is pushed
+func (wc *WebhookConsumer) btcInfoLoop() {
+	defer wc.wg.Done()
+
+	// the request being posted is aborted once the consumer stops
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-wc.quit
+		cancel()
+	}()
+
+	for {
+		select {
+		case <-wc.btcInfoNotify:
+			wc.btcInfoMu.Lock()
+			ev := wc.pendingBtcInfo
+			wc.pendingBtcInfo = nil
+			wc.btcInfoMu.Unlock()
+
+			if ev != nil {
+				wc.postBtcInfo(ctx, ev)
+			}
+		case <-wc.quit:
+			return
+		}
+	}
+}
+
+// postBtcInfo posts the btc info event to all the endpoints once. A failed
+// request is not retried nor written to the dead letter file, as the event is
+// superseded by the one of the next block
+func (wc *WebhookConsumer) postBtcInfo(ctx context.Context, ev client.EventMessage) {
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		wc.logger.Error("failed to marshal the btc info event", zap.Error(err))
+		return
+	}
+
+	for _, endpoint := range wc.cfg.Endpoints {
+		if _, err := wc.postOnce(ctx, endpoint, ev, payload); err != nil {
+			wc.logger.Warn("failed to push btc info event",
+				zap.String("endpoint", endpoint),
+				zap.Error(err))
+			continue
+		}
+
+		wc.logger.Debug("successfully pushed btc info event",
+			zap.String("endpoint", endpoint))
+	}
+}
+
+// post delivers the event to all the endpoints. An event that cannot be
+// delivered to an endpoint is appended to the dead letter file, and an error
+// is returned only if the dead letter cannot be written
+func (wc *WebhookConsumer) post(eventName string, ev client.EventMessage) error {
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+
+	for _, endpoint := range wc.cfg.Endpoints {
+		wc.logger.Info(fmt.Sprintf("pushing %s event", eventName),
+			zap.String("endpoint", endpoint),
+			zap.String("staking_tx_hash", ev.GetStakingTxHashHex()))
+
+		deliveryErr := wc.postWithRetries(endpoint, ev, payload)
+		if errors.Is(deliveryErr, errWebhookStopped) {
+			return fmt.Errorf("failed to push %s event: %w", eventName, deliveryErr)
+		}
+		if deliveryErr != nil {
+			wc.logger.Error(fmt.Sprintf("failed to push %s event, writing it to the dead letter file", eventName),
+				zap.String("endpoint", endpoint),
+				zap.String("staking_tx_hash", ev.GetStakingTxHashHex()),
+				zap.Error(deliveryErr))
+			if err := wc.writeDeadLetter(endpoint, ev.GetEventType(), payload, deliveryErr); err != nil {
+				return fmt.Errorf("failed to write the undeliverable %s event to the dead letter file: %w", eventName, err)
+			}
+			continue
+		}
+
+		wc.logger.Info(fmt.Sprintf("successfully pushed %s event", eventName),
+			zap.String("endpoint", endpoint),
+			zap.String("staking_tx_hash", ev.GetStakingTxHashHex()))
+	}
+
+	return nil
+}
+
+// postWithRetries posts the payload to the endpoint, and retries with
+// exponential backoff if the request fails with a network error, a server
+// error, or a rate limit
+func (wc *WebhookConsumer) postWithRetries(endpoint string, ev client.EventMessage, payload []byte) error {
+	backoff := wc.cfg.InitialBackoff
+
+	var err error
+	for attempt := 0; ; attempt++ {
+		var retryable bool
+		retryable, err = wc.postOnce(context.Background(), endpoint, ev, payload)
+		if err == nil {
+			return nil
+		}
+		if !retryable || attempt >= wc.cfg.MaxRetries {
+			return err
+		}
+
+		wc.logger.Warn("failed to post the event, retrying",
+			zap.String("endpoint", endpoint),
+			zap.Int("attempt", attempt+1),
+			zap.Duration("backoff", backoff),
+			zap.Error(err))
+
+		select {
+		case <-time.After(backoff):
+		case <-wc.quit:
+			return errWebhookStopped
+		}
+		backoff = min(backoff*2, wc.cfg.MaxBackoff)
+	}
+}
+
+// postOnce posts the signed payload to the endpoint, and returns whether the
+// request can be retried if it fails
+func (wc *WebhookConsumer) postOnce(ctx context.Context, endpoint string, ev client.EventMessage, payload []byte) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, wc.cfg.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return false, err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookTimestampHeader, timestamp)
+	req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(wc.cfg.Secret, timestamp, payload))
+	req.Header.Set(WebhookEventTypeHeader, strconv.Itoa(int(ev.GetEventType())))
+	if env, ok := ev.(*EventEnvelope); ok {
+		req.Header.Set(WebhookIdempotencyKeyHeader, env.IdempotencyKey)
+	}
+
+	resp, err := wc.httpClient.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	// drain the body so that the connection can be reused
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+
+	err = fmt.Errorf("unexpected status %s", resp.Status)
+	retryable := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+
+	return retryable, err
+}
+
+func (wc *WebhookConsumer) writeDeadLetter(endpoint string, eventType client.EventType, payload []byte, deliveryErr error) error {
+	line, err := json.Marshal(&WebhookDeadLetter{
+		Endpoint:  endpoint,
+		EventType: eventType,
+		Payload:   payload,
+		Error:     deliveryErr.Error(),
+		FailedAt:  time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+
+	wc.deadLetterMu.Lock()
+	defer wc.deadLetterMu.Unlock()
+
+	f, err := os.OpenFile(wc.cfg.DeadLetterFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,283 @@
+package consumer_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	bbndatagen "github.com/babylonlabs-io/babylon/testutil/datagen"
+	"github.com/babylonlabs-io/staking-queue-client/client"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/babylonlabs-io/staking-indexer/config"
+	"github.com/babylonlabs-io/staking-indexer/consumer"
+)
+
+// webhookReceiver is an endpoint that verifies the signatures of the posted
+// events and fails the first numFailures requests with the given status
+type webhookReceiver struct {
+	t             *testing.T
+	secret        string
+	numFailures   int
+	failureStatus int
+
+	mu          sync.Mutex
+	numRequests int
+	payloads    [][]byte
+	eventTypes  []client.EventType
+}
+
+func (wr *webhookReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	payload, err := io.ReadAll(req.Body)
+	require.NoError(wr.t, err)
+	require.Equal(wr.t, http.MethodPost, req.Method)
+	require.Equal(wr.t, "application/json", req.Header.Get("Content-Type"))
+	timestamp := req.Header.Get(consumer.WebhookTimestampHeader)
+	require.Equal(wr.t,
+		consumer.SignWebhookPayload(wr.secret, timestamp, payload),
+		req.Header.Get(consumer.WebhookSignatureHeader))
+	eventType, err := strconv.Atoi(req.Header.Get(consumer.WebhookEventTypeHeader))
+	require.NoError(wr.t, err)
+
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+	wr.numRequests++
+	if wr.numRequests <= wr.numFailures {
+		w.WriteHeader(wr.failureStatus)
+		return
+	}
+	wr.payloads = append(wr.payloads, payload)
+	wr.eventTypes = append(wr.eventTypes, client.EventType(eventType))
+}
+
+func newTestWebhookConfig(t *testing.T, r *rand.Rand, endpoints ...string) *config.WebhookConfig {
+	cfg := config.DefaultWebhookConfigWithHomePath(t.TempDir())
+	cfg.Endpoints = endpoints
+	cfg.Secret = bbndatagen.GenRandomHexStr(r, 32)
+	cfg.InitialBackoff = time.Millisecond
+	cfg.MaxBackoff = 4 * time.Millisecond
+	cfg.MaxRetries = 5
+	return cfg
+}
+
+func readDeadLetters(t *testing.T, path string) []*consumer.WebhookDeadLetter {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	require.NoError(t, err)
+	defer f.Close()
+
+	var deadLetters []*consumer.WebhookDeadLetter
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var deadLetter consumer.WebhookDeadLetter
+		err := json.Unmarshal(scanner.Bytes(), &deadLetter)
+		require.NoError(t, err)
+		deadLetters = append(deadLetters, &deadLetter)
+	}
+	require.NoError(t, scanner.Err())
+
+	return deadLetters
+}
+
+// FuzzWebhookConsumer tests that the signed events are posted to all the
+// endpoints, and the failed requests are retried until they succeed
+func FuzzWebhookConsumer(f *testing.F) {
+	bbndatagen.AddRandomSeedsToFuzzer(f, 10)
+
+	f.Fuzz(func(t *testing.T, seed int64) {
+		r := rand.New(rand.NewSource(seed))
+
+		secret := bbndatagen.GenRandomHexStr(r, 32)
+		failureStatuses := []int{http.StatusInternalServerError, http.StatusServiceUnavailable, http.StatusTooManyRequests}
+		receivers := make([]*webhookReceiver, r.Intn(3)+1)
+		endpoints := make([]string, len(receivers))
+		for i := range receivers {
+			receivers[i] = &webhookReceiver{
+				t:      t,
+				secret: secret,
+				// fail at most max retries times so that the events
+				// are eventually delivered
+				numFailures:   r.Intn(6),
+				failureStatus: failureStatuses[r.Intn(len(failureStatuses))],
+			}
+			server := httptest.NewServer(receivers[i])
+			defer server.Close()
+			endpoints[i] = server.URL
+		}
+		cfg := newTestWebhookConfig(t, r, endpoints...)
+		cfg.Secret = secret
+
+		webhookConsumer, err := consumer.NewWebhookConsumer(cfg, zap.NewNop())
+		require.NoError(t, err)
+		err = webhookConsumer.Start()
+		require.NoError(t, err)
+		defer func() {
+			err := webhookConsumer.Stop()
+			require.NoError(t, err)
+		}()
+
+		stakingTxHash := bbndatagen.GenRandomBtcdHash(r).String()
+		stakingEv := client.NewActiveStakingEvent(
+			stakingTxHash, "", "", uint64(r.Int63()), uint64(r.Int63()),
+			r.Int63(), uint64(r.Int63()), 0, "", false)
+		err = webhookConsumer.PushStakingEvent(&stakingEv)
+		require.NoError(t, err)
+		unbondingEv := client.NewUnbondingStakingEvent(stakingTxHash, uint64(r.Int63()), r.Int63(), 0, 0, "", "")
+		err = webhookConsumer.PushUnbondingEvent(&unbondingEv)
+		require.NoError(t, err)
+		withdrawEv := client.NewWithdrawStakingEvent(stakingTxHash)
+		err = webhookConsumer.PushWithdrawEvent(&withdrawEv)
+		require.NoError(t, err)
+		confirmedInfoEv := client.NewConfirmedInfoEvent(uint64(r.Int63()), uint64(r.Int63()))
+		err = webhookConsumer.PushConfirmedInfoEvent(&confirmedInfoEv)
+		require.NoError(t, err)
+
+		expectedEvents := []client.EventMessage{&stakingEv, &unbondingEv, &withdrawEv, &confirmedInfoEv}
+		for _, receiver := range receivers {
+			require.Equal(t, receiver.numFailures+len(expectedEvents), receiver.numRequests)
+			require.Len(t, receiver.payloads, len(expectedEvents))
+			for i, ev := range expectedEvents {
+				expectedPayload, err := json.Marshal(ev)
+				require.NoError(t, err)
+				require.JSONEq(t, string(expectedPayload), string(receiver.payloads[i]))
+				require.Equal(t, ev.GetEventType(), receiver.eventTypes[i])
+			}
+		}
+		require.Empty(t, readDeadLetters(t, cfg.DeadLetterFile))
+	})
+}
+
+func TestWebhookConsumerDeadLetter(t *testing.T) {
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	// the first endpoint keeps failing, the second one rejects the events,
+	// and the third one accepts them
+	failingReceiver := &webhookReceiver{t: t, numFailures: 1000, failureStatus: http.StatusBadGateway}
+	rejectingReceiver := &webhookReceiver{t: t, numFailures: 1000, failureStatus: http.StatusBadRequest}
+	acceptingReceiver := &webhookReceiver{t: t}
+	var endpoints []string
+	for _, receiver := range []*webhookReceiver{failingReceiver, rejectingReceiver, acceptingReceiver} {
+		server := httptest.NewServer(receiver)
+		defer server.Close()
+		endpoints = append(endpoints, server.URL)
+	}
+	cfg := newTestWebhookConfig(t, r, endpoints...)
+	for _, receiver := range []*webhookReceiver{failingReceiver, rejectingReceiver, acceptingReceiver} {
+		receiver.secret = cfg.Secret
+	}
+	cfg.DeadLetterFile = filepath.Join(t.TempDir(), "dead-letters.jsonl")
+
+	webhookConsumer, err := consumer.NewWebhookConsumer(cfg, zap.NewNop())
+	require.NoError(t, err)
+
+	// the undeliverable event does not fail the push
+	withdrawEv := client.NewWithdrawStakingEvent(bbndatagen.GenRandomBtcdHash(r).String())
+	err = webhookConsumer.PushWithdrawEvent(&withdrawEv)
+	require.NoError(t, err)
+
+	// the server errors are retried, the rejections are not
+	require.Equal(t, cfg.MaxRetries+1, failingReceiver.numRequests)
+	require.Equal(t, 1, rejectingReceiver.numRequests)
+	require.Len(t, acceptingReceiver.payloads, 1)
+
+	deadLetters := readDeadLetters(t, cfg.DeadLetterFile)
+	require.Len(t, deadLetters, 2)
+	expectedPayload, err := json.Marshal(&withdrawEv)
+	require.NoError(t, err)
+	for i, deadLetter := range deadLetters {
+		require.Equal(t, endpoints[i], deadLetter.Endpoint)
+		require.Equal(t, client.WithdrawStakingEventType, deadLetter.EventType)
+		require.JSONEq(t, string(expectedPayload), string(deadLetter.Payload))
+		require.NotEmpty(t, deadLetter.Error)
+	}
+
+	// the retries are aborted once the consumer is stopped
+	cfg.InitialBackoff = time.Hour
+	cfg.MaxBackoff = time.Hour
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		err := webhookConsumer.Stop()
+		require.NoError(t, err)
+	}()
+	err = webhookConsumer.PushWithdrawEvent(&withdrawEv)
+	require.Error(t, err)
+	require.Len(t, readDeadLetters(t, cfg.DeadLetterFile), 2)
+}
+
+// TestWebhookConsumerHangingEndpoint tests that the btc info events pushed for
+// the new blocks do not wait for an endpoint that hangs, and the latest one is
+// posted once the endpoint recovers
+func TestWebhookConsumerHangingEndpoint(t *testing.T) {
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	receiver := &webhookReceiver{t: t}
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		<-release
+		receiver.ServeHTTP(w, req)
+	}))
+	defer server.Close()
+	cfg := newTestWebhookConfig(t, r, server.URL)
+	cfg.Timeout = time.Minute
+	receiver.secret = cfg.Secret
+
+	webhookConsumer, err := consumer.NewWebhookConsumer(cfg, zap.NewNop())
+	require.NoError(t, err)
+	err = webhookConsumer.Start()
+	require.NoError(t, err)
+	defer func() {
+		err := webhookConsumer.Stop()
+		require.NoError(t, err)
+	}()
+
+	// the pushes of the blocks do not wait for the endpoint
+	numBlocks := r.Intn(10) + 2
+	pushed := make(chan *consumer.EventEnvelope)
+	go func() {
+		defer close(pushed)
+		for i := 0; i < numBlocks; i++ {
+			height := uint64(i + 1)
+			btcInfoEv := consumer.NewBtcInfoEvent(height, uint64(r.Int63()), uint64(r.Int63()), 1, 2, 3)
+			env, err := consumer.WrapUnsequencedEvent(height, bbndatagen.GenRandomBtcdHash(r).String(), &btcInfoEv)
+			require.NoError(t, err)
+			err = webhookConsumer.PushEventEnvelope(env)
+			require.NoError(t, err)
+			pushed <- env
+		}
+	}()
+	var lastEnv *consumer.EventEnvelope
+	for i := 0; i < numBlocks; i++ {
+		select {
+		case lastEnv = <-pushed:
+		case <-time.After(5 * time.Second):
+			t.Fatal("the btc info event waits for the hanging endpoint")
+		}
+	}
+
+	// only the event being posted and the latest one are delivered
+	close(release)
+	expectedPayload, err := json.Marshal(lastEnv)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		receiver.mu.Lock()
+		defer receiver.mu.Unlock()
+		return len(receiver.payloads) > 0 &&
+			string(receiver.payloads[len(receiver.payloads)-1]) == string(expectedPayload)
+	}, 5*time.Second, 10*time.Millisecond)
+	receiver.mu.Lock()
+	defer receiver.mu.Unlock()
+	require.LessOrEqual(t, receiver.numRequests, 2)
+	require.Empty(t, readDeadLetters(t, cfg.DeadLetterFile))
+}
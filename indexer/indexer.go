@@ -36,10 +36,24 @@ type StakingIndexer struct {
 	cfg    *config.Config
 	logger *zap.Logger
 
-	is *indexerstore.IndexerStore
+	is indexerstore.Store
 
 	btcScanner btcscanner.BtcScanner
 
+	// outboxNotify wakes up the outbox publisher when new events are committed
+	outboxNotify chan struct{}
+	// pushedOutboxSequence is the sequence of the last event in the outbox
+	// pushed to an AckingConsumer, which may not be acked yet. It is only
+	// accessed by the outbox publisher
+	pushedOutboxSequence uint64
+	// updates notifies the subscribers of the new events
+	updates *eventUpdates
+
+	// blockHeight and blockHash identify the block whose processing causes
+	// the events written to the outbox, blockHash is nil if it is unknown
+	blockHeight uint64
+	blockHash   *chainhash.Hash
+
 	wg   sync.WaitGroup
 	quit chan struct{}
 }
@@ -57,6 +71,19 @@ func NewStakingIndexer(
 		return nil, fmt.Errorf("failed to initiate staking indexer store: %w", err)
 	}
 
+	return NewStakingIndexerWithStore(cfg, logger, consumer, is, paramsVersions, btcScanner), nil
+}
+
+// NewStakingIndexerWithStore returns a staking indexer keeping its state in
+// the given store, which can be of any backend
+func NewStakingIndexerWithStore(
+	cfg *config.Config,
+	logger *zap.Logger,
+	consumer consumer.EventConsumer,
+	is indexerstore.Store,
+	paramsVersions *parser.ParsedGlobalParams,
+	btcScanner btcscanner.BtcScanner,
+) *StakingIndexer {
 	return &StakingIndexer{
 		cfg:            cfg,
 		logger:         logger.With(zap.String("module", "staking indexer")),
@@ -64,8 +91,10 @@ func NewStakingIndexer(
 		is:             is,
 		paramsVersions: paramsVersions,
 		btcScanner:     btcScanner,
+		outboxNotify:   make(chan struct{}, 1),
+		updates:        newEventUpdates(),
 		quit:           make(chan struct{}),
-	}, nil
+	}
 }
 
 // Start starts the staking indexer core
@@ -74,8 +103,9 @@ func (si *StakingIndexer) Start(startHeight uint64) error {
 	si.startOnce.Do(func() {
 		si.logger.Info("Starting Staking Indexer App")
 
-		si.wg.Add(1)
+		si.wg.Add(2)
 		go si.blocksEventLoop()
+		go si.outboxPublisherLoop()
 
 		if err := si.ValidateStartHeight(startHeight); err != nil {
 			startErr = fmt.Errorf("invalid start height %d: %w", startHeight, err)
@@ -138,11 +168,30 @@ func (si *StakingIndexer) blocksEventLoop() {
 	for {
 		select {
 		case update := <-si.btcScanner.ChainUpdateInfoChan():
+			if update.ForkHeight != nil {
+				si.logger.Warn("received a major reorg",
+					zap.Uint64("fork_height", *update.ForkHeight))
+
+				if err := si.RollbackToHeight(*update.ForkHeight); err != nil {
+					// this indicates systematic failure
+					si.logger.Fatal("failed to roll back",
+						zap.Uint64("fork_height", *update.ForkHeight),
+						zap.Error(err))
+				}
+			}
+
 			confirmedBlocks := update.ConfirmedBlocks
 			for _, block := range confirmedBlocks {
 				si.logger.Info("received confirmed block",
 					zap.Int32("height", block.Height))
 
+				// the events of the block are buffered in the outbox while
+				// the consumer is unavailable, up to the configured limit
+				if !si.waitForOutboxCapacity() {
+					si.logger.Info("closing the confirmed blocks loop")
+					return
+				}
+
 				if err := si.HandleConfirmedBlock(block); err != nil {
 					// this indicates systematic failure
 					si.logger.Fatal("failed to handle block",
@@ -167,8 +216,8 @@ func (si *StakingIndexer) blocksEventLoop() {
 
 // processUnconfirmedInfo processes information from given unconfirmed blocks
 // It follows the steps below:
-// 1. iterate all txs of each unconfirmed block to identify staking and unbonding transactions,
-// and calculate total unconfirmed tvl
+// 1. iterate all txs of each unconfirmed block to identify staking, unbonding,
+// and withdrawal transactions, and calculate total unconfirmed tvl
 // 2. get the current confirmed tvl
 // 3. push unconfirmed info event to the queue
 // 4. record metrics
@@ -185,10 +234,11 @@ func (si *StakingIndexer) processUnconfirmedInfo(unconfirmedBlocks []*types.Inde
 
 	tipBlockCache := unconfirmedBlocks[len(unconfirmedBlocks)-1]
 
-	tvlInUnconfirmedBlocks, err := si.CalculateTvlInUnconfirmedBlocks(unconfirmedBlocks)
+	unconfirmedBlocksInfo, err := si.CalculateTvlInUnconfirmedBlocks(unconfirmedBlocks)
 	if err != nil {
 		return fmt.Errorf("failed to calculate unconfirmed tvl: %w", err)
 	}
+	tvlInUnconfirmedBlocks := unconfirmedBlocksInfo.Tvl
 
 	confirmedTvl, err := si.GetConfirmedTvl()
 	if err != nil {
@@ -204,10 +254,20 @@ func (si *StakingIndexer) processUnconfirmedInfo(unconfirmedBlocks []*types.Inde
 		zap.Int32("tip_height", tipBlockCache.Height),
 		zap.Uint64("confirmed_tvl", confirmedTvl),
 		zap.Int64("tvl_in_unconfirmed_blocks", int64(tvlInUnconfirmedBlocks)),
-		zap.Int64("unconfirmed_tvl", int64(unconfirmedTvl)))
-
-	btcInfoEvent := queuecli.NewBtcInfoEvent(uint64(tipBlockCache.Height), confirmedTvl, uint64(unconfirmedTvl))
-	if err := si.consumer.PushBtcInfoEvent(&btcInfoEvent); err != nil {
+		zap.Int64("unconfirmed_tvl", int64(unconfirmedTvl)),
+		zap.Uint64("pending_staking_txs", unconfirmedBlocksInfo.NumStakingTxs),
+		zap.Uint64("pending_unbonding_txs", unconfirmedBlocksInfo.NumUnbondingTxs),
+		zap.Uint64("pending_withdrawal_txs", unconfirmedBlocksInfo.NumWithdrawalTxs))
+
+	btcInfoEvent := consumer.NewBtcInfoEvent(
+		uint64(tipBlockCache.Height),
+		confirmedTvl,
+		uint64(unconfirmedTvl),
+		unconfirmedBlocksInfo.NumStakingTxs,
+		unconfirmedBlocksInfo.NumUnbondingTxs,
+		unconfirmedBlocksInfo.NumWithdrawalTxs,
+	)
+	if err := si.pushBtcInfoEvent(&btcInfoEvent, tipBlockCache); err != nil {
 		return fmt.Errorf("failed to push the unconfirmed event: %w", err)
 	}
 
@@ -217,13 +277,53 @@ func (si *StakingIndexer) processUnconfirmedInfo(unconfirmedBlocks []*types.Inde
 	return nil
 }
 
-func (si *StakingIndexer) CalculateTvlInUnconfirmedBlocks(unconfirmedBlocks []*types.IndexedBlock) (btcutil.Amount, error) {
-	tvl := btcutil.Amount(0)
+// pushBtcInfoEvent pushes the btc info event of the given tip block to the
+// consumer and the subscribers directly, as it describes the unconfirmed tip
+// and is superseded by the next one. It is wrapped in an unsequenced envelope
+// if the consumer publishes envelopes
+func (si *StakingIndexer) pushBtcInfoEvent(ev *consumer.BtcInfoEvent, tip *types.IndexedBlock) error {
+	tipHash := tip.BlockHash()
+	env, err := consumer.WrapUnsequencedEvent(uint64(tip.Height), tipHash.String(), ev)
+	if err != nil {
+		return err
+	}
+	si.updates.setBtcInfo(env)
+
+	envelopeConsumer, ok := si.consumer.(consumer.EnvelopeConsumer)
+	if !ok {
+		return si.consumer.PushBtcInfoEvent(ev)
+	}
+
+	return envelopeConsumer.PushEventEnvelope(env)
+}
+
+// UnconfirmedBlocksInfo is the information collected from unconfirmed blocks
+type UnconfirmedBlocksInfo struct {
+	// Tvl is the change of tvl caused by the txs in the unconfirmed blocks
+	Tvl              btcutil.Amount
+	NumStakingTxs    uint64
+	NumUnbondingTxs  uint64
+	NumWithdrawalTxs uint64
+}
+
+// CalculateTvlInUnconfirmedBlocks calculates the change of tvl caused by the
+// txs in the given unconfirmed blocks and counts the pending txs. It follows
+// the same tvl policy as confirmed blocks:
+// 1. valid staking txs increase the tvl
+// 2. unbonding txs and slashing txs from staking decrease the tvl if the
+// staking tx is not overflow
+// 3. withdrawal txs do not change the tvl, as the value of the withdrawn
+// staking output is not subtracted from the confirmed tvl either and the
+// value of the unbonding output was subtracted by the unbonding tx
+// Overflow is not checked for unconfirmed staking txs.
+func (si *StakingIndexer) CalculateTvlInUnconfirmedBlocks(unconfirmedBlocks []*types.IndexedBlock) (*UnconfirmedBlocksInfo, error) {
+	info := &UnconfirmedBlocksInfo{}
 	unconfirmedStakingTxs := make(map[chainhash.Hash]*indexerstore.StoredStakingTransaction)
+	unconfirmedUnbondingTxs := make(map[chainhash.Hash]*indexerstore.StoredUnbondingTransaction)
 	for _, b := range unconfirmedBlocks {
 		params, err := si.getVersionedParams(uint64(b.Height))
 		if err != nil {
-			return 0, err
+			return nil, err
 		}
 
 		for _, tx := range b.Txs {
@@ -233,7 +333,7 @@ func (si *StakingIndexer) CalculateTvlInUnconfirmedBlocks(unconfirmedBlocks []*t
 			stakingData, err := si.tryParseStakingTx(msgTx, params)
 			if err == nil {
 				// this is a new staking tx, validate it against staking requirement
-				if err := si.validateStakingTx(params, stakingData); err != nil {
+				if _, err := si.validateStakingTx(params, stakingData); err != nil {
 					// Note: the metrics and logs will be repeated when the tx is confirmed
 					invalidTransactionsCounter.WithLabelValues("unconfirmed_staking_transaction").Inc()
 					si.logger.Warn("found an invalid staking tx",
@@ -247,7 +347,8 @@ func (si *StakingIndexer) CalculateTvlInUnconfirmedBlocks(unconfirmedBlocks []*t
 					continue
 				}
 
-				tvl += btcutil.Amount(stakingData.StakingOutput.Value)
+				info.Tvl += btcutil.Amount(stakingData.StakingOutput.Value)
+				info.NumStakingTxs++
 				// save the staking tx in memory for later identifying unbonding tx
 				stakingValue := uint64(stakingData.StakingOutput.Value)
 				unconfirmedStakingTxs[msgTx.TxHash()] = &indexerstore.StoredStakingTransaction{
@@ -269,17 +370,17 @@ func (si *StakingIndexer) CalculateTvlInUnconfirmedBlocks(unconfirmedBlocks []*t
 			}
 
 			// 2. not a staking tx, check whether it spends a stored staking tx
-			stakingTxs, _ := si.getSpentStakingTxs(msgTx)
+			stakingTxs, spendingInputIndexes := si.getSpentStakingTxs(msgTx)
 			if len(stakingTxs) == 0 {
 				// it does not spend a stored staking tx, check whether it spends
 				// an unconfirmed staking tx
-				stakingTxs, _ = getSpentFromStakingTxs(msgTx, unconfirmedStakingTxs)
+				stakingTxs, spendingInputIndexes = getSpentFromStakingTxs(msgTx, unconfirmedStakingTxs)
 			}
-			for _, stakingTx := range stakingTxs {
+			for i, stakingTx := range stakingTxs {
 				// 3. is a spending tx, check whether it is a valid unbonding tx
 				paramsFromStakingTxHeight, err := si.getVersionedParams(stakingTx.InclusionHeight)
 				if err != nil {
-					return 0, err
+					return nil, err
 				}
 				isUnbonding, err := si.IsValidUnbondingTx(msgTx, stakingTx, paramsFromStakingTxHeight)
 				if err != nil {
@@ -297,7 +398,7 @@ func (si *StakingIndexer) CalculateTvlInUnconfirmedBlocks(unconfirmedBlocks []*t
 
 					// record metrics
 					failedVerifyingUnbondingTxsCounter.Inc()
-					return 0, fmt.Errorf("failed to validate unconfirmed unbonding tx: %w", err)
+					return nil, fmt.Errorf("failed to validate unconfirmed unbonding tx: %w", err)
 				}
 				if isUnbonding {
 					si.logger.Info("found an unconfirmed unbonding tx",
@@ -307,31 +408,227 @@ func (si *StakingIndexer) CalculateTvlInUnconfirmedBlocks(unconfirmedBlocks []*t
 
 					// only subtract the tvl if the staking tx is not overflow
 					if !stakingTx.IsOverflow {
-						tvl -= btcutil.Amount(stakingTx.StakingValue)
+						info.Tvl -= btcutil.Amount(stakingTx.StakingValue)
+					}
+					info.NumUnbondingTxs++
+					// save the unbonding tx in memory for later identifying withdrawal tx
+					stakingTxHash := stakingTx.Tx.TxHash()
+					unconfirmedUnbondingTxs[msgTx.TxHash()] = &indexerstore.StoredUnbondingTransaction{
+						Tx:              msgTx,
+						StakingTxHash:   &stakingTxHash,
+						InclusionHeight: uint64(b.Height),
+						UnbondingTime:   uint32(paramsFromStakingTxHeight.UnbondingTime),
+					}
+					continue
+				}
+
+				isSlashing, err := si.IsSlashingTxFromStaking(msgTx, stakingTx, spendingInputIndexes[i], paramsFromStakingTxHeight)
+				if err != nil {
+					return nil, fmt.Errorf("failed to validate unconfirmed slashing tx: %w", err)
+				}
+				if isSlashing {
+					si.logger.Info("found an unconfirmed slashing tx",
+						zap.String("tx_hash", msgTx.TxHash().String()),
+						zap.String("staking_tx_hash", stakingTx.Tx.TxHash().String()),
+						zap.Uint64("value", stakingTx.StakingValue))
+
+					// only subtract the tvl if the staking tx is not overflow
+					if !stakingTx.IsOverflow {
+						info.Tvl -= btcutil.Amount(stakingTx.StakingValue)
+					}
+					continue
+				}
+
+				if err := si.ValidateWithdrawalTxFromStaking(msgTx, stakingTx, spendingInputIndexes[i], paramsFromStakingTxHeight); err != nil {
+					if errors.Is(err, ErrInvalidWithdrawalTx) {
+						invalidTransactionsCounter.WithLabelValues("unconfirmed_withdraw_staking_transactions").Inc()
+						si.logger.Warn("found an invalid withdrawal tx from staking",
+							zap.String("tx_hash", msgTx.TxHash().String()),
+							zap.Int32("height", b.Height),
+							zap.Bool("is_confirmed", false),
+							zap.Error(err),
+						)
+
+						continue
 					}
-				} else {
-					// TODO 1. Identify withdraw txs
-					// TODO 2. Decide whether to subtract tvl here
-					invalidTransactionsCounter.WithLabelValues("unconfirmed_unknown_transaction").Inc()
 
-					si.logger.Warn("found a tx that spends the staking tx but not an unbonding tx",
+					return nil, fmt.Errorf("failed to validate unconfirmed withdrawal tx: %w", err)
+				}
+
+				// the staking output can only be withdrawn once its timelock expires
+				if uint64(b.Height) < stakingTx.InclusionHeight+uint64(stakingTx.StakingTime) {
+					invalidTransactionsCounter.WithLabelValues("unconfirmed_withdraw_staking_transactions").Inc()
+					si.logger.Warn("found an immature withdrawal tx from staking",
 						zap.String("tx_hash", msgTx.TxHash().String()),
-						zap.String("staking_tx_hash", stakingTx.Tx.TxHash().String()))
+						zap.Int32("height", b.Height),
+						zap.Uint64("timelock_expiry_height", stakingTx.InclusionHeight+uint64(stakingTx.StakingTime)),
+					)
+
+					continue
+				}
+
+				si.logger.Info("found an unconfirmed withdrawal tx from staking",
+					zap.String("tx_hash", msgTx.TxHash().String()),
+					zap.String("staking_tx_hash", stakingTx.Tx.TxHash().String()))
+
+				// the withdrawn staking output stays counted in the tvl as the
+				// timelock expiry never changes it
+				info.NumWithdrawalTxs++
+			}
+
+			// 4. check whether it spends a stored or unconfirmed unbonding tx
+			unbondingTxs, spendingUnbondingInputIndexes := si.getSpentUnbondingTxs(msgTx)
+			if len(unbondingTxs) == 0 {
+				unbondingTxs, spendingUnbondingInputIndexes = getSpentFromUnbondingTxs(msgTx, unconfirmedUnbondingTxs)
+			}
+			for i, unbondingTx := range unbondingTxs {
+				isWithdrawal, err := si.isUnconfirmedWithdrawalTxFromUnbonding(
+					msgTx, unbondingTx, spendingUnbondingInputIndexes[i], uint64(b.Height), unconfirmedStakingTxs,
+				)
+				if err != nil {
+					return nil, err
+				}
+				if !isWithdrawal {
+					continue
 				}
+
+				si.logger.Info("found an unconfirmed withdrawal tx from unbonding",
+					zap.String("tx_hash", msgTx.TxHash().String()),
+					zap.String("staking_tx_hash", unbondingTx.StakingTxHash.String()))
+
+				// the value of the unbonding output was subtracted by the unbonding tx
+				info.NumWithdrawalTxs++
 			}
 		}
 	}
 
-	return tvl, nil
+	return info, nil
+}
+
+// isUnconfirmedWithdrawalTxFromUnbonding checks whether the given unconfirmed
+// tx at the given height withdraws the output of the given unbonding tx.
+// Slashing txs, invalid withdrawal txs, and withdrawal txs before the timelock
+// expires are not withdrawals and do not change the tvl
+func (si *StakingIndexer) isUnconfirmedWithdrawalTxFromUnbonding(
+	tx *wire.MsgTx,
+	unbondingTx *indexerstore.StoredUnbondingTransaction,
+	spendingInputIdx int,
+	height uint64,
+	unconfirmedStakingTxs map[chainhash.Hash]*indexerstore.StoredStakingTransaction,
+) (bool, error) {
+	stakingTx, ok := unconfirmedStakingTxs[*unbondingTx.StakingTxHash]
+	if !ok {
+		storedStakingTx, err := si.GetStakingTxByHash(unbondingTx.StakingTxHash)
+		if err != nil {
+			return false, err
+		}
+		if storedStakingTx == nil {
+			return false, fmt.Errorf("%w: staking tx %s", indexerstore.ErrTransactionNotFound, unbondingTx.StakingTxHash)
+		}
+		stakingTx = storedStakingTx
+	}
+
+	paramsFromStakingTxHeight, err := si.getVersionedParams(stakingTx.InclusionHeight)
+	if err != nil {
+		return false, err
+	}
+
+	isSlashing, err := si.IsSlashingTxFromUnbonding(tx, stakingTx, spendingInputIdx, paramsFromStakingTxHeight)
+	if err != nil {
+		return false, fmt.Errorf("failed to validate unconfirmed slashing tx: %w", err)
+	}
+	if isSlashing {
+		return false, nil
+	}
+
+	if err := si.ValidateWithdrawalTxFromUnbonding(tx, stakingTx, spendingInputIdx, paramsFromStakingTxHeight); err != nil {
+		if errors.Is(err, ErrInvalidWithdrawalTx) {
+			invalidTransactionsCounter.WithLabelValues("unconfirmed_withdraw_unbonding_transactions").Inc()
+			si.logger.Warn("found an invalid withdrawal tx from unbonding",
+				zap.String("tx_hash", tx.TxHash().String()),
+				zap.Bool("is_confirmed", false),
+				zap.Error(err),
+			)
+
+			return false, nil
+		}
+
+		return false, fmt.Errorf("failed to validate unconfirmed withdrawal tx: %w", err)
+	}
+
+	// the unbonding output can only be withdrawn once its timelock expires
+	timelockExpiryHeight := unbondingTx.InclusionHeight + uint64(unbondingTx.UnbondingTime)
+	if height < timelockExpiryHeight {
+		invalidTransactionsCounter.WithLabelValues("unconfirmed_withdraw_unbonding_transactions").Inc()
+		si.logger.Warn("found an immature withdrawal tx from unbonding",
+			zap.String("tx_hash", tx.TxHash().String()),
+			zap.Uint64("height", height),
+			zap.Uint64("timelock_expiry_height", timelockExpiryHeight),
+		)
+
+		return false, nil
+	}
+
+	return true, nil
 }
 
 // HandleConfirmedBlock iterates through the tx set of a confirmed block and
 // parse the staking, unbonding, and withdrawal txs if there are any.
+// All the state changes of the block are committed to the store together
+// with the block height as the last processed height, or none of them is
 func (si *StakingIndexer) HandleConfirmedBlock(b *types.IndexedBlock) error {
 	params, err := si.getVersionedParams(uint64(b.Height))
 	if err != nil {
 		return err
 	}
+
+	blockHash := b.BlockHash()
+	if err := si.is.UpdateBlock(uint64(b.Height), &blockHash, func(bs indexerstore.Store) error {
+		bsi := si.withStore(bs, uint64(b.Height), &blockHash)
+		if err := bsi.handleConfirmedBlockTxs(b, params); err != nil {
+			return err
+		}
+
+		if si.cfg.ExtraEventEnabled {
+			// emit ConfirmedInfoEvent to send the confirmed height and tvl
+			if err := bsi.pushConfirmedInfoEvent(uint64(b.Height)); err != nil {
+				return err
+			}
+		}
+
+		if si.cfg.FinalityProviderEventEnabled {
+			// emit FinalityProviderInfoEvent for the finality providers
+			// whose totals are changed by the block
+			fpPks, err := bsi.blockFinalityProviders(uint64(b.Height))
+			if err != nil {
+				return err
+			}
+			return bsi.pushFinalityProviderInfoEvents(uint64(b.Height), fpPks)
+		}
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	// notify the publisher of the new events in the outbox
+	si.notifyOutboxPublisher()
+
+	// record metrics
+	lastProcessedBtcHeight.Set(float64(b.Height))
+
+	return nil
+}
+
+// handleConfirmedBlockTxs processes the timelock expiries at the height of
+// the given confirmed block and the txs in it
+func (si *StakingIndexer) handleConfirmedBlockTxs(b *types.IndexedBlock, params *parser.ParsedVersionedGlobalParams) error {
+	// the outputs whose timelocks expire at this height can be withdrawn
+	// by the txs in this block, so the expiries are handled first
+	if err := si.processTimelockExpiries(uint64(b.Height), b.Header.Timestamp); err != nil {
+		return fmt.Errorf("failed to process the timelock expiries: %w", err)
+	}
+
 	for _, tx := range b.Txs {
 		msgTx := tx.MsgTx()
 
@@ -356,7 +653,7 @@ func (si *StakingIndexer) HandleConfirmedBlock(b *types.IndexedBlock) error {
 		stakingTxs, spendStakingInputIndexes := si.getSpentStakingTxs(msgTx)
 		for i, stakingTx := range stakingTxs {
 			// this is a spending tx from a previous staking tx, further process it
-			// by checking whether it is unbonding or withdrawal
+			// by checking whether it is unbonding, slashing, or withdrawal
 			if err := si.handleSpendingStakingTransaction(
 				msgTx, stakingTx, spendStakingInputIndexes[i],
 				uint64(b.Height), b.Header.Timestamp); err != nil {
@@ -372,31 +669,153 @@ func (si *StakingIndexer) HandleConfirmedBlock(b *types.IndexedBlock) error {
 		for i, unbondingTx := range unbondingTxs {
 			// this is a spending tx from the unbonding, validate it, and processes it
 			if err := si.handleSpendingUnbondingTransaction(
-				msgTx, unbondingTx, spendUnbondingInputIndexes[i], uint64(b.Height)); err != nil {
+				msgTx, unbondingTx, spendUnbondingInputIndexes[i],
+				uint64(b.Height), b.Header.Timestamp); err != nil {
 
 				return err
 			}
 		}
 	}
 
-	if err := si.is.SaveLastProcessedHeight(uint64(b.Height)); err != nil {
-		return fmt.Errorf("failed to save the last processed height: %w", err)
+	return nil
+}
+
+// withStore returns an indexer sharing the configuration and the consumer
+// of si, whose reads and writes go through the given store, and whose events
+// are attributed to the block with the given height and hash
+func (si *StakingIndexer) withStore(
+	is indexerstore.Store,
+	blockHeight uint64,
+	blockHash *chainhash.Hash,
+) *StakingIndexer {
+	return &StakingIndexer{
+		consumer:       si.consumer,
+		paramsVersions: si.paramsVersions,
+		cfg:            si.cfg,
+		logger:         si.logger,
+		is:             is,
+		btcScanner:     si.btcScanner,
+		outboxNotify:   si.outboxNotify,
+		updates:        si.updates,
+		blockHeight:    blockHeight,
+		blockHash:      blockHash,
+		quit:           si.quit,
 	}
+}
 
-	if si.cfg.ExtraEventEnabled {
-		// emit ConfirmedInfoEvent to send the confirmed height and tvl
-		confirmedTvl, err := si.is.GetConfirmedTvl()
-		if err != nil {
-			return fmt.Errorf("failed to get the confirmed tvl: %w", err)
+// RollbackToHeight undoes the state changes of all the processed blocks above
+// the given fork height, from the last processed block downwards. For each
+// undone transaction, a rollback event is written to the outbox.
+func (si *StakingIndexer) RollbackToHeight(forkHeight uint64) error {
+	lastProcessedHeight, err := si.is.GetLastProcessedHeight()
+	if err != nil {
+		if errors.Is(err, indexerstore.ErrLastProcessedHeightNotFound) {
+			// nothing has been processed yet
+			return nil
+		}
+		return fmt.Errorf("failed to get the last processed height: %w", err)
+	}
+
+	// the finality providers whose totals are changed by the rolled back blocks
+	var rolledBackFpPks []*btcec.PublicKey
+	seenFpPks := make(map[string]bool)
+
+	for height := lastProcessedHeight; height > forkHeight; height-- {
+		if err := si.is.Update(func(bs indexerstore.Store) error {
+			// the events are attributed to the block rolled back
+			blockHash, err := bs.GetBlockHash(height)
+			if err != nil {
+				return err
+			}
+
+			bsi := si.withStore(bs, height, blockHash)
+			if si.cfg.FinalityProviderEventEnabled {
+				fpPks, err := bsi.blockFinalityProviders(height)
+				if err != nil {
+					return err
+				}
+				for _, fpPk := range fpPks {
+					key := string(schnorr.SerializePubKey(fpPk))
+					if !seenFpPks[key] {
+						seenFpPks[key] = true
+						rolledBackFpPks = append(rolledBackFpPks, fpPk)
+					}
+				}
+			}
+
+			if err := bsi.rollbackBlock(height); err != nil {
+				return err
+			}
+
+			if height != forkHeight+1 {
+				return nil
+			}
+
+			if si.cfg.ExtraEventEnabled {
+				// emit ConfirmedInfoEvent to send the confirmed height and tvl after the rollback
+				if err := bsi.pushConfirmedInfoEvent(forkHeight); err != nil {
+					return err
+				}
+			}
+
+			if si.cfg.FinalityProviderEventEnabled {
+				// emit FinalityProviderInfoEvent to send the totals after the rollback
+				return bsi.pushFinalityProviderInfoEvents(forkHeight, rolledBackFpPks)
+			}
+
+			return nil
+		}); err != nil {
+			return fmt.Errorf("failed to roll back the block at height %d: %w", height, err)
+		}
+	}
+
+	// notify the publisher of the new events in the outbox
+	si.notifyOutboxPublisher()
+
+	// record metrics
+	lastProcessedBtcHeight.Set(float64(forkHeight))
+
+	return nil
+}
+
+func (si *StakingIndexer) rollbackBlock(height uint64) error {
+	journal, err := si.is.GetBlockJournal(height)
+	if err != nil {
+		return err
+	}
+
+	// the rollback events are written to the outbox, they are committed
+	// together with the rollback of the state
+	for i := len(journal) - 1; i >= 0; i-- {
+		entry := journal[i]
+		// state transitions are undone together with the tx causing them
+		// or with the block in which the timelock expired
+		if entry.TxType == indexerstore.StateTransitionType {
+			continue
 		}
-		confirmedInfoEvent := queuecli.NewConfirmedInfoEvent(uint64(b.Height), confirmedTvl)
-		if err := si.consumer.PushConfirmedInfoEvent(&confirmedInfoEvent); err != nil {
-			return fmt.Errorf("failed to push the confirmed info event: %w", err)
+		si.logger.Info("rolling back a transaction",
+			zap.Uint64("height", height),
+			zap.String("tx_hash", entry.TxHash.String()),
+			zap.String("tx_type", entry.TxType.String()),
+		)
+
+		rollbackEvent := consumer.NewRollbackEvent(
+			entry.StakingTxHash.String(),
+			entry.TxHash.String(),
+			entry.TxType.String(),
+			height,
+		)
+		if err := si.pushEvent(&rollbackEvent); err != nil {
+			return fmt.Errorf("failed to write the rollback event to the outbox: %w", err)
 		}
 	}
 
+	if err := si.is.RollbackBlock(height); err != nil {
+		return err
+	}
+
 	// record metrics
-	lastProcessedBtcHeight.Set(float64(b.Height))
+	totalRolledBackBlocks.Inc()
 
 	return nil
 }
@@ -406,6 +825,7 @@ func (si *StakingIndexer) handleSpendingUnbondingTransaction(
 	unbondingTx *indexerstore.StoredUnbondingTransaction,
 	spendingInputIdx int,
 	height uint64,
+	timestamp time.Time,
 ) error {
 	// get the stored staking tx for later validation
 	storedStakingTx, err := si.GetStakingTxByHash(unbondingTx.StakingTxHash)
@@ -421,9 +841,28 @@ func (si *StakingIndexer) handleSpendingUnbondingTransaction(
 		return err
 	}
 
+	unbondingTxHash := unbondingTx.Tx.TxHash()
+
+	// check whether it is a slashing tx
+	isSlashing, err := si.IsSlashingTxFromUnbonding(tx, storedStakingTx, spendingInputIdx, paramsFromStakingTxHeight)
+	if err != nil {
+		failedProcessingSlashingTxsCounter.Inc()
+		return err
+	}
+	if isSlashing {
+		if err := si.processSlashingTx(
+			tx, unbondingTx.StakingTxHash, &unbondingTxHash, height, timestamp,
+		); err != nil {
+			// record metrics
+			failedProcessingSlashingTxsCounter.Inc()
+
+			return err
+		}
+		return nil
+	}
+
 	if err := si.ValidateWithdrawalTxFromUnbonding(tx, storedStakingTx, spendingInputIdx, paramsFromStakingTxHeight); err != nil {
 		if errors.Is(err, ErrInvalidWithdrawalTx) {
-			// TODO consider slashing transaction for phase-2
 			invalidTransactionsCounter.WithLabelValues("confirmed_withdraw_unbonding_transactions").Inc()
 			si.logger.Warn("found an invalid withdrawal tx from unbonding",
 				zap.String("tx_hash", tx.TxHash().String()),
@@ -439,8 +878,11 @@ func (si *StakingIndexer) handleSpendingUnbondingTransaction(
 		return err
 	}
 
-	unbondingTxHash := unbondingTx.Tx.TxHash()
-	if err := si.processWithdrawTx(tx, unbondingTx.StakingTxHash, &unbondingTxHash, height); err != nil {
+	// the unbonding output can be withdrawn after the unbonding time
+	timelockExpiryHeight := unbondingTx.InclusionHeight + uint64(paramsFromStakingTxHeight.UnbondingTime)
+	if err := si.processWithdrawTx(
+		tx, unbondingTx.StakingTxHash, &unbondingTxHash, height, timelockExpiryHeight,
+	); err != nil {
 		// record metrics
 		failedProcessingWithdrawTxsFromUnbondingCounter.Inc()
 
@@ -483,7 +925,23 @@ func (si *StakingIndexer) handleSpendingStakingTransaction(
 	}
 
 	if !isUnbonding {
-		// not an unbonding tx, so this is a withdraw tx from the staking,
+		// not an unbonding tx, check whether it is a slashing tx
+		isSlashing, err := si.IsSlashingTxFromStaking(tx, stakingTx, spendingInputIndex, paramsFromStakingTxHeight)
+		if err != nil {
+			failedProcessingSlashingTxsCounter.Inc()
+			return err
+		}
+		if isSlashing {
+			if err := si.processSlashingTx(tx, &stakingTxHash, nil, height, timestamp); err != nil {
+				// record metrics
+				failedProcessingSlashingTxsCounter.Inc()
+
+				return err
+			}
+			return nil
+		}
+
+		// not a slashing tx, so this is a withdraw tx from the staking,
 		// validate it and process it
 		if err := si.ValidateWithdrawalTxFromStaking(tx, stakingTx, spendingInputIndex, paramsFromStakingTxHeight); err != nil {
 			if errors.Is(err, ErrInvalidWithdrawalTx) {
@@ -501,7 +959,10 @@ func (si *StakingIndexer) handleSpendingStakingTransaction(
 			failedProcessingWithdrawTxsFromStakingCounter.Inc()
 			return err
 		}
-		if err := si.processWithdrawTx(tx, &stakingTxHash, nil, height); err != nil {
+		timelockExpiryHeight := stakingTx.InclusionHeight + uint64(stakingTx.StakingTime)
+		if err := si.processWithdrawTx(
+			tx, &stakingTxHash, nil, height, timelockExpiryHeight,
+		); err != nil {
 			// record metrics
 			failedProcessingWithdrawTxsFromStakingCounter.Inc()
 
@@ -555,14 +1016,7 @@ func (si *StakingIndexer) ValidateWithdrawalTxFromStaking(
 		return fmt.Errorf("failed to get the unbonding path spend info: %w", err)
 	}
 
-	witness := tx.TxIn[spendingInputIdx].Witness
-	if len(witness) < 2 {
-		panic(fmt.Errorf("spending tx should have at least 2 elements in witness, got %d", len(witness)))
-	}
-
-	scriptFromWitness := tx.TxIn[spendingInputIdx].Witness[len(tx.TxIn[spendingInputIdx].Witness)-2]
-
-	if !bytes.Equal(timelockPathInfo.GetPkScriptPath(), scriptFromWitness) {
+	if !unlocksScriptPath(tx, spendingInputIdx, timelockPathInfo) {
 		return fmt.Errorf("%w: the tx does not unlock the time-lock path", ErrInvalidWithdrawalTx)
 	}
 
@@ -595,32 +1049,97 @@ func (si *StakingIndexer) ValidateWithdrawalTxFromUnbonding(
 		return fmt.Errorf("failed to get the unbonding path spend info: %w", err)
 	}
 
-	witness := tx.TxIn[spendingInputIdx].Witness
-	if len(witness) < 2 {
-		panic(fmt.Errorf("spending tx should have at least 2 elements in witness, got %d", len(witness)))
-	}
-
-	scriptFromWitness := tx.TxIn[spendingInputIdx].Witness[len(tx.TxIn[spendingInputIdx].Witness)-2]
-
-	if !bytes.Equal(timelockPathInfo.GetPkScriptPath(), scriptFromWitness) {
+	if !unlocksScriptPath(tx, spendingInputIdx, timelockPathInfo) {
 		return fmt.Errorf("%w: the tx does not unlock the time-lock path", ErrInvalidWithdrawalTx)
 	}
 
 	return nil
 }
 
-func (si *StakingIndexer) IsTxProcessed(txHash *chainhash.Hash) (bool, error) {
-	return si.is.TxExists(txHash)
+// IsSlashingTxFromStaking checks whether the given input of the tx unlocks
+// the slashing path of the staking output by re-building the slashing path
+// script and comparing it with the script from the witness
+func (si *StakingIndexer) IsSlashingTxFromStaking(
+	tx *wire.MsgTx,
+	stakingTx *indexerstore.StoredStakingTransaction,
+	spendingInputIdx int,
+	params *parser.ParsedVersionedGlobalParams,
+) (bool, error) {
+	stakingInfo, err := btcstaking.BuildStakingInfo(
+		stakingTx.StakerPk,
+		[]*btcec.PublicKey{stakingTx.FinalityProviderPk},
+		params.CovenantPks,
+		params.CovenantQuorum,
+		uint16(stakingTx.StakingTime),
+		btcutil.Amount(stakingTx.StakingValue),
+		&si.cfg.BTCNetParams,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to rebuid the staking info: %w", err)
+	}
+	slashingPathInfo, err := stakingInfo.SlashingPathSpendInfo()
+	if err != nil {
+		return false, fmt.Errorf("failed to get the slashing path spend info: %w", err)
+	}
+
+	return unlocksScriptPath(tx, spendingInputIdx, slashingPathInfo), nil
 }
 
-// getSpentStakingTxs find all the stored staking txs spent by the given tx.
-// It returns the found staking txs and the spending input index of the given tx
-func (si *StakingIndexer) getSpentStakingTxs(tx *wire.MsgTx) ([]*indexerstore.StoredStakingTransaction, []int) {
-	storedStakingTxs := make([]*indexerstore.StoredStakingTransaction, 0)
-	spendingInputIndexes := make([]int, 0)
-	for i, txIn := range tx.TxIn {
-		maybeStakingTxHash := txIn.PreviousOutPoint.Hash
-		stakingTx, err := si.GetStakingTxByHash(&maybeStakingTxHash)
+// IsSlashingTxFromUnbonding checks whether the given input of the tx unlocks
+// the slashing path of the unbonding output by re-building the slashing path
+// script and comparing it with the script from the witness
+func (si *StakingIndexer) IsSlashingTxFromUnbonding(
+	tx *wire.MsgTx,
+	stakingTx *indexerstore.StoredStakingTransaction,
+	spendingInputIdx int,
+	params *parser.ParsedVersionedGlobalParams,
+) (bool, error) {
+	expectedUnbondingOutputValue := btcutil.Amount(stakingTx.StakingValue) - params.UnbondingFee
+	unbondingInfo, err := btcstaking.BuildUnbondingInfo(
+		stakingTx.StakerPk,
+		[]*btcec.PublicKey{stakingTx.FinalityProviderPk},
+		params.CovenantPks,
+		params.CovenantQuorum,
+		params.UnbondingTime,
+		expectedUnbondingOutputValue,
+		&si.cfg.BTCNetParams,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to rebuid the unbonding info: %w", err)
+	}
+	slashingPathInfo, err := unbondingInfo.SlashingPathSpendInfo()
+	if err != nil {
+		return false, fmt.Errorf("failed to get the slashing path spend info: %w", err)
+	}
+
+	return unlocksScriptPath(tx, spendingInputIdx, slashingPathInfo), nil
+}
+
+// unlocksScriptPath checks whether the script from the witness of the given
+// input matches the script of the given spend info
+func unlocksScriptPath(tx *wire.MsgTx, inputIdx int, spendInfo *btcstaking.SpendInfo) bool {
+	witness := tx.TxIn[inputIdx].Witness
+	if len(witness) < 2 {
+		panic(fmt.Errorf("spending tx should have at least 2 elements in witness, got %d", len(witness)))
+	}
+
+	scriptFromWitness := witness[len(witness)-2]
+
+	return bytes.Equal(spendInfo.GetPkScriptPath(), scriptFromWitness)
+}
+
+func (si *StakingIndexer) IsTxProcessed(txHash *chainhash.Hash) (bool, error) {
+	return si.is.TxExists(txHash)
+}
+
+// getSpentStakingTxs find all the stored staking txs spent by the given tx.
+// It returns the found staking txs and the spending input index of the given tx
+func (si *StakingIndexer) getSpentStakingTxs(tx *wire.MsgTx) ([]*indexerstore.StoredStakingTransaction, []int) {
+	storedStakingTxs := make([]*indexerstore.StoredStakingTransaction, 0)
+	spendingInputIndexes := make([]int, 0)
+	for i, txIn := range tx.TxIn {
+		maybeStakingTxHash := txIn.PreviousOutPoint.Hash
+		stakingTx, err := si.GetStakingTxByHash(&maybeStakingTxHash)
 		if err != nil || stakingTx == nil {
 			continue
 		}
@@ -664,6 +1183,28 @@ func getSpentFromStakingTxs(
 	return storedStakingTxs, spendingInputIndexes
 }
 
+// getSpentFromUnbondingTxs finds the unbonding txs from the given map spent
+// by the given tx. It returns the found unbonding txs and the spending input
+// index of the given tx
+func getSpentFromUnbondingTxs(
+	tx *wire.MsgTx,
+	unbondingTxs map[chainhash.Hash]*indexerstore.StoredUnbondingTransaction,
+) ([]*indexerstore.StoredUnbondingTransaction, []int) {
+	storedUnbondingTxs := make([]*indexerstore.StoredUnbondingTransaction, 0)
+	spendingInputIndexes := make([]int, 0)
+	for i, txIn := range tx.TxIn {
+		unbondingTx, exists := unbondingTxs[txIn.PreviousOutPoint.Hash]
+		if !exists {
+			continue
+		}
+
+		storedUnbondingTxs = append(storedUnbondingTxs, unbondingTx)
+		spendingInputIndexes = append(spendingInputIndexes, i)
+	}
+
+	return storedUnbondingTxs, spendingInputIndexes
+}
+
 // getSpentUnbondingTxs find all the stored unbonding txs spent by the given tx.
 // It returns the found unbonding txs and the spending input index of the given tx
 func (si *StakingIndexer) getSpentUnbondingTxs(tx *wire.MsgTx) ([]*indexerstore.StoredUnbondingTransaction, []int) {
@@ -802,7 +1343,8 @@ func (si *StakingIndexer) ProcessStakingTx(
 		isOverflow = storedStakingTx.IsOverflow
 	} else {
 		// this is a new staking tx, validate it against staking requirement
-		if err := si.validateStakingTx(params, stakingData); err != nil {
+		reason, err := si.validateStakingTx(params, stakingData)
+		if err != nil {
 			invalidTransactionsCounter.WithLabelValues("confirmed_staking_transaction").Inc()
 			si.logger.Warn("found an invalid staking tx",
 				zap.String("tx_hash", tx.TxHash().String()),
@@ -810,8 +1352,16 @@ func (si *StakingIndexer) ProcessStakingTx(
 				zap.Bool("is_confirmed", true),
 				zap.Error(err),
 			)
-			// TODO handle invalid staking tx (storing and pushing events)
-			return nil
+
+			return si.addInvalidStakingTransaction(
+				height, timestamp, tx,
+				stakingData.OpReturnData.StakerPublicKey.PubKey,
+				stakingData.OpReturnData.FinalityProviderPublicKey.PubKey,
+				uint64(stakingData.StakingOutput.Value),
+				uint32(stakingData.OpReturnData.StakingTime),
+				uint32(stakingData.StakingOutputIdx),
+				reason,
+			)
 		}
 
 		// check if the staking tvl is overflow with this staking tx
@@ -857,28 +1407,17 @@ func (si *StakingIndexer) addStakingTransaction(
 	stakingOutputIndex uint32,
 	isOverflow bool,
 ) error {
-	txHex, err := getTxHex(tx)
+	stakingEvent, err := newActiveStakingEvent(
+		tx, stakerPk, fpPk, stakingValue, height, timestamp,
+		stakingTime, stakingOutputIndex, isOverflow,
+	)
 	if err != nil {
 		return err
 	}
 
-	stakingEvent := queuecli.NewActiveStakingEvent(
-		tx.TxHash().String(),
-		hex.EncodeToString(schnorr.SerializePubKey(stakerPk)),
-		hex.EncodeToString(schnorr.SerializePubKey(fpPk)),
-		stakingValue,
-		height,
-		timestamp.Unix(),
-		uint64(stakingTime),
-		uint64(stakingOutputIndex),
-		txHex,
-		isOverflow,
-	)
-
-	// push the events first then save the tx due to the assumption
-	// that the consumer can handle duplicate events
-	if err := si.consumer.PushStakingEvent(&stakingEvent); err != nil {
-		return fmt.Errorf("failed to push the staking event to the queue: %w", err)
+	// write the event to the outbox, it is committed together with the tx
+	if err := si.pushEvent(&stakingEvent); err != nil {
+		return fmt.Errorf("failed to write the staking event to the outbox: %w", err)
 	}
 
 	si.logger.Info("saving the staking transaction",
@@ -909,6 +1448,66 @@ func (si *StakingIndexer) addStakingTransaction(
 	return nil
 }
 
+// addInvalidStakingTransaction pushes the invalid staking event, saves it to
+// the database and records metrics
+func (si *StakingIndexer) addInvalidStakingTransaction(
+	height uint64,
+	timestamp time.Time,
+	tx *wire.MsgTx,
+	stakerPk *btcec.PublicKey,
+	fpPk *btcec.PublicKey,
+	stakingValue uint64,
+	stakingTime uint32,
+	stakingOutputIndex uint32,
+	reason indexerstore.InvalidStakingReason,
+) error {
+	txHex, err := getTxHex(tx)
+	if err != nil {
+		return err
+	}
+
+	invalidStakingEvent := consumer.NewInvalidStakingEvent(
+		tx.TxHash().String(),
+		hex.EncodeToString(schnorr.SerializePubKey(stakerPk)),
+		hex.EncodeToString(schnorr.SerializePubKey(fpPk)),
+		stakingValue,
+		height,
+		timestamp.Unix(),
+		uint64(stakingTime),
+		uint64(stakingOutputIndex),
+		txHex,
+		reason.String(),
+	)
+
+	// write the event to the outbox, it is committed together with the tx
+	if err := si.pushEvent(&invalidStakingEvent); err != nil {
+		return fmt.Errorf("failed to write the invalid staking event to the outbox: %w", err)
+	}
+
+	si.logger.Info("saving the invalid staking transaction",
+		zap.String("tx_hash", tx.TxHash().String()),
+		zap.String("reason", reason.String()),
+	)
+
+	// save the invalid staking tx in the db
+	if err := si.is.AddInvalidStakingTransaction(
+		tx, stakingOutputIndex, height,
+		stakerPk, stakingTime, fpPk,
+		stakingValue, reason,
+	); err != nil && !errors.Is(err, indexerstore.ErrDuplicateTransaction) {
+		return fmt.Errorf("failed to add the invalid staking tx to store: %w", err)
+	}
+
+	si.logger.Info("successfully saved the invalid staking transaction",
+		zap.String("tx_hash", tx.TxHash().String()),
+	)
+
+	// record metrics
+	totalStakingTxs.WithLabelValues("invalid").Inc()
+
+	return nil
+}
+
 func (si *StakingIndexer) ProcessUnbondingTx(
 	tx *wire.MsgTx,
 	stakingTxHash *chainhash.Hash,
@@ -921,25 +1520,23 @@ func (si *StakingIndexer) ProcessUnbondingTx(
 		zap.String("staking_tx_hash", stakingTxHash.String()),
 	)
 
-	unbondingTxHex, err := getTxHex(tx)
-	if err != nil {
+	unbondingTxHash := tx.TxHash()
+	isValidTransition, err := si.isValidStateTransition(
+		stakingTxHash, indexerstore.StateUnbonding, &unbondingTxHash, height,
+	)
+	if err != nil || !isValidTransition {
 		return err
 	}
 
-	unbondingTxHash := tx.TxHash()
-	unbondingEvent := queuecli.NewUnbondingStakingEvent(
-		stakingTxHash.String(),
-		height,
-		timestamp.Unix(),
-		uint64(params.UnbondingTime),
-		// valid unbonding tx always has one output
-		0,
-		unbondingTxHex,
-		unbondingTxHash.String(),
+	unbondingEvent, err := newUnbondingStakingEvent(
+		tx, stakingTxHash, height, timestamp, uint32(params.UnbondingTime),
 	)
+	if err != nil {
+		return err
+	}
 
-	if err := si.consumer.PushUnbondingEvent(&unbondingEvent); err != nil {
-		return fmt.Errorf("failed to push the unbonding event to the queue: %w", err)
+	if err := si.pushEvent(&unbondingEvent); err != nil {
+		return fmt.Errorf("failed to write the unbonding event to the outbox: %w", err)
 	}
 
 	si.logger.Info("saving the unbonding tx",
@@ -948,6 +1545,8 @@ func (si *StakingIndexer) ProcessUnbondingTx(
 	if err := si.is.AddUnbondingTransaction(
 		tx,
 		stakingTxHash,
+		height,
+		uint32(params.UnbondingTime),
 	); err != nil && !errors.Is(err, indexerstore.ErrDuplicateTransaction) {
 		return fmt.Errorf("failed to add the unbonding tx to store: %w", err)
 	}
@@ -962,8 +1561,19 @@ func (si *StakingIndexer) ProcessUnbondingTx(
 	return nil
 }
 
-func (si *StakingIndexer) processWithdrawTx(tx *wire.MsgTx, stakingTxHash *chainhash.Hash, unbondingTxHash *chainhash.Hash, height uint64) error {
-	txHashHex := tx.TxHash().String()
+// processWithdrawTx pushes the withdraw event, saves it to the database
+// and records metrics. unbondingTxHash is nil if the withdrawal tx spends
+// the staking tx. timelockExpiryHeight is the height from which the spent
+// output can be withdrawn
+func (si *StakingIndexer) processWithdrawTx(
+	tx *wire.MsgTx,
+	stakingTxHash *chainhash.Hash,
+	unbondingTxHash *chainhash.Hash,
+	height uint64,
+	timelockExpiryHeight uint64,
+) error {
+	txHash := tx.TxHash()
+	txHashHex := txHash.String()
 	if unbondingTxHash == nil {
 		si.logger.Info("found a withdraw tx from staking",
 			zap.String("tx_hash", txHashHex),
@@ -977,10 +1587,34 @@ func (si *StakingIndexer) processWithdrawTx(tx *wire.MsgTx, stakingTxHash *chain
 		)
 	}
 
+	if height >= timelockExpiryHeight {
+		spentTxHash := stakingTxHash
+		if unbondingTxHash != nil {
+			spentTxHash = unbondingTxHash
+		}
+		if err := si.expireTimelock(stakingTxHash, spentTxHash, height); err != nil {
+			return err
+		}
+	}
+
+	isValidTransition, err := si.isValidStateTransition(
+		stakingTxHash, indexerstore.StateWithdrawn, &txHash, height,
+	)
+	if err != nil || !isValidTransition {
+		return err
+	}
+
 	withdrawEvent := queuecli.NewWithdrawStakingEvent(stakingTxHash.String())
 
-	if err := si.consumer.PushWithdrawEvent(&withdrawEvent); err != nil {
-		return fmt.Errorf("failed to push the withdraw event to the consumer: %w", err)
+	// write the event to the outbox, it is committed together with the tx
+	if err := si.pushEvent(&withdrawEvent); err != nil {
+		return fmt.Errorf("failed to write the withdraw event to the outbox: %w", err)
+	}
+
+	if err := si.is.AddWithdrawalTransaction(
+		tx, stakingTxHash, unbondingTxHash, height,
+	); err != nil && !errors.Is(err, indexerstore.ErrDuplicateTransaction) {
+		return fmt.Errorf("failed to add the withdrawal tx to store: %w", err)
 	}
 
 	// record metrics
@@ -995,6 +1629,205 @@ func (si *StakingIndexer) processWithdrawTx(tx *wire.MsgTx, stakingTxHash *chain
 	return nil
 }
 
+// processSlashingTx pushes the slashing event, saves it to the database
+// and records metrics. unbondingTxHash is nil if the slashing tx spends
+// the staking tx
+func (si *StakingIndexer) processSlashingTx(
+	tx *wire.MsgTx,
+	stakingTxHash *chainhash.Hash,
+	unbondingTxHash *chainhash.Hash,
+	height uint64,
+	timestamp time.Time,
+) error {
+	txHash := tx.TxHash()
+	txHashHex := txHash.String()
+	unbondingTxHashHex := ""
+	if unbondingTxHash == nil {
+		si.logger.Info("found a slashing tx from staking",
+			zap.String("tx_hash", txHashHex),
+			zap.String("staking_tx_hash", stakingTxHash.String()),
+		)
+	} else {
+		unbondingTxHashHex = unbondingTxHash.String()
+		si.logger.Info("found a slashing tx from unbonding",
+			zap.String("tx_hash", txHashHex),
+			zap.String("staking_tx_hash", stakingTxHash.String()),
+			zap.String("unbonding_tx_hash", unbondingTxHashHex),
+		)
+	}
+
+	isValidTransition, err := si.isValidStateTransition(
+		stakingTxHash, indexerstore.StateSlashed, &txHash, height,
+	)
+	if err != nil || !isValidTransition {
+		return err
+	}
+
+	txHex, err := getTxHex(tx)
+	if err != nil {
+		return err
+	}
+
+	slashingEvent := consumer.NewSlashingEvent(
+		stakingTxHash.String(),
+		unbondingTxHashHex,
+		txHashHex,
+		txHex,
+		height,
+		timestamp.Unix(),
+	)
+
+	// write the event to the outbox, it is committed together with the tx
+	if err := si.pushEvent(&slashingEvent); err != nil {
+		return fmt.Errorf("failed to write the slashing event to the outbox: %w", err)
+	}
+
+	if err := si.is.AddSlashingTransaction(
+		tx, stakingTxHash, unbondingTxHash, height,
+	); err != nil && !errors.Is(err, indexerstore.ErrDuplicateTransaction) {
+		return fmt.Errorf("failed to add the slashing tx to store: %w", err)
+	}
+
+	// record metrics
+	if unbondingTxHash == nil {
+		totalSlashingTxsFromStaking.Inc()
+	} else {
+		totalSlashingTxsFromUnbonding.Inc()
+	}
+
+	return nil
+}
+
+// processTimelockExpiries pushes a timelock expired event for every staking
+// or unbonding output whose timelock expires at the given height and moves
+// its delegation to unbonded. Outputs that have already been spent are skipped
+func (si *StakingIndexer) processTimelockExpiries(height uint64, timestamp time.Time) error {
+	expiries, err := si.is.GetTimelockExpiries(height)
+	if err != nil {
+		return err
+	}
+
+	for _, expiry := range expiries {
+		storedStakingTx, err := si.GetStakingTxByHash(expiry.StakingTxHash)
+		if err != nil {
+			return err
+		}
+		if storedStakingTx == nil {
+			return fmt.Errorf("%w: staking tx %s", indexerstore.ErrTransactionNotFound, expiry.StakingTxHash)
+		}
+
+		// the staking output is only unspent before unbonding, and the
+		// unbonding output is only unspent while unbonding
+		spentTxHash := expiry.StakingTxHash
+		unbondingTxHashHex := ""
+		isUnspent := storedStakingTx.State == indexerstore.StateActive ||
+			storedStakingTx.State == indexerstore.StateOverflow
+		if expiry.IsFromUnbonding() {
+			spentTxHash = expiry.UnbondingTxHash
+			unbondingTxHashHex = expiry.UnbondingTxHash.String()
+			isUnspent = storedStakingTx.State == indexerstore.StateUnbonding
+		}
+		if !isUnspent {
+			continue
+		}
+
+		si.logger.Info("found an expired timelock",
+			zap.Uint64("height", height),
+			zap.String("staking_tx_hash", expiry.StakingTxHash.String()),
+			zap.String("unbonding_tx_hash", unbondingTxHashHex),
+		)
+
+		timelockExpiredEvent := consumer.NewTimelockExpiredEvent(
+			expiry.StakingTxHash.String(),
+			unbondingTxHashHex,
+			height,
+			timestamp.Unix(),
+		)
+
+		// write the event to the outbox, it is committed together with the state
+		if err := si.pushEvent(&timelockExpiredEvent); err != nil {
+			return fmt.Errorf("failed to write the timelock expired event to the outbox: %w", err)
+		}
+
+		if err := si.is.TransitionDelegationState(
+			expiry.StakingTxHash, indexerstore.StateUnbonded, height, spentTxHash,
+		); err != nil {
+			return fmt.Errorf("failed to expire the timelock: %w", err)
+		}
+
+		// record metrics
+		if expiry.IsFromUnbonding() {
+			totalExpiredTimelocks.WithLabelValues("unbonding").Inc()
+		} else {
+			totalExpiredTimelocks.WithLabelValues("staking").Inc()
+		}
+	}
+
+	return nil
+}
+
+// isValidStateTransition checks whether the delegation of the given staking tx
+// can move to the next state because of the given tx. An invalid move is not
+// considered critical, it is logged and recorded in metrics
+func (si *StakingIndexer) isValidStateTransition(
+	stakingTxHash *chainhash.Hash,
+	next indexerstore.DelegationState,
+	txHash *chainhash.Hash,
+	height uint64,
+) (bool, error) {
+	err := si.is.CheckDelegationStateTransition(stakingTxHash, next, txHash)
+	if err == nil {
+		return true, nil
+	}
+
+	var transitionErr *indexerstore.InvalidStateTransitionError
+	if !errors.As(err, &transitionErr) {
+		return false, fmt.Errorf("failed to check the state transition: %w", err)
+	}
+
+	invalidTransactionsCounter.WithLabelValues("confirmed_invalid_state_transitions").Inc()
+	si.logger.Warn("found a tx causing an invalid state transition",
+		zap.String("tx_hash", txHash.String()),
+		zap.String("staking_tx_hash", stakingTxHash.String()),
+		zap.String("from_state", transitionErr.From.String()),
+		zap.String("to_state", transitionErr.To.String()),
+		zap.Uint64("height", height),
+	)
+
+	return false, nil
+}
+
+// expireTimelock moves the delegation of the given staking tx to unbonded
+// because the timelock of the output of the spent tx expired. The expiry is
+// normally handled at the expiry height, but the expiries of the txs stored
+// before the timelock expiry index was introduced may be indexed later
+// it is a no-op if the delegation has already moved past the expiry
+func (si *StakingIndexer) expireTimelock(
+	stakingTxHash *chainhash.Hash,
+	spentTxHash *chainhash.Hash,
+	height uint64,
+) error {
+	storedStakingTx, err := si.GetStakingTxByHash(stakingTxHash)
+	if err != nil {
+		return err
+	}
+	if storedStakingTx == nil {
+		return fmt.Errorf("%w: staking tx %s", indexerstore.ErrTransactionNotFound, stakingTxHash)
+	}
+
+	if !storedStakingTx.State.CanTransitionTo(indexerstore.StateUnbonded) {
+		return nil
+	}
+
+	if err := si.is.TransitionDelegationState(
+		stakingTxHash, indexerstore.StateUnbonded, height, spentTxHash,
+	); err != nil {
+		return fmt.Errorf("failed to expire the timelock: %w", err)
+	}
+
+	return nil
+}
+
 func (si *StakingIndexer) tryParseStakingTx(tx *wire.MsgTx, params *parser.ParsedVersionedGlobalParams) (*btcstaking.ParsedV0StakingTx, error) {
 	possible := btcstaking.IsPossibleV0StakingTx(tx, params.Tag)
 	if !possible {
@@ -1018,10 +1851,50 @@ func (si *StakingIndexer) GetStakingTxByHash(hash *chainhash.Hash) (*indexerstor
 	return si.is.GetStakingTransaction(hash)
 }
 
+func (si *StakingIndexer) GetInvalidStakingTxByHash(hash *chainhash.Hash) (*indexerstore.StoredInvalidStakingTransaction, error) {
+	return si.is.GetInvalidStakingTransaction(hash)
+}
+
+func (si *StakingIndexer) GetWithdrawalTxByHash(hash *chainhash.Hash) (*indexerstore.StoredWithdrawalTransaction, error) {
+	return si.is.GetWithdrawalTransaction(hash)
+}
+
+// GetWithdrawalTxByStakingTxHash returns the withdrawal tx of the delegation
+// with the given staking tx hash, or nil if it has not been withdrawn
+func (si *StakingIndexer) GetWithdrawalTxByStakingTxHash(stakingTxHash *chainhash.Hash) (*indexerstore.StoredWithdrawalTransaction, error) {
+	return si.is.GetWithdrawalTransactionByStakingTxHash(stakingTxHash)
+}
+
+func (si *StakingIndexer) GetSlashingTxByHash(hash *chainhash.Hash) (*indexerstore.StoredSlashingTransaction, error) {
+	return si.is.GetSlashingTransaction(hash)
+}
+
 func (si *StakingIndexer) GetUnbondingTxByHash(hash *chainhash.Hash) (*indexerstore.StoredUnbondingTransaction, error) {
 	return si.is.GetUnbondingTransaction(hash)
 }
 
+// GetUnbondingTxByStakingTxHash returns the unbonding tx spending the staking
+// tx with the given hash, or nil if it is not unbonded early
+func (si *StakingIndexer) GetUnbondingTxByStakingTxHash(stakingTxHash *chainhash.Hash) (*indexerstore.StoredUnbondingTransaction, error) {
+	return si.is.GetUnbondingTransactionByStakingTxHash(stakingTxHash)
+}
+
+// GetStakingTxsByHeightRange returns at most limit staking txs included in
+// [startHeight, endHeight] in the order of their inclusion heights and hashes,
+// starting after the tx with afterTxHash, or from the first one if afterTxHash is nil
+func (si *StakingIndexer) GetStakingTxsByHeightRange(
+	startHeight, endHeight uint64,
+	afterTxHash *chainhash.Hash,
+	limit int,
+) ([]*indexerstore.StoredStakingTransaction, error) {
+	return si.is.GetStakingTransactionsByHeightRange(startHeight, endHeight, afterTxHash, limit)
+}
+
+// GetLastProcessedHeight returns the height of the last processed block
+func (si *StakingIndexer) GetLastProcessedHeight() (uint64, error) {
+	return si.is.GetLastProcessedHeight()
+}
+
 func (si *StakingIndexer) Stop() error {
 	var stopErr error
 	si.stopOnce.Do(func() {
@@ -1041,6 +1914,64 @@ func (si *StakingIndexer) Stop() error {
 	return stopErr
 }
 
+// newActiveStakingEvent returns the event of the staking tx included at the
+// given height in the block with the given timestamp
+func newActiveStakingEvent(
+	tx *wire.MsgTx,
+	stakerPk *btcec.PublicKey,
+	fpPk *btcec.PublicKey,
+	stakingValue uint64,
+	height uint64,
+	timestamp time.Time,
+	stakingTime uint32,
+	stakingOutputIndex uint32,
+	isOverflow bool,
+) (queuecli.ActiveStakingEvent, error) {
+	txHex, err := getTxHex(tx)
+	if err != nil {
+		return queuecli.ActiveStakingEvent{}, err
+	}
+
+	return queuecli.NewActiveStakingEvent(
+		tx.TxHash().String(),
+		hex.EncodeToString(schnorr.SerializePubKey(stakerPk)),
+		hex.EncodeToString(schnorr.SerializePubKey(fpPk)),
+		stakingValue,
+		height,
+		timestamp.Unix(),
+		uint64(stakingTime),
+		uint64(stakingOutputIndex),
+		txHex,
+		isOverflow,
+	), nil
+}
+
+// newUnbondingStakingEvent returns the event of the unbonding tx included at
+// the given height in the block with the given timestamp
+func newUnbondingStakingEvent(
+	tx *wire.MsgTx,
+	stakingTxHash *chainhash.Hash,
+	height uint64,
+	timestamp time.Time,
+	unbondingTime uint32,
+) (queuecli.UnbondingStakingEvent, error) {
+	unbondingTxHex, err := getTxHex(tx)
+	if err != nil {
+		return queuecli.UnbondingStakingEvent{}, err
+	}
+
+	return queuecli.NewUnbondingStakingEvent(
+		stakingTxHash.String(),
+		height,
+		timestamp.Unix(),
+		uint64(unbondingTime),
+		// valid unbonding tx always has one output
+		0,
+		unbondingTxHex,
+		tx.TxHash().String(),
+	), nil
+}
+
 func getTxHex(tx *wire.MsgTx) (string, error) {
 	var buf bytes.Buffer
 	if err := tx.Serialize(&buf); err != nil {
@@ -1052,34 +1983,38 @@ func getTxHex(tx *wire.MsgTx) (string, error) {
 }
 
 // validateStakingTx performs the validation checks for the staking tx
-// such as min and max staking amount and staking time
-func (si *StakingIndexer) validateStakingTx(params *parser.ParsedVersionedGlobalParams, stakingData *btcstaking.ParsedV0StakingTx) error {
+// against the global params, and returns the code of the violated rule
+// together with the error if the tx is invalid
+func (si *StakingIndexer) validateStakingTx(
+	params *parser.ParsedVersionedGlobalParams,
+	stakingData *btcstaking.ParsedV0StakingTx,
+) (indexerstore.InvalidStakingReason, error) {
 	value := btcutil.Amount(stakingData.StakingOutput.Value)
 	// Minimum staking amount check
 	if value < params.MinStakingAmount {
-		return fmt.Errorf("%w: staking amount is too low, expected: %v, got: %v",
+		return indexerstore.StakingAmountTooLow, fmt.Errorf("%w: staking amount is too low, expected: %v, got: %v",
 			ErrInvalidStakingTx, params.MinStakingAmount, value)
 	}
 
 	// Maximum staking amount check
 	if value > params.MaxStakingAmount {
-		return fmt.Errorf("%w: staking amount is too high, expected: %v, got: %v",
+		return indexerstore.StakingAmountTooHigh, fmt.Errorf("%w: staking amount is too high, expected: %v, got: %v",
 			ErrInvalidStakingTx, params.MaxStakingAmount, value)
 	}
 
 	// Maximum staking time check
 	if uint64(stakingData.OpReturnData.StakingTime) > uint64(params.MaxStakingTime) {
-		return fmt.Errorf("%w: staking time is too high, expected: %v, got: %v",
+		return indexerstore.StakingTimeTooHigh, fmt.Errorf("%w: staking time is too high, expected: %v, got: %v",
 			ErrInvalidStakingTx, params.MaxStakingTime, stakingData.OpReturnData.StakingTime)
 	}
 
 	// Minimum staking time check
 	if uint64(stakingData.OpReturnData.StakingTime) < uint64(params.MinStakingTime) {
-		return fmt.Errorf("%w: staking time is too low, expected: %v, got: %v",
+		return indexerstore.StakingTimeTooLow, fmt.Errorf("%w: staking time is too low, expected: %v, got: %v",
 			ErrInvalidStakingTx, params.MinStakingTime, stakingData.OpReturnData.StakingTime)
 	}
 
-	return nil
+	return 0, nil
 }
 
 func (si *StakingIndexer) isOverflow(height uint64, params *parser.ParsedVersionedGlobalParams) (bool, error) {
@@ -1110,6 +2045,12 @@ func (si *StakingIndexer) GetConfirmedTvl() (uint64, error) {
 	return si.is.GetConfirmedTvl()
 }
 
+// GetFinalityProviderStats returns the totals of the delegations to the
+// finality provider with the given public key, or nil if there is none
+func (si *StakingIndexer) GetFinalityProviderStats(fpPk *btcec.PublicKey) (*indexerstore.FinalityProviderStats, error) {
+	return si.is.GetFinalityProviderStats(fpPk)
+}
+
 func (si *StakingIndexer) getVersionedParams(height uint64) (*parser.ParsedVersionedGlobalParams, error) {
 	params := si.paramsVersions.GetVersionedGlobalParamsByHeight(height)
 	if params == nil {
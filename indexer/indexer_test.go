@@ -1,16 +1,22 @@
 package indexer_test
 
 import (
+	"encoding/hex"
 	"fmt"
+	"math"
 	"math/rand"
 	"path/filepath"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/babylonlabs-io/babylon/btcstaking"
 	bbndatagen "github.com/babylonlabs-io/babylon/testutil/datagen"
 	"github.com/babylonlabs-io/networks/parameters/parser"
+	queuecli "github.com/babylonlabs-io/staking-queue-client/client"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
 	"github.com/btcsuite/btcd/btcutil"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/wire"
@@ -20,7 +26,9 @@ import (
 
 	"github.com/babylonlabs-io/staking-indexer/btcscanner"
 	"github.com/babylonlabs-io/staking-indexer/config"
+	"github.com/babylonlabs-io/staking-indexer/consumer"
 	"github.com/babylonlabs-io/staking-indexer/indexer"
+	"github.com/babylonlabs-io/staking-indexer/indexerstore"
 	"github.com/babylonlabs-io/staking-indexer/testutils"
 	"github.com/babylonlabs-io/staking-indexer/testutils/datagen"
 	"github.com/babylonlabs-io/staking-indexer/testutils/mocks"
@@ -266,9 +274,181 @@ func FuzzBlockHandler(f *testing.F) {
 
 		// calculate unconfirmed tvl
 		testUnconfirmedScenario := NewTestScenario(r, t, sysParamsVersions, 80, n, false)
-		unconfirmedTvl, err := stakingIndexer.CalculateTvlInUnconfirmedBlocks(testUnconfirmedScenario.Blocks)
+		unconfirmedInfo, err := stakingIndexer.CalculateTvlInUnconfirmedBlocks(testUnconfirmedScenario.Blocks)
 		require.NoError(t, err)
-		require.Equal(t, testUnconfirmedScenario.Tvl, unconfirmedTvl)
+		require.Equal(t, testUnconfirmedScenario.Tvl, unconfirmedInfo.Tvl)
+		require.Equal(t, uint64(len(testUnconfirmedScenario.StakingEvents)), unconfirmedInfo.NumStakingTxs)
+		require.Equal(t, uint64(len(testUnconfirmedScenario.UnbondingEvents)), unconfirmedInfo.NumUnbondingTxs)
+		require.Zero(t, unconfirmedInfo.NumWithdrawalTxs)
+	})
+}
+
+// FuzzRollbackToHeight tests that rolling back to a fork height restores
+// the state as if only the blocks up to the fork height were processed
+func FuzzRollbackToHeight(f *testing.F) {
+	// small seed because db open/close is slow
+	bbndatagen.AddRandomSeedsToFuzzer(f, 10)
+
+	f.Fuzz(func(t *testing.T, seed int64) {
+		r := rand.New(rand.NewSource(seed))
+
+		homePath := filepath.Join(t.TempDir(), "indexer")
+		cfg := config.DefaultConfigWithHome(homePath)
+
+		n := r.Intn(100) + 1
+		sysParamsVersions := datagen.GenerateGlobalParamsVersions(r, t)
+		testScenario := NewTestScenario(r, t, sysParamsVersions, 80, n, true)
+
+		db, err := cfg.DatabaseConfig.GetDbBackend()
+		require.NoError(t, err)
+		chainUpdateInfoChan := make(chan *btcscanner.ChainUpdateInfo)
+		mockBtcScanner := NewMockedBtcScanner(t, chainUpdateInfoChan)
+		stakingIndexer, err := indexer.NewStakingIndexer(cfg, zap.NewNop(), NewMockedConsumer(t), db, sysParamsVersions, mockBtcScanner)
+		require.NoError(t, err)
+
+		defer func() {
+			err = db.Close()
+			require.NoError(t, err)
+		}()
+
+		for _, b := range testScenario.Blocks {
+			err := stakingIndexer.HandleConfirmedBlock(b)
+			require.NoError(t, err)
+		}
+
+		// roll back to a random height below the last processed height
+		firstHeight := testScenario.Blocks[0].Height
+		lastHeight := testScenario.Blocks[len(testScenario.Blocks)-1].Height
+		forkHeight := firstHeight + r.Int31n(lastHeight-firstHeight+1) - 1
+		err = stakingIndexer.RollbackToHeight(uint64(forkHeight))
+		require.NoError(t, err)
+
+		require.Equal(t, uint64(forkHeight)+1, stakingIndexer.GetStartHeight())
+		tvl, err := stakingIndexer.GetConfirmedTvl()
+		require.NoError(t, err)
+		require.Equal(t, uint64(testScenario.TvlToHeight[forkHeight]), tvl)
+
+		for _, stakingEv := range testScenario.StakingEvents {
+			storedTx, err := stakingIndexer.GetStakingTxByHash(stakingEv.StakingTx.Hash())
+			require.NoError(t, err)
+			require.Equal(t, stakingEv.Height <= forkHeight, storedTx != nil)
+		}
+
+		for _, unbondingEv := range testScenario.UnbondingEvents {
+			storedTx, err := stakingIndexer.GetUnbondingTxByHash(unbondingEv.UnbondingTx.Hash())
+			require.NoError(t, err)
+			require.Equal(t, unbondingEv.Height <= forkHeight, storedTx != nil)
+		}
+
+		// process the rolled back blocks again and the result should be
+		// the same as processing them once
+		for _, b := range testScenario.Blocks {
+			if b.Height <= forkHeight {
+				continue
+			}
+			err := stakingIndexer.HandleConfirmedBlock(b)
+			require.NoError(t, err)
+		}
+		tvl, err = stakingIndexer.GetConfirmedTvl()
+		require.NoError(t, err)
+		require.Equal(t, uint64(testScenario.Tvl), tvl)
+
+		for _, stakingEv := range testScenario.StakingEvents {
+			storedTx, err := stakingIndexer.GetStakingTxByHash(stakingEv.StakingTx.Hash())
+			require.NoError(t, err)
+			require.NotNil(t, storedTx)
+			require.Equal(t, stakingEv.IsOverflow, storedTx.IsOverflow)
+		}
+	})
+}
+
+// FuzzFinalityProviderInfoEvents tests that the finality provider info events
+// carry the totals of the finality providers changed by the confirmed blocks
+// and by the rollbacks
+func FuzzFinalityProviderInfoEvents(f *testing.F) {
+	// small seed because db open/close is slow
+	bbndatagen.AddRandomSeedsToFuzzer(f, 5)
+
+	f.Fuzz(func(t *testing.T, seed int64) {
+		r := rand.New(rand.NewSource(seed))
+
+		homePath := filepath.Join(t.TempDir(), "indexer")
+		cfg := config.DefaultConfigWithHome(homePath)
+		cfg.FinalityProviderEventEnabled = true
+
+		n := r.Intn(50) + 1
+		sysParamsVersions := datagen.GenerateGlobalParamsVersions(r, t)
+		testScenario := NewTestScenario(r, t, sysParamsVersions, 80, n, true)
+
+		db, err := cfg.DatabaseConfig.GetDbBackend()
+		require.NoError(t, err)
+		chainUpdateInfoChan := make(chan *btcscanner.ChainUpdateInfo)
+		mockBtcScanner := NewMockedBtcScanner(t, chainUpdateInfoChan)
+		stakingIndexer, err := indexer.NewStakingIndexer(cfg, zap.NewNop(), NewMockedConsumer(t), db, sysParamsVersions, mockBtcScanner)
+		require.NoError(t, err)
+
+		defer func() {
+			err = db.Close()
+			require.NoError(t, err)
+		}()
+
+		for _, b := range testScenario.Blocks {
+			err := stakingIndexer.HandleConfirmedBlock(b)
+			require.NoError(t, err)
+		}
+
+		// the last event of every finality provider carries its totals
+		checkFpEvents := func() map[string]*consumer.FinalityProviderInfoEvent {
+			envelopes, err := stakingIndexer.GetOutboxEvents(1, math.MaxInt32)
+			require.NoError(t, err)
+			lastFpEvents := make(map[string]*consumer.FinalityProviderInfoEvent)
+			for _, env := range envelopes {
+				if env.EventType != consumer.FinalityProviderInfoEventType {
+					continue
+				}
+				ev, err := env.DecodeEvent()
+				require.NoError(t, err)
+				fpEv := ev.(*consumer.FinalityProviderInfoEvent)
+				lastFpEvents[fpEv.FinalityProviderPkHex] = fpEv
+			}
+
+			for _, stakingEv := range testScenario.StakingEvents {
+				fpPk := stakingEv.StakingTxData.FinalityProviderKey
+				fpEv, ok := lastFpEvents[hex.EncodeToString(schnorr.SerializePubKey(fpPk))]
+				require.True(t, ok)
+
+				stats, err := stakingIndexer.GetFinalityProviderStats(fpPk)
+				require.NoError(t, err)
+				require.NotNil(t, stats)
+				require.Equal(t, stats.ActiveStake, fpEv.ActiveTvl)
+				require.Equal(t, stats.OverflowStake, fpEv.OverflowTvl)
+				require.Equal(t, stats.ActiveDelegations, fpEv.ActiveDelegations)
+				require.Equal(t, stats.UnbondedDelegations, fpEv.UnbondedDelegations)
+			}
+
+			return lastFpEvents
+		}
+		checkFpEvents()
+
+		// roll back to a random height below the last processed height
+		firstHeight := testScenario.Blocks[0].Height
+		lastHeight := testScenario.Blocks[len(testScenario.Blocks)-1].Height
+		forkHeight := firstHeight + r.Int31n(lastHeight-firstHeight+1) - 1
+		err = stakingIndexer.RollbackToHeight(uint64(forkHeight))
+		require.NoError(t, err)
+
+		// the finality providers changed by the rolled back blocks get an
+		// event at the fork height
+		lastFpEvents := checkFpEvents()
+		for _, stakingEv := range testScenario.StakingEvents {
+			if stakingEv.Height <= forkHeight {
+				continue
+			}
+			fpPkHex := hex.EncodeToString(schnorr.SerializePubKey(stakingEv.StakingTxData.FinalityProviderKey))
+			require.Equal(t, uint64(forkHeight), lastFpEvents[fpPkHex].Height)
+			require.Zero(t, lastFpEvents[fpPkHex].ActiveTvl)
+			require.Zero(t, lastFpEvents[fpPkHex].OverflowTvl)
+		}
 	})
 }
 
@@ -319,6 +499,7 @@ func FuzzGetStartHeight(f *testing.F) {
 			for i := 0; i < numBlocks; i++ {
 				b := &types.IndexedBlock{
 					Height: int32(initialHeight) + int32(i),
+					Header: &wire.BlockHeader{Timestamp: time.Now()},
 				}
 				confirmedBlocks = append(confirmedBlocks, b)
 			}
@@ -449,6 +630,1125 @@ func FuzzVerifyUnbondingTx(f *testing.F) {
 	})
 }
 
+// FuzzProcessInvalidStakingTx tests that a staking tx that does not follow
+// the global params is stored with the reason and an invalid staking event
+// is pushed
+func FuzzProcessInvalidStakingTx(f *testing.F) {
+	bbndatagen.AddRandomSeedsToFuzzer(f, 10)
+
+	f.Fuzz(func(t *testing.T, seed int64) {
+		r := rand.New(rand.NewSource(seed))
+
+		homePath := filepath.Join(t.TempDir(), "indexer")
+		cfg := config.DefaultConfigWithHome(homePath)
+
+		sysParamsVersions := datagen.GenerateGlobalParamsVersions(r, t)
+		// Select the first params versions to play with
+		params := sysParamsVersions.Versions[0]
+
+		// generate a staking tx with the staking amount higher than the max
+		stakingData := datagen.GenerateTestStakingData(t, r, params)
+		stakingData.StakingAmount = params.MaxStakingAmount + btcutil.Amount(r.Int63n(1000)+1)
+		_, stakingTx := datagen.GenerateStakingTxFromTestData(t, r, params, stakingData)
+		mockedHeight := uint64(params.ActivationHeight) + 1
+
+		ctl := gomock.NewController(t)
+		mockedConsumer := mocks.NewMockEventConsumer(ctl)
+		mockedConsumer.EXPECT().PushInvalidStakingEvent(gomock.Any()).DoAndReturn(
+			func(ev *consumer.InvalidStakingEvent) error {
+				require.Equal(t, stakingTx.Hash().String(), ev.StakingTxHashHex)
+				require.Equal(t, uint64(stakingData.StakingAmount), ev.StakingValue)
+				require.Equal(t, mockedHeight, ev.StakingStartHeight)
+				require.Equal(t, indexerstore.StakingAmountTooHigh.String(), ev.Reason)
+				return nil
+			}).Times(2)
+
+		db, err := cfg.DatabaseConfig.GetDbBackend()
+		require.NoError(t, err)
+		chainUpdateInfoChan := make(chan *btcscanner.ChainUpdateInfo)
+		mockBtcScanner := NewMockedBtcScanner(t, chainUpdateInfoChan)
+		stakingIndexer, err := indexer.NewStakingIndexer(cfg, zap.NewNop(), mockedConsumer, db, sysParamsVersions, mockBtcScanner)
+		require.NoError(t, err)
+		defer func() {
+			err = db.Close()
+			require.NoError(t, err)
+		}()
+
+		// process the invalid staking tx twice and it should be stored once
+		for i := 0; i < 2; i++ {
+			err = stakingIndexer.ProcessStakingTx(
+				stakingTx.MsgTx(),
+				getParsedStakingData(stakingData, stakingTx.MsgTx(), params),
+				mockedHeight, time.Now(), params)
+			require.NoError(t, err)
+		}
+		err = stakingIndexer.PublishOutboxEvents()
+		require.NoError(t, err)
+
+		storedStakingTx, err := stakingIndexer.GetStakingTxByHash(stakingTx.Hash())
+		require.NoError(t, err)
+		require.Nil(t, storedStakingTx)
+
+		storedInvalidTx, err := stakingIndexer.GetInvalidStakingTxByHash(stakingTx.Hash())
+		require.NoError(t, err)
+		require.NotNil(t, storedInvalidTx)
+		require.Equal(t, stakingTx.Hash().String(), storedInvalidTx.Tx.TxHash().String())
+		require.Equal(t, mockedHeight, storedInvalidTx.InclusionHeight)
+		require.Equal(t, uint64(stakingData.StakingAmount), storedInvalidTx.StakingValue)
+		require.Equal(t, uint32(stakingData.StakingTime), storedInvalidTx.StakingTime)
+		require.True(t, testutils.PubKeysEqual(stakingData.StakerKey, storedInvalidTx.StakerPk))
+		require.True(t, testutils.PubKeysEqual(stakingData.FinalityProviderKey, storedInvalidTx.FinalityProviderPk))
+		require.Equal(t, indexerstore.StakingAmountTooHigh, storedInvalidTx.Reason)
+
+		processed, err := stakingIndexer.IsTxProcessed(stakingTx.Hash())
+		require.NoError(t, err)
+		require.True(t, processed)
+
+		tvl, err := stakingIndexer.GetConfirmedTvl()
+		require.NoError(t, err)
+		require.Zero(t, tvl)
+	})
+}
+
+// FuzzProcessSlashingTx tests that slashing txs spending staking outputs
+// or unbonding outputs are identified and stored, and the tvl is only
+// decremented when the staking output is slashed
+func FuzzProcessSlashingTx(f *testing.F) {
+	bbndatagen.AddRandomSeedsToFuzzer(f, 10)
+
+	f.Fuzz(func(t *testing.T, seed int64) {
+		r := rand.New(rand.NewSource(seed))
+
+		homePath := filepath.Join(t.TempDir(), "indexer")
+		cfg := config.DefaultConfigWithHome(homePath)
+
+		sysParamsVersions := datagen.GenerateGlobalParamsVersions(r, t)
+
+		db, err := cfg.DatabaseConfig.GetDbBackend()
+		require.NoError(t, err)
+		chainUpdateInfoChan := make(chan *btcscanner.ChainUpdateInfo)
+		mockBtcScanner := NewMockedBtcScanner(t, chainUpdateInfoChan)
+		stakingIndexer, err := indexer.NewStakingIndexer(cfg, zap.NewNop(), NewMockedConsumer(t), db, sysParamsVersions, mockBtcScanner)
+		require.NoError(t, err)
+		defer func() {
+			err = db.Close()
+			require.NoError(t, err)
+		}()
+
+		height := int32(sysParamsVersions.Versions[0].ActivationHeight) + 1
+		params := sysParamsVersions.GetVersionedGlobalParamsByHeight(uint64(height))
+		require.NotNil(t, params)
+
+		// 1. generate and add two valid staking txs to the indexer
+		stakingData1 := datagen.GenerateTestStakingData(t, r, params)
+		_, stakingTx1 := datagen.GenerateStakingTxFromTestData(t, r, params, stakingData1)
+		stakingData2 := datagen.GenerateTestStakingData(t, r, params)
+		_, stakingTx2 := datagen.GenerateStakingTxFromTestData(t, r, params, stakingData2)
+		err = stakingIndexer.HandleConfirmedBlock(&types.IndexedBlock{
+			Height: height,
+			Header: &wire.BlockHeader{Timestamp: time.Now()},
+			Txs:    []*btcutil.Tx{stakingTx1, stakingTx2},
+		})
+		require.NoError(t, err)
+		storedStakingTx1, err := stakingIndexer.GetStakingTxByHash(stakingTx1.Hash())
+		require.NoError(t, err)
+		require.NotNil(t, storedStakingTx1)
+		storedStakingTx2, err := stakingIndexer.GetStakingTxByHash(stakingTx2.Hash())
+		require.NoError(t, err)
+		require.NotNil(t, storedStakingTx2)
+
+		// 2. slash the first staking tx and unbond the second one
+		slashingTx1 := datagen.GenerateSlashingTxFromStaking(t, r, params, stakingData1, stakingTx1.Hash(), 0)
+		unbondingTx2 := datagen.GenerateUnbondingTxFromStaking(t, params, stakingData2, stakingTx2.Hash(), 0)
+		height++
+		tvlBefore, err := stakingIndexer.GetConfirmedTvl()
+		require.NoError(t, err)
+		err = stakingIndexer.HandleConfirmedBlock(&types.IndexedBlock{
+			Height: height,
+			Header: &wire.BlockHeader{Timestamp: time.Now()},
+			Txs:    []*btcutil.Tx{slashingTx1, unbondingTx2},
+		})
+		require.NoError(t, err)
+
+		storedSlashingTx1, err := stakingIndexer.GetSlashingTxByHash(slashingTx1.Hash())
+		require.NoError(t, err)
+		require.NotNil(t, storedSlashingTx1)
+		require.Equal(t, stakingTx1.Hash(), storedSlashingTx1.StakingTxHash)
+		require.False(t, storedSlashingTx1.IsFromUnbonding())
+		require.Equal(t, uint64(height), storedSlashingTx1.InclusionHeight)
+
+		expectedTvl := tvlBefore
+		if !storedStakingTx1.IsOverflow {
+			expectedTvl -= storedStakingTx1.StakingValue
+		}
+		if !storedStakingTx2.IsOverflow {
+			expectedTvl -= storedStakingTx2.StakingValue
+		}
+		tvl, err := stakingIndexer.GetConfirmedTvl()
+		require.NoError(t, err)
+		require.Equal(t, expectedTvl, tvl)
+
+		// 3. slash the unbonding tx and the tvl should not change
+		slashingTx2 := datagen.GenerateSlashingTxFromUnbonding(t, r, params, stakingData2, unbondingTx2.Hash())
+		height++
+		err = stakingIndexer.HandleConfirmedBlock(&types.IndexedBlock{
+			Height: height,
+			Header: &wire.BlockHeader{Timestamp: time.Now()},
+			Txs:    []*btcutil.Tx{slashingTx2},
+		})
+		require.NoError(t, err)
+
+		storedSlashingTx2, err := stakingIndexer.GetSlashingTxByHash(slashingTx2.Hash())
+		require.NoError(t, err)
+		require.NotNil(t, storedSlashingTx2)
+		require.Equal(t, stakingTx2.Hash(), storedSlashingTx2.StakingTxHash)
+		require.True(t, storedSlashingTx2.IsFromUnbonding())
+		require.Equal(t, unbondingTx2.Hash(), storedSlashingTx2.UnbondingTxHash)
+
+		tvl, err = stakingIndexer.GetConfirmedTvl()
+		require.NoError(t, err)
+		require.Equal(t, expectedTvl, tvl)
+
+		// 4. roll back the slashing txs and the tvl should be restored
+		err = stakingIndexer.RollbackToHeight(uint64(height) - 2)
+		require.NoError(t, err)
+		tvl, err = stakingIndexer.GetConfirmedTvl()
+		require.NoError(t, err)
+		require.Equal(t, tvlBefore, tvl)
+		storedSlashingTx1, err = stakingIndexer.GetSlashingTxByHash(slashingTx1.Hash())
+		require.NoError(t, err)
+		require.Nil(t, storedSlashingTx1)
+	})
+}
+
+// FuzzProcessWithdrawalTx tests that withdrawal txs spending staking outputs
+// or unbonding outputs are stored without changing the tvl
+func FuzzProcessWithdrawalTx(f *testing.F) {
+	bbndatagen.AddRandomSeedsToFuzzer(f, 10)
+
+	f.Fuzz(func(t *testing.T, seed int64) {
+		r := rand.New(rand.NewSource(seed))
+
+		homePath := filepath.Join(t.TempDir(), "indexer")
+		cfg := config.DefaultConfigWithHome(homePath)
+
+		sysParamsVersions := datagen.GenerateGlobalParamsVersions(r, t)
+
+		db, err := cfg.DatabaseConfig.GetDbBackend()
+		require.NoError(t, err)
+		chainUpdateInfoChan := make(chan *btcscanner.ChainUpdateInfo)
+		mockBtcScanner := NewMockedBtcScanner(t, chainUpdateInfoChan)
+		stakingIndexer, err := indexer.NewStakingIndexer(cfg, zap.NewNop(), NewMockedConsumer(t), db, sysParamsVersions, mockBtcScanner)
+		require.NoError(t, err)
+		defer func() {
+			err = db.Close()
+			require.NoError(t, err)
+		}()
+
+		height := int32(sysParamsVersions.Versions[0].ActivationHeight) + 1
+		params := sysParamsVersions.GetVersionedGlobalParamsByHeight(uint64(height))
+		require.NotNil(t, params)
+
+		// 1. generate and add two valid staking txs to the indexer and
+		// unbond the second one
+		stakingData1 := datagen.GenerateTestStakingData(t, r, params)
+		_, stakingTx1 := datagen.GenerateStakingTxFromTestData(t, r, params, stakingData1)
+		stakingData2 := datagen.GenerateTestStakingData(t, r, params)
+		_, stakingTx2 := datagen.GenerateStakingTxFromTestData(t, r, params, stakingData2)
+		unbondingTx2 := datagen.GenerateUnbondingTxFromStaking(t, params, stakingData2, stakingTx2.Hash(), 0)
+		err = stakingIndexer.HandleConfirmedBlock(&types.IndexedBlock{
+			Height: height,
+			Header: &wire.BlockHeader{Timestamp: time.Now()},
+			Txs:    []*btcutil.Tx{stakingTx1, stakingTx2, unbondingTx2},
+		})
+		require.NoError(t, err)
+		tvlBefore, err := stakingIndexer.GetConfirmedTvl()
+		require.NoError(t, err)
+
+		// no delegations are withdrawn
+		storedWithdrawalTx, err := stakingIndexer.GetWithdrawalTxByStakingTxHash(stakingTx1.Hash())
+		require.NoError(t, err)
+		require.Nil(t, storedWithdrawalTx)
+
+		// 2. withdrawals in unconfirmed blocks before the timelocks expire
+		// are not counted
+		withdrawalTx1 := datagen.GenerateWithdrawalTxFromStaking(t, r, params, stakingData1, stakingTx1.Hash(), 0)
+		withdrawalTx2 := datagen.GenerateWithdrawalTxFromUnbonding(t, r, params, stakingData2, unbondingTx2.Hash())
+		unconfirmedInfo, err := stakingIndexer.CalculateTvlInUnconfirmedBlocks([]*types.IndexedBlock{{
+			Height: height + 1,
+			Header: &wire.BlockHeader{Timestamp: time.Now()},
+			Txs:    []*btcutil.Tx{withdrawalTx1, withdrawalTx2},
+		}})
+		require.NoError(t, err)
+		require.Zero(t, unconfirmedInfo.Tvl)
+		require.Zero(t, unconfirmedInfo.NumWithdrawalTxs)
+		require.Zero(t, unconfirmedInfo.NumStakingTxs)
+		require.Zero(t, unconfirmedInfo.NumUnbondingTxs)
+
+		// 3. withdrawals before the timelocks expire are rejected
+		err = stakingIndexer.HandleConfirmedBlock(&types.IndexedBlock{
+			Height: height + 1,
+			Header: &wire.BlockHeader{Timestamp: time.Now()},
+			Txs:    []*btcutil.Tx{withdrawalTx1, withdrawalTx2},
+		})
+		require.NoError(t, err)
+		processed, err := stakingIndexer.IsTxProcessed(withdrawalTx1.Hash())
+		require.NoError(t, err)
+		require.False(t, processed)
+		processed, err = stakingIndexer.IsTxProcessed(withdrawalTx2.Hash())
+		require.NoError(t, err)
+		require.False(t, processed)
+		storedStakingTx1, err := stakingIndexer.GetStakingTxByHash(stakingTx1.Hash())
+		require.NoError(t, err)
+		require.Equal(t, indexerstore.StateActive, storedStakingTx1.State)
+		storedStakingTx2, err := stakingIndexer.GetStakingTxByHash(stakingTx2.Hash())
+		require.NoError(t, err)
+		require.Equal(t, indexerstore.StateUnbonding, storedStakingTx2.State)
+
+		// 4. withdraw from the first staking tx and the second unbonding tx
+		// after both timelocks expire
+		height += int32(max(uint32(stakingData1.StakingTime), uint32(params.UnbondingTime)))
+
+		// the withdrawals in unconfirmed blocks after the timelocks expire
+		// are identified but do not change the tvl
+		unconfirmedInfo, err = stakingIndexer.CalculateTvlInUnconfirmedBlocks([]*types.IndexedBlock{{
+			Height: height,
+			Header: &wire.BlockHeader{Timestamp: time.Now()},
+			Txs:    []*btcutil.Tx{withdrawalTx1, withdrawalTx2},
+		}})
+		require.NoError(t, err)
+		require.Zero(t, unconfirmedInfo.Tvl)
+		require.Equal(t, uint64(2), unconfirmedInfo.NumWithdrawalTxs)
+
+		err = stakingIndexer.HandleConfirmedBlock(&types.IndexedBlock{
+			Height: height,
+			Header: &wire.BlockHeader{Timestamp: time.Now()},
+			Txs:    []*btcutil.Tx{withdrawalTx1, withdrawalTx2},
+		})
+		require.NoError(t, err)
+
+		storedWithdrawalTx1, err := stakingIndexer.GetWithdrawalTxByHash(withdrawalTx1.Hash())
+		require.NoError(t, err)
+		require.NotNil(t, storedWithdrawalTx1)
+		require.Equal(t, stakingTx1.Hash(), storedWithdrawalTx1.StakingTxHash)
+		require.False(t, storedWithdrawalTx1.IsFromUnbonding())
+		require.Equal(t, uint64(height), storedWithdrawalTx1.InclusionHeight)
+
+		storedWithdrawalTx2, err := stakingIndexer.GetWithdrawalTxByStakingTxHash(stakingTx2.Hash())
+		require.NoError(t, err)
+		require.NotNil(t, storedWithdrawalTx2)
+		require.Equal(t, withdrawalTx2.Hash().String(), storedWithdrawalTx2.Tx.TxHash().String())
+		require.True(t, storedWithdrawalTx2.IsFromUnbonding())
+		require.Equal(t, unbondingTx2.Hash(), storedWithdrawalTx2.UnbondingTxHash)
+
+		processed, err = stakingIndexer.IsTxProcessed(withdrawalTx2.Hash())
+		require.NoError(t, err)
+		require.True(t, processed)
+
+		// the delegations moved through the unbonded state to withdrawn
+		storedStakingTx1, err = stakingIndexer.GetStakingTxByHash(stakingTx1.Hash())
+		require.NoError(t, err)
+		requireStateTransitions(t, storedStakingTx1,
+			indexerstore.StateActive, indexerstore.StateUnbonded, indexerstore.StateWithdrawn)
+		require.Equal(t, withdrawalTx1.Hash(), storedStakingTx1.StateTransitions[2].TxHash)
+		require.Equal(t, uint64(height), storedStakingTx1.StateTransitions[2].Height)
+		storedStakingTx2, err = stakingIndexer.GetStakingTxByHash(stakingTx2.Hash())
+		require.NoError(t, err)
+		requireStateTransitions(t, storedStakingTx2,
+			indexerstore.StateActive, indexerstore.StateUnbonding,
+			indexerstore.StateUnbonded, indexerstore.StateWithdrawn)
+		require.Equal(t, unbondingTx2.Hash(), storedStakingTx2.StateTransitions[1].TxHash)
+
+		tvl, err := stakingIndexer.GetConfirmedTvl()
+		require.NoError(t, err)
+		require.Equal(t, tvlBefore, tvl)
+
+		// 5. roll back the withdrawal txs
+		err = stakingIndexer.RollbackToHeight(uint64(height) - 1)
+		require.NoError(t, err)
+		storedWithdrawalTx, err = stakingIndexer.GetWithdrawalTxByStakingTxHash(stakingTx2.Hash())
+		require.NoError(t, err)
+		require.Nil(t, storedWithdrawalTx)
+		processed, err = stakingIndexer.IsTxProcessed(withdrawalTx1.Hash())
+		require.NoError(t, err)
+		require.False(t, processed)
+
+		// the state transitions are rolled back as well
+		storedStakingTx1, err = stakingIndexer.GetStakingTxByHash(stakingTx1.Hash())
+		require.NoError(t, err)
+		requireStateTransitions(t, storedStakingTx1, indexerstore.StateActive)
+		storedStakingTx2, err = stakingIndexer.GetStakingTxByHash(stakingTx2.Hash())
+		require.NoError(t, err)
+		requireStateTransitions(t, storedStakingTx2,
+			indexerstore.StateActive, indexerstore.StateUnbonding)
+	})
+}
+
+func requireStateTransitions(
+	t *testing.T,
+	stakingTx *indexerstore.StoredStakingTransaction,
+	states ...indexerstore.DelegationState,
+) {
+	require.Equal(t, states[len(states)-1], stakingTx.State)
+	require.Len(t, stakingTx.StateTransitions, len(states))
+	for i, state := range states {
+		require.Equal(t, state, stakingTx.StateTransitions[i].State)
+	}
+}
+
+func FuzzProcessTimelockExpiry(f *testing.F) {
+	bbndatagen.AddRandomSeedsToFuzzer(f, 10)
+
+	f.Fuzz(func(t *testing.T, seed int64) {
+		r := rand.New(rand.NewSource(seed))
+
+		homePath := filepath.Join(t.TempDir(), "indexer")
+		cfg := config.DefaultConfigWithHome(homePath)
+
+		sysParamsVersions := datagen.GenerateGlobalParamsVersions(r, t)
+
+		// record the pushed timelock expired events
+		var expiredEvents []*consumer.TimelockExpiredEvent
+		ctl := gomock.NewController(t)
+		mockedConsumer := mocks.NewMockEventConsumer(ctl)
+		mockedConsumer.EXPECT().PushStakingEvent(gomock.Any()).Return(nil).AnyTimes()
+		mockedConsumer.EXPECT().PushUnbondingEvent(gomock.Any()).Return(nil).AnyTimes()
+		mockedConsumer.EXPECT().PushRollbackEvent(gomock.Any()).Return(nil).AnyTimes()
+		mockedConsumer.EXPECT().PushTimelockExpiredEvent(gomock.Any()).DoAndReturn(
+			func(ev *consumer.TimelockExpiredEvent) error {
+				expiredEvents = append(expiredEvents, ev)
+				return nil
+			}).AnyTimes()
+
+		db, err := cfg.DatabaseConfig.GetDbBackend()
+		require.NoError(t, err)
+		chainUpdateInfoChan := make(chan *btcscanner.ChainUpdateInfo)
+		mockBtcScanner := NewMockedBtcScanner(t, chainUpdateInfoChan)
+		stakingIndexer, err := indexer.NewStakingIndexer(cfg, zap.NewNop(), mockedConsumer, db, sysParamsVersions, mockBtcScanner)
+		require.NoError(t, err)
+		defer func() {
+			err = db.Close()
+			require.NoError(t, err)
+		}()
+
+		height := int32(sysParamsVersions.Versions[0].ActivationHeight) + 1
+		params := sysParamsVersions.GetVersionedGlobalParamsByHeight(uint64(height))
+		require.NotNil(t, params)
+
+		// 1. generate and add two valid staking txs to the indexer and
+		// unbond the second one
+		stakingData1 := datagen.GenerateTestStakingData(t, r, params)
+		_, stakingTx1 := datagen.GenerateStakingTxFromTestData(t, r, params, stakingData1)
+		stakingData2 := datagen.GenerateTestStakingData(t, r, params)
+		_, stakingTx2 := datagen.GenerateStakingTxFromTestData(t, r, params, stakingData2)
+		unbondingTx2 := datagen.GenerateUnbondingTxFromStaking(t, params, stakingData2, stakingTx2.Hash(), 0)
+		err = stakingIndexer.HandleConfirmedBlock(&types.IndexedBlock{
+			Height: height,
+			Header: &wire.BlockHeader{Timestamp: time.Now()},
+			Txs:    []*btcutil.Tx{stakingTx1, stakingTx2, unbondingTx2},
+		})
+		require.NoError(t, err)
+
+		// 2. process the blocks at which the timelocks expire, the staking
+		// timelock of the unbonded staking tx is ignored
+		stakingExpiryHeight := height + int32(stakingData1.StakingTime)
+		unbondingExpiryHeight := height + int32(params.UnbondingTime)
+		expiryHeights := []int32{stakingExpiryHeight, unbondingExpiryHeight, height + int32(stakingData2.StakingTime)}
+		sort.Slice(expiryHeights, func(i, j int) bool { return expiryHeights[i] < expiryHeights[j] })
+		lastHeight := height
+		for _, h := range expiryHeights {
+			if h == lastHeight {
+				continue
+			}
+			err = stakingIndexer.HandleConfirmedBlock(&types.IndexedBlock{
+				Height: h,
+				Header: &wire.BlockHeader{Timestamp: time.Now()},
+			})
+			require.NoError(t, err)
+			lastHeight = h
+		}
+		err = stakingIndexer.PublishOutboxEvents()
+		require.NoError(t, err)
+
+		require.Len(t, expiredEvents, 2)
+		for _, ev := range expiredEvents {
+			switch ev.StakingTxHashHex {
+			case stakingTx1.Hash().String():
+				require.Empty(t, ev.UnbondingTxHashHex)
+				require.Equal(t, uint64(stakingExpiryHeight), ev.ExpiryHeight)
+			case stakingTx2.Hash().String():
+				require.Equal(t, unbondingTx2.Hash().String(), ev.UnbondingTxHashHex)
+				require.Equal(t, uint64(unbondingExpiryHeight), ev.ExpiryHeight)
+			default:
+				t.Fatalf("unexpected timelock expired event of %s", ev.StakingTxHashHex)
+			}
+		}
+
+		storedStakingTx1, err := stakingIndexer.GetStakingTxByHash(stakingTx1.Hash())
+		require.NoError(t, err)
+		requireStateTransitions(t, storedStakingTx1, indexerstore.StateActive, indexerstore.StateUnbonded)
+		require.Equal(t, uint64(stakingExpiryHeight), storedStakingTx1.StateTransitions[1].Height)
+		require.Equal(t, stakingTx1.Hash(), storedStakingTx1.StateTransitions[1].TxHash)
+		storedStakingTx2, err := stakingIndexer.GetStakingTxByHash(stakingTx2.Hash())
+		require.NoError(t, err)
+		requireStateTransitions(t, storedStakingTx2,
+			indexerstore.StateActive, indexerstore.StateUnbonding, indexerstore.StateUnbonded)
+		require.Equal(t, uint64(unbondingExpiryHeight), storedStakingTx2.StateTransitions[2].Height)
+		require.Equal(t, unbondingTx2.Hash(), storedStakingTx2.StateTransitions[2].TxHash)
+
+		// 3. roll back the last block and process it again
+		numEventsAtLastHeight := 0
+		for _, ev := range expiredEvents {
+			if ev.ExpiryHeight == uint64(lastHeight) {
+				numEventsAtLastHeight++
+			}
+		}
+		err = stakingIndexer.RollbackToHeight(uint64(lastHeight) - 1)
+		require.NoError(t, err)
+		storedStakingTx1, err = stakingIndexer.GetStakingTxByHash(stakingTx1.Hash())
+		require.NoError(t, err)
+		if stakingExpiryHeight == lastHeight {
+			requireStateTransitions(t, storedStakingTx1, indexerstore.StateActive)
+		} else {
+			requireStateTransitions(t, storedStakingTx1, indexerstore.StateActive, indexerstore.StateUnbonded)
+		}
+		storedStakingTx2, err = stakingIndexer.GetStakingTxByHash(stakingTx2.Hash())
+		require.NoError(t, err)
+		if unbondingExpiryHeight == lastHeight {
+			requireStateTransitions(t, storedStakingTx2, indexerstore.StateActive, indexerstore.StateUnbonding)
+		} else {
+			requireStateTransitions(t, storedStakingTx2,
+				indexerstore.StateActive, indexerstore.StateUnbonding, indexerstore.StateUnbonded)
+		}
+
+		err = stakingIndexer.HandleConfirmedBlock(&types.IndexedBlock{
+			Height: lastHeight,
+			Header: &wire.BlockHeader{Timestamp: time.Now()},
+		})
+		require.NoError(t, err)
+		err = stakingIndexer.PublishOutboxEvents()
+		require.NoError(t, err)
+		require.Len(t, expiredEvents, 2+numEventsAtLastHeight)
+		storedStakingTx1, err = stakingIndexer.GetStakingTxByHash(stakingTx1.Hash())
+		require.NoError(t, err)
+		require.Equal(t, indexerstore.StateUnbonded, storedStakingTx1.State)
+		storedStakingTx2, err = stakingIndexer.GetStakingTxByHash(stakingTx2.Hash())
+		require.NoError(t, err)
+		require.Equal(t, indexerstore.StateUnbonded, storedStakingTx2.State)
+	})
+}
+
+// FuzzPublishOutboxEvents tests that a failure of the consumer does not fail
+// the block, and the events are delivered in order exactly once on retry
+func FuzzPublishOutboxEvents(f *testing.F) {
+	bbndatagen.AddRandomSeedsToFuzzer(f, 5)
+
+	f.Fuzz(func(t *testing.T, seed int64) {
+		r := rand.New(rand.NewSource(seed))
+
+		homePath := filepath.Join(t.TempDir(), "indexer")
+		cfg := config.DefaultConfigWithHome(homePath)
+
+		sysParamsVersions := datagen.GenerateGlobalParamsVersions(r, t)
+
+		// the consumer fails to push the second staking event once
+		var pushedStakingTxHashes []string
+		numPushAttempts := 0
+		ctl := gomock.NewController(t)
+		mockedConsumer := mocks.NewMockEventConsumer(ctl)
+		mockedConsumer.EXPECT().PushStakingEvent(gomock.Any()).DoAndReturn(
+			func(ev *queuecli.ActiveStakingEvent) error {
+				numPushAttempts++
+				if numPushAttempts == 2 {
+					return fmt.Errorf("consumer failure")
+				}
+				pushedStakingTxHashes = append(pushedStakingTxHashes, ev.StakingTxHashHex)
+				return nil
+			}).AnyTimes()
+
+		db, err := cfg.DatabaseConfig.GetDbBackend()
+		require.NoError(t, err)
+		chainUpdateInfoChan := make(chan *btcscanner.ChainUpdateInfo)
+		mockBtcScanner := NewMockedBtcScanner(t, chainUpdateInfoChan)
+		stakingIndexer, err := indexer.NewStakingIndexer(cfg, zap.NewNop(), mockedConsumer, db, sysParamsVersions, mockBtcScanner)
+		require.NoError(t, err)
+		defer func() {
+			err = db.Close()
+			require.NoError(t, err)
+		}()
+
+		height := int32(sysParamsVersions.Versions[0].ActivationHeight) + 1
+		params := sysParamsVersions.GetVersionedGlobalParamsByHeight(uint64(height))
+		require.NotNil(t, params)
+
+		numStakingTxs := r.Intn(5) + 2
+		stakingTxs := make([]*btcutil.Tx, numStakingTxs)
+		for i := range stakingTxs {
+			stakingData := datagen.GenerateTestStakingData(t, r, params)
+			_, stakingTxs[i] = datagen.GenerateStakingTxFromTestData(t, r, params, stakingData)
+		}
+
+		// the block is applied regardless of the consumer
+		err = stakingIndexer.HandleConfirmedBlock(&types.IndexedBlock{
+			Height: height,
+			Header: &wire.BlockHeader{Timestamp: time.Now()},
+			Txs:    stakingTxs,
+		})
+		require.NoError(t, err)
+		for _, stakingTx := range stakingTxs {
+			storedTx, err := stakingIndexer.GetStakingTxByHash(stakingTx.Hash())
+			require.NoError(t, err)
+			require.NotNil(t, storedTx)
+		}
+		require.Equal(t, uint64(height)+1, stakingIndexer.GetStartHeight())
+
+		// the delivery stops at the failed event
+		err = stakingIndexer.PublishOutboxEvents()
+		require.Error(t, err)
+		require.Len(t, pushedStakingTxHashes, 1)
+
+		// the rest of the events are delivered on retry, without delivering
+		// the delivered ones again
+		err = stakingIndexer.PublishOutboxEvents()
+		require.NoError(t, err)
+		err = stakingIndexer.PublishOutboxEvents()
+		require.NoError(t, err)
+		require.Len(t, pushedStakingTxHashes, numStakingTxs)
+		for i, stakingTx := range stakingTxs {
+			require.Equal(t, stakingTx.Hash().String(), pushedStakingTxHashes[i])
+		}
+	})
+}
+
+// FuzzPublishOutboxEventsWithAcks tests that the events buffered by a sink
+// of the fan-out consumer are not marked delivered, so they are pushed again
+// after a restart, while the delivered ones are not
+func FuzzPublishOutboxEventsWithAcks(f *testing.F) {
+	bbndatagen.AddRandomSeedsToFuzzer(f, 5)
+
+	f.Fuzz(func(t *testing.T, seed int64) {
+		r := rand.New(rand.NewSource(seed))
+
+		homePath := filepath.Join(t.TempDir(), "indexer")
+		cfg := config.DefaultConfigWithHome(homePath)
+
+		sysParamsVersions := datagen.GenerateGlobalParamsVersions(r, t)
+
+		// the sink takes the staking events while it is up
+		var mu sync.Mutex
+		sinkDown := true
+		var pushedStakingTxHashes []string
+		ctl := gomock.NewController(t)
+		mockedSink := mocks.NewMockEventConsumer(ctl)
+		mockedSink.EXPECT().Start().Return(nil).AnyTimes()
+		mockedSink.EXPECT().Stop().Return(nil).AnyTimes()
+		mockedSink.EXPECT().PushStakingEvent(gomock.Any()).DoAndReturn(
+			func(ev *queuecli.ActiveStakingEvent) error {
+				mu.Lock()
+				defer mu.Unlock()
+				if sinkDown {
+					return fmt.Errorf("sink failure")
+				}
+				pushedStakingTxHashes = append(pushedStakingTxHashes, ev.StakingTxHashHex)
+				return nil
+			}).AnyTimes()
+		newFanoutConsumer := func() *consumer.FanoutConsumer {
+			fanoutConsumer, err := consumer.NewFanoutConsumer([]*consumer.Sink{{
+				Name:          config.EventConsumerWebhook,
+				Consumer:      mockedSink,
+				EventTypes:    []queuecli.EventType{queuecli.ActiveStakingEventType},
+				FailurePolicy: config.SinkFailurePolicyBuffer,
+			}}, config.DefaultFanoutConfig(), zap.NewNop())
+			require.NoError(t, err)
+			require.NoError(t, fanoutConsumer.Start())
+			return fanoutConsumer
+		}
+
+		db, err := cfg.DatabaseConfig.GetDbBackend()
+		require.NoError(t, err)
+		defer func() {
+			err = db.Close()
+			require.NoError(t, err)
+		}()
+		chainUpdateInfoChan := make(chan *btcscanner.ChainUpdateInfo)
+		mockBtcScanner := NewMockedBtcScanner(t, chainUpdateInfoChan)
+		fanoutConsumer := newFanoutConsumer()
+		stakingIndexer, err := indexer.NewStakingIndexer(cfg, zap.NewNop(), fanoutConsumer, db, sysParamsVersions, mockBtcScanner)
+		require.NoError(t, err)
+
+		height := int32(sysParamsVersions.Versions[0].ActivationHeight) + 1
+		params := sysParamsVersions.GetVersionedGlobalParamsByHeight(uint64(height))
+		require.NotNil(t, params)
+
+		numStakingTxs := r.Intn(5) + 1
+		stakingTxs := make([]*btcutil.Tx, numStakingTxs)
+		for i := range stakingTxs {
+			stakingData := datagen.GenerateTestStakingData(t, r, params)
+			_, stakingTxs[i] = datagen.GenerateStakingTxFromTestData(t, r, params, stakingData)
+		}
+		err = stakingIndexer.HandleConfirmedBlock(&types.IndexedBlock{
+			Height: height,
+			Header: &wire.BlockHeader{Timestamp: time.Now()},
+			Txs:    stakingTxs,
+		})
+		require.NoError(t, err)
+
+		// the events are buffered for the failed sink
+		err = stakingIndexer.PublishOutboxEvents()
+		require.NoError(t, err)
+		require.Equal(t, numStakingTxs, fanoutConsumer.BufferedEvents(config.EventConsumerWebhook))
+		require.Empty(t, pushedStakingTxHashes)
+		require.NoError(t, fanoutConsumer.Stop())
+
+		// the buffered events are pushed again after a restart
+		mu.Lock()
+		sinkDown = false
+		mu.Unlock()
+		fanoutConsumer = newFanoutConsumer()
+		stakingIndexer, err = indexer.NewStakingIndexer(cfg, zap.NewNop(), fanoutConsumer, db, sysParamsVersions, mockBtcScanner)
+		require.NoError(t, err)
+		err = stakingIndexer.PublishOutboxEvents()
+		require.NoError(t, err)
+		require.NoError(t, fanoutConsumer.Stop())
+		require.Len(t, pushedStakingTxHashes, numStakingTxs)
+		for i, stakingTx := range stakingTxs {
+			require.Equal(t, stakingTx.Hash().String(), pushedStakingTxHashes[i])
+		}
+
+		// the delivered events are not pushed again
+		fanoutConsumer = newFanoutConsumer()
+		stakingIndexer, err = indexer.NewStakingIndexer(cfg, zap.NewNop(), fanoutConsumer, db, sysParamsVersions, mockBtcScanner)
+		require.NoError(t, err)
+		err = stakingIndexer.PublishOutboxEvents()
+		require.NoError(t, err)
+		require.NoError(t, fanoutConsumer.Stop())
+		require.Len(t, pushedStakingTxHashes, numStakingTxs)
+	})
+}
+
+// FuzzPruneOutboxEvents tests that only the delivered events caused by the
+// blocks older than the retention are pruned from the outbox
+func FuzzPruneOutboxEvents(f *testing.F) {
+	bbndatagen.AddRandomSeedsToFuzzer(f, 5)
+
+	f.Fuzz(func(t *testing.T, seed int64) {
+		r := rand.New(rand.NewSource(seed))
+
+		homePath := filepath.Join(t.TempDir(), "indexer")
+		cfg := config.DefaultConfigWithHome(homePath)
+		cfg.OutboxConfig.RetentionBlocks = 1
+
+		sysParamsVersions := datagen.GenerateGlobalParamsVersions(r, t)
+
+		// the consumer fails to push the second staking event once
+		numPushAttempts := 0
+		ctl := gomock.NewController(t)
+		mockedConsumer := mocks.NewMockEventConsumer(ctl)
+		mockedConsumer.EXPECT().PushStakingEvent(gomock.Any()).DoAndReturn(
+			func(ev *queuecli.ActiveStakingEvent) error {
+				numPushAttempts++
+				if numPushAttempts == 2 {
+					return fmt.Errorf("consumer failure")
+				}
+				return nil
+			}).AnyTimes()
+
+		db, err := cfg.DatabaseConfig.GetDbBackend()
+		require.NoError(t, err)
+		chainUpdateInfoChan := make(chan *btcscanner.ChainUpdateInfo)
+		mockBtcScanner := NewMockedBtcScanner(t, chainUpdateInfoChan)
+		stakingIndexer, err := indexer.NewStakingIndexer(cfg, zap.NewNop(), mockedConsumer, db, sysParamsVersions, mockBtcScanner)
+		require.NoError(t, err)
+		defer func() {
+			err = db.Close()
+			require.NoError(t, err)
+		}()
+
+		height := int32(sysParamsVersions.Versions[0].ActivationHeight) + 1
+		params := sysParamsVersions.GetVersionedGlobalParamsByHeight(uint64(height))
+		require.NotNil(t, params)
+
+		numStakingTxs := r.Intn(5) + 2
+		stakingTxs := make([]*btcutil.Tx, numStakingTxs)
+		for i := range stakingTxs {
+			stakingData := datagen.GenerateTestStakingData(t, r, params)
+			_, stakingTxs[i] = datagen.GenerateStakingTxFromTestData(t, r, params, stakingData)
+		}
+		err = stakingIndexer.HandleConfirmedBlock(&types.IndexedBlock{
+			Height: height,
+			Header: &wire.BlockHeader{Timestamp: time.Now()},
+			Txs:    stakingTxs,
+		})
+		require.NoError(t, err)
+
+		// the events of the last processed block are kept
+		prunedBelowHeight, err := stakingIndexer.PruneOutboxEvents(0)
+		require.NoError(t, err)
+		require.Equal(t, uint64(height), prunedBelowHeight)
+		envelopes, err := stakingIndexer.GetOutboxEvents(0, numStakingTxs+1)
+		require.NoError(t, err)
+		require.Len(t, envelopes, numStakingTxs)
+
+		// only the delivered event is pruned once the block is out of the
+		// retention
+		err = stakingIndexer.PublishOutboxEvents()
+		require.Error(t, err)
+		err = stakingIndexer.HandleConfirmedBlock(&types.IndexedBlock{
+			Height: height + 1,
+			Header: &wire.BlockHeader{Timestamp: time.Now()},
+		})
+		require.NoError(t, err)
+		prunedBelowHeight, err = stakingIndexer.PruneOutboxEvents(prunedBelowHeight)
+		require.NoError(t, err)
+		require.Equal(t, uint64(height)+1, prunedBelowHeight)
+		envelopes, err = stakingIndexer.GetOutboxEvents(0, numStakingTxs+1)
+		require.NoError(t, err)
+		require.Len(t, envelopes, numStakingTxs-1)
+		require.Equal(t, uint64(2), envelopes[0].Sequence)
+
+		// the delivered events are not pruned again until a new block is
+		// processed
+		err = stakingIndexer.PublishOutboxEvents()
+		require.NoError(t, err)
+		prunedBelowHeight, err = stakingIndexer.PruneOutboxEvents(prunedBelowHeight)
+		require.NoError(t, err)
+		require.Equal(t, uint64(height)+1, prunedBelowHeight)
+		envelopes, err = stakingIndexer.GetOutboxEvents(0, numStakingTxs+1)
+		require.NoError(t, err)
+		require.Len(t, envelopes, numStakingTxs-1)
+
+		err = stakingIndexer.HandleConfirmedBlock(&types.IndexedBlock{
+			Height: height + 2,
+			Header: &wire.BlockHeader{Timestamp: time.Now()},
+		})
+		require.NoError(t, err)
+		prunedBelowHeight, err = stakingIndexer.PruneOutboxEvents(prunedBelowHeight)
+		require.NoError(t, err)
+		require.Equal(t, uint64(height)+2, prunedBelowHeight)
+		envelopes, err = stakingIndexer.GetOutboxEvents(0, numStakingTxs+1)
+		require.NoError(t, err)
+		require.Empty(t, envelopes)
+	})
+}
+
+// FuzzPublishEventEnvelopes tests that the events are pushed to the consumers
+// publishing envelopes with increasing sequences and the blocks causing them
+func FuzzPublishEventEnvelopes(f *testing.F) {
+	bbndatagen.AddRandomSeedsToFuzzer(f, 5)
+
+	f.Fuzz(func(t *testing.T, seed int64) {
+		r := rand.New(rand.NewSource(seed))
+
+		homePath := filepath.Join(t.TempDir(), "indexer")
+		cfg := config.DefaultConfigWithHome(homePath)
+		cfg.ExtraEventEnabled = true
+
+		sysParamsVersions := datagen.GenerateGlobalParamsVersions(r, t)
+
+		var envelopes []*consumer.EventEnvelope
+		ctl := gomock.NewController(t)
+		mockedConsumer := mocks.NewMockEnvelopeConsumer(ctl)
+		mockedConsumer.EXPECT().PushEventEnvelope(gomock.Any()).DoAndReturn(
+			func(env *consumer.EventEnvelope) error {
+				envelopes = append(envelopes, env)
+				return nil
+			}).AnyTimes()
+
+		db, err := cfg.DatabaseConfig.GetDbBackend()
+		require.NoError(t, err)
+		chainUpdateInfoChan := make(chan *btcscanner.ChainUpdateInfo)
+		mockBtcScanner := NewMockedBtcScanner(t, chainUpdateInfoChan)
+		stakingIndexer, err := indexer.NewStakingIndexer(cfg, zap.NewNop(), mockedConsumer, db, sysParamsVersions, mockBtcScanner)
+		require.NoError(t, err)
+		defer func() {
+			err = db.Close()
+			require.NoError(t, err)
+		}()
+
+		height := int32(sysParamsVersions.Versions[0].ActivationHeight) + 1
+		params := sysParamsVersions.GetVersionedGlobalParamsByHeight(uint64(height))
+		require.NotNil(t, params)
+
+		numStakingTxs := r.Intn(5) + 1
+		stakingTxs := make([]*btcutil.Tx, numStakingTxs)
+		for i := range stakingTxs {
+			stakingData := datagen.GenerateTestStakingData(t, r, params)
+			_, stakingTxs[i] = datagen.GenerateStakingTxFromTestData(t, r, params, stakingData)
+		}
+		b := &types.IndexedBlock{
+			Height: height,
+			Header: &wire.BlockHeader{Timestamp: time.Now(), Nonce: r.Uint32()},
+			Txs:    stakingTxs,
+		}
+		blockHash := b.BlockHash()
+
+		err = stakingIndexer.HandleConfirmedBlock(b)
+		require.NoError(t, err)
+		err = stakingIndexer.PublishOutboxEvents()
+		require.NoError(t, err)
+
+		// a staking event for each tx and a confirmed info event
+		require.Len(t, envelopes, numStakingTxs+1)
+		for i, env := range envelopes {
+			require.Equal(t, consumer.EventSchemaVersion, env.SchemaVersion)
+			require.Equal(t, uint64(i+1), env.Sequence)
+			require.Equal(t, uint64(height), env.BlockHeight)
+			require.Equal(t, blockHash.String(), env.BlockHash)
+			require.Equal(t, consumer.EventIdempotencyKey(env.EventType, env.BlockHash, env.Event), env.IdempotencyKey)
+
+			ev, err := env.DecodeEvent()
+			require.NoError(t, err)
+			require.Equal(t, env.EventType, ev.GetEventType())
+			if i < numStakingTxs {
+				require.Equal(t, queuecli.ActiveStakingEventType, env.EventType)
+				require.Equal(t, stakingTxs[i].Hash().String(), ev.GetStakingTxHashHex())
+			} else {
+				require.Equal(t, queuecli.ConfirmedInfoEventType, env.EventType)
+			}
+		}
+
+		// the rollback events are attributed to the block rolled back, and
+		// continue the sequence
+		err = stakingIndexer.RollbackToHeight(uint64(height - 1))
+		require.NoError(t, err)
+		err = stakingIndexer.PublishOutboxEvents()
+		require.NoError(t, err)
+		require.Len(t, envelopes, 2*(numStakingTxs+1))
+		for i, env := range envelopes[numStakingTxs+1:] {
+			require.Equal(t, uint64(numStakingTxs+2+i), env.Sequence)
+			require.Equal(t, uint64(height), env.BlockHeight)
+			require.Equal(t, blockHash.String(), env.BlockHash)
+			if i < numStakingTxs {
+				require.Equal(t, consumer.RollbackEventType, env.EventType)
+			} else {
+				require.Equal(t, queuecli.ConfirmedInfoEventType, env.EventType)
+			}
+		}
+
+		// the idempotency keys differ between the events
+		keys := make(map[string]bool)
+		for _, env := range envelopes {
+			require.False(t, keys[env.IdempotencyKey])
+			keys[env.IdempotencyKey] = true
+		}
+	})
+}
+
+// TestOutboxBackpressure tests that the indexing keeps going while the
+// consumer is unavailable until the outbox is full, and resumes once the
+// events are drained in order
+func TestOutboxBackpressure(t *testing.T) {
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	homePath := filepath.Join(t.TempDir(), "indexer")
+	cfg := config.DefaultConfigWithHome(homePath)
+	// a confirmed info event is written for every block
+	cfg.ExtraEventEnabled = true
+	cfg.OutboxConfig.MaxPendingEvents = uint64(r.Intn(3) + 1)
+
+	sysParamsVersions := datagen.GenerateGlobalParamsVersions(r, t)
+
+	var consumerDown atomic.Bool
+	consumerDown.Store(true)
+	var mu sync.Mutex
+	var pushedHeights []uint64
+	ctl := gomock.NewController(t)
+	mockedConsumer := mocks.NewMockEventConsumer(ctl)
+	mockedConsumer.EXPECT().PushConfirmedInfoEvent(gomock.Any()).DoAndReturn(
+		func(ev *queuecli.ConfirmedInfoEvent) error {
+			if consumerDown.Load() {
+				return fmt.Errorf("consumer is down")
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			pushedHeights = append(pushedHeights, ev.Height)
+			return nil
+		}).AnyTimes()
+
+	db, err := cfg.DatabaseConfig.GetDbBackend()
+	require.NoError(t, err)
+	chainUpdateInfoChan := make(chan *btcscanner.ChainUpdateInfo)
+	mockBtcScanner := NewMockedBtcScanner(t, chainUpdateInfoChan)
+	stakingIndexer, err := indexer.NewStakingIndexer(cfg, zap.NewNop(), mockedConsumer, db, sysParamsVersions, mockBtcScanner)
+	require.NoError(t, err)
+
+	initialHeight := stakingIndexer.GetStartHeight()
+	err = stakingIndexer.Start(initialHeight)
+	require.NoError(t, err)
+	defer func() {
+		err := stakingIndexer.Stop()
+		require.NoError(t, err)
+		err = db.Close()
+		require.NoError(t, err)
+	}()
+
+	numBlocks := int(cfg.OutboxConfig.MaxPendingEvents) + r.Intn(3) + 1
+	confirmedBlocks := make([]*types.IndexedBlock, numBlocks)
+	for i := range confirmedBlocks {
+		confirmedBlocks[i] = &types.IndexedBlock{
+			Height: int32(initialHeight) + int32(i),
+			Header: &wire.BlockHeader{Timestamp: time.Now()},
+		}
+	}
+	chainUpdateInfoChan <- &btcscanner.ChainUpdateInfo{
+		ConfirmedBlocks: confirmedBlocks,
+	}
+
+	// the indexing pauses once the outbox is full
+	pausedStartHeight := initialHeight + cfg.OutboxConfig.MaxPendingEvents
+	require.Eventually(t, func() bool {
+		return stakingIndexer.GetStartHeight() == pausedStartHeight
+	}, 10*time.Second, 100*time.Millisecond)
+	time.Sleep(2 * time.Second)
+	require.Equal(t, pausedStartHeight, stakingIndexer.GetStartHeight())
+
+	// the indexing resumes once the consumer is back, and the events are
+	// delivered in order
+	consumerDown.Store(false)
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(pushedHeights) == numBlocks
+	}, 60*time.Second, 100*time.Millisecond)
+	require.Equal(t, initialHeight+uint64(numBlocks), stakingIndexer.GetStartHeight())
+	mu.Lock()
+	defer mu.Unlock()
+	for i, height := range pushedHeights {
+		require.Equal(t, initialHeight+uint64(i), height)
+	}
+}
+
+// FuzzReplayEvents tests that the replayed events of a height range are the
+// ones published for the range, and the replay does not change the state
+func FuzzReplayEvents(f *testing.F) {
+	bbndatagen.AddRandomSeedsToFuzzer(f, 5)
+
+	f.Fuzz(func(t *testing.T, seed int64) {
+		r := rand.New(rand.NewSource(seed))
+
+		homePath := filepath.Join(t.TempDir(), "indexer")
+		cfg := config.DefaultConfigWithHome(homePath)
+
+		n := r.Intn(50) + 1
+		sysParamsVersions := datagen.GenerateGlobalParamsVersions(r, t)
+		testScenario := NewTestScenario(r, t, sysParamsVersions, 70, n, true)
+
+		var published []*consumer.EventEnvelope
+		ctl := gomock.NewController(t)
+		mockedConsumer := mocks.NewMockEnvelopeConsumer(ctl)
+		mockedConsumer.EXPECT().PushEventEnvelope(gomock.Any()).DoAndReturn(
+			func(env *consumer.EventEnvelope) error {
+				published = append(published, env)
+				return nil
+			}).AnyTimes()
+
+		db, err := cfg.DatabaseConfig.GetDbBackend()
+		require.NoError(t, err)
+		chainUpdateInfoChan := make(chan *btcscanner.ChainUpdateInfo)
+		mockBtcScanner := NewMockedBtcScanner(t, chainUpdateInfoChan)
+		stakingIndexer, err := indexer.NewStakingIndexer(cfg, zap.NewNop(), mockedConsumer, db, sysParamsVersions, mockBtcScanner)
+		require.NoError(t, err)
+		defer func() {
+			err = db.Close()
+			require.NoError(t, err)
+		}()
+
+		headers := make(map[uint64]*wire.BlockHeader)
+		for _, b := range testScenario.Blocks {
+			err := stakingIndexer.HandleConfirmedBlock(b)
+			require.NoError(t, err)
+			headers[uint64(b.Height)] = b.Header
+		}
+		err = stakingIndexer.PublishOutboxEvents()
+		require.NoError(t, err)
+
+		mockBtcClient := mocks.NewMockClient(ctl)
+		mockBtcClient.EXPECT().GetBlockHeaderByHeight(gomock.Any()).DoAndReturn(
+			func(height uint64) (*wire.BlockHeader, error) {
+				header, ok := headers[height]
+				if !ok {
+					return nil, fmt.Errorf("no block at height %d", height)
+				}
+				return header, nil
+			}).AnyTimes()
+
+		is, err := indexerstore.NewIndexerStore(db)
+		require.NoError(t, err)
+		replayer := indexer.NewEventReplayer(is, mockBtcClient, zap.NewNop())
+
+		// replay a random range with random event types
+		firstHeight := uint64(testScenario.Blocks[0].Height)
+		lastHeight := uint64(testScenario.Blocks[len(testScenario.Blocks)-1].Height)
+		startHeight := firstHeight + uint64(r.Int63n(int64(lastHeight-firstHeight+1)))
+		endHeight := startHeight + uint64(r.Int63n(int64(lastHeight-startHeight+1)))
+		var eventTypes []queuecli.EventType
+		for _, eventType := range indexer.ReplayableEventTypes {
+			if r.Intn(2) == 0 {
+				eventTypes = append(eventTypes, eventType)
+			}
+		}
+		accepts := func(eventType queuecli.EventType) bool {
+			for _, t := range eventTypes {
+				if t == eventType {
+					return true
+				}
+			}
+			return len(eventTypes) == 0 && eventType != queuecli.ConfirmedInfoEventType
+		}
+
+		var replayed []*consumer.EventEnvelope
+		numEvents, err := replayer.ReplayEvents(startHeight, endHeight, eventTypes, func(env *consumer.EventEnvelope) error {
+			replayed = append(replayed, env)
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, len(replayed), numEvents)
+
+		var expected []*consumer.EventEnvelope
+		for _, env := range published {
+			if env.BlockHeight < startHeight || env.BlockHeight > endHeight || !accepts(env.EventType) {
+				continue
+			}
+			// the replayed events are not written to the outbox
+			replayedEnv := *env
+			replayedEnv.Sequence = 0
+			replayedEnv.Unsequenced = true
+			expected = append(expected, &replayedEnv)
+		}
+		require.Len(t, replayed, len(expected))
+
+		// the events are replayed in the order of the heights
+		for i := 1; i < len(replayed); i++ {
+			require.LessOrEqual(t, replayed[i-1].BlockHeight, replayed[i].BlockHeight)
+		}
+		sortByKey := func(envs []*consumer.EventEnvelope) {
+			sort.Slice(envs, func(i, j int) bool {
+				return envs[i].IdempotencyKey < envs[j].IdempotencyKey
+			})
+		}
+		sortByKey(expected)
+		sortByKey(replayed)
+		for i, env := range replayed {
+			require.Equal(t, expected[i].IdempotencyKey, env.IdempotencyKey)
+			require.JSONEq(t, string(expected[i].Event), string(env.Event))
+			env.Event = expected[i].Event
+			require.Equal(t, expected[i], env)
+		}
+
+		// nothing is written to the outbox
+		numPublished := len(published)
+		err = stakingIndexer.PublishOutboxEvents()
+		require.NoError(t, err)
+		require.Len(t, published, numPublished)
+
+		// the events without the stored txs cannot be replayed
+		_, err = replayer.ReplayEvents(startHeight, endHeight, []queuecli.EventType{queuecli.ConfirmedInfoEventType}, func(env *consumer.EventEnvelope) error {
+			return nil
+		})
+		require.Error(t, err)
+	})
+}
+
 func FuzzValidateWithdrawTxFromStaking(f *testing.F) {
 	bbndatagen.AddRandomSeedsToFuzzer(f, 10)
 
@@ -589,6 +1889,10 @@ func NewMockedConsumer(t *testing.T) *mocks.MockEventConsumer {
 	mockedConsumer.EXPECT().PushStakingEvent(gomock.Any()).Return(nil).AnyTimes()
 	mockedConsumer.EXPECT().PushUnbondingEvent(gomock.Any()).Return(nil).AnyTimes()
 	mockedConsumer.EXPECT().PushWithdrawEvent(gomock.Any()).Return(nil).AnyTimes()
+	mockedConsumer.EXPECT().PushRollbackEvent(gomock.Any()).Return(nil).AnyTimes()
+	mockedConsumer.EXPECT().PushInvalidStakingEvent(gomock.Any()).Return(nil).AnyTimes()
+	mockedConsumer.EXPECT().PushSlashingEvent(gomock.Any()).Return(nil).AnyTimes()
+	mockedConsumer.EXPECT().PushTimelockExpiredEvent(gomock.Any()).Return(nil).AnyTimes()
 	mockedConsumer.EXPECT().Start().Return(nil).AnyTimes()
 	mockedConsumer.EXPECT().Stop().Return(nil).AnyTimes()
 
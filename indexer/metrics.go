@@ -84,6 +84,37 @@ var (
 		},
 	)
 
+	totalSlashingTxsFromStaking = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "si_total_slashing_txs_from_staking",
+			Help: "Total number of slashing transactions from the staking path",
+		},
+	)
+
+	totalSlashingTxsFromUnbonding = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "si_total_slashing_txs_from_unbonding",
+			Help: "Total number of slashing transactions from the unbonding path",
+		},
+	)
+
+	totalExpiredTimelocks = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "si_total_expired_timelocks",
+			Help: "Total number of expired timelocks of staking and unbonding outputs",
+		},
+		[]string{
+			"output",
+		},
+	)
+
+	totalRolledBackBlocks = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "si_total_rolled_back_blocks",
+			Help: "Total number of confirmed blocks rolled back due to major reorgs",
+		},
+	)
+
 	/* alerts */
 
 	failedProcessingStakingTxsCounter = promauto.NewCounter(
@@ -100,6 +131,13 @@ var (
 		},
 	)
 
+	failedProcessingSlashingTxsCounter = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "si_failed_processing_slashing_txs_counter",
+			Help: "Total number of failures when processing slashing transactions",
+		},
+	)
+
 	invalidTransactionsCounter = promauto.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "si_invalid_txs_counter",
@@ -137,4 +175,49 @@ var (
 			Help: "Total number of failures when processing valid withdrawal transactions from unbonding",
 		},
 	)
+
+	totalDeliveredOutboxEvents = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "si_total_delivered_outbox_events",
+			Help: "Total number of events delivered from the outbox to the consumer",
+		},
+		[]string{
+			"event_type",
+		},
+	)
+
+	totalPrunedOutboxEvents = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "si_total_pruned_outbox_events",
+			Help: "Total number of delivered events deleted from the outbox as they are older than the retention",
+		},
+	)
+
+	pendingOutboxEvents = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "si_pending_outbox_events",
+			Help: "Number of events in the outbox waiting to be delivered to the consumer",
+		},
+	)
+
+	oldestPendingOutboxEventAge = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "si_oldest_pending_outbox_event_age_seconds",
+			Help: "Age in seconds of the oldest event in the outbox waiting to be delivered to the consumer",
+		},
+	)
+
+	outboxFull = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "si_outbox_full",
+			Help: "Whether the indexing is paused as the outbox reaches the max number of pending events",
+		},
+	)
+
+	failedDeliveringOutboxEventsCounter = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "si_failed_delivering_outbox_events_counter",
+			Help: "Total number of failures when delivering events from the outbox to the consumer",
+		},
+	)
 )
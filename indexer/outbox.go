@@ -0,0 +1,409 @@
+package indexer
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	queuecli "github.com/babylonlabs-io/staking-queue-client/client"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"go.uber.org/zap"
+
+	"github.com/babylonlabs-io/staking-indexer/consumer"
+	"github.com/babylonlabs-io/staking-indexer/indexerstore"
+)
+
+const (
+	// outboxBatchSize is the max number of events read from the outbox at once
+	outboxBatchSize = 100
+	// outboxPollInterval is the interval of checking the outbox for new events
+	outboxPollInterval = time.Second
+	// outboxMaxRetryInterval caps the backoff after failed deliveries
+	outboxMaxRetryInterval = time.Minute
+)
+
+// pushEvent writes the event to the outbox together with the block causing
+// it. As the outbox is in the same store as the state, the event is committed
+// together with the state changes causing it. The events are delivered to
+// the consumer by the publisher
+func (si *StakingIndexer) pushEvent(ev queuecli.EventMessage) error {
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		return fmt.Errorf("failed to marshal the event: %w", err)
+	}
+
+	return si.is.AddOutboxEntry(uint32(ev.GetEventType()), payload, si.blockHeight, si.blockHash)
+}
+
+// pushConfirmedInfoEvent writes the ConfirmedInfoEvent carrying the given
+// height and the current confirmed tvl to the outbox
+func (si *StakingIndexer) pushConfirmedInfoEvent(height uint64) error {
+	confirmedTvl, err := si.is.GetConfirmedTvl()
+	if err != nil {
+		return fmt.Errorf("failed to get the confirmed tvl: %w", err)
+	}
+
+	confirmedInfoEvent := queuecli.NewConfirmedInfoEvent(height, confirmedTvl)
+	if err := si.pushEvent(&confirmedInfoEvent); err != nil {
+		return fmt.Errorf("failed to write the confirmed info event to the outbox: %w", err)
+	}
+
+	return nil
+}
+
+// blockFinalityProviders returns the public keys of the finality providers of
+// the delegations changed by the block at the given height, in the order
+// they are first changed. It must be called before the block is rolled back
+func (si *StakingIndexer) blockFinalityProviders(height uint64) ([]*btcec.PublicKey, error) {
+	journal, err := si.is.GetBlockJournal(height)
+	if err != nil {
+		if errors.Is(err, indexerstore.ErrBlockJournalNotFound) {
+			// nothing is changed by the block
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get the journal of the block: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	var fpPks []*btcec.PublicKey
+	for _, entry := range journal {
+		stakingTx, err := si.is.GetStakingTransaction(entry.StakingTxHash)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the staking tx %s: %w", entry.StakingTxHash, err)
+		}
+		// invalid staking txs are not delegations
+		if stakingTx == nil {
+			continue
+		}
+
+		key := string(schnorr.SerializePubKey(stakingTx.FinalityProviderPk))
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		fpPks = append(fpPks, stakingTx.FinalityProviderPk)
+	}
+
+	return fpPks, nil
+}
+
+// pushFinalityProviderInfoEvents writes a FinalityProviderInfoEvent carrying
+// the current totals of each of the given finality providers at the given
+// height to the outbox
+func (si *StakingIndexer) pushFinalityProviderInfoEvents(height uint64, fpPks []*btcec.PublicKey) error {
+	for _, fpPk := range fpPks {
+		stats, err := si.is.GetFinalityProviderStats(fpPk)
+		if err != nil {
+			return fmt.Errorf("failed to get the finality provider stats: %w", err)
+		}
+		if stats == nil {
+			stats = &indexerstore.FinalityProviderStats{FinalityProviderPk: fpPk}
+		}
+
+		fpInfoEvent := consumer.NewFinalityProviderInfoEvent(
+			hex.EncodeToString(schnorr.SerializePubKey(fpPk)),
+			height,
+			stats.ActiveStake,
+			stats.OverflowStake,
+			stats.ActiveDelegations,
+			stats.UnbondedDelegations,
+		)
+		if err := si.pushEvent(&fpInfoEvent); err != nil {
+			return fmt.Errorf("failed to write the finality provider info event to the outbox: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// notifyOutboxPublisher wakes up the publisher without waiting for the next
+// poll, and notifies the subscribers. It does not block if a notification is
+// already pending
+func (si *StakingIndexer) notifyOutboxPublisher() {
+	si.updates.notify()
+
+	select {
+	case si.outboxNotify <- struct{}{}:
+	default:
+	}
+}
+
+// PublishOutboxEvents delivers the undelivered events in the outbox to the
+// consumer in the order they were written, and marks each of them delivered.
+// It stops at the first event that fails to be delivered so that the order
+// is kept, and the event is retried in the next call. The events pushed to an
+// AckingConsumer are only marked delivered once the consumer acks them.
+func (si *StakingIndexer) PublishOutboxEvents() error {
+	if ackingConsumer, ok := si.consumer.(consumer.AckingConsumer); ok {
+		return si.publishOutboxEventsWithAcks(ackingConsumer)
+	}
+
+	for {
+		entries, err := si.is.GetUndeliveredOutboxEntries(outboxBatchSize)
+		if err != nil {
+			return fmt.Errorf("failed to get the undelivered events: %w", err)
+		}
+		if len(entries) == 0 {
+			return nil
+		}
+
+		for _, entry := range entries {
+			if err := si.deliverOutboxEntry(entry); err != nil {
+				// record metrics
+				failedDeliveringOutboxEventsCounter.Inc()
+				return fmt.Errorf("failed to deliver the event %d: %w", entry.Sequence, err)
+			}
+
+			if err := si.is.MarkOutboxEntryDelivered(entry.Sequence); err != nil {
+				return fmt.Errorf("failed to mark the event %d delivered: %w", entry.Sequence, err)
+			}
+
+			// record metrics
+			totalDeliveredOutboxEvents.WithLabelValues(strconv.FormatUint(uint64(entry.EventType), 10)).Inc()
+		}
+	}
+}
+
+// PruneOutboxEvents deletes the delivered events in the outbox caused by the
+// blocks older than the configured number of the latest processed blocks, so
+// that the outbox does not grow without bound while the recent history is
+// kept for the subscriptions. It does nothing if the events below the height
+// computed from the last processed height have already been pruned, i.e., if
+// the height is not above the given one. It returns the height below which
+// the events are pruned
+func (si *StakingIndexer) PruneOutboxEvents(prunedBelowHeight uint64) (uint64, error) {
+	retentionBlocks := si.cfg.OutboxConfig.RetentionBlocks
+	if retentionBlocks == 0 {
+		return prunedBelowHeight, nil
+	}
+
+	lastProcessedHeight, err := si.is.GetLastProcessedHeight()
+	if err != nil {
+		if errors.Is(err, indexerstore.ErrLastProcessedHeightNotFound) {
+			return prunedBelowHeight, nil
+		}
+		return prunedBelowHeight, fmt.Errorf("failed to get the last processed height: %w", err)
+	}
+	if lastProcessedHeight < retentionBlocks {
+		return prunedBelowHeight, nil
+	}
+
+	belowHeight := lastProcessedHeight - retentionBlocks + 1
+	if belowHeight <= prunedBelowHeight {
+		return prunedBelowHeight, nil
+	}
+
+	numPruned, err := si.is.PruneOutboxEntries(belowHeight)
+	if err != nil {
+		return prunedBelowHeight, err
+	}
+	if numPruned > 0 {
+		si.logger.Debug("pruned the delivered events in the outbox",
+			zap.Uint64("below_height", belowHeight),
+			zap.Uint64("num_pruned", numPruned))
+	}
+
+	// record metrics
+	totalPrunedOutboxEvents.Add(float64(numPruned))
+
+	return belowHeight, nil
+}
+
+// updateOutboxMetrics records the depth and the age of the outbox, and
+// returns the number of the events waiting in it
+func (si *StakingIndexer) updateOutboxMetrics() (uint64, error) {
+	stats, err := si.is.GetOutboxStats()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get the outbox stats: %w", err)
+	}
+
+	// record metrics
+	pendingOutboxEvents.Set(float64(stats.NumUndelivered))
+	if stats.OldestUndeliveredAt.IsZero() {
+		oldestPendingOutboxEventAge.Set(0)
+	} else {
+		oldestPendingOutboxEventAge.Set(time.Since(stats.OldestUndeliveredAt).Seconds())
+	}
+
+	return stats.NumUndelivered, nil
+}
+
+// waitForOutboxCapacity blocks while the number of the events waiting in the
+// outbox reaches the configured max, so that the indexing pauses during a
+// long outage of the consumer instead of growing the outbox without bound.
+// It returns false if the indexer is stopped while waiting
+func (si *StakingIndexer) waitForOutboxCapacity() bool {
+	maxPendingEvents := si.cfg.OutboxConfig.MaxPendingEvents
+
+	ticker := time.NewTicker(outboxPollInterval)
+	defer ticker.Stop()
+
+	paused := false
+	for {
+		numPending, err := si.updateOutboxMetrics()
+		if err != nil {
+			si.logger.Error("failed to check the outbox capacity", zap.Error(err))
+		} else if maxPendingEvents == 0 || numPending < maxPendingEvents {
+			if paused {
+				si.logger.Info("resuming the indexing as the outbox is drained",
+					zap.Uint64("pending_events", numPending))
+				// record metrics
+				outboxFull.Set(0)
+			}
+			return true
+		} else if !paused {
+			paused = true
+			si.logger.Warn("pausing the indexing until the consumer catches up",
+				zap.Uint64("pending_events", numPending),
+				zap.Uint64("max_pending_events", maxPendingEvents))
+			// record metrics
+			outboxFull.Set(1)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-si.quit:
+			return false
+		}
+	}
+}
+
+// outboxPublisherLoop delivers the events in the outbox periodically, and
+// backs off exponentially while the deliveries fail
+func (si *StakingIndexer) outboxPublisherLoop() {
+	defer si.wg.Done()
+
+	interval := outboxPollInterval
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// the height below which the delivered events are pruned last time
+	var prunedBelowHeight uint64
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-si.outboxNotify:
+			if interval != outboxPollInterval {
+				// backing off after a failed delivery
+				continue
+			}
+		case <-si.quit:
+			si.logger.Info("closing the outbox publisher loop")
+			return
+		}
+
+		if err := si.PublishOutboxEvents(); err != nil {
+			interval = min(interval*2, outboxMaxRetryInterval)
+			si.logger.Error("failed to publish the events in the outbox",
+				zap.Duration("retry_in", interval),
+				zap.Error(err))
+		} else {
+			interval = outboxPollInterval
+
+			prunedBelowHeight, err = si.PruneOutboxEvents(prunedBelowHeight)
+			if err != nil {
+				si.logger.Error("failed to prune the delivered events in the outbox", zap.Error(err))
+			}
+		}
+		ticker.Reset(interval)
+
+		if _, err := si.updateOutboxMetrics(); err != nil {
+			si.logger.Error("failed to record the outbox metrics", zap.Error(err))
+		}
+	}
+}
+
+// publishOutboxEventsWithAcks pushes the events in the outbox following the
+// last pushed one to the consumer in order, and marks the events delivered
+// once the consumer acks them. The events pushed but not acked yet are
+// marked in the following calls, or pushed again after a restart
+func (si *StakingIndexer) publishOutboxEventsWithAcks(ac consumer.AckingConsumer) error {
+	for {
+		var entries []*indexerstore.OutboxEntry
+		var err error
+		if si.pushedOutboxSequence == 0 {
+			entries, err = si.is.GetUndeliveredOutboxEntries(outboxBatchSize)
+		} else {
+			entries, err = si.is.GetOutboxEntries(si.pushedOutboxSequence+1, outboxBatchSize)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get the events to push: %w", err)
+		}
+
+		var pushErr error
+		for _, entry := range entries {
+			if err := si.deliverOutboxEntry(entry); err != nil {
+				// record metrics
+				failedDeliveringOutboxEventsCounter.Inc()
+				pushErr = fmt.Errorf("failed to deliver the event %d: %w", entry.Sequence, err)
+				break
+			}
+			si.pushedOutboxSequence = entry.Sequence
+		}
+
+		// the events acked before the failure are marked as well
+		if err := si.markOutboxEventsAcked(ac.DeliveredSequence()); err != nil {
+			return err
+		}
+		if pushErr != nil {
+			return pushErr
+		}
+		if len(entries) == 0 {
+			return nil
+		}
+	}
+}
+
+// markOutboxEventsAcked marks the undelivered events in the outbox up to the
+// acked sequence delivered
+func (si *StakingIndexer) markOutboxEventsAcked(ackedSeq uint64) error {
+	for {
+		entries, err := si.is.GetUndeliveredOutboxEntries(outboxBatchSize)
+		if err != nil {
+			return fmt.Errorf("failed to get the undelivered events: %w", err)
+		}
+
+		for _, entry := range entries {
+			if entry.Sequence > ackedSeq {
+				return nil
+			}
+
+			if err := si.is.MarkOutboxEntryDelivered(entry.Sequence); err != nil {
+				return fmt.Errorf("failed to mark the event %d delivered: %w", entry.Sequence, err)
+			}
+
+			// record metrics
+			totalDeliveredOutboxEvents.WithLabelValues(strconv.FormatUint(uint64(entry.EventType), 10)).Inc()
+		}
+		if len(entries) == 0 {
+			return nil
+		}
+	}
+}
+
+// deliverOutboxEntry pushes the event wrapped in an envelope to the consumer
+func (si *StakingIndexer) deliverOutboxEntry(entry *indexerstore.OutboxEntry) error {
+	return consumer.PushEventEnvelope(si.consumer, outboxEntryEnvelope(entry))
+}
+
+// outboxEntryEnvelope wraps the event in an envelope. The sequence of the
+// envelope is the one of the event in the outbox
+func outboxEntryEnvelope(entry *indexerstore.OutboxEntry) *consumer.EventEnvelope {
+	var blockHash string
+	if entry.BlockHash != nil {
+		blockHash = entry.BlockHash.String()
+	}
+
+	return consumer.NewEventEnvelope(
+		entry.Sequence,
+		queuecli.EventType(entry.EventType),
+		entry.BlockHeight,
+		blockHash,
+		entry.Payload,
+	)
+}
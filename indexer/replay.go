@@ -0,0 +1,226 @@
+package indexer
+
+import (
+	"fmt"
+	"sort"
+
+	queuecli "github.com/babylonlabs-io/staking-queue-client/client"
+	"github.com/btcsuite/btcd/wire"
+	"go.uber.org/zap"
+
+	"github.com/babylonlabs-io/staking-indexer/btcscanner"
+	"github.com/babylonlabs-io/staking-indexer/consumer"
+	"github.com/babylonlabs-io/staking-indexer/indexerstore"
+)
+
+// ReplayableEventTypes are the types of the events that can be rebuilt from
+// the stored transactions, in the order they are replayed within a height
+var ReplayableEventTypes = []queuecli.EventType{
+	queuecli.ActiveStakingEventType,
+	queuecli.UnbondingStakingEventType,
+	queuecli.WithdrawStakingEventType,
+}
+
+// replayedTx is a stored transaction whose event is replayed
+type replayedTx struct {
+	eventType queuecli.EventType
+	height    uint64
+	txHash    string
+	// buildEvent builds the event of the tx given the header of the block
+	// including it
+	buildEvent func(header *wire.BlockHeader) (queuecli.EventMessage, error)
+}
+
+// EventReplayer rebuilds the events of the stored transactions without
+// changing the state of the indexer. The block headers are read from the BTC
+// client, as the timestamps in the events are not stored
+type EventReplayer struct {
+	is        indexerstore.Store
+	btcClient btcscanner.Client
+
+	logger *zap.Logger
+}
+
+func NewEventReplayer(
+	is indexerstore.Store,
+	btcClient btcscanner.Client,
+	logger *zap.Logger,
+) *EventReplayer {
+	return &EventReplayer{
+		is:        is,
+		btcClient: btcClient,
+		logger:    logger.With(zap.String("module", "event replayer")),
+	}
+}
+
+// ReplayEvents rebuilds the events of the given types of the transactions
+// included in [startHeight, endHeight], and passes them to push wrapped in
+// envelopes, ordered by height. All the replayable events are rebuilt if
+// eventTypes is empty. The envelopes have the same block hashes, payloads,
+// and idempotency keys as the original ones, while they are unsequenced as
+// they are not written to the outbox. It returns the number of the events
+func (er *EventReplayer) ReplayEvents(
+	startHeight, endHeight uint64,
+	eventTypes []queuecli.EventType,
+	push func(env *consumer.EventEnvelope) error,
+) (int, error) {
+	if startHeight > endHeight {
+		return 0, fmt.Errorf("the start height %d should not be greater than the end height %d", startHeight, endHeight)
+	}
+
+	accepted := make(map[queuecli.EventType]bool)
+	for _, eventType := range eventTypes {
+		accepted[eventType] = true
+	}
+	for eventType := range accepted {
+		if eventTypeOrder(eventType) < 0 {
+			return 0, fmt.Errorf("the %s events cannot be replayed", consumer.EventTypeName(eventType))
+		}
+	}
+	accepts := func(eventType queuecli.EventType) bool {
+		return len(accepted) == 0 || accepted[eventType]
+	}
+
+	txs, err := er.collectTxs(startHeight, endHeight, accepts)
+	if err != nil {
+		return 0, err
+	}
+
+	sort.SliceStable(txs, func(i, j int) bool {
+		if txs[i].height != txs[j].height {
+			return txs[i].height < txs[j].height
+		}
+		if txs[i].eventType != txs[j].eventType {
+			return eventTypeOrder(txs[i].eventType) < eventTypeOrder(txs[j].eventType)
+		}
+		return txs[i].txHash < txs[j].txHash
+	})
+
+	var header *wire.BlockHeader
+	var headerHeight uint64
+	for _, tx := range txs {
+		if header == nil || headerHeight != tx.height {
+			header, err = er.btcClient.GetBlockHeaderByHeight(tx.height)
+			if err != nil {
+				return 0, fmt.Errorf("failed to get the block header at height %d: %w", tx.height, err)
+			}
+			headerHeight = tx.height
+		}
+
+		ev, err := tx.buildEvent(header)
+		if err != nil {
+			return 0, fmt.Errorf("failed to build the event of tx %s: %w", tx.txHash, err)
+		}
+
+		blockHash := header.BlockHash()
+		env, err := consumer.WrapUnsequencedEvent(tx.height, blockHash.String(), ev)
+		if err != nil {
+			return 0, err
+		}
+
+		if err := push(env); err != nil {
+			return 0, fmt.Errorf("failed to push the %s event of tx %s: %w",
+				consumer.EventTypeName(tx.eventType), tx.txHash, err)
+		}
+
+		er.logger.Debug("replayed the event",
+			zap.String("event_type", consumer.EventTypeName(tx.eventType)),
+			zap.String("tx_hash", tx.txHash),
+			zap.Uint64("height", tx.height))
+	}
+
+	return len(txs), nil
+}
+
+// collectTxs returns the stored txs whose events are accepted and whose
+// inclusion heights are in [startHeight, endHeight]. The staking txs are read
+// from the height index, while the unbonding and withdrawal txs, which are
+// not indexed by height, are filtered from all the stored ones
+func (er *EventReplayer) collectTxs(
+	startHeight, endHeight uint64,
+	accepts func(queuecli.EventType) bool,
+) ([]*replayedTx, error) {
+	var txs []*replayedTx
+
+	inRange := func(height uint64) bool {
+		return height >= startHeight && height <= endHeight
+	}
+
+	if accepts(queuecli.ActiveStakingEventType) {
+		if err := er.is.ScanStakingTransactionsByHeightRange(startHeight, endHeight, func(stakingTx *indexerstore.StoredStakingTransaction) error {
+			txs = append(txs, &replayedTx{
+				eventType: queuecli.ActiveStakingEventType,
+				height:    stakingTx.InclusionHeight,
+				txHash:    stakingTx.Tx.TxHash().String(),
+				buildEvent: func(header *wire.BlockHeader) (queuecli.EventMessage, error) {
+					ev, err := newActiveStakingEvent(
+						stakingTx.Tx, stakingTx.StakerPk, stakingTx.FinalityProviderPk,
+						stakingTx.StakingValue, stakingTx.InclusionHeight, header.Timestamp,
+						stakingTx.StakingTime, stakingTx.StakingOutputIdx, stakingTx.IsOverflow,
+					)
+					return &ev, err
+				},
+			})
+			return nil
+		}); err != nil {
+			return nil, fmt.Errorf("failed to scan the staking txs: %w", err)
+		}
+	}
+
+	if accepts(queuecli.UnbondingStakingEventType) {
+		if err := er.is.ScanStoredUnbondingTransactions(func(unbondingTx *indexerstore.StoredUnbondingTransaction) error {
+			if !inRange(unbondingTx.InclusionHeight) {
+				return nil
+			}
+			txs = append(txs, &replayedTx{
+				eventType: queuecli.UnbondingStakingEventType,
+				height:    unbondingTx.InclusionHeight,
+				txHash:    unbondingTx.Tx.TxHash().String(),
+				buildEvent: func(header *wire.BlockHeader) (queuecli.EventMessage, error) {
+					ev, err := newUnbondingStakingEvent(
+						unbondingTx.Tx, unbondingTx.StakingTxHash,
+						unbondingTx.InclusionHeight, header.Timestamp, unbondingTx.UnbondingTime,
+					)
+					return &ev, err
+				},
+			})
+			return nil
+		}); err != nil {
+			return nil, fmt.Errorf("failed to scan the unbonding txs: %w", err)
+		}
+	}
+
+	if accepts(queuecli.WithdrawStakingEventType) {
+		if err := er.is.ScanStoredWithdrawalTransactions(func(withdrawalTx *indexerstore.StoredWithdrawalTransaction) error {
+			if !inRange(withdrawalTx.InclusionHeight) {
+				return nil
+			}
+			txs = append(txs, &replayedTx{
+				eventType: queuecli.WithdrawStakingEventType,
+				height:    withdrawalTx.InclusionHeight,
+				txHash:    withdrawalTx.Tx.TxHash().String(),
+				buildEvent: func(_ *wire.BlockHeader) (queuecli.EventMessage, error) {
+					ev := queuecli.NewWithdrawStakingEvent(withdrawalTx.StakingTxHash.String())
+					return &ev, nil
+				},
+			})
+			return nil
+		}); err != nil {
+			return nil, fmt.Errorf("failed to scan the withdrawal txs: %w", err)
+		}
+	}
+
+	return txs, nil
+}
+
+// eventTypeOrder returns the position of the event type in
+// ReplayableEventTypes, or -1 if it cannot be replayed
+func eventTypeOrder(eventType queuecli.EventType) int {
+	for i, t := range ReplayableEventTypes {
+		if t == eventType {
+			return i
+		}
+	}
+
+	return -1
+}
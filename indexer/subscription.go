@@ -0,0 +1,91 @@
+package indexer
+
+import (
+	"sync"
+
+	"github.com/babylonlabs-io/staking-indexer/consumer"
+)
+
+// eventUpdates notifies the subscribers of the new events of the indexer.
+// A notification closes the channel returned to the subscribers, so that
+// all of them are woken up, and replaces it with a new one
+type eventUpdates struct {
+	mu      sync.Mutex
+	updated chan struct{}
+	// btcInfo is the latest btc info event, which is not written to the
+	// outbox as it describes the unconfirmed tip
+	btcInfo *consumer.EventEnvelope
+}
+
+func newEventUpdates() *eventUpdates {
+	return &eventUpdates{
+		updated: make(chan struct{}),
+	}
+}
+
+func (eu *eventUpdates) wait() <-chan struct{} {
+	eu.mu.Lock()
+	defer eu.mu.Unlock()
+
+	return eu.updated
+}
+
+func (eu *eventUpdates) notify() {
+	eu.mu.Lock()
+	defer eu.mu.Unlock()
+
+	close(eu.updated)
+	eu.updated = make(chan struct{})
+}
+
+func (eu *eventUpdates) latestBtcInfo() *consumer.EventEnvelope {
+	eu.mu.Lock()
+	defer eu.mu.Unlock()
+
+	return eu.btcInfo
+}
+
+func (eu *eventUpdates) setBtcInfo(env *consumer.EventEnvelope) {
+	eu.mu.Lock()
+	eu.btcInfo = env
+	eu.mu.Unlock()
+
+	eu.notify()
+}
+
+// GetOutboxEvents returns at most limit events in the outbox starting from
+// the one with the given sequence, wrapped in envelopes as they are pushed to
+// the consumer
+func (si *StakingIndexer) GetOutboxEvents(fromSeq uint64, limit int) ([]*consumer.EventEnvelope, error) {
+	entries, err := si.is.GetOutboxEntries(fromSeq, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	envelopes := make([]*consumer.EventEnvelope, len(entries))
+	for i, entry := range entries {
+		envelopes[i] = outboxEntryEnvelope(entry)
+	}
+
+	return envelopes, nil
+}
+
+// GetOutboxSequenceFromHeight returns the sequence of the first event in the
+// outbox caused by a block at or above the given height, or the sequence of
+// the next event if there is no such event
+func (si *StakingIndexer) GetOutboxSequenceFromHeight(height uint64) (uint64, error) {
+	return si.is.GetOutboxSequenceFromHeight(height)
+}
+
+// EventUpdates returns a channel closed once new events are written to the
+// outbox or a new btc info event is pushed. The channel should be obtained
+// before reading the events so that no update is missed
+func (si *StakingIndexer) EventUpdates() <-chan struct{} {
+	return si.updates.wait()
+}
+
+// LatestBtcInfoEvent returns the latest btc info event wrapped in an
+// envelope, or nil if no btc info event is pushed since the indexer started
+func (si *StakingIndexer) LatestBtcInfoEvent() *consumer.EventEnvelope {
+	return si.updates.latestBtcInfo()
+}
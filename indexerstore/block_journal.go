@@ -0,0 +1,488 @@
+package indexerstore
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/lightningnetwork/lnd/kvdb"
+	pm "google.golang.org/protobuf/proto"
+
+	"github.com/babylonlabs-io/staking-indexer/proto"
+)
+
+// TxType is the type of a transaction recorded in the block journal
+type TxType uint32
+
+const (
+	StakingTxType TxType = iota
+	UnbondingTxType
+	InvalidStakingTxType
+	SlashingTxType
+	WithdrawalTxType
+	// StateTransitionType is a move of the lifecycle state of the delegation
+	// whose staking tx hash is recorded
+	StateTransitionType
+)
+
+func (t TxType) String() string {
+	switch t {
+	case StakingTxType:
+		return "staking"
+	case UnbondingTxType:
+		return "unbonding"
+	case InvalidStakingTxType:
+		return "invalid_staking"
+	case SlashingTxType:
+		return "slashing"
+	case WithdrawalTxType:
+		return "withdrawal"
+	case StateTransitionType:
+		return "state_transition"
+	default:
+		return fmt.Sprintf("unknown(%d)", uint32(t))
+	}
+}
+
+// JournalEntry is a state change applied when processing a confirmed block
+type JournalEntry struct {
+	TxType TxType
+	TxHash *chainhash.Hash
+	// StakingTxHash is the hash of the staking tx the entry belongs to,
+	// it is equal to TxHash for staking txs
+	StakingTxHash *chainhash.Hash
+}
+
+// SaveProcessedBlock records the hash of the processed block in its journal
+// and saves the block height as the last processed height
+func (is *IndexerStore) SaveProcessedBlock(height uint64, blockHash *chainhash.Hash) error {
+	return is.update(func(tx kvdb.RwTx) error {
+		return saveProcessedBlock(tx, height, blockHash)
+	})
+}
+
+func saveProcessedBlock(tx kvdb.RwTx, height uint64, blockHash *chainhash.Hash) error {
+	journal, err := getBlockJournal(tx, height)
+	if err != nil {
+		return err
+	}
+	if journal == nil {
+		journal = &proto.BlockJournal{}
+	}
+	journal.BlockHash = blockHash.CloneBytes()
+
+	if err := putBlockJournal(tx, height, journal); err != nil {
+		return err
+	}
+
+	return putLastProcessedHeight(tx, height)
+}
+
+// GetBlockJournal returns the state changes applied when processing the block
+// at the given height, in the order they were applied
+// it returns ErrBlockJournalNotFound if the block has not been processed
+func (is *IndexerStore) GetBlockJournal(height uint64) ([]*JournalEntry, error) {
+	var entries []*JournalEntry
+
+	err := is.view(func(tx kvdb.RTx) error {
+		journal, err := getBlockJournal(tx, height)
+		if err != nil {
+			return err
+		}
+		if journal == nil {
+			return ErrBlockJournalNotFound
+		}
+
+		for _, e := range journal.Entries {
+			entry, err := journalEntryFromProto(tx, e)
+			if err != nil {
+				return err
+			}
+			entries = append(entries, entry)
+		}
+
+		return nil
+	}, func() {})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// GetBlockHash returns the hash of the processed block at the given height
+// it returns ErrBlockJournalNotFound if the block has not been processed
+func (is *IndexerStore) GetBlockHash(height uint64) (*chainhash.Hash, error) {
+	var blockHash *chainhash.Hash
+
+	err := is.view(func(tx kvdb.RTx) error {
+		journal, err := getBlockJournal(tx, height)
+		if err != nil {
+			return err
+		}
+		if journal == nil {
+			return ErrBlockJournalNotFound
+		}
+
+		blockHash, err = chainhash.NewHash(journal.BlockHash)
+		if err != nil {
+			return ErrCorruptedStateDb
+		}
+
+		return nil
+	}, func() {})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return blockHash, nil
+}
+
+// RollbackBlock undoes all the state changes applied when processing the block
+// at the given height, which must be the last processed block. The last
+// processed height is set to height - 1 afterwards.
+func (is *IndexerStore) RollbackBlock(height uint64) error {
+	return is.update(func(tx kvdb.RwTx) error {
+		stateBucket := tx.ReadWriteBucket(indexerStateBucketName)
+		if stateBucket == nil {
+			return ErrCorruptedStateDb
+		}
+		v := stateBucket.Get(getLastProcessedHeightKey())
+		if v == nil {
+			return ErrLastProcessedHeightNotFound
+		}
+		lastProcessedHeight, err := uint64FromBytes(v)
+		if err != nil {
+			return err
+		}
+		if lastProcessedHeight != height {
+			return fmt.Errorf("%w: expected the last processed height %d, got %d",
+				ErrInvalidRollbackHeight, lastProcessedHeight, height)
+		}
+
+		journal, err := getBlockJournal(tx, height)
+		if err != nil {
+			return err
+		}
+		if journal == nil {
+			return ErrBlockJournalNotFound
+		}
+
+		// undo the entries in the reverse order they were applied
+		for i := len(journal.Entries) - 1; i >= 0; i-- {
+			if err := is.undoJournalEntry(tx, journal.Entries[i]); err != nil {
+				return err
+			}
+		}
+
+		journalBucket := tx.ReadWriteBucket(blockJournalBucketName)
+		if journalBucket == nil {
+			return ErrCorruptedStateDb
+		}
+		if err := journalBucket.Delete(uint64ToBytes(height)); err != nil {
+			return err
+		}
+
+		return putLastProcessedHeight(tx, height-1)
+	})
+}
+
+func (is *IndexerStore) undoJournalEntry(tx kvdb.RwTx, entry *proto.JournalEntry) error {
+	switch TxType(entry.TxType) {
+	case StakingTxType:
+		stakingTxBucket := tx.ReadWriteBucket(stakingTxBucketName)
+		if stakingTxBucket == nil {
+			return ErrCorruptedTransactionsDb
+		}
+		storedTxProto, err := getStakingTxProto(stakingTxBucket, entry.TxHash)
+		if err != nil {
+			return err
+		}
+		if err := stakingTxBucket.Delete(entry.TxHash); err != nil {
+			return err
+		}
+		if err := deleteTimelockExpiry(
+			tx, storedTxProto.InclusionHeight+uint64(storedTxProto.StakingTime), entry.TxHash,
+		); err != nil {
+			return err
+		}
+		if err := deleteStakerIndex(tx, storedTxProto.StakerPk, entry.TxHash); err != nil {
+			return err
+		}
+		if err := deleteHeightIndex(tx, storedTxProto.InclusionHeight, entry.TxHash); err != nil {
+			return err
+		}
+		if err := updateFinalityProviderStats(tx, storedTxProto, currentState(storedTxProto), false); err != nil {
+			return err
+		}
+
+		// overflow staking txs were never counted in the confirmed tvl
+		if storedTxProto.IsOverflow {
+			return nil
+		}
+		return is.subtractConfirmedTvl(tx, storedTxProto.StakingValue)
+
+	case UnbondingTxType:
+		unbondingTxBucket := tx.ReadWriteBucket(unbondingTxBucketName)
+		if unbondingTxBucket == nil {
+			return ErrCorruptedTransactionsDb
+		}
+		maybeTx := unbondingTxBucket.Get(entry.TxHash)
+		if maybeTx == nil {
+			return ErrTransactionNotFound
+		}
+		var unbondingTxProto proto.UnbondingTransaction
+		if err := pm.Unmarshal(maybeTx, &unbondingTxProto); err != nil {
+			return ErrCorruptedTransactionsDb
+		}
+		if err := unbondingTxBucket.Delete(entry.TxHash); err != nil {
+			return err
+		}
+		if err := deleteTimelockExpiry(
+			tx, unbondingTxProto.InclusionHeight+uint64(unbondingTxProto.UnbondingTime), entry.TxHash,
+		); err != nil {
+			return err
+		}
+
+		stakingTxBucket := tx.ReadWriteBucket(stakingTxBucketName)
+		if stakingTxBucket == nil {
+			return ErrCorruptedTransactionsDb
+		}
+		stakingTxProto, err := getStakingTxProto(stakingTxBucket, unbondingTxProto.StakingTxHash)
+		if err != nil {
+			return err
+		}
+
+		// the unbonding of an overflow staking tx never changed the confirmed tvl
+		if stakingTxProto.IsOverflow {
+			return nil
+		}
+		return is.incrementConfirmedTvl(tx, stakingTxProto.StakingValue)
+
+	case InvalidStakingTxType:
+		invalidStakingTxBucket := tx.ReadWriteBucket(invalidStakingTxBucketName)
+		if invalidStakingTxBucket == nil {
+			return ErrCorruptedTransactionsDb
+		}
+		if invalidStakingTxBucket.Get(entry.TxHash) == nil {
+			return ErrTransactionNotFound
+		}
+
+		return invalidStakingTxBucket.Delete(entry.TxHash)
+
+	case WithdrawalTxType:
+		withdrawalTxBucket := tx.ReadWriteBucket(withdrawalTxBucketName)
+		if withdrawalTxBucket == nil {
+			return ErrCorruptedTransactionsDb
+		}
+		maybeTx := withdrawalTxBucket.Get(entry.TxHash)
+		if maybeTx == nil {
+			return ErrTransactionNotFound
+		}
+		var withdrawalTxProto proto.WithdrawalTransaction
+		if err := pm.Unmarshal(maybeTx, &withdrawalTxProto); err != nil {
+			return ErrCorruptedTransactionsDb
+		}
+		if err := withdrawalTxBucket.Delete(entry.TxHash); err != nil {
+			return err
+		}
+
+		byStakingTxBucket := tx.ReadWriteBucket(withdrawalTxByStakingTxBucketName)
+		if byStakingTxBucket == nil {
+			return ErrCorruptedTransactionsDb
+		}
+
+		// withdrawal txs never changed the confirmed tvl
+		return byStakingTxBucket.Delete(withdrawalTxProto.StakingTxHash)
+
+	case SlashingTxType:
+		slashingTxBucket := tx.ReadWriteBucket(slashingTxBucketName)
+		if slashingTxBucket == nil {
+			return ErrCorruptedTransactionsDb
+		}
+		maybeTx := slashingTxBucket.Get(entry.TxHash)
+		if maybeTx == nil {
+			return ErrTransactionNotFound
+		}
+		var slashingTxProto proto.SlashingTransaction
+		if err := pm.Unmarshal(maybeTx, &slashingTxProto); err != nil {
+			return ErrCorruptedTransactionsDb
+		}
+		if err := slashingTxBucket.Delete(entry.TxHash); err != nil {
+			return err
+		}
+
+		// the slashing of an unbonding tx never changed the confirmed tvl
+		if len(slashingTxProto.UnbondingTxHash) != 0 {
+			return nil
+		}
+
+		stakingTxBucket := tx.ReadWriteBucket(stakingTxBucketName)
+		if stakingTxBucket == nil {
+			return ErrCorruptedTransactionsDb
+		}
+		stakingTxProto, err := getStakingTxProto(stakingTxBucket, slashingTxProto.StakingTxHash)
+		if err != nil {
+			return err
+		}
+
+		// the slashing of an overflow staking tx never changed the confirmed tvl
+		if stakingTxProto.IsOverflow {
+			return nil
+		}
+		return is.incrementConfirmedTvl(tx, stakingTxProto.StakingValue)
+
+	case StateTransitionType:
+		return undoStateTransition(tx, entry.TxHash)
+
+	default:
+		return fmt.Errorf("%w: unknown journal entry type %d", ErrCorruptedStateDb, entry.TxType)
+	}
+}
+
+// appendJournalEntry records a state change in the journal of the block at the given height
+func (is *IndexerStore) appendJournalEntry(
+	tx kvdb.RwTx,
+	height uint64,
+	txType TxType,
+	txHashBytes []byte,
+) error {
+	journal, err := getBlockJournal(tx, height)
+	if err != nil {
+		return err
+	}
+	if journal == nil {
+		journal = &proto.BlockJournal{}
+	}
+
+	journal.Entries = append(journal.Entries, &proto.JournalEntry{
+		TxType: uint32(txType),
+		TxHash: txHashBytes,
+	})
+
+	return putBlockJournal(tx, height, journal)
+}
+
+// getBlockJournal returns the journal of the block at the given height
+// it returns (nil, nil) if the journal is not found
+func getBlockJournal(tx kvdb.RTx, height uint64) (*proto.BlockJournal, error) {
+	journalBucket := tx.ReadBucket(blockJournalBucketName)
+	if journalBucket == nil {
+		return nil, ErrCorruptedStateDb
+	}
+
+	v := journalBucket.Get(uint64ToBytes(height))
+	if v == nil {
+		return nil, nil
+	}
+
+	var journal proto.BlockJournal
+	if err := pm.Unmarshal(v, &journal); err != nil {
+		return nil, ErrCorruptedStateDb
+	}
+
+	return &journal, nil
+}
+
+func putBlockJournal(tx kvdb.RwTx, height uint64, journal *proto.BlockJournal) error {
+	journalBucket := tx.ReadWriteBucket(blockJournalBucketName)
+	if journalBucket == nil {
+		return ErrCorruptedStateDb
+	}
+
+	marshalled, err := pm.Marshal(journal)
+	if err != nil {
+		return err
+	}
+
+	return journalBucket.Put(uint64ToBytes(height), marshalled)
+}
+
+func journalEntryFromProto(tx kvdb.RTx, e *proto.JournalEntry) (*JournalEntry, error) {
+	txHash, err := chainhash.NewHash(e.TxHash)
+	if err != nil {
+		return nil, ErrCorruptedStateDb
+	}
+
+	entry := &JournalEntry{
+		TxType:        TxType(e.TxType),
+		TxHash:        txHash,
+		StakingTxHash: txHash,
+	}
+
+	switch entry.TxType {
+	case UnbondingTxType:
+		unbondingTxBucket := tx.ReadBucket(unbondingTxBucketName)
+		if unbondingTxBucket == nil {
+			return nil, ErrCorruptedTransactionsDb
+		}
+		maybeTx := unbondingTxBucket.Get(e.TxHash)
+		if maybeTx == nil {
+			return nil, ErrTransactionNotFound
+		}
+		var unbondingTxProto proto.UnbondingTransaction
+		if err := pm.Unmarshal(maybeTx, &unbondingTxProto); err != nil {
+			return nil, ErrCorruptedTransactionsDb
+		}
+		stakingTxHash, err := chainhash.NewHash(unbondingTxProto.StakingTxHash)
+		if err != nil {
+			return nil, ErrCorruptedTransactionsDb
+		}
+		entry.StakingTxHash = stakingTxHash
+
+	case WithdrawalTxType:
+		withdrawalTxBucket := tx.ReadBucket(withdrawalTxBucketName)
+		if withdrawalTxBucket == nil {
+			return nil, ErrCorruptedTransactionsDb
+		}
+		maybeTx := withdrawalTxBucket.Get(e.TxHash)
+		if maybeTx == nil {
+			return nil, ErrTransactionNotFound
+		}
+		var withdrawalTxProto proto.WithdrawalTransaction
+		if err := pm.Unmarshal(maybeTx, &withdrawalTxProto); err != nil {
+			return nil, ErrCorruptedTransactionsDb
+		}
+		stakingTxHash, err := chainhash.NewHash(withdrawalTxProto.StakingTxHash)
+		if err != nil {
+			return nil, ErrCorruptedTransactionsDb
+		}
+		entry.StakingTxHash = stakingTxHash
+
+	case SlashingTxType:
+		slashingTxBucket := tx.ReadBucket(slashingTxBucketName)
+		if slashingTxBucket == nil {
+			return nil, ErrCorruptedTransactionsDb
+		}
+		maybeTx := slashingTxBucket.Get(e.TxHash)
+		if maybeTx == nil {
+			return nil, ErrTransactionNotFound
+		}
+		var slashingTxProto proto.SlashingTransaction
+		if err := pm.Unmarshal(maybeTx, &slashingTxProto); err != nil {
+			return nil, ErrCorruptedTransactionsDb
+		}
+		stakingTxHash, err := chainhash.NewHash(slashingTxProto.StakingTxHash)
+		if err != nil {
+			return nil, ErrCorruptedTransactionsDb
+		}
+		entry.StakingTxHash = stakingTxHash
+	}
+
+	return entry, nil
+}
+
+func getStakingTxProto(stakingTxBucket kvdb.RBucket, txHashBytes []byte) (*proto.StakingTransaction, error) {
+	maybeTx := stakingTxBucket.Get(txHashBytes)
+	if maybeTx == nil {
+		return nil, ErrTransactionNotFound
+	}
+
+	var storedTxProto proto.StakingTransaction
+	if err := pm.Unmarshal(maybeTx, &storedTxProto); err != nil {
+		return nil, ErrCorruptedTransactionsDb
+	}
+
+	return &storedTxProto, nil
+}
@@ -20,4 +20,34 @@ var (
 
 	// ErrNegativeTvl the tvl is negative
 	ErrNegativeTvl = errors.New("negative tvl")
+
+	// ErrBlockJournalNotFound the journal of the block to roll back is not found in db
+	ErrBlockJournalNotFound = errors.New("block journal not found")
+
+	// ErrInvalidRollbackHeight the block to roll back is not the last processed block
+	ErrInvalidRollbackHeight = errors.New("invalid rollback height")
+
+	// ErrInvalidStateTransition the delegation cannot move to the requested lifecycle state
+	ErrInvalidStateTransition = errors.New("invalid delegation state transition")
+
+	// ErrOutboxEntryNotFound the event to mark delivered is not found in the outbox
+	ErrOutboxEntryNotFound = errors.New("outbox entry not found")
+
+	// ErrSchemaTooNew the db is written by a binary with a newer schema version
+	ErrSchemaTooNew = errors.New("db schema version is newer than supported")
+
+	// ErrInvalidSnapshot the snapshot is malformed or its data is inconsistent
+	ErrInvalidSnapshot = errors.New("invalid snapshot")
+
+	// ErrSnapshotChecksumMismatch the checksum of the snapshot does not match its data
+	ErrSnapshotChecksumMismatch = errors.New("snapshot checksum mismatch")
+
+	// ErrSnapshotParamsMismatch the snapshot is created with different global params
+	ErrSnapshotParamsMismatch = errors.New("snapshot params mismatch")
+
+	// ErrDbNotEmpty the db to restore a snapshot into already has data
+	ErrDbNotEmpty = errors.New("db is not empty")
+
+	// ErrOutboxNotDrained the snapshot is refused as events are waiting in the outbox
+	ErrOutboxNotDrained = errors.New("outbox has undelivered events")
 )
@@ -0,0 +1,204 @@
+package indexerstore
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/lightningnetwork/lnd/kvdb"
+	pm "google.golang.org/protobuf/proto"
+
+	"github.com/babylonlabs-io/staking-indexer/proto"
+)
+
+// FinalityProviderStats are the totals of the delegations to a finality
+// provider. They follow the lifecycle states of the delegations, so a
+// delegation leaves the active stake once it is unbonding, its timelock
+// expires, or it is slashed
+type FinalityProviderStats struct {
+	FinalityProviderPk *btcec.PublicKey
+	// ActiveStake is the total value of the delegations in StateActive
+	ActiveStake uint64
+	// OverflowStake is the total value of the delegations in StateOverflow
+	OverflowStake uint64
+	// ActiveDelegations is the number of the delegations in StateActive
+	ActiveDelegations uint64
+	// UnbondedDelegations is the number of the delegations in StateUnbonding,
+	// StateUnbonded, or StateWithdrawn
+	UnbondedDelegations uint64
+}
+
+// GetFinalityProviderStats returns the totals of the delegations to the
+// finality provider with the given public key
+// it returns (nil, nil) if there is no delegation to the finality provider
+func (is *IndexerStore) GetFinalityProviderStats(fpPk *btcec.PublicKey) (*FinalityProviderStats, error) {
+	var stats *FinalityProviderStats
+
+	err := is.view(func(tx kvdb.RTx) error {
+		statsBucket := tx.ReadBucket(finalityProviderStatsBucketName)
+		if statsBucket == nil {
+			return ErrCorruptedStateDb
+		}
+
+		statsBytes := statsBucket.Get(schnorr.SerializePubKey(fpPk))
+		if statsBytes == nil {
+			return nil
+		}
+
+		var statsProto proto.FinalityProviderStats
+		if err := pm.Unmarshal(statsBytes, &statsProto); err != nil {
+			return ErrCorruptedStateDb
+		}
+
+		stats = protoFinalityProviderStatsToStats(fpPk, &statsProto)
+		return nil
+	}, func() {
+		stats = nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// ScanFinalityProviderStats iterates through the totals of the delegations
+// of all the finality providers
+func (is *IndexerStore) ScanFinalityProviderStats(callback func(*FinalityProviderStats) error) error {
+	return is.view(func(tx kvdb.RTx) error {
+		statsBucket := tx.ReadBucket(finalityProviderStatsBucketName)
+		if statsBucket == nil {
+			return ErrCorruptedStateDb
+		}
+
+		return statsBucket.ForEach(func(k, v []byte) error {
+			fpPk, err := schnorr.ParsePubKey(k)
+			if err != nil {
+				return fmt.Errorf("invalid finality provider pk: %w", err)
+			}
+
+			var statsProto proto.FinalityProviderStats
+			if err := pm.Unmarshal(v, &statsProto); err != nil {
+				return ErrCorruptedStateDb
+			}
+
+			return callback(protoFinalityProviderStatsToStats(fpPk, &statsProto))
+		})
+	}, func() {})
+}
+
+func protoFinalityProviderStatsToStats(fpPk *btcec.PublicKey, statsProto *proto.FinalityProviderStats) *FinalityProviderStats {
+	return &FinalityProviderStats{
+		FinalityProviderPk:  fpPk,
+		ActiveStake:         statsProto.ActiveStake,
+		OverflowStake:       statsProto.OverflowStake,
+		ActiveDelegations:   statsProto.ActiveDelegations,
+		UnbondedDelegations: statsProto.UnbondedDelegations,
+	}
+}
+
+// moveFinalityProviderDelegation updates the totals of the finality provider
+// of the given delegation for its move from one lifecycle state to another
+func moveFinalityProviderDelegation(
+	tx kvdb.RwTx,
+	stakingTxProto *proto.StakingTransaction,
+	from DelegationState,
+	to DelegationState,
+) error {
+	if err := updateFinalityProviderStats(tx, stakingTxProto, from, false); err != nil {
+		return err
+	}
+
+	return updateFinalityProviderStats(tx, stakingTxProto, to, true)
+}
+
+// updateFinalityProviderStats adds the given delegation in the given state
+// to the totals of its finality provider, or removes it if add is false
+func updateFinalityProviderStats(
+	tx kvdb.RwTx,
+	stakingTxProto *proto.StakingTransaction,
+	state DelegationState,
+	add bool,
+) error {
+	statsBucket := tx.ReadWriteBucket(finalityProviderStatsBucketName)
+	if statsBucket == nil {
+		return ErrCorruptedStateDb
+	}
+
+	var statsProto proto.FinalityProviderStats
+	if statsBytes := statsBucket.Get(stakingTxProto.FinalityProviderPk); statsBytes != nil {
+		if err := pm.Unmarshal(statsBytes, &statsProto); err != nil {
+			return ErrCorruptedStateDb
+		}
+	}
+
+	var stake *uint64
+	var count *uint64
+	switch state {
+	case StateActive:
+		stake = &statsProto.ActiveStake
+		count = &statsProto.ActiveDelegations
+	case StateOverflow:
+		stake = &statsProto.OverflowStake
+	case StateUnbonding, StateUnbonded, StateWithdrawn:
+		count = &statsProto.UnbondedDelegations
+	default:
+		// the slashed delegations are not counted
+		return nil
+	}
+
+	if add {
+		if stake != nil {
+			*stake += stakingTxProto.StakingValue
+		}
+		if count != nil {
+			*count++
+		}
+	} else {
+		if (stake != nil && *stake < stakingTxProto.StakingValue) || (count != nil && *count == 0) {
+			return fmt.Errorf("%w: negative finality provider stats", ErrCorruptedStateDb)
+		}
+		if stake != nil {
+			*stake -= stakingTxProto.StakingValue
+		}
+		if count != nil {
+			*count--
+		}
+	}
+
+	marshalled, err := pm.Marshal(&statsProto)
+	if err != nil {
+		return err
+	}
+
+	return statsBucket.Put(stakingTxProto.FinalityProviderPk, marshalled)
+}
+
+// migrateFinalityProviderStats creates the totals of the finality providers
+// and computes them from the staking txs stored before they were introduced.
+// It does nothing if the totals already exist, as they are then maintained
+// with the delegations
+func migrateFinalityProviderStats(tx kvdb.RwTx) error {
+	if tx.ReadWriteBucket(finalityProviderStatsBucketName) != nil {
+		return nil
+	}
+
+	if _, err := tx.CreateTopLevelBucket(finalityProviderStatsBucketName); err != nil {
+		return err
+	}
+
+	txBucket := tx.ReadWriteBucket(stakingTxBucketName)
+	if txBucket == nil {
+		return ErrCorruptedTransactionsDb
+	}
+
+	return txBucket.ForEach(func(k, v []byte) error {
+		var storedTxProto proto.StakingTransaction
+		if err := pm.Unmarshal(v, &storedTxProto); err != nil {
+			return ErrCorruptedTransactionsDb
+		}
+
+		return updateFinalityProviderStats(tx, &storedTxProto, currentState(&storedTxProto), true)
+	})
+}
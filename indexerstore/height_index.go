@@ -0,0 +1,180 @@
+package indexerstore
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/lightningnetwork/lnd/kvdb"
+	pm "google.golang.org/protobuf/proto"
+
+	"github.com/babylonlabs-io/staking-indexer/proto"
+)
+
+// errStopScan stops the scan of the height index without an error
+var errStopScan = errors.New("stop scan")
+
+// ScanStakingTransactionsByHeightRange iterates through the staking
+// transactions included in [startHeight, endHeight] in the order of their
+// inclusion heights, and of their hashes at the same height. Only the
+// transactions in the range are read
+func (is *IndexerStore) ScanStakingTransactionsByHeightRange(
+	startHeight, endHeight uint64,
+	callback func(*StoredStakingTransaction) error,
+) error {
+	return is.view(func(tx kvdb.RTx) error {
+		return scanStakingTxsByHeight(tx, heightIndexKey(startHeight, nil), endHeight, callback)
+	}, func() {})
+}
+
+// GetStakingTransactionsByHeightRange returns at most limit staking
+// transactions included in [startHeight, endHeight] in the order of their
+// inclusion heights, and of their hashes at the same height, starting after
+// the transaction with afterTxHash. It starts from the first transaction if
+// afterTxHash is nil, so that the transactions can be paginated by passing
+// the hash of the last transaction of the previous page
+// it returns ErrTransactionNotFound if the transaction with afterTxHash is
+// not stored
+func (is *IndexerStore) GetStakingTransactionsByHeightRange(
+	startHeight, endHeight uint64,
+	afterTxHash *chainhash.Hash,
+	limit int,
+) ([]*StoredStakingTransaction, error) {
+	var storedTxs []*StoredStakingTransaction
+
+	err := is.view(func(tx kvdb.RTx) error {
+		startKey := heightIndexKey(startHeight, nil)
+		if afterTxHash != nil {
+			txBucket := tx.ReadBucket(stakingTxBucketName)
+			if txBucket == nil {
+				return ErrCorruptedTransactionsDb
+			}
+			afterTxProto, err := getStakingTxProto(txBucket, afterTxHash.CloneBytes())
+			if err != nil {
+				return err
+			}
+
+			// the key following the one of the tx with afterTxHash
+			afterKey := heightIndexKey(afterTxProto.InclusionHeight, afterTxHash.CloneBytes())
+			if bytes.Compare(afterKey, startKey) >= 0 {
+				startKey = append(afterKey, 0)
+			}
+		}
+
+		return scanStakingTxsByHeight(tx, startKey, endHeight, func(storedTx *StoredStakingTransaction) error {
+			if len(storedTxs) == limit {
+				return errStopScan
+			}
+			storedTxs = append(storedTxs, storedTx)
+			return nil
+		})
+	}, func() {
+		storedTxs = nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return storedTxs, nil
+}
+
+// scanStakingTxsByHeight iterates through the staking txs in the height
+// index from the given key up to the end height. It stops without an error
+// if the callback returns errStopScan
+func scanStakingTxsByHeight(
+	tx kvdb.RTx,
+	startKey []byte,
+	endHeight uint64,
+	callback func(*StoredStakingTransaction) error,
+) error {
+	indexBucket := tx.ReadBucket(stakingTxByHeightBucketName)
+	if indexBucket == nil {
+		return ErrCorruptedTransactionsDb
+	}
+	txBucket := tx.ReadBucket(stakingTxBucketName)
+	if txBucket == nil {
+		return ErrCorruptedTransactionsDb
+	}
+
+	c := indexBucket.ReadCursor()
+	for k, _ := c.Seek(startKey); k != nil; k, _ = c.Next() {
+		if len(k) != 8+chainhash.HashSize {
+			return ErrCorruptedTransactionsDb
+		}
+		if binary.BigEndian.Uint64(k[:8]) > endHeight {
+			return nil
+		}
+
+		storedTxProto, err := getStakingTxProto(txBucket, k[8:])
+		if err != nil {
+			return err
+		}
+		storedTx, err := protoStakingTxToStoredStakingTx(storedTxProto)
+		if err != nil {
+			return err
+		}
+
+		if err := callback(storedTx); err != nil {
+			if errors.Is(err, errStopScan) {
+				return nil
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+// putHeightIndex indexes the staking tx by its inclusion height
+func putHeightIndex(tx kvdb.RwTx, inclusionHeight uint64, txHashBytes []byte) error {
+	indexBucket := tx.ReadWriteBucket(stakingTxByHeightBucketName)
+	if indexBucket == nil {
+		return ErrCorruptedTransactionsDb
+	}
+
+	return indexBucket.Put(heightIndexKey(inclusionHeight, txHashBytes), []byte{})
+}
+
+func deleteHeightIndex(tx kvdb.RwTx, inclusionHeight uint64, txHashBytes []byte) error {
+	indexBucket := tx.ReadWriteBucket(stakingTxByHeightBucketName)
+	if indexBucket == nil {
+		return ErrCorruptedTransactionsDb
+	}
+
+	return indexBucket.Delete(heightIndexKey(inclusionHeight, txHashBytes))
+}
+
+// heightIndexKey is the big-endian inclusion height followed by the staking
+// tx hash so that the staking txs are ordered by height
+func heightIndexKey(inclusionHeight uint64, txHashBytes []byte) []byte {
+	return append(uint64ToBytes(inclusionHeight), txHashBytes...)
+}
+
+// migrateHeightIndex creates the height index and backfills it with the
+// staking txs stored before the index was introduced. It does nothing if the
+// index already exists, as it is then maintained with the staking txs
+func migrateHeightIndex(tx kvdb.RwTx) error {
+	if tx.ReadWriteBucket(stakingTxByHeightBucketName) != nil {
+		return nil
+	}
+
+	if _, err := tx.CreateTopLevelBucket(stakingTxByHeightBucketName); err != nil {
+		return err
+	}
+
+	txBucket := tx.ReadWriteBucket(stakingTxBucketName)
+	if txBucket == nil {
+		return ErrCorruptedTransactionsDb
+	}
+
+	return txBucket.ForEach(func(k, v []byte) error {
+		var storedTxProto proto.StakingTransaction
+		if err := pm.Unmarshal(v, &storedTxProto); err != nil {
+			return ErrCorruptedTransactionsDb
+		}
+
+		return putHeightIndex(tx, storedTxProto.InclusionHeight, k)
+	})
+}
@@ -29,10 +29,52 @@ var (
 
 	// stores the confirmed tvl
 	confirmedTvlBucketName = []byte("confirmedtvl")
+
+	// mapping tx hash -> withdrawal transaction
+	withdrawalTxBucketName = []byte("withdrawaltxs")
+
+	// mapping staking tx hash -> withdrawal tx hash
+	withdrawalTxByStakingTxBucketName = []byte("withdrawaltxsbystaking")
+
+	// mapping staker pk || staking tx hash -> nothing
+	stakingTxByStakerBucketName = []byte("stakingtxsbystaker")
+
+	// mapping inclusion height || staking tx hash -> nothing
+	stakingTxByHeightBucketName = []byte("stakingtxsbyheight")
+
+	// mapping finality provider pk -> totals of its delegations
+	finalityProviderStatsBucketName = []byte("finalityproviderstats")
+
+	// mapping tx hash -> slashing transaction
+	slashingTxBucketName = []byte("slashingtxs")
+
+	// mapping tx hash -> invalid staking transaction
+	invalidStakingTxBucketName = []byte("invalidstakingtxs")
+
+	// mapping block height -> block journal
+	blockJournalBucketName = []byte("blockjournal")
+
+	// mapping expiry height || spent tx hash -> staking tx hash
+	timelockExpiryBucketName = []byte("timelockexpiries")
+
+	// mapping sequence -> outbox entry
+	outboxBucketName = []byte("outbox")
 )
 
+// txBucketNames are the buckets keyed by the hash of the stored transactions
+var txBucketNames = [][]byte{
+	stakingTxBucketName,
+	unbondingTxBucketName,
+	withdrawalTxBucketName,
+	slashingTxBucketName,
+	invalidStakingTxBucketName,
+}
+
 type IndexerStore struct {
 	db kvdb.Backend
+	// blockTx is the write transaction of the block being processed, it is
+	// nil if the store is not bound to a block
+	blockTx kvdb.RwTx
 }
 
 type StoredStakingTransaction struct {
@@ -44,18 +86,37 @@ type StoredStakingTransaction struct {
 	FinalityProviderPk *btcec.PublicKey
 	IsOverflow         bool
 	StakingValue       uint64
+	State              DelegationState
+	// StateTransitions are the moves between lifecycle states in the order
+	// they happened, the first one is the initial state
+	StateTransitions []*StateTransition
 }
 
 type StoredUnbondingTransaction struct {
+	Tx              *wire.MsgTx
+	StakingTxHash   *chainhash.Hash
+	InclusionHeight uint64
+	UnbondingTime   uint32
+}
+
+type StoredWithdrawalTransaction struct {
 	Tx            *wire.MsgTx
 	StakingTxHash *chainhash.Hash
+	// UnbondingTxHash is nil if the withdrawal tx spends the staking tx
+	UnbondingTxHash *chainhash.Hash
+	InclusionHeight uint64
+}
+
+// IsFromUnbonding returns whether the withdrawal tx spends the unbonding output
+func (wt *StoredWithdrawalTransaction) IsFromUnbonding() bool {
+	return wt.UnbondingTxHash != nil
 }
 
 // NewIndexerStore returns a new store backed by db
 func NewIndexerStore(db kvdb.Backend) (*IndexerStore,
 	error) {
 
-	store := &IndexerStore{db}
+	store := &IndexerStore{db: db}
 	if err := store.initBuckets(); err != nil {
 		return nil, err
 	}
@@ -63,32 +124,121 @@ func NewIndexerStore(db kvdb.Backend) (*IndexerStore,
 	return store, nil
 }
 
-func (c *IndexerStore) initBuckets() error {
-	return kvdb.Batch(c.db, func(tx kvdb.RwTx) error {
-		_, err := tx.CreateTopLevelBucket(stakingTxBucketName)
-		if err != nil {
-			return err
-		}
+// UpdateBlock applies all the state changes of processing the block at the
+// given height in a single write transaction, together with saving the block
+// as the last processed one. The store passed to fn is bound to the
+// transaction, so the reads through it observe the changes not committed
+// yet. The changes are committed only if fn succeeds, otherwise none of them
+// is applied. The bound store must not be used after fn returns
+func (is *IndexerStore) UpdateBlock(
+	height uint64,
+	blockHash *chainhash.Hash,
+	fn func(bs Store) error,
+) error {
+	if is.blockTx != nil {
+		return fmt.Errorf("the store is already bound to a block")
+	}
 
-		_, err = tx.CreateTopLevelBucket(unbondingTxBucketName)
-		if err != nil {
+	return kvdb.Update(is.db, func(tx kvdb.RwTx) error {
+		bs := &IndexerStore{db: is.db, blockTx: tx}
+		if err := fn(bs); err != nil {
 			return err
 		}
 
-		_, err = tx.CreateTopLevelBucket(indexerStateBucketName)
-		if err != nil {
-			return err
-		}
+		return saveProcessedBlock(tx, height, blockHash)
+	}, func() {})
+}
 
-		_, err = tx.CreateTopLevelBucket(confirmedTvlBucketName)
-		if err != nil {
+// update runs f in a write transaction, or in the block transaction if the
+// store is bound to a block
+func (is *IndexerStore) update(f func(tx kvdb.RwTx) error) error {
+	if is.blockTx != nil {
+		return f(is.blockTx)
+	}
+
+	return kvdb.Batch(is.db, f)
+}
+
+// view runs f in a read transaction, or in the block transaction if the
+// store is bound to a block
+func (is *IndexerStore) view(f func(tx kvdb.RTx) error, reset func()) error {
+	if is.blockTx != nil {
+		reset()
+		return f(is.blockTx)
+	}
+
+	return is.db.View(f, reset)
+}
+
+// initBuckets creates the buckets and migrates the database to the latest
+// schema version
+func (c *IndexerStore) initBuckets() error {
+	return kvdb.Batch(c.db, func(tx kvdb.RwTx) error {
+		if err := createBuckets(tx); err != nil {
 			return err
 		}
 
-		return nil
+		_, err := migrate(tx)
+		return err
 	})
 }
 
+// createBuckets creates the buckets that are not created by the migrations
+func createBuckets(tx kvdb.RwTx) error {
+	_, err := tx.CreateTopLevelBucket(stakingTxBucketName)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.CreateTopLevelBucket(unbondingTxBucketName)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.CreateTopLevelBucket(indexerStateBucketName)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.CreateTopLevelBucket(confirmedTvlBucketName)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.CreateTopLevelBucket(withdrawalTxBucketName)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.CreateTopLevelBucket(withdrawalTxByStakingTxBucketName)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.CreateTopLevelBucket(slashingTxBucketName)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.CreateTopLevelBucket(invalidStakingTxBucketName)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.CreateTopLevelBucket(blockJournalBucketName)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.CreateTopLevelBucket(timelockExpiryBucketName)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.CreateTopLevelBucket(outboxBucketName)
+	return err
+}
+
 func (is *IndexerStore) AddStakingTransaction(
 	tx *wire.MsgTx,
 	stakingOutputIdx uint32,
@@ -115,6 +265,12 @@ func (is *IndexerStore) AddStakingTransaction(
 		FinalityProviderPk: schnorr.SerializePubKey(fpPk),
 		IsOverflow:         isOverflow,
 		StakingValue:       stakingValue,
+		State:              uint32(initialState(isOverflow)),
+		StateTransitions: []*proto.StateTransition{{
+			State:  uint32(initialState(isOverflow)),
+			Height: inclusionHeight,
+			TxHash: txHash.CloneBytes(),
+		}},
 	}
 
 	return is.addStakingTransaction(txHash[:], &msg)
@@ -124,7 +280,7 @@ func (is *IndexerStore) addStakingTransaction(
 	txHashBytes []byte,
 	st *proto.StakingTransaction,
 ) error {
-	return kvdb.Batch(is.db, func(tx kvdb.RwTx) error {
+	return is.update(func(tx kvdb.RwTx) error {
 
 		txBucket := tx.ReadWriteBucket(stakingTxBucketName)
 		if txBucket == nil {
@@ -145,6 +301,30 @@ func (is *IndexerStore) addStakingTransaction(
 			return err
 		}
 
+		if err := is.appendJournalEntry(
+			tx, st.InclusionHeight, StakingTxType, txHashBytes,
+		); err != nil {
+			return err
+		}
+
+		if err := putTimelockExpiry(
+			tx, st.InclusionHeight+uint64(st.StakingTime), txHashBytes, txHashBytes,
+		); err != nil {
+			return err
+		}
+
+		if err := putStakerIndex(tx, st.StakerPk, txHashBytes); err != nil {
+			return err
+		}
+
+		if err := putHeightIndex(tx, st.InclusionHeight, txHashBytes); err != nil {
+			return err
+		}
+
+		if err := updateFinalityProviderStats(tx, st, currentState(st), true); err != nil {
+			return err
+		}
+
 		// if the staking tx is an overflow, we don't increment the confirmed tvl
 		if st.IsOverflow {
 			return nil
@@ -159,7 +339,7 @@ func (is *IndexerStore) GetStakingTransaction(txHash *chainhash.Hash) (*StoredSt
 	var storedTx *StoredStakingTransaction
 	txHashBytes := txHash.CloneBytes()
 
-	err := is.db.View(func(tx kvdb.RTx) error {
+	err := is.view(func(tx kvdb.RTx) error {
 		txBucket := tx.ReadBucket(stakingTxBucketName)
 		if txBucket == nil {
 			return ErrCorruptedTransactionsDb
@@ -193,7 +373,7 @@ func (is *IndexerStore) GetStakingTransaction(txHash *chainhash.Hash) (*StoredSt
 
 // ScanStoredStakingTransactions iterates through and exports all stored staking transactions
 func (is *IndexerStore) ScanStoredStakingTransactions(callback func(*StoredStakingTransaction) error) error {
-	return is.db.View(func(tx kvdb.RTx) error {
+	return is.view(func(tx kvdb.RTx) error {
 		txBucket := tx.ReadBucket(stakingTxBucketName)
 		if txBucket == nil {
 			return ErrCorruptedTransactionsDb
@@ -232,6 +412,19 @@ func protoStakingTxToStoredStakingTx(protoTx *proto.StakingTransaction) (*Stored
 		return nil, fmt.Errorf("invalid finality provider pk: %w", err)
 	}
 
+	stateTransitions := make([]*StateTransition, 0, len(protoTx.StateTransitions))
+	for _, st := range protoTx.StateTransitions {
+		txHash, err := chainhash.NewHash(st.TxHash)
+		if err != nil {
+			return nil, fmt.Errorf("invalid state transition tx hash: %w", err)
+		}
+		stateTransitions = append(stateTransitions, &StateTransition{
+			State:  DelegationState(st.State),
+			Height: st.Height,
+			TxHash: txHash,
+		})
+	}
+
 	return &StoredStakingTransaction{
 		Tx:                 &stakingTx,
 		StakingOutputIdx:   protoTx.StakingOutputIdx,
@@ -241,12 +434,19 @@ func protoStakingTxToStoredStakingTx(protoTx *proto.StakingTransaction) (*Stored
 		FinalityProviderPk: fpPk,
 		IsOverflow:         protoTx.IsOverflow,
 		StakingValue:       protoTx.StakingValue,
+		State:              currentState(protoTx),
+		StateTransitions:   stateTransitions,
 	}, nil
 }
 
+// AddUnbondingTransaction saves an unbonding tx that spends the output of the
+// given staking tx. The unbonding output can be withdrawn unbondingTime
+// blocks after the inclusion height
 func (is *IndexerStore) AddUnbondingTransaction(
 	tx *wire.MsgTx,
 	stakingTxHash *chainhash.Hash,
+	inclusionHeight uint64,
+	unbondingTime uint32,
 ) error {
 	txHash := tx.TxHash()
 	serializedTx, err := utils.SerializeBtcTransaction(tx)
@@ -259,6 +459,8 @@ func (is *IndexerStore) AddUnbondingTransaction(
 	msg := proto.UnbondingTransaction{
 		TransactionBytes: serializedTx,
 		StakingTxHash:    stakingTxHash.CloneBytes(),
+		InclusionHeight:  inclusionHeight,
+		UnbondingTime:    unbondingTime,
 	}
 
 	return is.addUnbondingTransaction(txHash[:], stakingTxHashBytes, &msg)
@@ -269,7 +471,7 @@ func (is *IndexerStore) addUnbondingTransaction(
 	stakingHashBytes []byte,
 	ut *proto.UnbondingTransaction,
 ) error {
-	return kvdb.Batch(is.db, func(tx kvdb.RwTx) error {
+	return is.update(func(tx kvdb.RwTx) error {
 		stakingTxBucket := tx.ReadWriteBucket(stakingTxBucketName)
 		if stakingTxBucket == nil {
 			return ErrCorruptedTransactionsDb
@@ -307,6 +509,24 @@ func (is *IndexerStore) addUnbondingTransaction(
 			return err
 		}
 
+		if err := is.appendJournalEntry(
+			tx, ut.InclusionHeight, UnbondingTxType, txHashBytes,
+		); err != nil {
+			return err
+		}
+
+		if err := is.transitionState(
+			tx, stakingHashBytes, StateUnbonding, ut.InclusionHeight, txHashBytes,
+		); err != nil {
+			return err
+		}
+
+		if err := putTimelockExpiry(
+			tx, ut.InclusionHeight+uint64(ut.UnbondingTime), txHashBytes, stakingHashBytes,
+		); err != nil {
+			return err
+		}
+
 		// if the staking tx is an overflow, we don't decrement the confirmed tvl
 		// as it was never added
 		if storedTxProto.IsOverflow {
@@ -325,7 +545,7 @@ func (is *IndexerStore) GetUnbondingTransaction(txHash *chainhash.Hash) (*Stored
 	var storedTx *StoredUnbondingTransaction
 	txHashBytes := txHash.CloneBytes()
 
-	err := is.db.View(func(tx kvdb.RTx) error {
+	err := is.view(func(tx kvdb.RTx) error {
 		txBucket := tx.ReadBucket(unbondingTxBucketName)
 		if txBucket == nil {
 			return ErrCorruptedTransactionsDb
@@ -357,21 +577,27 @@ func (is *IndexerStore) GetUnbondingTransaction(txHash *chainhash.Hash) (*Stored
 	return storedTx, nil
 }
 
-func (is *IndexerStore) TxExists(txHash *chainhash.Hash) (bool, error) {
-	txHashBytes := txHash.CloneBytes()
-
-	existed := false
+// GetUnbondingTransactionByStakingTxHash retrieves the stored unbonding
+// transaction spending the staking transaction with the given hash
+// it returns (nil, nil) if the staking transaction is not unbonded early
+func (is *IndexerStore) GetUnbondingTransactionByStakingTxHash(stakingTxHash *chainhash.Hash) (*StoredUnbondingTransaction, error) {
+	var storedTx *StoredUnbondingTransaction
+	stakingTxHashBytes := stakingTxHash.CloneBytes()
 
-	err := is.db.View(func(tx kvdb.RTx) error {
+	err := is.view(func(tx kvdb.RTx) error {
 		stakingTxBucket := tx.ReadBucket(stakingTxBucketName)
 		if stakingTxBucket == nil {
 			return ErrCorruptedTransactionsDb
 		}
 
-		maybeTx := stakingTxBucket.Get(txHashBytes)
-		if maybeTx != nil {
-			existed = true
-			return nil
+		maybeStakingTx := stakingTxBucket.Get(stakingTxHashBytes)
+		if maybeStakingTx == nil {
+			return ErrTransactionNotFound
+		}
+
+		var stakingTxProto proto.StakingTransaction
+		if err := pm.Unmarshal(maybeStakingTx, &stakingTxProto); err != nil {
+			return ErrCorruptedTransactionsDb
 		}
 
 		unbondingTxBucket := tx.ReadBucket(unbondingTxBucketName)
@@ -379,10 +605,277 @@ func (is *IndexerStore) TxExists(txHash *chainhash.Hash) (bool, error) {
 			return ErrCorruptedTransactionsDb
 		}
 
-		maybeTx = unbondingTxBucket.Get(txHashBytes)
+		// the unbonding tx is the one moving the delegation to unbonding
+		var unbondingTxBytes []byte
+		for _, transition := range stakingTxProto.StateTransitions {
+			if DelegationState(transition.State) == StateUnbonding {
+				unbondingTxBytes = unbondingTxBucket.Get(transition.TxHash)
+			}
+		}
+
+		if unbondingTxBytes == nil {
+			return ErrTransactionNotFound
+		}
+
+		var storedTxProto proto.UnbondingTransaction
+		if err := pm.Unmarshal(unbondingTxBytes, &storedTxProto); err != nil {
+			return ErrCorruptedTransactionsDb
+		}
+
+		txFromDb, err := protoUnbondingTxToStoredUnbondingTx(&storedTxProto)
+		if err != nil {
+			return err
+		}
+
+		storedTx = txFromDb
+		return nil
+	}, func() {
+		storedTx = nil
+	})
+
+	if err != nil && !errors.Is(err, ErrTransactionNotFound) {
+		return nil, err
+	}
+
+	return storedTx, nil
+}
+
+// ScanStoredUnbondingTransactions iterates through all stored unbonding transactions
+func (is *IndexerStore) ScanStoredUnbondingTransactions(callback func(*StoredUnbondingTransaction) error) error {
+	return is.view(func(tx kvdb.RTx) error {
+		txBucket := tx.ReadBucket(unbondingTxBucketName)
+		if txBucket == nil {
+			return ErrCorruptedTransactionsDb
+		}
+
+		return txBucket.ForEach(func(k, v []byte) error {
+			var storedTxProto proto.UnbondingTransaction
+			if err := pm.Unmarshal(v, &storedTxProto); err != nil {
+				return fmt.Errorf("failed to parse unbonding transaction: %w", err)
+			}
+
+			storedTx, err := protoUnbondingTxToStoredUnbondingTx(&storedTxProto)
+			if err != nil {
+				return fmt.Errorf("failed to convert unbonding transaction: %w", err)
+			}
+
+			return callback(storedTx)
+		})
+	}, func() {})
+}
+
+// AddWithdrawalTransaction saves a withdrawal tx that spends the output of the
+// given staking tx, or of the given unbonding tx if it is not nil
+func (is *IndexerStore) AddWithdrawalTransaction(
+	tx *wire.MsgTx,
+	stakingTxHash *chainhash.Hash,
+	unbondingTxHash *chainhash.Hash,
+	inclusionHeight uint64,
+) error {
+	txHash := tx.TxHash()
+	serializedTx, err := utils.SerializeBtcTransaction(tx)
+	if err != nil {
+		return err
+	}
+
+	msg := proto.WithdrawalTransaction{
+		TransactionBytes: serializedTx,
+		StakingTxHash:    stakingTxHash.CloneBytes(),
+		InclusionHeight:  inclusionHeight,
+	}
+	if unbondingTxHash != nil {
+		msg.UnbondingTxHash = unbondingTxHash.CloneBytes()
+	}
+
+	return is.addWithdrawalTransaction(txHash[:], &msg)
+}
+
+func (is *IndexerStore) addWithdrawalTransaction(
+	txHashBytes []byte,
+	wt *proto.WithdrawalTransaction,
+) error {
+	return is.update(func(tx kvdb.RwTx) error {
+		stakingTxBucket := tx.ReadWriteBucket(stakingTxBucketName)
+		if stakingTxBucket == nil {
+			return ErrCorruptedTransactionsDb
+		}
+
+		// we need to ensure the staking tx already exists
+		if stakingTxBucket.Get(wt.StakingTxHash) == nil {
+			return ErrTransactionNotFound
+		}
+
+		if len(wt.UnbondingTxHash) != 0 {
+			unbondingTxBucket := tx.ReadWriteBucket(unbondingTxBucketName)
+			if unbondingTxBucket == nil {
+				return ErrCorruptedTransactionsDb
+			}
+			if unbondingTxBucket.Get(wt.UnbondingTxHash) == nil {
+				return ErrTransactionNotFound
+			}
+		}
+
+		withdrawalTxBucket := tx.ReadWriteBucket(withdrawalTxBucketName)
+		if withdrawalTxBucket == nil {
+			return ErrCorruptedTransactionsDb
+		}
+
+		// check duplicate
+		maybeTx := withdrawalTxBucket.Get(txHashBytes)
 		if maybeTx != nil {
-			existed = true
-			return nil
+			return ErrDuplicateTransaction
+		}
+
+		marshalled, err := pm.Marshal(wt)
+		if err != nil {
+			return err
+		}
+
+		if err := withdrawalTxBucket.Put(txHashBytes, marshalled); err != nil {
+			return err
+		}
+
+		byStakingTxBucket := tx.ReadWriteBucket(withdrawalTxByStakingTxBucketName)
+		if byStakingTxBucket == nil {
+			return ErrCorruptedTransactionsDb
+		}
+		if err := byStakingTxBucket.Put(wt.StakingTxHash, txHashBytes); err != nil {
+			return err
+		}
+
+		if err := is.appendJournalEntry(
+			tx, wt.InclusionHeight, WithdrawalTxType, txHashBytes,
+		); err != nil {
+			return err
+		}
+
+		// withdrawal txs do not change the confirmed tvl. The output of an
+		// unbonding tx was subtracted by the unbonding tx, while the stake of a
+		// withdrawn staking output stays counted as the timelock expiry never
+		// changes the confirmed tvl
+		return is.transitionState(
+			tx, wt.StakingTxHash, StateWithdrawn, wt.InclusionHeight, txHashBytes,
+		)
+	})
+}
+
+// GetWithdrawalTransaction retrieves the stored withdrawal transaction by the given hash
+// it returns (nil, nil) if the transaction is not found
+func (is *IndexerStore) GetWithdrawalTransaction(txHash *chainhash.Hash) (*StoredWithdrawalTransaction, error) {
+	var storedTx *StoredWithdrawalTransaction
+
+	err := is.view(func(tx kvdb.RTx) error {
+		txFromDb, err := getWithdrawalTransaction(tx, txHash.CloneBytes())
+		if err != nil {
+			return err
+		}
+
+		storedTx = txFromDb
+		return nil
+	}, func() {})
+
+	if err != nil && !errors.Is(err, ErrTransactionNotFound) {
+		return nil, err
+	}
+
+	return storedTx, nil
+}
+
+// GetWithdrawalTransactionByStakingTxHash retrieves the stored withdrawal
+// transaction of the delegation with the given staking tx hash
+// it returns (nil, nil) if the delegation has not been withdrawn
+func (is *IndexerStore) GetWithdrawalTransactionByStakingTxHash(stakingTxHash *chainhash.Hash) (*StoredWithdrawalTransaction, error) {
+	var storedTx *StoredWithdrawalTransaction
+
+	err := is.view(func(tx kvdb.RTx) error {
+		byStakingTxBucket := tx.ReadBucket(withdrawalTxByStakingTxBucketName)
+		if byStakingTxBucket == nil {
+			return ErrCorruptedTransactionsDb
+		}
+
+		withdrawalTxHash := byStakingTxBucket.Get(stakingTxHash.CloneBytes())
+		if withdrawalTxHash == nil {
+			return ErrTransactionNotFound
+		}
+
+		txFromDb, err := getWithdrawalTransaction(tx, withdrawalTxHash)
+		if err != nil {
+			return err
+		}
+
+		storedTx = txFromDb
+		return nil
+	}, func() {})
+
+	if err != nil && !errors.Is(err, ErrTransactionNotFound) {
+		return nil, err
+	}
+
+	return storedTx, nil
+}
+
+// ScanStoredWithdrawalTransactions iterates through all stored withdrawal transactions
+func (is *IndexerStore) ScanStoredWithdrawalTransactions(callback func(*StoredWithdrawalTransaction) error) error {
+	return is.view(func(tx kvdb.RTx) error {
+		txBucket := tx.ReadBucket(withdrawalTxBucketName)
+		if txBucket == nil {
+			return ErrCorruptedTransactionsDb
+		}
+
+		return txBucket.ForEach(func(k, v []byte) error {
+			var storedTxProto proto.WithdrawalTransaction
+			if err := pm.Unmarshal(v, &storedTxProto); err != nil {
+				return fmt.Errorf("failed to parse withdrawal transaction: %w", err)
+			}
+
+			storedTx, err := protoWithdrawalTxToStoredWithdrawalTx(&storedTxProto)
+			if err != nil {
+				return fmt.Errorf("failed to convert withdrawal transaction: %w", err)
+			}
+
+			return callback(storedTx)
+		})
+	}, func() {})
+}
+
+func getWithdrawalTransaction(tx kvdb.RTx, txHashBytes []byte) (*StoredWithdrawalTransaction, error) {
+	txBucket := tx.ReadBucket(withdrawalTxBucketName)
+	if txBucket == nil {
+		return nil, ErrCorruptedTransactionsDb
+	}
+
+	maybeTx := txBucket.Get(txHashBytes)
+	if maybeTx == nil {
+		return nil, ErrTransactionNotFound
+	}
+
+	var storedTxProto proto.WithdrawalTransaction
+	if err := pm.Unmarshal(maybeTx, &storedTxProto); err != nil {
+		return nil, ErrCorruptedTransactionsDb
+	}
+
+	return protoWithdrawalTxToStoredWithdrawalTx(&storedTxProto)
+}
+
+// TxExists returns whether the tx with the given hash is stored
+// in any of the transaction buckets
+func (is *IndexerStore) TxExists(txHash *chainhash.Hash) (bool, error) {
+	txHashBytes := txHash.CloneBytes()
+
+	existed := false
+
+	err := is.view(func(tx kvdb.RTx) error {
+		for _, bucketName := range txBucketNames {
+			txBucket := tx.ReadBucket(bucketName)
+			if txBucket == nil {
+				return ErrCorruptedTransactionsDb
+			}
+
+			maybeTx := txBucket.Get(txHashBytes)
+			if maybeTx != nil {
+				existed = true
+				return nil
+			}
 		}
 
 		return nil
@@ -408,8 +901,38 @@ func protoUnbondingTxToStoredUnbondingTx(protoTx *proto.UnbondingTransaction) (*
 	}
 
 	return &StoredUnbondingTransaction{
-		Tx:            &unbondingTx,
-		StakingTxHash: stakingTxHash,
+		Tx:              &unbondingTx,
+		StakingTxHash:   stakingTxHash,
+		InclusionHeight: protoTx.InclusionHeight,
+		UnbondingTime:   protoTx.UnbondingTime,
+	}, nil
+}
+
+func protoWithdrawalTxToStoredWithdrawalTx(protoTx *proto.WithdrawalTransaction) (*StoredWithdrawalTransaction, error) {
+	var withdrawalTx wire.MsgTx
+	err := withdrawalTx.Deserialize(bytes.NewReader(protoTx.TransactionBytes))
+	if err != nil {
+		return nil, fmt.Errorf("invalid withdrawal tx: %w", err)
+	}
+
+	stakingTxHash, err := chainhash.NewHash(protoTx.StakingTxHash)
+	if err != nil {
+		return nil, fmt.Errorf("invalid staking tx hash")
+	}
+
+	var unbondingTxHash *chainhash.Hash
+	if len(protoTx.UnbondingTxHash) != 0 {
+		unbondingTxHash, err = chainhash.NewHash(protoTx.UnbondingTxHash)
+		if err != nil {
+			return nil, fmt.Errorf("invalid unbonding tx hash")
+		}
+	}
+
+	return &StoredWithdrawalTransaction{
+		Tx:              &withdrawalTx,
+		StakingTxHash:   stakingTxHash,
+		UnbondingTxHash: unbondingTxHash,
+		InclusionHeight: protoTx.InclusionHeight,
 	}, nil
 }
 
@@ -477,7 +1000,7 @@ func (is *IndexerStore) GetConfirmedTvl() (uint64, error) {
 	key := getConfirmedTvlKey()
 
 	var confirmedTvl uint64
-	err := is.db.View(func(tx kvdb.RTx) error {
+	err := is.view(func(tx kvdb.RTx) error {
 		tvlBucket := tx.ReadBucket(confirmedTvlBucketName)
 		if tvlBucket == nil {
 			return ErrCorruptedStateDb
@@ -512,36 +1035,25 @@ func getLastProcessedHeightKey() []byte {
 }
 
 func (is *IndexerStore) SaveLastProcessedHeight(height uint64) error {
-	key := getLastProcessedHeightKey()
-	heightBytes := uint64ToBytes(height)
+	return is.update(func(tx kvdb.RwTx) error {
+		return putLastProcessedHeight(tx, height)
+	})
+}
 
-	return kvdb.Batch(is.db, func(tx kvdb.RwTx) error {
-		stateBucket := tx.ReadWriteBucket(indexerStateBucketName)
-		if stateBucket == nil {
-			return ErrCorruptedStateDb
-		}
+func putLastProcessedHeight(tx kvdb.RwTx, height uint64) error {
+	stateBucket := tx.ReadWriteBucket(indexerStateBucketName)
+	if stateBucket == nil {
+		return ErrCorruptedStateDb
+	}
 
-		return stateBucket.Put(key, heightBytes)
-	})
+	return stateBucket.Put(getLastProcessedHeightKey(), uint64ToBytes(height))
 }
 
 func (is *IndexerStore) GetLastProcessedHeight() (uint64, error) {
-	key := getLastProcessedHeightKey()
-
 	var lastProcessedHeight uint64
 
-	err := is.db.View(func(tx kvdb.RTx) error {
-		stateBucket := tx.ReadBucket(indexerStateBucketName)
-		if stateBucket == nil {
-			return ErrCorruptedStateDb
-		}
-
-		v := stateBucket.Get(key)
-		if v == nil {
-			return ErrLastProcessedHeightNotFound
-		}
-
-		height, err := uint64FromBytes(v)
+	err := is.view(func(tx kvdb.RTx) error {
+		height, err := getLastProcessedHeight(tx)
 		if err != nil {
 			return err
 		}
@@ -558,6 +1070,20 @@ func (is *IndexerStore) GetLastProcessedHeight() (uint64, error) {
 	return lastProcessedHeight, nil
 }
 
+func getLastProcessedHeight(tx kvdb.RTx) (uint64, error) {
+	stateBucket := tx.ReadBucket(indexerStateBucketName)
+	if stateBucket == nil {
+		return 0, ErrCorruptedStateDb
+	}
+
+	v := stateBucket.Get(getLastProcessedHeightKey())
+	if v == nil {
+		return 0, ErrLastProcessedHeightNotFound
+	}
+
+	return uint64FromBytes(v)
+}
+
 func uint64ToBytes(v uint64) []byte {
 	var buf [8]byte
 	binary.BigEndian.PutUint64(buf[:], v)
@@ -1,29 +1,393 @@
 package indexerstore_test
 
 import (
+	"bytes"
+	"encoding/binary"
+	"errors"
 	"math/rand"
 	"testing"
 	"time"
 
 	bbndatagen "github.com/babylonlabs-io/babylon/testutil/datagen"
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/lightningnetwork/lnd/kvdb"
 	"github.com/stretchr/testify/require"
+	pm "google.golang.org/protobuf/proto"
 
 	"github.com/babylonlabs-io/staking-indexer/indexerstore"
+	"github.com/babylonlabs-io/staking-indexer/proto"
 	"github.com/babylonlabs-io/staking-indexer/testutils"
 	"github.com/babylonlabs-io/staking-indexer/testutils/datagen"
 )
 
+// testStore is a store of any backend under test, boltDb is the database of
+// the bolt store and nil for the other backends
+type testStore struct {
+	indexerstore.Store
+	boltDb kvdb.Backend
+}
+
+// forEachStore runs the test against an empty store of each backend, so that
+// all the backends are checked to behave the same. The Postgres store is only
+// tested if the testutils.TestPostgresDSNEnv environment variable is set
+func forEachStore(t *testing.T, test func(t *testing.T, s *testStore)) {
+	t.Run("bolt", func(t *testing.T) {
+		db := testutils.MakeTestBackend(t)
+		s, err := indexerstore.NewIndexerStore(db)
+		require.NoError(t, err)
+		test(t, &testStore{Store: s, boltDb: db})
+	})
+	t.Run("postgres", func(t *testing.T) {
+		db := testutils.MakeTestPostgresDb(t)
+		s, err := indexerstore.NewPostgresStore(db)
+		require.NoError(t, err)
+		test(t, &testStore{Store: s})
+	})
+}
+
 func TestEmptyStore(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s *testStore) {
+		r := rand.New(rand.NewSource(time.Now().UnixNano()))
+		hash := bbndatagen.GenRandomBtcdHash(r)
+		stakingTx, err := s.GetStakingTransaction(&hash)
+		require.Nil(t, stakingTx)
+		require.NoError(t, err)
+		unbondingTx, err := s.GetUnbondingTransaction(&hash)
+		require.Nil(t, unbondingTx)
+		require.NoError(t, err)
+		_, err = s.GetLastProcessedHeight()
+		require.ErrorIs(t, err, indexerstore.ErrLastProcessedHeightNotFound)
+		tvl, err := s.GetConfirmedTvl()
+		require.NoError(t, err)
+		require.Zero(t, tvl)
+	})
+}
+
+func TestSchemaMigrations(t *testing.T) {
 	r := rand.New(rand.NewSource(time.Now().UnixNano()))
 	db := testutils.MakeTestBackend(t)
+
+	// all the migrations are pending for an empty database
+	version, err := indexerstore.GetSchemaVersion(db)
+	require.NoError(t, err)
+	require.Zero(t, version)
+	pending, err := indexerstore.PendingMigrations(db)
+	require.NoError(t, err)
+	require.Len(t, pending, int(indexerstore.LatestSchemaVersion()))
+	for i, m := range pending {
+		require.Equal(t, uint64(i+1), m.Version)
+		require.NotEmpty(t, m.Description)
+	}
+
+	// the store is migrated to the latest version when it is opened
 	s, err := indexerstore.NewIndexerStore(db)
 	require.NoError(t, err)
-	hash := bbndatagen.GenRandomBtcdHash(r)
-	stakingTx, err := s.GetStakingTransaction(&hash)
-	require.Nil(t, stakingTx)
+	version, err = indexerstore.GetSchemaVersion(db)
+	require.NoError(t, err)
+	require.Equal(t, indexerstore.LatestSchemaVersion(), version)
+	pending, err = indexerstore.PendingMigrations(db)
+	require.NoError(t, err)
+	require.Empty(t, pending)
+	applied, err := indexerstore.Migrate(db)
+	require.NoError(t, err)
+	require.Empty(t, applied)
+
+	// a database written before the schema version was stored, i.e., with
+	// only the staking txs, is migrated without losing its data
+	_, stakerPk, err := bbndatagen.GenRandomBTCKeyPair(r)
+	require.NoError(t, err)
+	_, fpPk, err := bbndatagen.GenRandomBTCKeyPair(r)
+	require.NoError(t, err)
+	stakingTxs := datagen.GenNStoredStakingTxs(t, r, r.Intn(10)+1, 200)
+	expectedStats := &indexerstore.FinalityProviderStats{FinalityProviderPk: fpPk}
+	for _, storedTx := range stakingTxs {
+		storedTx.StakerPk = stakerPk
+		storedTx.FinalityProviderPk = fpPk
+		putLegacyStakingTx(t, db, storedTx)
+		if storedTx.IsOverflow {
+			expectedStats.OverflowStake += storedTx.StakingValue
+		} else {
+			expectedStats.ActiveStake += storedTx.StakingValue
+			expectedStats.ActiveDelegations++
+		}
+	}
+	setLegacySchema(t, db)
+	pending, err = indexerstore.PendingMigrations(db)
+	require.NoError(t, err)
+	require.Len(t, pending, int(indexerstore.LatestSchemaVersion()))
+	applied, err = indexerstore.Migrate(db)
+	require.NoError(t, err)
+	require.Equal(t, pending, applied)
+	version, err = indexerstore.GetSchemaVersion(db)
+	require.NoError(t, err)
+	require.Equal(t, indexerstore.LatestSchemaVersion(), version)
+
+	for _, storedTx := range stakingTxs {
+		txHash := storedTx.Tx.TxHash()
+		migratedTx, err := s.GetStakingTransaction(&txHash)
+		require.NoError(t, err)
+		require.Equal(t, storedTx.Tx, migratedTx.Tx)
+	}
+	page, err := s.GetStakingTransactionsByStakerPk(stakerPk, nil, len(stakingTxs)+1)
+	require.NoError(t, err)
+	require.Len(t, page, len(stakingTxs))
+	var scannedHeights []uint64
+	err = s.ScanStakingTransactionsByHeightRange(
+		stakingTxs[0].InclusionHeight,
+		stakingTxs[len(stakingTxs)-1].InclusionHeight,
+		func(storedTx *indexerstore.StoredStakingTransaction) error {
+			scannedHeights = append(scannedHeights, storedTx.InclusionHeight)
+			return nil
+		})
+	require.NoError(t, err)
+	require.Len(t, scannedHeights, len(stakingTxs))
+	for i, storedTx := range stakingTxs {
+		require.Equal(t, storedTx.InclusionHeight, scannedHeights[i])
+	}
+	stats, err := s.GetFinalityProviderStats(fpPk)
+	require.NoError(t, err)
+	require.Equal(t, expectedStats, stats)
+
+	// a database written by a newer binary is refused
+	newerVersion := make([]byte, 8)
+	binary.BigEndian.PutUint64(newerVersion, indexerstore.LatestSchemaVersion()+1)
+	err = kvdb.Update(db, func(tx kvdb.RwTx) error {
+		return tx.ReadWriteBucket([]byte("indexerstate")).Put([]byte("schemaversion"), newerVersion)
+	}, func() {})
+	require.NoError(t, err)
+	_, err = indexerstore.NewIndexerStore(db)
+	require.ErrorIs(t, err, indexerstore.ErrSchemaTooNew)
+	_, err = indexerstore.PendingMigrations(db)
+	require.ErrorIs(t, err, indexerstore.ErrSchemaTooNew)
+	_, err = indexerstore.Migrate(db)
+	require.ErrorIs(t, err, indexerstore.ErrSchemaTooNew)
+}
+
+func TestMigrateDelegationStates(t *testing.T) {
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	db := testutils.MakeTestBackend(t)
+	_, err := indexerstore.NewIndexerStore(db)
+	require.NoError(t, err)
+
+	// the delegations to the same finality provider are stored without the
+	// lifecycle states, one is unbonding, one is withdrawn from the staking
+	// output, and one is active
+	_, fpPk, err := bbndatagen.GenRandomBTCKeyPair(r)
+	require.NoError(t, err)
+	stakingTxs := datagen.GenNStoredStakingTxs(t, r, 3, 200)
+	for _, storedTx := range stakingTxs {
+		storedTx.FinalityProviderPk = fpPk
+		putLegacyStakingTx(t, db, storedTx)
+	}
+	unbondingTx := datagen.GenStoredUnbondingTxs(r, stakingTxs[:1])[0]
+	withdrawnTxHash := stakingTxs[1].Tx.TxHash()
+	withdrawalTx := &indexerstore.StoredWithdrawalTransaction{
+		Tx:              datagen.GenRandomTx(r),
+		StakingTxHash:   &withdrawnTxHash,
+		InclusionHeight: stakingTxs[1].InclusionHeight + uint64(stakingTxs[1].StakingTime),
+	}
+	putLegacyUnbondingTx(t, db, unbondingTx)
+	putLegacyWithdrawalTx(t, db, withdrawalTx)
+	setLegacySchema(t, db)
+
+	// the states are recorded before the totals are computed
+	s, err := indexerstore.NewIndexerStore(db)
+	require.NoError(t, err)
+
+	unbondingTxHash := unbondingTx.Tx.TxHash()
+	withdrawalTxHash := withdrawalTx.Tx.TxHash()
+	expectedTransitions := [][]*indexerstore.StateTransition{
+		{
+			{State: indexerstore.StateActive, Height: stakingTxs[0].InclusionHeight},
+			{State: indexerstore.StateUnbonding, Height: unbondingTx.InclusionHeight, TxHash: &unbondingTxHash},
+		},
+		{
+			{State: indexerstore.StateActive, Height: stakingTxs[1].InclusionHeight},
+			{State: indexerstore.StateUnbonded, Height: withdrawalTx.InclusionHeight, TxHash: &withdrawnTxHash},
+			{State: indexerstore.StateWithdrawn, Height: withdrawalTx.InclusionHeight, TxHash: &withdrawalTxHash},
+		},
+		{
+			{State: indexerstore.StateActive, Height: stakingTxs[2].InclusionHeight},
+		},
+	}
+	for i, storedTx := range stakingTxs {
+		txHash := storedTx.Tx.TxHash()
+		expectedTransitions[i][0].TxHash = &txHash
+		migratedTx, err := s.GetStakingTransaction(&txHash)
+		require.NoError(t, err)
+		require.Equal(t, expectedTransitions[i], migratedTx.StateTransitions)
+		require.Equal(t, expectedTransitions[i][len(expectedTransitions[i])-1].State, migratedTx.State)
+	}
+
+	stats, err := s.GetFinalityProviderStats(fpPk)
+	require.NoError(t, err)
+	require.Equal(t, stakingTxs[2].StakingValue, stats.ActiveStake)
+	require.Equal(t, uint64(1), stats.ActiveDelegations)
+	require.Equal(t, uint64(2), stats.UnbondedDelegations)
+	require.Zero(t, stats.OverflowStake)
+}
+
+func TestMigrateTimelockExpiries(t *testing.T) {
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+	db := testutils.MakeTestBackend(t)
+	s, err := indexerstore.NewIndexerStore(db)
+	require.NoError(t, err)
+
+	// the timelock of the first staking output expires at the last
+	// processed height, the one of the second after it, and the third one
+	// is unbonding
+	stakingTxs := datagen.GenNStoredStakingTxs(t, r, 3, 200)
+	lastProcessedHeight := stakingTxs[0].InclusionHeight + uint64(stakingTxs[0].StakingTime)
+	stakingTxs[1].StakingTime = stakingTxs[0].StakingTime + uint32(r.Intn(100)+1)
+	for _, storedTx := range stakingTxs {
+		putLegacyStakingTx(t, db, storedTx)
+	}
+	unbondingTx := datagen.GenStoredUnbondingTxs(r, stakingTxs[2:])[0]
+	putLegacyUnbondingTx(t, db, unbondingTx)
+	err = s.SaveLastProcessedHeight(lastProcessedHeight)
+	require.NoError(t, err)
+	setLegacySchema(t, db)
+
+	s, err = indexerstore.NewIndexerStore(db)
+	require.NoError(t, err)
+
+	// the expiries above the last processed height are indexed
+	stakingTxHashes := make([]chainhash.Hash, len(stakingTxs))
+	for i, storedTx := range stakingTxs {
+		stakingTxHashes[i] = storedTx.Tx.TxHash()
+	}
+	unbondingTxHash := unbondingTx.Tx.TxHash()
+	stakingExpiryHeight := stakingTxs[1].InclusionHeight + uint64(stakingTxs[1].StakingTime)
+	expiries, err := s.GetTimelockExpiries(stakingExpiryHeight)
+	require.NoError(t, err)
+	require.Contains(t, expiries, &indexerstore.TimelockExpiry{StakingTxHash: &stakingTxHashes[1]})
+	storedTx, err := s.GetStakingTransaction(&stakingTxHashes[1])
+	require.NoError(t, err)
+	require.Equal(t, indexerstore.StateActive, storedTx.State)
+
+	// the expired timelock moves the delegation to unbonded at the expiry
+	// height instead of being indexed
+	expiryHeight := stakingTxs[0].InclusionHeight + uint64(stakingTxs[0].StakingTime)
+	for height := expiryHeight; height <= lastProcessedHeight+1; height++ {
+		expiries, err := s.GetTimelockExpiries(height)
+		require.NoError(t, err)
+		for _, expiry := range expiries {
+			require.False(t, expiry.StakingTxHash.IsEqual(&stakingTxHashes[0]))
+		}
+	}
+	storedTx, err = s.GetStakingTransaction(&stakingTxHashes[0])
+	require.NoError(t, err)
+	require.Equal(t, indexerstore.StateUnbonded, storedTx.State)
+	require.Len(t, storedTx.StateTransitions, 2)
+	require.Equal(t, &indexerstore.StateTransition{
+		State:  indexerstore.StateUnbonded,
+		Height: expiryHeight,
+		TxHash: &stakingTxHashes[0],
+	}, storedTx.StateTransitions[1])
+	fpStats, err := s.GetFinalityProviderStats(stakingTxs[0].FinalityProviderPk)
+	require.NoError(t, err)
+	require.Zero(t, fpStats.ActiveStake)
+	require.Zero(t, fpStats.OverflowStake)
+	require.Zero(t, fpStats.ActiveDelegations)
+	require.Equal(t, uint64(1), fpStats.UnbondedDelegations)
+
+	// the unbonding output is indexed if its timelock has not expired, and
+	// the expired staking output of the unbonding delegation is not indexed
+	unbondingExpiryHeight := unbondingTx.InclusionHeight + uint64(unbondingTx.UnbondingTime)
+	storedTx, err = s.GetStakingTransaction(&stakingTxHashes[2])
+	require.NoError(t, err)
+	if unbondingExpiryHeight > lastProcessedHeight {
+		expiries, err := s.GetTimelockExpiries(unbondingExpiryHeight)
+		require.NoError(t, err)
+		require.Contains(t, expiries, &indexerstore.TimelockExpiry{
+			StakingTxHash:   &stakingTxHashes[2],
+			UnbondingTxHash: &unbondingTxHash,
+		})
+		require.Equal(t, indexerstore.StateUnbonding, storedTx.State)
+	} else {
+		require.Equal(t, indexerstore.StateUnbonded, storedTx.State)
+		lastTransition := storedTx.StateTransitions[len(storedTx.StateTransitions)-1]
+		require.Equal(t, unbondingExpiryHeight, lastTransition.Height)
+		require.True(t, lastTransition.TxHash.IsEqual(&unbondingTxHash))
+	}
+	stakingExpiryHeight = stakingTxs[2].InclusionHeight + uint64(stakingTxs[2].StakingTime)
+	expiries, err = s.GetTimelockExpiries(stakingExpiryHeight)
+	require.NoError(t, err)
+	for _, expiry := range expiries {
+		if expiry.StakingTxHash.IsEqual(&stakingTxHashes[2]) {
+			require.True(t, expiry.IsFromUnbonding())
+		}
+	}
+}
+
+// putLegacyStakingTx writes the given staking tx only to the staking tx
+// bucket without its lifecycle state, as a database written before the
+// schema version was stored
+func putLegacyStakingTx(t *testing.T, db kvdb.Backend, storedTx *indexerstore.StoredStakingTransaction) {
+	var txBuf bytes.Buffer
+	require.NoError(t, storedTx.Tx.Serialize(&txBuf))
+	txHash := storedTx.Tx.TxHash()
+	putLegacyRecord(t, db, "stakingtxs", txHash[:], &proto.StakingTransaction{
+		TransactionBytes:   txBuf.Bytes(),
+		StakingOutputIdx:   storedTx.StakingOutputIdx,
+		InclusionHeight:    storedTx.InclusionHeight,
+		StakerPk:           schnorr.SerializePubKey(storedTx.StakerPk),
+		FinalityProviderPk: schnorr.SerializePubKey(storedTx.FinalityProviderPk),
+		StakingTime:        storedTx.StakingTime,
+		IsOverflow:         storedTx.IsOverflow,
+		StakingValue:       storedTx.StakingValue,
+	})
+}
+
+func putLegacyUnbondingTx(t *testing.T, db kvdb.Backend, storedTx *indexerstore.StoredUnbondingTransaction) {
+	var txBuf bytes.Buffer
+	require.NoError(t, storedTx.Tx.Serialize(&txBuf))
+	txHash := storedTx.Tx.TxHash()
+	putLegacyRecord(t, db, "unbondingtxs", txHash[:], &proto.UnbondingTransaction{
+		TransactionBytes: txBuf.Bytes(),
+		StakingTxHash:    storedTx.StakingTxHash.CloneBytes(),
+		InclusionHeight:  storedTx.InclusionHeight,
+		UnbondingTime:    storedTx.UnbondingTime,
+	})
+}
+
+func putLegacyWithdrawalTx(t *testing.T, db kvdb.Backend, storedTx *indexerstore.StoredWithdrawalTransaction) {
+	var txBuf bytes.Buffer
+	require.NoError(t, storedTx.Tx.Serialize(&txBuf))
+	txHash := storedTx.Tx.TxHash()
+	msg := &proto.WithdrawalTransaction{
+		TransactionBytes: txBuf.Bytes(),
+		StakingTxHash:    storedTx.StakingTxHash.CloneBytes(),
+		InclusionHeight:  storedTx.InclusionHeight,
+	}
+	if storedTx.UnbondingTxHash != nil {
+		msg.UnbondingTxHash = storedTx.UnbondingTxHash.CloneBytes()
+	}
+	putLegacyRecord(t, db, "withdrawaltxs", txHash[:], msg)
+}
+
+func putLegacyRecord(t *testing.T, db kvdb.Backend, bucket string, key []byte, msg pm.Message) {
+	marshalled, err := pm.Marshal(msg)
+	require.NoError(t, err)
+	err = kvdb.Update(db, func(tx kvdb.RwTx) error {
+		return tx.ReadWriteBucket([]byte(bucket)).Put(key, marshalled)
+	}, func() {})
 	require.NoError(t, err)
-	unbondingTx, err := s.GetUnbondingTransaction(&hash)
-	require.Nil(t, unbondingTx)
+}
+
+// setLegacySchema removes the schema version and the data computed by the
+// migrations, as a database written before the schema version was stored
+func setLegacySchema(t *testing.T, db kvdb.Backend) {
+	err := kvdb.Update(db, func(tx kvdb.RwTx) error {
+		for _, bucket := range []string{"stakingtxsbystaker", "stakingtxsbyheight", "finalityproviderstats", "timelockexpiries"} {
+			if err := tx.DeleteTopLevelBucket([]byte(bucket)); err != nil {
+				return err
+			}
+		}
+		return tx.ReadWriteBucket([]byte("indexerstate")).Delete([]byte("schemaversion"))
+	}, func() {})
 	require.NoError(t, err)
 }
 
@@ -31,17 +395,898 @@ func FuzzStoringTxs(f *testing.F) {
 	// only 3 seeds as this is pretty slow test opening/closing db
 	bbndatagen.AddRandomSeedsToFuzzer(f, 3)
 
+	f.Fuzz(func(t *testing.T, seed int64) {
+		forEachStore(t, func(t *testing.T, s *testStore) {
+			r := rand.New(rand.NewSource(seed))
+			maxCreatedTx := 30
+			numTx := r.Intn(maxCreatedTx) + 1
+			stakingtxs := datagen.GenNStoredStakingTxs(t, r, numTx, 200)
+
+			// add staking txs to store
+			for _, storedTx := range stakingtxs {
+				err := s.AddStakingTransaction(
+					storedTx.Tx,
+					storedTx.StakingOutputIdx,
+					storedTx.InclusionHeight,
+					storedTx.StakerPk,
+					storedTx.StakingTime,
+					storedTx.FinalityProviderPk,
+					storedTx.StakingValue,
+					storedTx.IsOverflow,
+				)
+				require.NoError(t, err)
+			}
+
+			// check staking txs from store
+			for _, storedTx := range stakingtxs {
+				hash := storedTx.Tx.TxHash()
+				tx, err := s.GetStakingTransaction(&hash)
+				require.NoError(t, err)
+				require.Equal(t, storedTx.Tx, tx.Tx)
+				require.True(t, testutils.PubKeysEqual(storedTx.StakerPk, tx.StakerPk))
+				require.Equal(t, storedTx.StakingTime, tx.StakingTime)
+				require.True(t, testutils.PubKeysEqual(storedTx.FinalityProviderPk, tx.FinalityProviderPk))
+			}
+
+			// the pages of the staking txs in a height range cover all of them
+			startHeight := uint64(r.Intn(200))
+			endHeight := startHeight + uint64(r.Intn(200))
+			limit := r.Intn(numTx) + 1
+			listed := make(map[chainhash.Hash]bool)
+			var afterTxHash *chainhash.Hash
+			for {
+				page, err := s.GetStakingTransactionsByHeightRange(startHeight, endHeight, afterTxHash, limit)
+				require.NoError(t, err)
+				require.LessOrEqual(t, len(page), limit)
+				for _, tx := range page {
+					require.GreaterOrEqual(t, tx.InclusionHeight, startHeight)
+					require.LessOrEqual(t, tx.InclusionHeight, endHeight)
+					listed[tx.Tx.TxHash()] = true
+				}
+				if len(page) < limit {
+					break
+				}
+				lastTxHash := page[len(page)-1].Tx.TxHash()
+				afterTxHash = &lastTxHash
+			}
+			for _, storedTx := range stakingtxs {
+				inRange := storedTx.InclusionHeight >= startHeight && storedTx.InclusionHeight <= endHeight
+				require.Equal(t, inRange, listed[storedTx.Tx.TxHash()])
+			}
+
+			// add unbonding txs to store
+			unbondingTxs := datagen.GenStoredUnbondingTxs(r, stakingtxs)
+			for _, storedTx := range unbondingTxs {
+				err := s.AddUnbondingTransaction(storedTx.Tx, storedTx.StakingTxHash, storedTx.InclusionHeight, storedTx.UnbondingTime)
+				require.NoError(t, err)
+			}
+
+			// check unbonding txs from store
+			for _, storedTx := range unbondingTxs {
+				hash := storedTx.Tx.TxHash()
+				tx, err := s.GetUnbondingTransaction(&hash)
+				require.NoError(t, err)
+				require.Equal(t, storedTx.Tx, tx.Tx)
+				require.True(t, storedTx.StakingTxHash.IsEqual(tx.StakingTxHash))
+				require.Equal(t, storedTx.InclusionHeight, tx.InclusionHeight)
+
+				txByStaking, err := s.GetUnbondingTransactionByStakingTxHash(storedTx.StakingTxHash)
+				require.NoError(t, err)
+				require.Equal(t, tx, txByStaking)
+			}
+
+			// add unbonding txs that do not spend previous staking tx
+			// should expect error
+			// add unbonding txs to store
+			notStoredStakingTxs := datagen.GenNStoredStakingTxs(t, r, numTx, 200)
+			wrongUnbondingTxs := datagen.GenStoredUnbondingTxs(r, notStoredStakingTxs)
+			for _, storedTx := range wrongUnbondingTxs {
+				err := s.AddUnbondingTransaction(storedTx.Tx, storedTx.StakingTxHash, storedTx.InclusionHeight, storedTx.UnbondingTime)
+				require.ErrorIs(t, err, indexerstore.ErrTransactionNotFound)
+			}
+
+			// add withdrawal txs to store, randomly spending the staking tx
+			// or the unbonding tx
+			withdrawalTxs := datagen.GenStoredWithdrawalTxs(r, unbondingTxs)
+			for _, storedTx := range withdrawalTxs {
+				// the timelock of the spent output must expire before withdrawal
+				spentTxHash := storedTx.StakingTxHash
+				if storedTx.IsFromUnbonding() {
+					spentTxHash = storedTx.UnbondingTxHash
+				}
+				err := s.TransitionDelegationState(storedTx.StakingTxHash, indexerstore.StateUnbonded, storedTx.InclusionHeight, spentTxHash)
+				require.NoError(t, err)
+			}
+			for _, storedTx := range withdrawalTxs {
+				err := s.AddWithdrawalTransaction(storedTx.Tx, storedTx.StakingTxHash, storedTx.UnbondingTxHash, storedTx.InclusionHeight)
+				require.NoError(t, err)
+				err = s.AddWithdrawalTransaction(storedTx.Tx, storedTx.StakingTxHash, storedTx.UnbondingTxHash, storedTx.InclusionHeight)
+				require.ErrorIs(t, err, indexerstore.ErrDuplicateTransaction)
+			}
+
+			// check withdrawal txs from store
+			for _, storedTx := range withdrawalTxs {
+				hash := storedTx.Tx.TxHash()
+				tx, err := s.GetWithdrawalTransaction(&hash)
+				require.NoError(t, err)
+				require.Equal(t, storedTx.Tx, tx.Tx)
+				require.True(t, storedTx.StakingTxHash.IsEqual(tx.StakingTxHash))
+				require.Equal(t, storedTx.IsFromUnbonding(), tx.IsFromUnbonding())
+				if storedTx.IsFromUnbonding() {
+					require.True(t, storedTx.UnbondingTxHash.IsEqual(tx.UnbondingTxHash))
+				}
+				require.Equal(t, storedTx.InclusionHeight, tx.InclusionHeight)
+
+				txByStaking, err := s.GetWithdrawalTransactionByStakingTxHash(storedTx.StakingTxHash)
+				require.NoError(t, err)
+				require.Equal(t, tx, txByStaking)
+
+				exists, err := s.TxExists(&hash)
+				require.NoError(t, err)
+				require.True(t, exists)
+			}
+
+			// delegations that are not withdrawn
+			for _, stakingTx := range notStoredStakingTxs {
+				hash := stakingTx.Tx.TxHash()
+				tx, err := s.GetWithdrawalTransactionByStakingTxHash(&hash)
+				require.NoError(t, err)
+				require.Nil(t, tx)
+			}
+		})
+	})
+}
+
+func FuzzStoringInvalidStakingTxs(f *testing.F) {
+	// only 3 seeds as this is pretty slow test opening/closing db
+	bbndatagen.AddRandomSeedsToFuzzer(f, 3)
+
+	f.Fuzz(func(t *testing.T, seed int64) {
+		forEachStore(t, func(t *testing.T, s *testStore) {
+			r := rand.New(rand.NewSource(seed))
+			numTx := r.Intn(30) + 1
+			stakingtxs := datagen.GenNStoredStakingTxs(t, r, numTx, 200)
+			reasons := make([]indexerstore.InvalidStakingReason, numTx)
+
+			// add invalid staking txs to store
+			for i, storedTx := range stakingtxs {
+				reasons[i] = indexerstore.InvalidStakingReason(r.Intn(4) + 1)
+				err := s.AddInvalidStakingTransaction(
+					storedTx.Tx,
+					storedTx.StakingOutputIdx,
+					storedTx.InclusionHeight,
+					storedTx.StakerPk,
+					storedTx.StakingTime,
+					storedTx.FinalityProviderPk,
+					storedTx.StakingValue,
+					reasons[i],
+				)
+				require.NoError(t, err)
+			}
+
+			// check invalid staking txs from store
+			for i, storedTx := range stakingtxs {
+				hash := storedTx.Tx.TxHash()
+				tx, err := s.GetInvalidStakingTransaction(&hash)
+				require.NoError(t, err)
+				require.Equal(t, storedTx.Tx, tx.Tx)
+				require.Equal(t, storedTx.InclusionHeight, tx.InclusionHeight)
+				require.True(t, testutils.PubKeysEqual(storedTx.StakerPk, tx.StakerPk))
+				require.Equal(t, storedTx.StakingTime, tx.StakingTime)
+				require.True(t, testutils.PubKeysEqual(storedTx.FinalityProviderPk, tx.FinalityProviderPk))
+				require.Equal(t, storedTx.StakingValue, tx.StakingValue)
+				require.Equal(t, reasons[i], tx.Reason)
+
+				exists, err := s.TxExists(&hash)
+				require.NoError(t, err)
+				require.True(t, exists)
+
+				// invalid staking txs are not valid staking txs
+				stakingTx, err := s.GetStakingTransaction(&hash)
+				require.NoError(t, err)
+				require.Nil(t, stakingTx)
+
+				err = s.AddInvalidStakingTransaction(
+					storedTx.Tx,
+					storedTx.StakingOutputIdx,
+					storedTx.InclusionHeight,
+					storedTx.StakerPk,
+					storedTx.StakingTime,
+					storedTx.FinalityProviderPk,
+					storedTx.StakingValue,
+					reasons[i],
+				)
+				require.ErrorIs(t, err, indexerstore.ErrDuplicateTransaction)
+			}
+
+			// invalid staking txs are not counted in the tvl
+			tvl, err := s.GetConfirmedTvl()
+			require.NoError(t, err)
+			require.Zero(t, tvl)
+		})
+	})
+}
+
+func FuzzDelegationLifecycle(f *testing.F) {
+	// only 3 seeds as this is pretty slow test opening/closing db
+	bbndatagen.AddRandomSeedsToFuzzer(f, 3)
+
+	f.Fuzz(func(t *testing.T, seed int64) {
+		forEachStore(t, func(t *testing.T, s *testStore) {
+			r := rand.New(rand.NewSource(seed))
+			storedTx := datagen.GenNStoredStakingTxs(t, r, 1, 200)[0]
+			stakingTxHash := storedTx.Tx.TxHash()
+			err := s.AddStakingTransaction(
+				storedTx.Tx,
+				storedTx.StakingOutputIdx,
+				storedTx.InclusionHeight,
+				storedTx.StakerPk,
+				storedTx.StakingTime,
+				storedTx.FinalityProviderPk,
+				storedTx.StakingValue,
+				storedTx.IsOverflow,
+			)
+			require.NoError(t, err)
+
+			initialState := indexerstore.StateActive
+			if storedTx.IsOverflow {
+				initialState = indexerstore.StateOverflow
+			}
+			tx, err := s.GetStakingTransaction(&stakingTxHash)
+			require.NoError(t, err)
+			require.Equal(t, initialState, tx.State)
+			require.Len(t, tx.StateTransitions, 1)
+			require.Equal(t, storedTx.InclusionHeight, tx.StateTransitions[0].Height)
+			require.True(t, stakingTxHash.IsEqual(tx.StateTransitions[0].TxHash))
+
+			// withdrawal before unbonding has matured is rejected
+			unbondingTx := datagen.GenStoredUnbondingTxs(r, []*indexerstore.StoredStakingTransaction{storedTx})[0]
+			err = s.AddUnbondingTransaction(unbondingTx.Tx, unbondingTx.StakingTxHash, unbondingTx.InclusionHeight, unbondingTx.UnbondingTime)
+			require.NoError(t, err)
+			withdrawalTxHash := bbndatagen.GenRandomBtcdHash(r)
+			err = s.CheckDelegationStateTransition(&stakingTxHash, indexerstore.StateWithdrawn, &withdrawalTxHash)
+			var transitionErr *indexerstore.InvalidStateTransitionError
+			require.ErrorAs(t, err, &transitionErr)
+			require.ErrorIs(t, err, indexerstore.ErrInvalidStateTransition)
+			require.Equal(t, indexerstore.StateUnbonding, transitionErr.From)
+			require.Equal(t, indexerstore.StateWithdrawn, transitionErr.To)
+			err = s.TransitionDelegationState(&stakingTxHash, indexerstore.StateWithdrawn, unbondingTx.InclusionHeight+1, &withdrawalTxHash)
+			require.ErrorAs(t, err, &transitionErr)
+
+			// the timelocks of both outputs are indexed by the expiry height
+			expiries, err := s.GetTimelockExpiries(storedTx.InclusionHeight + uint64(storedTx.StakingTime))
+			require.NoError(t, err)
+			require.Contains(t, expiries, &indexerstore.TimelockExpiry{StakingTxHash: &stakingTxHash})
+			expiries, err = s.GetTimelockExpiries(unbondingTx.InclusionHeight + uint64(unbondingTx.UnbondingTime))
+			require.NoError(t, err)
+			unbondingTxHash := unbondingTx.Tx.TxHash()
+			require.Contains(t, expiries, &indexerstore.TimelockExpiry{
+				StakingTxHash:   &stakingTxHash,
+				UnbondingTxHash: &unbondingTxHash,
+			})
+
+			// the recorded move is not rejected
+			err = s.CheckDelegationStateTransition(&stakingTxHash, indexerstore.StateUnbonding, &unbondingTxHash)
+			require.NoError(t, err)
+
+			// the timelock of the unbonding output expires
+			expiryHeight := unbondingTx.InclusionHeight + uint64(r.Intn(1000)) + 1
+			err = s.TransitionDelegationState(&stakingTxHash, indexerstore.StateUnbonded, expiryHeight, &unbondingTxHash)
+			require.NoError(t, err)
+			err = s.CheckDelegationStateTransition(&stakingTxHash, indexerstore.StateWithdrawn, &withdrawalTxHash)
+			require.NoError(t, err)
+
+			tx, err = s.GetStakingTransaction(&stakingTxHash)
+			require.NoError(t, err)
+			require.Equal(t, indexerstore.StateUnbonded, tx.State)
+			require.Len(t, tx.StateTransitions, 3)
+			require.Equal(t, indexerstore.StateUnbonding, tx.StateTransitions[1].State)
+			require.True(t, unbondingTxHash.IsEqual(tx.StateTransitions[1].TxHash))
+			require.Equal(t, expiryHeight, tx.StateTransitions[2].Height)
+
+			// rolling back the expiry block restores the unbonding state
+			err = s.SaveProcessedBlock(expiryHeight, &withdrawalTxHash)
+			require.NoError(t, err)
+			err = s.RollbackBlock(expiryHeight)
+			require.NoError(t, err)
+			tx, err = s.GetStakingTransaction(&stakingTxHash)
+			require.NoError(t, err)
+			require.Equal(t, indexerstore.StateUnbonding, tx.State)
+			require.Len(t, tx.StateTransitions, 2)
+		})
+	})
+}
+
+func FuzzStoringIndexerState(f *testing.F) {
+	// only 3 seeds as this is pretty slow test opening/closing db
+	bbndatagen.AddRandomSeedsToFuzzer(f, 3)
+
+	f.Fuzz(func(t *testing.T, seed int64) {
+		forEachStore(t, func(t *testing.T, s *testStore) {
+			r := rand.New(rand.NewSource(seed))
+
+			_, err := s.GetLastProcessedHeight()
+			require.ErrorIs(t, err, indexerstore.ErrLastProcessedHeightNotFound)
+
+			lastProcessedHeight := uint64(r.Int63n(1000) + 1)
+			err = s.SaveLastProcessedHeight(lastProcessedHeight)
+			require.NoError(t, err)
+
+			storedLastProcessedHeight, err := s.GetLastProcessedHeight()
+			require.NoError(t, err)
+			require.Equal(t, lastProcessedHeight, storedLastProcessedHeight)
+		})
+	})
+}
+
+func FuzzUpdateBlock(f *testing.F) {
+	// only 3 seeds as this is pretty slow test opening/closing db
+	bbndatagen.AddRandomSeedsToFuzzer(f, 3)
+
+	f.Fuzz(func(t *testing.T, seed int64) {
+		forEachStore(t, func(t *testing.T, s *testStore) {
+			r := rand.New(rand.NewSource(seed))
+			stakingTxs := datagen.GenNStoredStakingTxs(t, r, r.Intn(10)+2, 200)
+			height := uint64(r.Int63n(1000) + 1)
+			blockHash := bbndatagen.GenRandomBtcdHash(r)
+
+			addStakingTxs := func(bs indexerstore.Store) error {
+				for _, storedTx := range stakingTxs {
+					err := bs.AddStakingTransaction(
+						storedTx.Tx,
+						storedTx.StakingOutputIdx,
+						height,
+						storedTx.StakerPk,
+						storedTx.StakingTime,
+						storedTx.FinalityProviderPk,
+						storedTx.StakingValue,
+						storedTx.IsOverflow,
+					)
+					if err != nil {
+						return err
+					}
+
+					// the changes not committed yet are visible through the bound store
+					hash := storedTx.Tx.TxHash()
+					tx, err := bs.GetStakingTransaction(&hash)
+					require.NoError(t, err)
+					require.NotNil(t, tx)
+				}
+
+				return nil
+			}
+
+			// none of the changes is applied if the block fails in the middle
+			errBlock := errors.New("block failed")
+			err := s.UpdateBlock(height, &blockHash, func(bs indexerstore.Store) error {
+				if err := addStakingTxs(bs); err != nil {
+					return err
+				}
+				return errBlock
+			})
+			require.ErrorIs(t, err, errBlock)
+			for _, storedTx := range stakingTxs {
+				hash := storedTx.Tx.TxHash()
+				tx, err := s.GetStakingTransaction(&hash)
+				require.NoError(t, err)
+				require.Nil(t, tx)
+			}
+			tvl, err := s.GetConfirmedTvl()
+			require.NoError(t, err)
+			require.Zero(t, tvl)
+			_, err = s.GetLastProcessedHeight()
+			require.ErrorIs(t, err, indexerstore.ErrLastProcessedHeightNotFound)
+			_, err = s.GetBlockHash(height)
+			require.ErrorIs(t, err, indexerstore.ErrBlockJournalNotFound)
+
+			// all the changes are applied together with the last processed height
+			err = s.UpdateBlock(height, &blockHash, addStakingTxs)
+			require.NoError(t, err)
+			for _, storedTx := range stakingTxs {
+				hash := storedTx.Tx.TxHash()
+				tx, err := s.GetStakingTransaction(&hash)
+				require.NoError(t, err)
+				require.NotNil(t, tx)
+			}
+			lastProcessedHeight, err := s.GetLastProcessedHeight()
+			require.NoError(t, err)
+			require.Equal(t, height, lastProcessedHeight)
+			journal, err := s.GetBlockJournal(height)
+			require.NoError(t, err)
+			require.Len(t, journal, len(stakingTxs))
+			storedBlockHash, err := s.GetBlockHash(height)
+			require.NoError(t, err)
+			require.Equal(t, blockHash, *storedBlockHash)
+		})
+	})
+}
+
+func FuzzRollbackBlock(f *testing.F) {
+	// only 3 seeds as this is pretty slow test opening/closing db
+	bbndatagen.AddRandomSeedsToFuzzer(f, 3)
+
+	f.Fuzz(func(t *testing.T, seed int64) {
+		forEachStore(t, func(t *testing.T, s *testStore) {
+			r := rand.New(rand.NewSource(seed))
+			stakingTxs := datagen.GenNStoredStakingTxs(t, r, r.Intn(10)+1, 200)
+			stakingHeight := uint64(r.Int63n(1000) + 1)
+			spendingHeight := stakingHeight + uint64(r.Intn(10)) + 1
+			stakingBlockHash := bbndatagen.GenRandomBtcdHash(r)
+			spendingBlockHash := bbndatagen.GenRandomBtcdHash(r)
+
+			// the first block includes the staking txs
+			err := s.UpdateBlock(stakingHeight, &stakingBlockHash, func(bs indexerstore.Store) error {
+				for _, storedTx := range stakingTxs {
+					storedTx.IsOverflow = r.Intn(3) == 0
+					err := bs.AddStakingTransaction(
+						storedTx.Tx,
+						storedTx.StakingOutputIdx,
+						stakingHeight,
+						storedTx.StakerPk,
+						storedTx.StakingTime,
+						storedTx.FinalityProviderPk,
+						storedTx.StakingValue,
+						storedTx.IsOverflow,
+					)
+					if err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+			require.NoError(t, err)
+			stakingTvl, err := s.GetConfirmedTvl()
+			require.NoError(t, err)
+
+			// the second block unbonds or slashes some of the delegations,
+			// and includes an invalid staking tx
+			unbondingTxs := datagen.GenStoredUnbondingTxs(r, stakingTxs)
+			invalidTx := datagen.GenNStoredStakingTxs(t, r, 1, 200)[0]
+			slashingTxs := make(map[chainhash.Hash]*chainhash.Hash)
+			err = s.UpdateBlock(spendingHeight, &spendingBlockHash, func(bs indexerstore.Store) error {
+				for i, storedTx := range stakingTxs {
+					stakingTxHash := storedTx.Tx.TxHash()
+					switch r.Intn(3) {
+					case 0:
+						err := bs.AddUnbondingTransaction(unbondingTxs[i].Tx, &stakingTxHash, spendingHeight, unbondingTxs[i].UnbondingTime)
+						if err != nil {
+							return err
+						}
+					case 1:
+						slashingTx := datagen.GenRandomTx(r)
+						if err := bs.AddSlashingTransaction(slashingTx, &stakingTxHash, nil, spendingHeight); err != nil {
+							return err
+						}
+						slashingTxHash := slashingTx.TxHash()
+						slashingTxs[stakingTxHash] = &slashingTxHash
+					}
+				}
+				return bs.AddInvalidStakingTransaction(
+					invalidTx.Tx,
+					invalidTx.StakingOutputIdx,
+					spendingHeight,
+					invalidTx.StakerPk,
+					invalidTx.StakingTime,
+					invalidTx.FinalityProviderPk,
+					invalidTx.StakingValue,
+					indexerstore.StakingAmountTooLow,
+				)
+			})
+			require.NoError(t, err)
+			journal, err := s.GetBlockJournal(spendingHeight)
+			require.NoError(t, err)
+			for _, entry := range journal {
+				// the entries of the spending txs refer to the staking txs they spend
+				if entry.TxType == indexerstore.UnbondingTxType || entry.TxType == indexerstore.SlashingTxType {
+					stakingTx, err := s.GetStakingTransaction(entry.StakingTxHash)
+					require.NoError(t, err)
+					require.NotNil(t, stakingTx)
+				}
+			}
+
+			// only the last processed block can be rolled back
+			err = s.RollbackBlock(stakingHeight)
+			require.ErrorIs(t, err, indexerstore.ErrInvalidRollbackHeight)
+
+			// rolling back the second block restores the state after the first one
+			err = s.RollbackBlock(spendingHeight)
+			require.NoError(t, err)
+			lastProcessedHeight, err := s.GetLastProcessedHeight()
+			require.NoError(t, err)
+			require.Equal(t, spendingHeight-1, lastProcessedHeight)
+			_, err = s.GetBlockJournal(spendingHeight)
+			require.ErrorIs(t, err, indexerstore.ErrBlockJournalNotFound)
+			tvl, err := s.GetConfirmedTvl()
+			require.NoError(t, err)
+			require.Equal(t, stakingTvl, tvl)
+			for i, storedTx := range stakingTxs {
+				stakingTxHash := storedTx.Tx.TxHash()
+				tx, err := s.GetStakingTransaction(&stakingTxHash)
+				require.NoError(t, err)
+				require.Len(t, tx.StateTransitions, 1)
+				require.Equal(t, tx.StateTransitions[0].State, tx.State)
+
+				unbondingTxHash := unbondingTxs[i].Tx.TxHash()
+				exists, err := s.TxExists(&unbondingTxHash)
+				require.NoError(t, err)
+				require.False(t, exists)
+				unbondingTx, err := s.GetUnbondingTransactionByStakingTxHash(&stakingTxHash)
+				require.NoError(t, err)
+				require.Nil(t, unbondingTx)
+				if slashingTxHash, ok := slashingTxs[stakingTxHash]; ok {
+					slashingTx, err := s.GetSlashingTransaction(slashingTxHash)
+					require.NoError(t, err)
+					require.Nil(t, slashingTx)
+				}
+			}
+			invalidTxHash := invalidTx.Tx.TxHash()
+			exists, err := s.TxExists(&invalidTxHash)
+			require.NoError(t, err)
+			require.False(t, exists)
+
+			// rolling back the first block removes the staking txs
+			err = s.SaveLastProcessedHeight(stakingHeight)
+			require.NoError(t, err)
+			err = s.RollbackBlock(stakingHeight)
+			require.NoError(t, err)
+			tvl, err = s.GetConfirmedTvl()
+			require.NoError(t, err)
+			require.Zero(t, tvl)
+			for _, storedTx := range stakingTxs {
+				stakingTxHash := storedTx.Tx.TxHash()
+				tx, err := s.GetStakingTransaction(&stakingTxHash)
+				require.NoError(t, err)
+				require.Nil(t, tx)
+				expiries, err := s.GetTimelockExpiries(stakingHeight + uint64(storedTx.StakingTime))
+				require.NoError(t, err)
+				require.Empty(t, expiries)
+			}
+			_, err = s.GetBlockHash(stakingHeight)
+			require.ErrorIs(t, err, indexerstore.ErrBlockJournalNotFound)
+		})
+	})
+}
+
+func FuzzOutbox(f *testing.F) {
+	// only 3 seeds as this is pretty slow test opening/closing db
+	bbndatagen.AddRandomSeedsToFuzzer(f, 3)
+
+	f.Fuzz(func(t *testing.T, seed int64) {
+		forEachStore(t, func(t *testing.T, s *testStore) {
+			r := rand.New(rand.NewSource(seed))
+
+			entries, err := s.GetUndeliveredOutboxEntries(10)
+			require.NoError(t, err)
+			require.Empty(t, entries)
+			stats, err := s.GetOutboxStats()
+			require.NoError(t, err)
+			require.Zero(t, stats.NumUndelivered)
+			require.True(t, stats.OldestUndeliveredAt.IsZero())
+
+			// the entries written by a failed update are discarded
+			err = s.Update(func(bs indexerstore.Store) error {
+				if err := bs.AddOutboxEntry(1, bbndatagen.GenRandomByteArray(r, 32), 1, nil); err != nil {
+					return err
+				}
+				return errors.New("failure")
+			})
+			require.Error(t, err)
+			entries, err = s.GetUndeliveredOutboxEntries(10)
+			require.NoError(t, err)
+			require.Empty(t, entries)
+
+			numEntries := r.Intn(20) + 1
+			payloads := make([][]byte, numEntries)
+			blockHashes := make([]*chainhash.Hash, numEntries)
+			for i := range payloads {
+				payloads[i] = bbndatagen.GenRandomByteArray(r, 32)
+				// the block of some events is unknown
+				if r.Intn(4) != 0 {
+					blockHash := bbndatagen.GenRandomBtcdHash(r)
+					blockHashes[i] = &blockHash
+				}
+				err := s.AddOutboxEntry(uint32(i), payloads[i], uint64(i), blockHashes[i])
+				require.NoError(t, err)
+			}
+
+			// the entries are returned in the order they were added
+			entries, err = s.GetUndeliveredOutboxEntries(numEntries + 1)
+			require.NoError(t, err)
+			require.Len(t, entries, numEntries)
+			stats, err = s.GetOutboxStats()
+			require.NoError(t, err)
+			require.Equal(t, uint64(numEntries), stats.NumUndelivered)
+			require.Equal(t, entries[0].CreatedAt, stats.OldestUndeliveredAt)
+			for i, entry := range entries {
+				require.False(t, entry.CreatedAt.IsZero())
+				require.Equal(t, uint32(i), entry.EventType)
+				require.Equal(t, payloads[i], entry.Payload)
+				require.False(t, entry.Delivered)
+				require.Equal(t, uint64(i), entry.BlockHeight)
+				require.Equal(t, blockHashes[i], entry.BlockHash)
+			}
+
+			// only the undelivered entries are returned
+			numDelivered := r.Intn(numEntries + 1)
+			for _, entry := range entries[:numDelivered] {
+				err := s.MarkOutboxEntryDelivered(entry.Sequence)
+				require.NoError(t, err)
+			}
+			undelivered, err := s.GetUndeliveredOutboxEntries(numEntries)
+			require.NoError(t, err)
+			require.Len(t, undelivered, numEntries-numDelivered)
+			for i, entry := range undelivered {
+				require.Equal(t, entries[numDelivered+i], entry)
+			}
+			stats, err = s.GetOutboxStats()
+			require.NoError(t, err)
+			require.Equal(t, uint64(numEntries-numDelivered), stats.NumUndelivered)
+			if numDelivered == numEntries {
+				require.True(t, stats.OldestUndeliveredAt.IsZero())
+			} else {
+				require.Equal(t, entries[numDelivered].CreatedAt, stats.OldestUndeliveredAt)
+			}
+
+			limit := r.Intn(numEntries) + 1
+			undelivered, err = s.GetUndeliveredOutboxEntries(limit)
+			require.NoError(t, err)
+			require.Len(t, undelivered, min(limit, numEntries-numDelivered))
+
+			// the entries from a sequence are returned whether they are
+			// delivered or not
+			from := r.Intn(numEntries)
+			fromEntries, err := s.GetOutboxEntries(entries[from].Sequence, limit)
+			require.NoError(t, err)
+			require.Len(t, fromEntries, min(limit, numEntries-from))
+			for i, entry := range fromEntries {
+				require.Equal(t, entries[from+i].Sequence, entry.Sequence)
+				require.Equal(t, from+i < numDelivered, entry.Delivered)
+			}
+
+			err = s.MarkOutboxEntryDelivered(entries[numEntries-1].Sequence + 1)
+			require.ErrorIs(t, err, indexerstore.ErrOutboxEntryNotFound)
+
+			// only the delivered entries of the blocks below the height are
+			// pruned, the entry at height i is the i-th one
+			belowHeight := r.Intn(numEntries + 1)
+			numPruned, err := s.PruneOutboxEntries(uint64(belowHeight))
+			require.NoError(t, err)
+			expectedPruned := min(belowHeight, numDelivered)
+			require.Equal(t, uint64(expectedPruned), numPruned)
+			kept, err := s.GetOutboxEntries(0, numEntries)
+			require.NoError(t, err)
+			require.Len(t, kept, numEntries-expectedPruned)
+			for i, entry := range kept {
+				require.Equal(t, entries[expectedPruned+i].Sequence, entry.Sequence)
+			}
+			stats, err = s.GetOutboxStats()
+			require.NoError(t, err)
+			require.Equal(t, uint64(numEntries-numDelivered), stats.NumUndelivered)
+
+			numPruned, err = s.PruneOutboxEntries(uint64(belowHeight))
+			require.NoError(t, err)
+			require.Zero(t, numPruned)
+
+			// the sequence from a height is the one of the first kept entry
+			// at or above it, or the next one above all the entries
+			if expectedPruned < numEntries {
+				height := expectedPruned + r.Intn(numEntries-expectedPruned)
+				seq, err := s.GetOutboxSequenceFromHeight(uint64(height))
+				require.NoError(t, err)
+				require.Equal(t, entries[height].Sequence, seq)
+			}
+			seq, err := s.GetOutboxSequenceFromHeight(uint64(numEntries))
+			require.NoError(t, err)
+			require.Equal(t, entries[numEntries-1].Sequence+1, seq)
+		})
+	})
+}
+
+func FuzzStakerIndex(f *testing.F) {
+	// only 3 seeds as this is pretty slow test opening/closing db
+	bbndatagen.AddRandomSeedsToFuzzer(f, 3)
+
+	f.Fuzz(func(t *testing.T, seed int64) {
+		forEachStore(t, func(t *testing.T, s *testStore) {
+			r := rand.New(rand.NewSource(seed))
+
+			// the staking txs are shared among a few stakers
+			_, stakerPks, err := bbndatagen.GenRandomBTCKeyPairs(r, r.Intn(3)+1)
+			require.NoError(t, err)
+			stakingTxs := datagen.GenNStoredStakingTxs(t, r, r.Intn(20)+1, 200)
+			txsByStaker := make(map[*btcec.PublicKey][]*indexerstore.StoredStakingTransaction)
+			for _, storedTx := range stakingTxs {
+				storedTx.StakerPk = stakerPks[r.Intn(len(stakerPks))]
+				txsByStaker[storedTx.StakerPk] = append(txsByStaker[storedTx.StakerPk], storedTx)
+				err := s.AddStakingTransaction(
+					storedTx.Tx,
+					storedTx.StakingOutputIdx,
+					storedTx.InclusionHeight,
+					storedTx.StakerPk,
+					storedTx.StakingTime,
+					storedTx.FinalityProviderPk,
+					storedTx.StakingValue,
+					storedTx.IsOverflow,
+				)
+				require.NoError(t, err)
+			}
+
+			checkStakerTxs := func(s indexerstore.Store, stakerPk *btcec.PublicKey, expected []*indexerstore.StoredStakingTransaction) {
+				limit := r.Intn(5) + 1
+				listed := make(map[chainhash.Hash]bool)
+				var afterTxHash *chainhash.Hash
+				for {
+					page, err := s.GetStakingTransactionsByStakerPk(stakerPk, afterTxHash, limit)
+					require.NoError(t, err)
+					require.LessOrEqual(t, len(page), limit)
+					for _, tx := range page {
+						require.True(t, testutils.PubKeysEqual(stakerPk, tx.StakerPk))
+						require.False(t, listed[tx.Tx.TxHash()])
+						listed[tx.Tx.TxHash()] = true
+					}
+					if len(page) < limit {
+						break
+					}
+					lastTxHash := page[len(page)-1].Tx.TxHash()
+					afterTxHash = &lastTxHash
+				}
+				require.Len(t, listed, len(expected))
+				for _, storedTx := range expected {
+					require.True(t, listed[storedTx.Tx.TxHash()])
+				}
+			}
+
+			for _, stakerPk := range stakerPks {
+				checkStakerTxs(s, stakerPk, txsByStaker[stakerPk])
+			}
+
+			// the index of a bolt database created before it is backfilled when the
+			// store is opened
+			if s.boltDb != nil {
+				err = kvdb.Update(s.boltDb, func(tx kvdb.RwTx) error {
+					if err := tx.DeleteTopLevelBucket([]byte("stakingtxsbystaker")); err != nil {
+						return err
+					}
+					return tx.ReadWriteBucket([]byte("indexerstate")).Delete([]byte("schemaversion"))
+				}, func() {})
+				require.NoError(t, err)
+				bs, err := indexerstore.NewIndexerStore(s.boltDb)
+				require.NoError(t, err)
+				s = &testStore{Store: bs, boltDb: s.boltDb}
+				for _, stakerPk := range stakerPks {
+					checkStakerTxs(s, stakerPk, txsByStaker[stakerPk])
+				}
+			}
+
+			// rolling back the block of the last staking tx removes it from the index
+			lastTx := stakingTxs[len(stakingTxs)-1]
+			blockHash := bbndatagen.GenRandomBtcdHash(r)
+			err = s.SaveProcessedBlock(lastTx.InclusionHeight, &blockHash)
+			require.NoError(t, err)
+			err = s.RollbackBlock(lastTx.InclusionHeight)
+			require.NoError(t, err)
+			stakerTxs := txsByStaker[lastTx.StakerPk]
+			checkStakerTxs(s, lastTx.StakerPk, stakerTxs[:len(stakerTxs)-1])
+		})
+	})
+}
+
+func FuzzHeightIndex(f *testing.F) {
+	// only 3 seeds as this is pretty slow test opening/closing db
+	bbndatagen.AddRandomSeedsToFuzzer(f, 3)
+
+	f.Fuzz(func(t *testing.T, seed int64) {
+		forEachStore(t, func(t *testing.T, s *testStore) {
+			r := rand.New(rand.NewSource(seed))
+
+			// a few staking txs are included at the same height
+			stakingTxs := datagen.GenNStoredStakingTxs(t, r, r.Intn(20)+1, 200)
+			for i, storedTx := range stakingTxs {
+				if i > 0 && r.Intn(2) == 0 {
+					storedTx.InclusionHeight = stakingTxs[i-1].InclusionHeight
+				}
+				err := s.AddStakingTransaction(
+					storedTx.Tx,
+					storedTx.StakingOutputIdx,
+					storedTx.InclusionHeight,
+					storedTx.StakerPk,
+					storedTx.StakingTime,
+					storedTx.FinalityProviderPk,
+					storedTx.StakingValue,
+					storedTx.IsOverflow,
+				)
+				require.NoError(t, err)
+			}
+
+			checkRange := func(s indexerstore.Store, startHeight, endHeight uint64, expected []*indexerstore.StoredStakingTransaction) {
+				var scanned []*indexerstore.StoredStakingTransaction
+				err := s.ScanStakingTransactionsByHeightRange(startHeight, endHeight, func(tx *indexerstore.StoredStakingTransaction) error {
+					scanned = append(scanned, tx)
+					return nil
+				})
+				require.NoError(t, err)
+
+				expectedHashes := make(map[chainhash.Hash]bool)
+				for _, storedTx := range expected {
+					if storedTx.InclusionHeight >= startHeight && storedTx.InclusionHeight <= endHeight {
+						expectedHashes[storedTx.Tx.TxHash()] = true
+					}
+				}
+				require.Len(t, scanned, len(expectedHashes))
+				for i, tx := range scanned {
+					require.True(t, expectedHashes[tx.Tx.TxHash()])
+					// ordered by height, and by hash at the same height
+					if i > 0 {
+						prev := scanned[i-1]
+						prevHash, hash := prev.Tx.TxHash(), tx.Tx.TxHash()
+						require.True(t, prev.InclusionHeight < tx.InclusionHeight ||
+							(prev.InclusionHeight == tx.InclusionHeight && bytes.Compare(prevHash[:], hash[:]) < 0))
+					}
+				}
+			}
+
+			firstHeight := stakingTxs[0].InclusionHeight
+			lastHeight := stakingTxs[len(stakingTxs)-1].InclusionHeight
+			checkRange(s, 0, lastHeight, stakingTxs)
+			startHeight := firstHeight + uint64(r.Intn(int(lastHeight-firstHeight)+1))
+			endHeight := startHeight + uint64(r.Intn(int(lastHeight-startHeight)+1))
+			checkRange(s, startHeight, endHeight, stakingTxs)
+			checkRange(s, lastHeight+1, lastHeight+1, stakingTxs)
+
+			// paginating from a tx that is not stored fails
+			unknownTxHash := bbndatagen.GenRandomBtcdHash(r)
+			_, err := s.GetStakingTransactionsByHeightRange(0, lastHeight, &unknownTxHash, 1)
+			require.ErrorIs(t, err, indexerstore.ErrTransactionNotFound)
+
+			// the index of a bolt database created before it is backfilled when the
+			// store is opened
+			if s.boltDb != nil {
+				err = kvdb.Update(s.boltDb, func(tx kvdb.RwTx) error {
+					if err := tx.DeleteTopLevelBucket([]byte("stakingtxsbyheight")); err != nil {
+						return err
+					}
+					return tx.ReadWriteBucket([]byte("indexerstate")).Delete([]byte("schemaversion"))
+				}, func() {})
+				require.NoError(t, err)
+				bs, err := indexerstore.NewIndexerStore(s.boltDb)
+				require.NoError(t, err)
+				s = &testStore{Store: bs, boltDb: s.boltDb}
+				checkRange(s, 0, lastHeight, stakingTxs)
+			}
+
+			// rolling back the last block removes its staking txs from the index
+			blockHash := bbndatagen.GenRandomBtcdHash(r)
+			err = s.SaveProcessedBlock(lastHeight, &blockHash)
+			require.NoError(t, err)
+			err = s.RollbackBlock(lastHeight)
+			require.NoError(t, err)
+			var remaining []*indexerstore.StoredStakingTransaction
+			for _, storedTx := range stakingTxs {
+				if storedTx.InclusionHeight < lastHeight {
+					remaining = append(remaining, storedTx)
+				}
+			}
+			checkRange(s, 0, lastHeight, remaining)
+		})
+	})
+}
+
+func FuzzVerifyDb(f *testing.F) {
+	// only 3 seeds as this is pretty slow test opening/closing db
+	bbndatagen.AddRandomSeedsToFuzzer(f, 3)
+
 	f.Fuzz(func(t *testing.T, seed int64) {
 		r := rand.New(rand.NewSource(seed))
 		db := testutils.MakeTestBackend(t)
 		s, err := indexerstore.NewIndexerStore(db)
 		require.NoError(t, err)
-		maxCreatedTx := 30
-		numTx := r.Intn(maxCreatedTx) + 1
-		stakingtxs := datagen.GenNStoredStakingTxs(t, r, numTx, 200)
 
-		// add staking txs to store
-		for _, storedTx := range stakingtxs {
+		// some of the delegations are unbonded, and some of the others are
+		// slashed
+		stakingTxs := datagen.GenNStoredStakingTxs(t, r, r.Intn(20)+1, 200)
+		lastHeight := uint64(0)
+		for _, storedTx := range stakingTxs {
+			storedTx.IsOverflow = r.Intn(3) == 0
 			err := s.AddStakingTransaction(
 				storedTx.Tx,
 				storedTx.StakingOutputIdx,
@@ -53,48 +1298,83 @@ func FuzzStoringTxs(f *testing.F) {
 				storedTx.IsOverflow,
 			)
 			require.NoError(t, err)
+			lastHeight = max(lastHeight, storedTx.InclusionHeight)
 		}
-
-		// check staking txs from store
-		for _, storedTx := range stakingtxs {
-			hash := storedTx.Tx.TxHash()
-			tx, err := s.GetStakingTransaction(&hash)
-			require.NoError(t, err)
-			require.Equal(t, storedTx.Tx, tx.Tx)
-			require.True(t, testutils.PubKeysEqual(storedTx.StakerPk, tx.StakerPk))
-			require.Equal(t, storedTx.StakingTime, tx.StakingTime)
-			require.True(t, testutils.PubKeysEqual(storedTx.FinalityProviderPk, tx.FinalityProviderPk))
+		var unbondedStakingTxs []*indexerstore.StoredStakingTransaction
+		for _, storedTx := range stakingTxs {
+			stakingTxHash := storedTx.Tx.TxHash()
+			switch r.Intn(3) {
+			case 0:
+				unbondingTx := datagen.GenStoredUnbondingTxs(r, []*indexerstore.StoredStakingTransaction{storedTx})[0]
+				err := s.AddUnbondingTransaction(unbondingTx.Tx, &stakingTxHash, unbondingTx.InclusionHeight, unbondingTx.UnbondingTime)
+				require.NoError(t, err)
+				unbondedStakingTxs = append(unbondedStakingTxs, storedTx)
+				lastHeight = max(lastHeight, unbondingTx.InclusionHeight)
+			case 1:
+				slashingHeight := storedTx.InclusionHeight + 1
+				err := s.AddSlashingTransaction(datagen.GenRandomTx(r), &stakingTxHash, nil, slashingHeight)
+				require.NoError(t, err)
+				lastHeight = max(lastHeight, slashingHeight)
+			}
 		}
+		err = s.SaveLastProcessedHeight(lastHeight)
+		require.NoError(t, err)
 
-		// add unbonding txs to store
-		unbondingTxs := datagen.GenStoredUnbondingTxs(r, stakingtxs)
-		for _, storedTx := range unbondingTxs {
-			err := s.AddUnbondingTransaction(storedTx.Tx, storedTx.StakingTxHash)
-			require.NoError(t, err)
-		}
+		// the stored data is consistent
+		report, err := indexerstore.VerifyDb(db)
+		require.NoError(t, err)
+		require.True(t, report.OK(), report.Issues)
+		confirmedTvl, err := s.GetConfirmedTvl()
+		require.NoError(t, err)
+		require.Equal(t, confirmedTvl, report.StoredConfirmedTvl)
+		require.Equal(t, confirmedTvl, report.ComputedConfirmedTvl)
+		require.Equal(t, uint64(len(stakingTxs)), report.NumStakingTxs)
+		require.Equal(t, uint64(len(unbondedStakingTxs)), report.NumUnbondingTxs)
+		require.Equal(t, lastHeight, *report.LastProcessedHeight)
 
-		// check unbonding txs from store
-		for _, storedTx := range unbondingTxs {
-			hash := storedTx.Tx.TxHash()
-			tx, err := s.GetUnbondingTransaction(&hash)
-			require.NoError(t, err)
-			require.Equal(t, storedTx.Tx, tx.Tx)
-			require.True(t, storedTx.StakingTxHash.IsEqual(tx.StakingTxHash))
+		hasIssue := func(report *indexerstore.VerifyReport, check string) bool {
+			for _, issue := range report.Issues {
+				if issue.Check == check {
+					return true
+				}
+			}
+			return false
 		}
 
-		// add unbonding txs that do not spend previous staking tx
-		// should expect error
-		// add unbonding txs to store
-		notStoredStakingTxs := datagen.GenNStoredStakingTxs(t, r, numTx, 200)
-		wrongUnbondingTxs := datagen.GenStoredUnbondingTxs(r, notStoredStakingTxs)
-		for _, storedTx := range wrongUnbondingTxs {
-			err := s.AddUnbondingTransaction(storedTx.Tx, storedTx.StakingTxHash)
-			require.ErrorIs(t, err, indexerstore.ErrTransactionNotFound)
+		// a wrong confirmed tvl is reported
+		err = kvdb.Update(db, func(tx kvdb.RwTx) error {
+			wrongTvl := make([]byte, 8)
+			binary.BigEndian.PutUint64(wrongTvl, confirmedTvl+1)
+			return tx.ReadWriteBucket([]byte("confirmedtvl")).Put([]byte("confirmedtvl"), wrongTvl)
+		}, func() {})
+		require.NoError(t, err)
+		report, err = indexerstore.VerifyDb(db)
+		require.NoError(t, err)
+		require.True(t, hasIssue(report, indexerstore.CheckConfirmedTvl))
+
+		// the txs stored above the last processed height are reported
+		err = s.SaveLastProcessedHeight(lastHeight - 1)
+		require.NoError(t, err)
+		report, err = indexerstore.VerifyDb(db)
+		require.NoError(t, err)
+		require.True(t, hasIssue(report, indexerstore.CheckInclusionHeight))
+
+		// an unbonding tx of a staking tx that is not stored is reported
+		if len(unbondedStakingTxs) == 0 {
+			return
 		}
+		err = kvdb.Update(db, func(tx kvdb.RwTx) error {
+			stakingTxHash := unbondedStakingTxs[0].Tx.TxHash()
+			return tx.ReadWriteBucket([]byte("stakingtxs")).Delete(stakingTxHash[:])
+		}, func() {})
+		require.NoError(t, err)
+		report, err = indexerstore.VerifyDb(db)
+		require.NoError(t, err)
+		require.True(t, hasIssue(report, indexerstore.CheckStakingTxReference))
 	})
 }
 
-func FuzzStoringIndexerState(f *testing.F) {
+func FuzzSnapshot(f *testing.F) {
 	// only 3 seeds as this is pretty slow test opening/closing db
 	bbndatagen.AddRandomSeedsToFuzzer(f, 3)
 
@@ -104,15 +1384,237 @@ func FuzzStoringIndexerState(f *testing.F) {
 		s, err := indexerstore.NewIndexerStore(db)
 		require.NoError(t, err)
 
-		_, err = s.GetLastProcessedHeight()
+		// nothing is processed yet
+		paramsHash := bbndatagen.GenRandomByteArray(r, 32)
+		_, err = indexerstore.CreateSnapshot(db, &bytes.Buffer{}, paramsHash)
 		require.ErrorIs(t, err, indexerstore.ErrLastProcessedHeightNotFound)
 
-		lastProcessedHeight := uint64(r.Int63n(1000) + 1)
-		err = s.SaveLastProcessedHeight(lastProcessedHeight)
+		stakingTxs := datagen.GenNStoredStakingTxs(t, r, r.Intn(20)+1, 200)
+		for _, storedTx := range stakingTxs {
+			err := s.AddStakingTransaction(
+				storedTx.Tx,
+				storedTx.StakingOutputIdx,
+				storedTx.InclusionHeight,
+				storedTx.StakerPk,
+				storedTx.StakingTime,
+				storedTx.FinalityProviderPk,
+				storedTx.StakingValue,
+				storedTx.IsOverflow,
+			)
+			require.NoError(t, err)
+		}
+		lastHeight := stakingTxs[len(stakingTxs)-1].InclusionHeight
+		blockHash := bbndatagen.GenRandomBtcdHash(r)
+		err = s.SaveProcessedBlock(lastHeight, &blockHash)
+		require.NoError(t, err)
+
+		// the snapshot is refused until the events in the outbox are delivered
+		numEvents := r.Intn(5) + 1
+		for i := 0; i < numEvents; i++ {
+			err := s.AddOutboxEntry(1, bbndatagen.GenRandomByteArray(r, 32), lastHeight, &blockHash)
+			require.NoError(t, err)
+		}
+		_, err = indexerstore.CreateSnapshot(db, &bytes.Buffer{}, paramsHash)
+		require.ErrorIs(t, err, indexerstore.ErrOutboxNotDrained)
+		for seq := uint64(1); seq <= uint64(numEvents); seq++ {
+			err := s.MarkOutboxEntryDelivered(seq)
+			require.NoError(t, err)
+		}
+
+		var snapshot bytes.Buffer
+		header, err := indexerstore.CreateSnapshot(db, &snapshot, paramsHash)
+		require.NoError(t, err)
+		require.Equal(t, uint32(indexerstore.SnapshotFormatVersion), header.FormatVersion)
+		require.Equal(t, indexerstore.LatestSchemaVersion(), header.SchemaVersion)
+		require.Equal(t, lastHeight, header.LastProcessedHeight)
+		readHeader, err := indexerstore.ReadSnapshotHeader(bytes.NewReader(snapshot.Bytes()))
+		require.NoError(t, err)
+		require.Equal(t, header, readHeader)
+
+		dumpDb := func(db kvdb.Backend) map[string]map[string][]byte {
+			dump := make(map[string]map[string][]byte)
+			err := kvdb.View(db, func(tx kvdb.RTx) error {
+				return tx.ForEachBucket(func(bucketName []byte) error {
+					bucketDump := make(map[string][]byte)
+					dump[string(bucketName)] = bucketDump
+					return tx.ReadBucket(bucketName).ForEach(func(k, v []byte) error {
+						bucketDump[string(k)] = append([]byte{}, v...)
+						return nil
+					})
+				})
+			}, func() {})
+			require.NoError(t, err)
+			return dump
+		}
+		isEmpty := func(db kvdb.Backend) bool {
+			return len(dumpDb(db)) == 0
+		}
+
+		// a snapshot with a corrupted byte is not installed
+		corrupted := append([]byte{}, snapshot.Bytes()...)
+		corrupted[len(corrupted)-1-r.Intn(32)] ^= 0xff
+		restoredDb := testutils.MakeTestBackend(t)
+		_, err = indexerstore.RestoreSnapshot(restoredDb, bytes.NewReader(corrupted), paramsHash)
+		require.ErrorIs(t, err, indexerstore.ErrSnapshotChecksumMismatch)
+		require.True(t, isEmpty(restoredDb))
+		_, err = indexerstore.RestoreSnapshot(restoredDb, bytes.NewReader(snapshot.Bytes()[:snapshot.Len()/2]), paramsHash)
+		require.ErrorIs(t, err, indexerstore.ErrInvalidSnapshot)
+		require.True(t, isEmpty(restoredDb))
+
+		// a snapshot created with other params is not installed
+		otherParamsHash := bbndatagen.GenRandomByteArray(r, 32)
+		_, err = indexerstore.RestoreSnapshot(restoredDb, bytes.NewReader(snapshot.Bytes()), otherParamsHash)
+		require.ErrorIs(t, err, indexerstore.ErrSnapshotParamsMismatch)
+		require.True(t, isEmpty(restoredDb))
+
+		// the restored database has the same data
+		restoredHeader, err := indexerstore.RestoreSnapshot(restoredDb, bytes.NewReader(snapshot.Bytes()), paramsHash)
+		require.NoError(t, err)
+		require.Equal(t, header, restoredHeader)
+		require.Equal(t, dumpDb(db), dumpDb(restoredDb))
+		restoredStore, err := indexerstore.NewIndexerStore(restoredDb)
+		require.NoError(t, err)
+		restoredHeight, err := restoredStore.GetLastProcessedHeight()
+		require.NoError(t, err)
+		require.Equal(t, lastHeight, restoredHeight)
+		report, err := indexerstore.VerifyDb(restoredDb)
 		require.NoError(t, err)
+		require.True(t, report.OK(), report.Issues)
 
-		storedLastProcessedHeight, err := s.GetLastProcessedHeight()
+		// the restored indexer does not publish the delivered events again
+		undelivered, err := restoredStore.GetUndeliveredOutboxEntries(numEvents)
 		require.NoError(t, err)
-		require.Equal(t, lastProcessedHeight, storedLastProcessedHeight)
+		require.Empty(t, undelivered)
+
+		// a snapshot is only installed into an empty database
+		_, err = indexerstore.RestoreSnapshot(restoredDb, bytes.NewReader(snapshot.Bytes()), paramsHash)
+		require.ErrorIs(t, err, indexerstore.ErrDbNotEmpty)
+	})
+}
+
+func FuzzFinalityProviderStats(f *testing.F) {
+	// only 3 seeds as this is pretty slow test opening/closing db
+	bbndatagen.AddRandomSeedsToFuzzer(f, 3)
+
+	f.Fuzz(func(t *testing.T, seed int64) {
+		forEachStore(t, func(t *testing.T, s *testStore) {
+			r := rand.New(rand.NewSource(seed))
+
+			// the staking txs are shared among a few finality providers
+			_, fpPks, err := bbndatagen.GenRandomBTCKeyPairs(r, r.Intn(3)+1)
+			require.NoError(t, err)
+			stakingTxs := datagen.GenNStoredStakingTxs(t, r, r.Intn(20)+1, 200)
+			for _, storedTx := range stakingTxs {
+				storedTx.FinalityProviderPk = fpPks[r.Intn(len(fpPks))]
+				err := s.AddStakingTransaction(
+					storedTx.Tx,
+					storedTx.StakingOutputIdx,
+					storedTx.InclusionHeight,
+					storedTx.StakerPk,
+					storedTx.StakingTime,
+					storedTx.FinalityProviderPk,
+					storedTx.StakingValue,
+					storedTx.IsOverflow,
+				)
+				require.NoError(t, err)
+			}
+
+			// some of the delegations are unbonded early, and some of the others
+			// expire, and are then withdrawn or not
+			lastHeight := stakingTxs[len(stakingTxs)-1].InclusionHeight
+			for _, storedTx := range stakingTxs {
+				stakingTxHash := storedTx.Tx.TxHash()
+				switch r.Intn(4) {
+				case 0:
+					unbondingTx := datagen.GenStoredUnbondingTxs(r, []*indexerstore.StoredStakingTransaction{storedTx})[0]
+					err := s.AddUnbondingTransaction(unbondingTx.Tx, unbondingTx.StakingTxHash, unbondingTx.InclusionHeight, unbondingTx.UnbondingTime)
+					require.NoError(t, err)
+					lastHeight = max(lastHeight, unbondingTx.InclusionHeight)
+				case 1, 2:
+					expiryHeight := storedTx.InclusionHeight + uint64(storedTx.StakingTime)
+					err := s.TransitionDelegationState(&stakingTxHash, indexerstore.StateUnbonded, expiryHeight, &stakingTxHash)
+					require.NoError(t, err)
+					lastHeight = max(lastHeight, expiryHeight)
+					if r.Intn(2) == 0 {
+						continue
+					}
+					withdrawalTx := datagen.GenRandomTx(r)
+					err = s.AddWithdrawalTransaction(withdrawalTx, &stakingTxHash, nil, expiryHeight+1)
+					require.NoError(t, err)
+					lastHeight = max(lastHeight, expiryHeight+1)
+				}
+			}
+
+			// the totals match the ones computed from the stored delegations
+			checkStats := func(s indexerstore.Store) {
+				expected := make(map[string]*indexerstore.FinalityProviderStats)
+				for _, fpPk := range fpPks {
+					expected[string(schnorr.SerializePubKey(fpPk))] = &indexerstore.FinalityProviderStats{FinalityProviderPk: fpPk}
+				}
+				err := s.ScanStoredStakingTransactions(func(tx *indexerstore.StoredStakingTransaction) error {
+					stats := expected[string(schnorr.SerializePubKey(tx.FinalityProviderPk))]
+					switch tx.State {
+					case indexerstore.StateActive:
+						stats.ActiveStake += tx.StakingValue
+						stats.ActiveDelegations++
+					case indexerstore.StateOverflow:
+						stats.OverflowStake += tx.StakingValue
+					case indexerstore.StateUnbonding, indexerstore.StateUnbonded, indexerstore.StateWithdrawn:
+						stats.UnbondedDelegations++
+					}
+					return nil
+				})
+				require.NoError(t, err)
+
+				numScanned := 0
+				err = s.ScanFinalityProviderStats(func(stats *indexerstore.FinalityProviderStats) error {
+					numScanned++
+					expectedStats := expected[string(schnorr.SerializePubKey(stats.FinalityProviderPk))]
+					require.NotNil(t, expectedStats)
+					require.Equal(t, expectedStats.ActiveStake, stats.ActiveStake)
+					require.Equal(t, expectedStats.OverflowStake, stats.OverflowStake)
+					require.Equal(t, expectedStats.ActiveDelegations, stats.ActiveDelegations)
+					require.Equal(t, expectedStats.UnbondedDelegations, stats.UnbondedDelegations)
+
+					storedStats, err := s.GetFinalityProviderStats(stats.FinalityProviderPk)
+					require.NoError(t, err)
+					require.Equal(t, stats, storedStats)
+					return nil
+				})
+				require.NoError(t, err)
+				require.LessOrEqual(t, numScanned, len(fpPks))
+			}
+			checkStats(s)
+
+			_, unknownFpPk, err := bbndatagen.GenRandomBTCKeyPair(r)
+			require.NoError(t, err)
+			stats, err := s.GetFinalityProviderStats(unknownFpPk)
+			require.NoError(t, err)
+			require.Nil(t, stats)
+
+			// the totals of a bolt database created before them are computed when the
+			// store is opened
+			if s.boltDb != nil {
+				err = kvdb.Update(s.boltDb, func(tx kvdb.RwTx) error {
+					if err := tx.DeleteTopLevelBucket([]byte("finalityproviderstats")); err != nil {
+						return err
+					}
+					return tx.ReadWriteBucket([]byte("indexerstate")).Delete([]byte("schemaversion"))
+				}, func() {})
+				require.NoError(t, err)
+				bs, err := indexerstore.NewIndexerStore(s.boltDb)
+				require.NoError(t, err)
+				s = &testStore{Store: bs, boltDb: s.boltDb}
+				checkStats(s)
+			}
+
+			// rolling back the last block restores the totals before it
+			blockHash := bbndatagen.GenRandomBtcdHash(r)
+			err = s.SaveProcessedBlock(lastHeight, &blockHash)
+			require.NoError(t, err)
+			err = s.RollbackBlock(lastHeight)
+			require.NoError(t, err)
+			checkStats(s)
+		})
 	})
 }
@@ -0,0 +1,185 @@
+package indexerstore
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/lightningnetwork/lnd/kvdb"
+	pm "google.golang.org/protobuf/proto"
+
+	"github.com/babylonlabs-io/staking-indexer/proto"
+	"github.com/babylonlabs-io/staking-indexer/utils"
+)
+
+// InvalidStakingReason is the code of the global parameter rule that
+// an invalid staking tx violates
+type InvalidStakingReason uint32
+
+const (
+	StakingAmountTooLow InvalidStakingReason = iota + 1
+	StakingAmountTooHigh
+	StakingTimeTooLow
+	StakingTimeTooHigh
+)
+
+func (r InvalidStakingReason) String() string {
+	switch r {
+	case StakingAmountTooLow:
+		return "staking_amount_too_low"
+	case StakingAmountTooHigh:
+		return "staking_amount_too_high"
+	case StakingTimeTooLow:
+		return "staking_time_too_low"
+	case StakingTimeTooHigh:
+		return "staking_time_too_high"
+	default:
+		return fmt.Sprintf("unknown(%d)", uint32(r))
+	}
+}
+
+type StoredInvalidStakingTransaction struct {
+	Tx                 *wire.MsgTx
+	StakingOutputIdx   uint32
+	InclusionHeight    uint64
+	StakerPk           *btcec.PublicKey
+	StakingTime        uint32
+	FinalityProviderPk *btcec.PublicKey
+	StakingValue       uint64
+	Reason             InvalidStakingReason
+}
+
+// AddInvalidStakingTransaction saves a staking tx that does not follow the
+// global parameters together with the reason why it is invalid
+// it returns ErrDuplicateTransaction if the tx is already stored
+func (is *IndexerStore) AddInvalidStakingTransaction(
+	tx *wire.MsgTx,
+	stakingOutputIdx uint32,
+	inclusionHeight uint64,
+	stakerPk *btcec.PublicKey,
+	stakingTime uint32,
+	fpPk *btcec.PublicKey,
+	stakingValue uint64,
+	reason InvalidStakingReason,
+) error {
+	txHash := tx.TxHash()
+	serializedTx, err := utils.SerializeBtcTransaction(tx)
+	if err != nil {
+		return err
+	}
+
+	msg := proto.InvalidStakingTransaction{
+		TransactionBytes:   serializedTx,
+		StakingOutputIdx:   stakingOutputIdx,
+		InclusionHeight:    inclusionHeight,
+		StakerPk:           schnorr.SerializePubKey(stakerPk),
+		FinalityProviderPk: schnorr.SerializePubKey(fpPk),
+		StakingTime:        stakingTime,
+		StakingValue:       stakingValue,
+		Reason:             uint32(reason),
+	}
+
+	return is.addInvalidStakingTransaction(txHash[:], &msg)
+}
+
+func (is *IndexerStore) addInvalidStakingTransaction(
+	txHashBytes []byte,
+	st *proto.InvalidStakingTransaction,
+) error {
+	return is.update(func(tx kvdb.RwTx) error {
+		txBucket := tx.ReadWriteBucket(invalidStakingTxBucketName)
+		if txBucket == nil {
+			return ErrCorruptedTransactionsDb
+		}
+		maybeTx := txBucket.Get(txHashBytes)
+		if maybeTx != nil {
+			return ErrDuplicateTransaction
+		}
+
+		marshalled, err := pm.Marshal(st)
+		if err != nil {
+			return err
+		}
+
+		if err := txBucket.Put(txHashBytes, marshalled); err != nil {
+			return err
+		}
+
+		// invalid staking txs never change the confirmed tvl
+		return is.appendJournalEntry(
+			tx, st.InclusionHeight, InvalidStakingTxType, txHashBytes,
+		)
+	})
+}
+
+// GetInvalidStakingTransaction retrieves the stored invalid staking transaction
+// by the given hash
+// it returns (nil, nil) if the transaction is not found
+func (is *IndexerStore) GetInvalidStakingTransaction(txHash *chainhash.Hash) (*StoredInvalidStakingTransaction, error) {
+	var storedTx *StoredInvalidStakingTransaction
+	txHashBytes := txHash.CloneBytes()
+
+	err := is.view(func(tx kvdb.RTx) error {
+		txBucket := tx.ReadBucket(invalidStakingTxBucketName)
+		if txBucket == nil {
+			return ErrCorruptedTransactionsDb
+		}
+
+		maybeTx := txBucket.Get(txHashBytes)
+		if maybeTx == nil {
+			return ErrTransactionNotFound
+		}
+
+		var storedTxProto proto.InvalidStakingTransaction
+		if err := pm.Unmarshal(maybeTx, &storedTxProto); err != nil {
+			return ErrCorruptedTransactionsDb
+		}
+
+		txFromDb, err := protoInvalidStakingTxToStoredInvalidStakingTx(&storedTxProto)
+		if err != nil {
+			return err
+		}
+
+		storedTx = txFromDb
+		return nil
+	}, func() {})
+
+	if err != nil && !errors.Is(err, ErrTransactionNotFound) {
+		return nil, err
+	}
+
+	return storedTx, nil
+}
+
+func protoInvalidStakingTxToStoredInvalidStakingTx(protoTx *proto.InvalidStakingTransaction) (*StoredInvalidStakingTransaction, error) {
+	var stakingTx wire.MsgTx
+	err := stakingTx.Deserialize(bytes.NewReader(protoTx.TransactionBytes))
+	if err != nil {
+		return nil, fmt.Errorf("invalid staking tx: %w", err)
+	}
+
+	stakerPk, err := schnorr.ParsePubKey(protoTx.StakerPk)
+	if err != nil {
+		return nil, fmt.Errorf("invalid staker pk: %w", err)
+	}
+
+	fpPk, err := schnorr.ParsePubKey(protoTx.FinalityProviderPk)
+	if err != nil {
+		return nil, fmt.Errorf("invalid finality provider pk: %w", err)
+	}
+
+	return &StoredInvalidStakingTransaction{
+		Tx:                 &stakingTx,
+		StakingOutputIdx:   protoTx.StakingOutputIdx,
+		InclusionHeight:    protoTx.InclusionHeight,
+		StakerPk:           stakerPk,
+		StakingTime:        protoTx.StakingTime,
+		FinalityProviderPk: fpPk,
+		StakingValue:       protoTx.StakingValue,
+		Reason:             InvalidStakingReason(protoTx.Reason),
+	}, nil
+}
@@ -0,0 +1,400 @@
+package indexerstore
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/lightningnetwork/lnd/kvdb"
+	pm "google.golang.org/protobuf/proto"
+
+	"github.com/babylonlabs-io/staking-indexer/proto"
+)
+
+// DelegationState is the lifecycle state of a delegation, i.e., a stored
+// staking tx
+type DelegationState uint32
+
+const (
+	// StateActive the staking tx is counted in the tvl
+	StateActive DelegationState = iota + 1
+	// StateOverflow the staking tx exceeds the staking cap
+	StateOverflow
+	// StateUnbonding the staking tx is spent by an unbonding tx
+	StateUnbonding
+	// StateUnbonded the timelock of the staking or unbonding output expired
+	StateUnbonded
+	// StateWithdrawn the staking or unbonding output is withdrawn
+	StateWithdrawn
+	// StateSlashed the staking or unbonding output is slashed
+	StateSlashed
+)
+
+// validStateTransitions maps a state to the states it can move to
+var validStateTransitions = map[DelegationState][]DelegationState{
+	StateActive:    {StateUnbonding, StateUnbonded, StateSlashed},
+	StateOverflow:  {StateUnbonding, StateUnbonded, StateSlashed},
+	StateUnbonding: {StateUnbonded, StateSlashed},
+	// the staking output can still be unbonded after its timelock expires
+	StateUnbonded: {StateUnbonding, StateWithdrawn, StateSlashed},
+}
+
+func (s DelegationState) String() string {
+	switch s {
+	case StateActive:
+		return "active"
+	case StateOverflow:
+		return "overflow"
+	case StateUnbonding:
+		return "unbonding"
+	case StateUnbonded:
+		return "unbonded"
+	case StateWithdrawn:
+		return "withdrawn"
+	case StateSlashed:
+		return "slashed"
+	default:
+		return fmt.Sprintf("unknown(%d)", uint32(s))
+	}
+}
+
+// CanTransitionTo returns whether the state can move to the next state
+func (s DelegationState) CanTransitionTo(next DelegationState) bool {
+	for _, st := range validStateTransitions[s] {
+		if st == next {
+			return true
+		}
+	}
+
+	return false
+}
+
+// StateTransition is a move of a delegation to a lifecycle state
+type StateTransition struct {
+	State DelegationState
+	// Height is the BTC height of the block causing the move
+	Height uint64
+	// TxHash is the hash of the tx causing the move
+	TxHash *chainhash.Hash
+}
+
+// InvalidStateTransitionError is returned when a delegation is requested
+// to move to a state that it cannot move to from its current state
+type InvalidStateTransitionError struct {
+	StakingTxHash *chainhash.Hash
+	From          DelegationState
+	To            DelegationState
+}
+
+func (e *InvalidStateTransitionError) Error() string {
+	return fmt.Sprintf("%s: delegation %s cannot move from %s to %s",
+		ErrInvalidStateTransition, e.StakingTxHash, e.From, e.To)
+}
+
+func (e *InvalidStateTransitionError) Unwrap() error {
+	return ErrInvalidStateTransition
+}
+
+// TransitionDelegationState moves the delegation of the given staking tx to
+// the next state because of the given tx included at the given height
+// it returns InvalidStateTransitionError if the move is invalid
+func (is *IndexerStore) TransitionDelegationState(
+	stakingTxHash *chainhash.Hash,
+	next DelegationState,
+	height uint64,
+	txHash *chainhash.Hash,
+) error {
+	return is.update(func(tx kvdb.RwTx) error {
+		return is.transitionState(tx, stakingTxHash.CloneBytes(), next, height, txHash.CloneBytes())
+	})
+}
+
+// CheckDelegationStateTransition checks whether the delegation of the given
+// staking tx can move to the next state because of the given tx
+// it returns nil if the move caused by the tx is already recorded, and
+// InvalidStateTransitionError if the move is invalid
+func (is *IndexerStore) CheckDelegationStateTransition(
+	stakingTxHash *chainhash.Hash,
+	next DelegationState,
+	txHash *chainhash.Hash,
+) error {
+	return is.view(func(tx kvdb.RTx) error {
+		stakingTxBucket := tx.ReadBucket(stakingTxBucketName)
+		if stakingTxBucket == nil {
+			return ErrCorruptedTransactionsDb
+		}
+
+		stakingTxProto, err := getStakingTxProto(stakingTxBucket, stakingTxHash.CloneBytes())
+		if err != nil {
+			return err
+		}
+
+		for _, st := range stakingTxProto.StateTransitions {
+			if DelegationState(st.State) == next && txHash.IsEqual((*chainhash.Hash)(st.TxHash)) {
+				return nil
+			}
+		}
+
+		current := currentState(stakingTxProto)
+		if !current.CanTransitionTo(next) {
+			return &InvalidStateTransitionError{
+				StakingTxHash: stakingTxHash,
+				From:          current,
+				To:            next,
+			}
+		}
+
+		return nil
+	}, func() {})
+}
+
+// transitionState moves the delegation of the given staking tx to the next
+// state and records the move in the journal of the block at the given height
+func (is *IndexerStore) transitionState(
+	tx kvdb.RwTx,
+	stakingTxHashBytes []byte,
+	next DelegationState,
+	height uint64,
+	txHashBytes []byte,
+) error {
+	if err := putStateTransition(tx, stakingTxHashBytes, next, height, txHashBytes); err != nil {
+		return err
+	}
+
+	return is.appendJournalEntry(tx, height, StateTransitionType, stakingTxHashBytes)
+}
+
+// putStateTransition moves the delegation of the given staking tx to the next
+// state and records the move in the staking tx, without journaling it
+func putStateTransition(
+	tx kvdb.RwTx,
+	stakingTxHashBytes []byte,
+	next DelegationState,
+	height uint64,
+	txHashBytes []byte,
+) error {
+	stakingTxBucket := tx.ReadWriteBucket(stakingTxBucketName)
+	if stakingTxBucket == nil {
+		return ErrCorruptedTransactionsDb
+	}
+
+	stakingTxProto, err := getStakingTxProto(stakingTxBucket, stakingTxHashBytes)
+	if err != nil {
+		return err
+	}
+
+	current := currentState(stakingTxProto)
+	if !current.CanTransitionTo(next) {
+		stakingTxHash, err := chainhash.NewHash(stakingTxHashBytes)
+		if err != nil {
+			return err
+		}
+		return &InvalidStateTransitionError{
+			StakingTxHash: stakingTxHash,
+			From:          current,
+			To:            next,
+		}
+	}
+
+	if err := moveFinalityProviderDelegation(tx, stakingTxProto, current, next); err != nil {
+		return err
+	}
+
+	stakingTxProto.State = uint32(next)
+	stakingTxProto.StateTransitions = append(stakingTxProto.StateTransitions, &proto.StateTransition{
+		State:  uint32(next),
+		Height: height,
+		TxHash: txHashBytes,
+	})
+
+	return putStakingTxProto(stakingTxBucket, stakingTxHashBytes, stakingTxProto)
+}
+
+// undoStateTransition moves the delegation of the given staking tx back
+// to the state before its last move
+func undoStateTransition(tx kvdb.RwTx, stakingTxHashBytes []byte) error {
+	stakingTxBucket := tx.ReadWriteBucket(stakingTxBucketName)
+	if stakingTxBucket == nil {
+		return ErrCorruptedTransactionsDb
+	}
+
+	stakingTxProto, err := getStakingTxProto(stakingTxBucket, stakingTxHashBytes)
+	if err != nil {
+		return err
+	}
+
+	// the first transition is the initial state
+	numTransitions := len(stakingTxProto.StateTransitions)
+	if numTransitions < 2 {
+		return fmt.Errorf("%w: no state transition to undo", ErrCorruptedStateDb)
+	}
+
+	current := currentState(stakingTxProto)
+	stakingTxProto.StateTransitions = stakingTxProto.StateTransitions[:numTransitions-1]
+	stakingTxProto.State = stakingTxProto.StateTransitions[numTransitions-2].State
+
+	if err := moveFinalityProviderDelegation(tx, stakingTxProto, current, currentState(stakingTxProto)); err != nil {
+		return err
+	}
+
+	return putStakingTxProto(stakingTxBucket, stakingTxHashBytes, stakingTxProto)
+}
+
+// currentState returns the lifecycle state of the stored staking tx
+// the states of the staking txs stored before the lifecycle states were
+// introduced are recorded by migrateDelegationStates
+func currentState(stakingTxProto *proto.StakingTransaction) DelegationState {
+	return DelegationState(stakingTxProto.State)
+}
+
+func initialState(isOverflow bool) DelegationState {
+	if isOverflow {
+		return StateOverflow
+	}
+
+	return StateActive
+}
+
+// migrateDelegationStates records the lifecycle states of the staking txs
+// stored before they were introduced, which have no state, so that they are
+// not read as active or overflow. The moves are replayed from the stored
+// unbonding, withdrawal, and slashing txs in the way the indexer records
+// them, i.e., the expiry of the timelock of a withdrawn output is recorded at
+// the withdrawal height. The staking txs whose state is recorded are skipped
+// Note: the expiries of the outputs that are not spent are not known from
+// the stored txs and are left to the timelock expiry index
+func migrateDelegationStates(tx kvdb.RwTx) error {
+	stakingTxBucket := tx.ReadWriteBucket(stakingTxBucketName)
+	if stakingTxBucket == nil {
+		return ErrCorruptedTransactionsDb
+	}
+
+	var legacyTxHashes [][]byte
+	if err := stakingTxBucket.ForEach(func(k, v []byte) error {
+		var stakingTxProto proto.StakingTransaction
+		if err := pm.Unmarshal(v, &stakingTxProto); err != nil {
+			return ErrCorruptedTransactionsDb
+		}
+		if stakingTxProto.State == 0 {
+			legacyTxHashes = append(legacyTxHashes, k)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	if len(legacyTxHashes) == 0 {
+		return nil
+	}
+
+	transitions, err := getLegacyStateTransitions(tx)
+	if err != nil {
+		return err
+	}
+
+	for _, txHashBytes := range legacyTxHashes {
+		stakingTxProto, err := getStakingTxProto(stakingTxBucket, txHashBytes)
+		if err != nil {
+			return err
+		}
+
+		current := initialState(stakingTxProto.IsOverflow)
+		stakingTxProto.StateTransitions = []*proto.StateTransition{{
+			State:  uint32(current),
+			Height: stakingTxProto.InclusionHeight,
+			TxHash: txHashBytes,
+		}}
+
+		// the moves are applied in the order of their heights, and the
+		// invalid ones are skipped as the indexer does
+		txTransitions := transitions[string(txHashBytes)]
+		sort.SliceStable(txTransitions, func(i, j int) bool {
+			return txTransitions[i].Height < txTransitions[j].Height
+		})
+		for _, st := range txTransitions {
+			next := DelegationState(st.State)
+			if !current.CanTransitionTo(next) {
+				continue
+			}
+			current = next
+			stakingTxProto.StateTransitions = append(stakingTxProto.StateTransitions, st)
+		}
+		stakingTxProto.State = uint32(current)
+
+		if err := putStakingTxProto(stakingTxBucket, txHashBytes, stakingTxProto); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getLegacyStateTransitions returns the moves caused by the stored unbonding,
+// withdrawal, and slashing txs by the hash of their staking tx
+func getLegacyStateTransitions(tx kvdb.RTx) (map[string][]*proto.StateTransition, error) {
+	transitions := make(map[string][]*proto.StateTransition)
+	addTransition := func(stakingTxHashBytes []byte, state DelegationState, height uint64, txHashBytes []byte) {
+		transitions[string(stakingTxHashBytes)] = append(transitions[string(stakingTxHashBytes)], &proto.StateTransition{
+			State:  uint32(state),
+			Height: height,
+			TxHash: txHashBytes,
+		})
+	}
+
+	unbondingTxBucket := tx.ReadBucket(unbondingTxBucketName)
+	withdrawalTxBucket := tx.ReadBucket(withdrawalTxBucketName)
+	slashingTxBucket := tx.ReadBucket(slashingTxBucketName)
+	if unbondingTxBucket == nil || withdrawalTxBucket == nil || slashingTxBucket == nil {
+		return nil, ErrCorruptedTransactionsDb
+	}
+
+	if err := unbondingTxBucket.ForEach(func(k, v []byte) error {
+		var unbondingTxProto proto.UnbondingTransaction
+		if err := pm.Unmarshal(v, &unbondingTxProto); err != nil {
+			return ErrCorruptedTransactionsDb
+		}
+		addTransition(unbondingTxProto.StakingTxHash, StateUnbonding, unbondingTxProto.InclusionHeight, k)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := withdrawalTxBucket.ForEach(func(k, v []byte) error {
+		var withdrawalTxProto proto.WithdrawalTransaction
+		if err := pm.Unmarshal(v, &withdrawalTxProto); err != nil {
+			return ErrCorruptedTransactionsDb
+		}
+		// the withdrawn output is unbonded by the expiry of its timelock
+		spentTxHashBytes := withdrawalTxProto.StakingTxHash
+		if len(withdrawalTxProto.UnbondingTxHash) != 0 {
+			spentTxHashBytes = withdrawalTxProto.UnbondingTxHash
+		}
+		addTransition(withdrawalTxProto.StakingTxHash, StateUnbonded, withdrawalTxProto.InclusionHeight, spentTxHashBytes)
+		addTransition(withdrawalTxProto.StakingTxHash, StateWithdrawn, withdrawalTxProto.InclusionHeight, k)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := slashingTxBucket.ForEach(func(k, v []byte) error {
+		var slashingTxProto proto.SlashingTransaction
+		if err := pm.Unmarshal(v, &slashingTxProto); err != nil {
+			return ErrCorruptedTransactionsDb
+		}
+		addTransition(slashingTxProto.StakingTxHash, StateSlashed, slashingTxProto.InclusionHeight, k)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return transitions, nil
+}
+
+func putStakingTxProto(stakingTxBucket kvdb.RwBucket, txHashBytes []byte, stakingTxProto *proto.StakingTransaction) error {
+	marshalled, err := pm.Marshal(stakingTxProto)
+	if err != nil {
+		return err
+	}
+
+	return stakingTxBucket.Put(txHashBytes, marshalled)
+}
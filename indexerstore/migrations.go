@@ -0,0 +1,198 @@
+package indexerstore
+
+import (
+	"fmt"
+
+	"github.com/lightningnetwork/lnd/kvdb"
+)
+
+// Migration upgrades the stored data to the schema with its version
+type Migration struct {
+	// Version is the schema version of the database once the migration is
+	// applied
+	Version uint64
+	// Description tells what the migration changes
+	Description string
+
+	migrate func(tx kvdb.RwTx) error
+}
+
+// migrations are the registered migrations in the order they are applied,
+// the version of each one is its position in the list starting from 1. A
+// change to the stored data, e.g., a new index or a new field of a stored
+// proto message, is done by appending a migration
+// the databases created before the schema version was stored have the
+// version 0, so all the migrations are applied to them, which is why the
+// migrations must also do nothing if their changes are already there
+var migrations = []*Migration{
+	{
+		Version:     1,
+		Description: "index the staking transactions by staker public key",
+		migrate:     migrateStakerIndex,
+	},
+	{
+		Version:     2,
+		Description: "index the staking transactions by inclusion height",
+		migrate:     migrateHeightIndex,
+	},
+	{
+		Version:     3,
+		Description: "record the lifecycle states of the delegations",
+		migrate:     migrateDelegationStates,
+	},
+	{
+		// the totals follow the lifecycle states, so they are computed
+		// once the states are recorded
+		Version:     4,
+		Description: "compute the delegation totals of the finality providers",
+		migrate:     migrateFinalityProviderStats,
+	},
+	{
+		Version:     5,
+		Description: "index the timelock expiries of the unspent outputs",
+		migrate:     migrateTimelockExpiries,
+	},
+}
+
+// LatestSchemaVersion is the schema version supported by this binary, the
+// databases are migrated to it when the store is opened
+func LatestSchemaVersion() uint64 {
+	return migrations[len(migrations)-1].Version
+}
+
+// GetSchemaVersion returns the schema version of the database, or 0 if it
+// is not stored yet
+func GetSchemaVersion(db kvdb.Backend) (uint64, error) {
+	var version uint64
+
+	err := db.View(func(tx kvdb.RTx) error {
+		var err error
+		version, err = getSchemaVersion(tx)
+		return err
+	}, func() {
+		version = 0
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return version, nil
+}
+
+// PendingMigrations returns the migrations that are not applied to the
+// database yet, without changing it
+// it returns ErrSchemaTooNew if the database is written by a newer binary
+func PendingMigrations(db kvdb.Backend) ([]*Migration, error) {
+	version, err := GetSchemaVersion(db)
+	if err != nil {
+		return nil, err
+	}
+
+	return pendingMigrations(version)
+}
+
+// Migrate applies the pending migrations to the database in a single write
+// transaction, so none of them is applied if one fails. It returns the
+// applied migrations
+// it returns ErrSchemaTooNew if the database is written by a newer binary
+func Migrate(db kvdb.Backend) ([]*Migration, error) {
+	var applied []*Migration
+
+	err := kvdb.Update(db, func(tx kvdb.RwTx) error {
+		if err := createBuckets(tx); err != nil {
+			return err
+		}
+
+		var err error
+		applied, err = migrate(tx)
+		return err
+	}, func() {
+		applied = nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return applied, nil
+}
+
+// migrate applies the pending migrations in order, and stores the latest
+// schema version
+func migrate(tx kvdb.RwTx) ([]*Migration, error) {
+	version, err := getSchemaVersion(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	pending, err := pendingMigrations(version)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, m := range pending {
+		if err := m.migrate(tx); err != nil {
+			return nil, fmt.Errorf("failed to apply the migration to schema version %d: %w", m.Version, err)
+		}
+	}
+
+	if len(pending) == 0 {
+		return nil, nil
+	}
+
+	stateBucket := tx.ReadWriteBucket(indexerStateBucketName)
+	if stateBucket == nil {
+		return nil, ErrCorruptedStateDb
+	}
+
+	if err := stateBucket.Put(getSchemaVersionKey(), uint64ToBytes(LatestSchemaVersion())); err != nil {
+		return nil, err
+	}
+
+	return pending, nil
+}
+
+func pendingMigrations(version uint64) ([]*Migration, error) {
+	if err := checkSchemaVersion(version); err != nil {
+		return nil, err
+	}
+
+	return migrations[version:], nil
+}
+
+// checkSchemaVersion returns ErrSchemaTooNew if the given schema version is
+// newer than the latest supported one
+func checkSchemaVersion(version uint64) error {
+	if version > LatestSchemaVersion() {
+		return fmt.Errorf("%w: the database has schema version %d, but the latest supported one is %d",
+			ErrSchemaTooNew, version, LatestSchemaVersion())
+	}
+
+	return nil
+}
+
+func getSchemaVersionKey() []byte {
+	return []byte("schemaversion")
+}
+
+// getSchemaVersion returns the stored schema version, or 0 if it is not
+// stored yet, including when the database is empty
+func getSchemaVersion(tx kvdb.RTx) (uint64, error) {
+	stateBucket := tx.ReadBucket(indexerStateBucketName)
+	if stateBucket == nil {
+		return 0, nil
+	}
+
+	v := stateBucket.Get(getSchemaVersionKey())
+	if v == nil {
+		return 0, nil
+	}
+
+	version, err := uint64FromBytes(v)
+	if err != nil {
+		return 0, ErrCorruptedStateDb
+	}
+
+	return version, nil
+}
@@ -0,0 +1,432 @@
+package indexerstore
+
+import (
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/lightningnetwork/lnd/kvdb"
+	pm "google.golang.org/protobuf/proto"
+
+	"github.com/babylonlabs-io/staking-indexer/proto"
+)
+
+// OutboxEntry is an event waiting to be published to the consumer
+type OutboxEntry struct {
+	// Sequence is the position of the event in the outbox
+	Sequence  uint64
+	EventType uint32
+	// Payload is the JSON encoded event
+	Payload   []byte
+	Delivered bool
+	// BlockHeight and BlockHash identify the block whose processing caused
+	// the event, BlockHash is nil if the block is unknown
+	BlockHeight uint64
+	BlockHash   *chainhash.Hash
+	// CreatedAt is the time at which the event is written to the outbox, it
+	// is zero for the events written before it was recorded
+	CreatedAt time.Time
+}
+
+// OutboxStats describes the events waiting in the outbox
+type OutboxStats struct {
+	// NumUndelivered is the number of the events not delivered yet
+	NumUndelivered uint64
+	// OldestUndeliveredAt is the time at which the oldest undelivered event
+	// is written to the outbox, it is zero if there is no such event or the
+	// time is unknown
+	OldestUndeliveredAt time.Time
+}
+
+// Update runs fn in a single write transaction. The store passed to fn is
+// bound to the transaction, and the changes are committed only if fn succeeds
+func (is *IndexerStore) Update(fn func(bs Store) error) error {
+	return is.update(func(tx kvdb.RwTx) error {
+		return fn(&IndexerStore{db: is.db, blockTx: tx})
+	})
+}
+
+// AddOutboxEntry appends an event caused by the block with the given height
+// and hash to the outbox. The event is committed together with the state
+// changes if the store is bound to a transaction
+func (is *IndexerStore) AddOutboxEntry(
+	eventType uint32,
+	payload []byte,
+	blockHeight uint64,
+	blockHash *chainhash.Hash,
+) error {
+	return is.update(func(tx kvdb.RwTx) error {
+		outboxBucket := tx.ReadWriteBucket(outboxBucketName)
+		if outboxBucket == nil {
+			return ErrCorruptedStateDb
+		}
+
+		seq, err := outboxBucket.NextSequence()
+		if err != nil {
+			return err
+		}
+
+		entryProto := &proto.OutboxEntry{
+			EventType:   eventType,
+			Payload:     payload,
+			BlockHeight: blockHeight,
+			CreatedAt:   time.Now().Unix(),
+		}
+		if blockHash != nil {
+			entryProto.BlockHash = blockHash.CloneBytes()
+		}
+
+		marshalled, err := pm.Marshal(entryProto)
+		if err != nil {
+			return err
+		}
+
+		return outboxBucket.Put(uint64ToBytes(seq), marshalled)
+	})
+}
+
+// GetUndeliveredOutboxEntries returns at most limit events that are not
+// delivered yet, in the order they were added
+func (is *IndexerStore) GetUndeliveredOutboxEntries(limit int) ([]*OutboxEntry, error) {
+	var entries []*OutboxEntry
+
+	err := is.view(func(tx kvdb.RTx) error {
+		outboxBucket := tx.ReadBucket(outboxBucketName)
+		if outboxBucket == nil {
+			return ErrCorruptedStateDb
+		}
+
+		lastDelivered, err := getLastDeliveredOutboxSequence(tx)
+		if err != nil {
+			return err
+		}
+
+		c := outboxBucket.ReadCursor()
+		for k, v := c.Seek(uint64ToBytes(lastDelivered + 1)); k != nil && len(entries) < limit; k, v = c.Next() {
+			entry, err := outboxEntryFromBytes(k, v)
+			if err != nil {
+				return err
+			}
+			if entry.Delivered {
+				continue
+			}
+			entries = append(entries, entry)
+		}
+
+		return nil
+	}, func() {
+		entries = nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// GetOutboxEntries returns at most limit events starting from the one with
+// the given sequence, in the order they were added, whether they are
+// delivered or not
+func (is *IndexerStore) GetOutboxEntries(fromSeq uint64, limit int) ([]*OutboxEntry, error) {
+	var entries []*OutboxEntry
+
+	err := is.view(func(tx kvdb.RTx) error {
+		outboxBucket := tx.ReadBucket(outboxBucketName)
+		if outboxBucket == nil {
+			return ErrCorruptedStateDb
+		}
+
+		c := outboxBucket.ReadCursor()
+		for k, v := c.Seek(uint64ToBytes(fromSeq)); k != nil && len(entries) < limit; k, v = c.Next() {
+			entry, err := outboxEntryFromBytes(k, v)
+			if err != nil {
+				return err
+			}
+			entries = append(entries, entry)
+		}
+
+		return nil
+	}, func() {
+		entries = nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// GetOutboxSequenceFromHeight returns the sequence of the first event in the
+// outbox caused by a block at or above the given height, or the sequence of
+// the next event if there is no such event
+func (is *IndexerStore) GetOutboxSequenceFromHeight(height uint64) (uint64, error) {
+	var seq uint64
+
+	err := is.view(func(tx kvdb.RTx) error {
+		seq = 0
+
+		outboxBucket := tx.ReadBucket(outboxBucketName)
+		if outboxBucket == nil {
+			return ErrCorruptedStateDb
+		}
+
+		c := outboxBucket.ReadCursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			entry, err := outboxEntryFromBytes(k, v)
+			if err != nil {
+				return err
+			}
+			if entry.BlockHeight >= height {
+				seq = entry.Sequence
+				return nil
+			}
+			seq = entry.Sequence + 1
+		}
+
+		// the pruned events are all delivered, so the next event follows
+		// the last delivered one if the outbox is empty
+		lastDelivered, err := getLastDeliveredOutboxSequence(tx)
+		if err != nil {
+			return err
+		}
+		seq = max(seq, lastDelivered+1)
+
+		return nil
+	}, func() {
+		seq = 0
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return seq, nil
+}
+
+// GetOutboxStats returns the number of the undelivered events and the time
+// the oldest of them was written. As the events are delivered in order, the
+// undelivered events are the ones after the last delivered event
+func (is *IndexerStore) GetOutboxStats() (*OutboxStats, error) {
+	var stats *OutboxStats
+
+	err := is.view(func(tx kvdb.RTx) error {
+		outboxBucket := tx.ReadBucket(outboxBucketName)
+		if outboxBucket == nil {
+			return ErrCorruptedStateDb
+		}
+
+		lastDelivered, err := getLastDeliveredOutboxSequence(tx)
+		if err != nil {
+			return err
+		}
+
+		stats = &OutboxStats{}
+		numUndelivered, err := getNumUndeliveredOutboxEntries(tx)
+		if err != nil {
+			return err
+		}
+		if numUndelivered == 0 {
+			return nil
+		}
+		stats.NumUndelivered = numUndelivered
+
+		k, v := outboxBucket.ReadCursor().Seek(uint64ToBytes(lastDelivered + 1))
+		if k == nil {
+			return nil
+		}
+		oldest, err := outboxEntryFromBytes(k, v)
+		if err != nil {
+			return err
+		}
+		stats.OldestUndeliveredAt = oldest.CreatedAt
+
+		return nil
+	}, func() {
+		stats = nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// MarkOutboxEntryDelivered marks the event with the given sequence delivered
+// it returns ErrOutboxEntryNotFound if the event does not exist
+func (is *IndexerStore) MarkOutboxEntryDelivered(seq uint64) error {
+	return is.update(func(tx kvdb.RwTx) error {
+		outboxBucket := tx.ReadWriteBucket(outboxBucketName)
+		if outboxBucket == nil {
+			return ErrCorruptedStateDb
+		}
+
+		key := uint64ToBytes(seq)
+		v := outboxBucket.Get(key)
+		if v == nil {
+			return ErrOutboxEntryNotFound
+		}
+
+		var entryProto proto.OutboxEntry
+		if err := pm.Unmarshal(v, &entryProto); err != nil {
+			return ErrCorruptedStateDb
+		}
+		entryProto.Delivered = true
+
+		marshalled, err := pm.Marshal(&entryProto)
+		if err != nil {
+			return err
+		}
+		if err := outboxBucket.Put(key, marshalled); err != nil {
+			return err
+		}
+
+		// the events are delivered in order, so the scan of undelivered
+		// events can start after the last delivered one
+		lastDelivered, err := getLastDeliveredOutboxSequence(tx)
+		if err != nil {
+			return err
+		}
+		if seq <= lastDelivered {
+			return nil
+		}
+
+		stateBucket := tx.ReadWriteBucket(indexerStateBucketName)
+		if stateBucket == nil {
+			return ErrCorruptedStateDb
+		}
+
+		return stateBucket.Put(getLastDeliveredOutboxSequenceKey(), key)
+	})
+}
+
+// PruneOutboxEntries deletes the delivered events caused by the blocks below
+// the given height, and returns the number of the deleted events. Only the
+// oldest events are deleted, i.e., it stops at the first event that is not
+// delivered or is caused by a block at or above the height, so the events
+// kept always follow each other
+func (is *IndexerStore) PruneOutboxEntries(belowHeight uint64) (uint64, error) {
+	var numPruned uint64
+
+	err := is.update(func(tx kvdb.RwTx) error {
+		numPruned = 0
+
+		outboxBucket := tx.ReadWriteBucket(outboxBucketName)
+		if outboxBucket == nil {
+			return ErrCorruptedStateDb
+		}
+
+		lastDelivered, err := getLastDeliveredOutboxSequence(tx)
+		if err != nil {
+			return err
+		}
+
+		// the cursor is moved to the first event after each deletion as
+		// deleting at the cursor position can skip the next key
+		c := outboxBucket.ReadWriteCursor()
+		for k, v := c.First(); k != nil; k, v = c.First() {
+			entry, err := outboxEntryFromBytes(k, v)
+			if err != nil {
+				return err
+			}
+			if entry.Sequence > lastDelivered || !entry.Delivered || entry.BlockHeight >= belowHeight {
+				return nil
+			}
+
+			if err := c.Delete(); err != nil {
+				return err
+			}
+			numPruned++
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return numPruned, nil
+}
+
+func getLastDeliveredOutboxSequenceKey() []byte {
+	return []byte("lastdeliveredoutboxsequence")
+}
+
+// getLastDeliveredOutboxSequence returns the sequence of the last delivered
+// event, or 0 if no event is delivered yet
+func getLastDeliveredOutboxSequence(tx kvdb.RTx) (uint64, error) {
+	stateBucket := tx.ReadBucket(indexerStateBucketName)
+	if stateBucket == nil {
+		return 0, ErrCorruptedStateDb
+	}
+
+	v := stateBucket.Get(getLastDeliveredOutboxSequenceKey())
+	if v == nil {
+		return 0, nil
+	}
+
+	return uint64FromBytes(v)
+}
+
+// getNumUndeliveredOutboxEntries returns the number of the events after the
+// last delivered event, which are the undelivered events as the events are
+// delivered in order
+func getNumUndeliveredOutboxEntries(tx kvdb.RTx) (uint64, error) {
+	outboxBucket := tx.ReadBucket(outboxBucketName)
+	if outboxBucket == nil {
+		return 0, ErrCorruptedStateDb
+	}
+
+	lastDelivered, err := getLastDeliveredOutboxSequence(tx)
+	if err != nil {
+		return 0, err
+	}
+
+	lastKey, _ := outboxBucket.ReadCursor().Last()
+	if lastKey == nil {
+		return 0, nil
+	}
+	lastSeq, err := uint64FromBytes(lastKey)
+	if err != nil {
+		return 0, ErrCorruptedStateDb
+	}
+	if lastSeq <= lastDelivered {
+		return 0, nil
+	}
+
+	return lastSeq - lastDelivered, nil
+}
+
+func outboxEntryFromBytes(k, v []byte) (*OutboxEntry, error) {
+	seq, err := uint64FromBytes(k)
+	if err != nil {
+		return nil, ErrCorruptedStateDb
+	}
+
+	var entryProto proto.OutboxEntry
+	if err := pm.Unmarshal(v, &entryProto); err != nil {
+		return nil, ErrCorruptedStateDb
+	}
+
+	entry := &OutboxEntry{
+		Sequence:    seq,
+		EventType:   entryProto.EventType,
+		Payload:     entryProto.Payload,
+		Delivered:   entryProto.Delivered,
+		BlockHeight: entryProto.BlockHeight,
+	}
+	if entryProto.CreatedAt > 0 {
+		entry.CreatedAt = time.Unix(entryProto.CreatedAt, 0)
+	}
+	if len(entryProto.BlockHash) > 0 {
+		blockHash, err := chainhash.NewHash(entryProto.BlockHash)
+		if err != nil {
+			return nil, ErrCorruptedStateDb
+		}
+		entry.BlockHash = blockHash
+	}
+
+	return entry, nil
+}
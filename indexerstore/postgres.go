@@ -0,0 +1,959 @@
+package indexerstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+)
+
+// postgresMigrations are the statements creating the tables of the Postgres
+// store in the order they are applied, the schema version of the database
+// is the number of the applied migrations. A change to the tables is done by
+// appending a migration
+var postgresMigrations = [][]string{
+	{
+		// the single row of the indexer state, the last processed height is
+		// NULL if no block is processed yet
+		`CREATE TABLE indexer_state (
+			id SMALLINT PRIMARY KEY CHECK (id = 1),
+			last_processed_height BIGINT,
+			confirmed_tvl BIGINT NOT NULL DEFAULT 0 CHECK (confirmed_tvl >= 0),
+			outbox_sequence BIGINT NOT NULL DEFAULT 0,
+			last_delivered_outbox_sequence BIGINT NOT NULL DEFAULT 0
+		)`,
+		`INSERT INTO indexer_state (id) VALUES (1)`,
+		`CREATE TABLE staking_transactions (
+			tx_hash BYTEA PRIMARY KEY,
+			tx_bytes BYTEA NOT NULL,
+			staking_output_idx BIGINT NOT NULL,
+			inclusion_height BIGINT NOT NULL,
+			staker_pk BYTEA NOT NULL,
+			staking_time BIGINT NOT NULL,
+			finality_provider_pk BYTEA NOT NULL,
+			staking_value BIGINT NOT NULL,
+			is_overflow BOOLEAN NOT NULL,
+			state INTEGER NOT NULL
+		)`,
+		`CREATE INDEX staking_transactions_height_idx ON staking_transactions (inclusion_height, tx_hash)`,
+		`CREATE INDEX staking_transactions_staker_idx ON staking_transactions (staker_pk, tx_hash)`,
+		`CREATE INDEX staking_transactions_fp_idx ON staking_transactions (finality_provider_pk)`,
+		// the moves of the delegations between lifecycle states, idx is the
+		// position of the move starting from 0 for the initial state
+		`CREATE TABLE delegation_state_transitions (
+			staking_tx_hash BYTEA NOT NULL REFERENCES staking_transactions (tx_hash),
+			idx INTEGER NOT NULL,
+			state INTEGER NOT NULL,
+			height BIGINT NOT NULL,
+			tx_hash BYTEA NOT NULL,
+			PRIMARY KEY (staking_tx_hash, idx)
+		)`,
+		`CREATE TABLE unbonding_transactions (
+			tx_hash BYTEA PRIMARY KEY,
+			tx_bytes BYTEA NOT NULL,
+			staking_tx_hash BYTEA NOT NULL REFERENCES staking_transactions (tx_hash),
+			inclusion_height BIGINT NOT NULL,
+			unbonding_time BIGINT NOT NULL
+		)`,
+		`CREATE INDEX unbonding_transactions_staking_idx ON unbonding_transactions (staking_tx_hash)`,
+		// unbonding_tx_hash is NULL if the withdrawal tx spends the staking tx
+		`CREATE TABLE withdrawal_transactions (
+			tx_hash BYTEA PRIMARY KEY,
+			tx_bytes BYTEA NOT NULL,
+			staking_tx_hash BYTEA NOT NULL REFERENCES staking_transactions (tx_hash),
+			unbonding_tx_hash BYTEA REFERENCES unbonding_transactions (tx_hash),
+			inclusion_height BIGINT NOT NULL
+		)`,
+		`CREATE INDEX withdrawal_transactions_staking_idx ON withdrawal_transactions (staking_tx_hash)`,
+		// unbonding_tx_hash is NULL if the slashing tx spends the staking tx
+		`CREATE TABLE slashing_transactions (
+			tx_hash BYTEA PRIMARY KEY,
+			tx_bytes BYTEA NOT NULL,
+			staking_tx_hash BYTEA NOT NULL REFERENCES staking_transactions (tx_hash),
+			unbonding_tx_hash BYTEA REFERENCES unbonding_transactions (tx_hash),
+			inclusion_height BIGINT NOT NULL
+		)`,
+		`CREATE TABLE invalid_staking_transactions (
+			tx_hash BYTEA PRIMARY KEY,
+			tx_bytes BYTEA NOT NULL,
+			staking_output_idx BIGINT NOT NULL,
+			inclusion_height BIGINT NOT NULL,
+			staker_pk BYTEA NOT NULL,
+			staking_time BIGINT NOT NULL,
+			finality_provider_pk BYTEA NOT NULL,
+			staking_value BIGINT NOT NULL,
+			reason INTEGER NOT NULL
+		)`,
+		// the spent tx is the staking tx or its unbonding tx
+		`CREATE TABLE timelock_expiries (
+			expiry_height BIGINT NOT NULL,
+			spent_tx_hash BYTEA NOT NULL,
+			staking_tx_hash BYTEA NOT NULL,
+			PRIMARY KEY (expiry_height, spent_tx_hash)
+		)`,
+		// the blocks with a journal, block_hash is NULL until the block is
+		// saved as processed
+		`CREATE TABLE blocks (
+			height BIGINT PRIMARY KEY,
+			block_hash BYTEA
+		)`,
+		`CREATE TABLE block_journal_entries (
+			height BIGINT NOT NULL REFERENCES blocks (height),
+			idx INTEGER NOT NULL,
+			tx_type INTEGER NOT NULL,
+			tx_hash BYTEA NOT NULL,
+			PRIMARY KEY (height, idx)
+		)`,
+		`CREATE TABLE outbox (
+			sequence BIGINT PRIMARY KEY,
+			event_type BIGINT NOT NULL,
+			payload BYTEA NOT NULL,
+			block_height BIGINT NOT NULL,
+			block_hash BYTEA,
+			created_at BIGINT NOT NULL,
+			delivered BOOLEAN NOT NULL DEFAULT FALSE
+		)`,
+		// the totals of the delegations to the finality providers, computed
+		// from the lifecycle states: 1 active, 2 overflow, 3 unbonding,
+		// 4 unbonded, 5 withdrawn
+		`CREATE VIEW finality_provider_stats AS
+			SELECT
+				finality_provider_pk,
+				CAST(SUM(CASE WHEN state = 1 THEN staking_value ELSE 0 END) AS BIGINT) AS active_stake,
+				CAST(SUM(CASE WHEN state = 2 THEN staking_value ELSE 0 END) AS BIGINT) AS overflow_stake,
+				CAST(SUM(CASE WHEN state = 1 THEN 1 ELSE 0 END) AS BIGINT) AS active_delegations,
+				CAST(SUM(CASE WHEN state IN (3, 4, 5) THEN 1 ELSE 0 END) AS BIGINT) AS unbonded_delegations
+			FROM staking_transactions
+			GROUP BY finality_provider_pk`,
+	},
+}
+
+// postgresScanBatchSize is the number of rows read at once when scanning a
+// table, the callbacks are run between the reads so that they can use the
+// store
+const postgresScanBatchSize = 1000
+
+// PostgresStore is the store on top of a Postgres database, in which the
+// stored data are kept in relational tables instead of encoded records. It
+// behaves the same as IndexerStore, see doc/state.md for the tables
+type PostgresStore struct {
+	db *sql.DB
+	// tx is the write transaction of the block being processed, it is nil
+	// if the store is not bound to a block
+	tx *sql.Tx
+}
+
+// NewPostgresStore returns a new store backed by the Postgres database db,
+// whose tables are created or migrated to the latest schema version
+// it returns ErrSchemaTooNew if the database is written by a newer binary
+func NewPostgresStore(db *sql.DB) (*PostgresStore, error) {
+	store := &PostgresStore{db: db}
+	if err := store.initTables(); err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
+
+// initTables applies the pending migrations in a single transaction
+func (ps *PostgresStore) initTables() error {
+	return ps.runTx(false, func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`CREATE TABLE IF NOT EXISTS schema_version (
+			id SMALLINT PRIMARY KEY CHECK (id = 1),
+			version BIGINT NOT NULL
+		)`); err != nil {
+			return err
+		}
+
+		var version int64
+		err := tx.QueryRow(`SELECT version FROM schema_version WHERE id = 1`).Scan(&version)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		latest := int64(len(postgresMigrations))
+		if version > latest {
+			return fmt.Errorf("%w: the database has schema version %d, but the latest supported one is %d",
+				ErrSchemaTooNew, version, latest)
+		}
+		if version == latest {
+			return nil
+		}
+
+		for i, statements := range postgresMigrations[version:] {
+			for _, statement := range statements {
+				if _, err := tx.Exec(statement); err != nil {
+					return fmt.Errorf("failed to apply the migration to schema version %d: %w",
+						version+int64(i)+1, err)
+				}
+			}
+		}
+
+		_, err = tx.Exec(`INSERT INTO schema_version (id, version) VALUES (1, $1)
+			ON CONFLICT (id) DO UPDATE SET version = excluded.version`, latest)
+		return err
+	})
+}
+
+// UpdateBlock applies all the state changes of processing the block at the
+// given height in a single write transaction, together with saving the block
+// as the last processed one. The store passed to fn is bound to the
+// transaction, so the reads through it observe the changes not committed
+// yet. The changes are committed only if fn succeeds, otherwise none of them
+// is applied. The bound store must not be used after fn returns
+func (ps *PostgresStore) UpdateBlock(
+	height uint64,
+	blockHash *chainhash.Hash,
+	fn func(bs Store) error,
+) error {
+	if ps.tx != nil {
+		return fmt.Errorf("the store is already bound to a block")
+	}
+
+	return ps.runWriteTx(func(tx *sql.Tx) error {
+		if err := fn(&PostgresStore{db: ps.db, tx: tx}); err != nil {
+			return err
+		}
+
+		return pgSaveProcessedBlock(tx, height, blockHash)
+	})
+}
+
+// Update runs fn in a single write transaction. The store passed to fn is
+// bound to the transaction, and the changes are committed only if fn succeeds
+func (ps *PostgresStore) Update(fn func(bs Store) error) error {
+	return ps.update(func(tx *sql.Tx) error {
+		return fn(&PostgresStore{db: ps.db, tx: tx})
+	})
+}
+
+// update runs f in a write transaction, or in the block transaction if the
+// store is bound to a block
+func (ps *PostgresStore) update(f func(tx *sql.Tx) error) error {
+	if ps.tx != nil {
+		return f(ps.tx)
+	}
+
+	return ps.runWriteTx(f)
+}
+
+// view runs f in a read transaction, or in the block transaction if the
+// store is bound to a block
+func (ps *PostgresStore) view(f func(tx *sql.Tx) error) error {
+	if ps.tx != nil {
+		return f(ps.tx)
+	}
+
+	return ps.runTx(true, f)
+}
+
+// runTx runs f in a new transaction, which is committed only if f succeeds.
+// The read transactions see a single snapshot of the database
+func (ps *PostgresStore) runTx(readOnly bool, f func(tx *sql.Tx) error) error {
+	opts := &sql.TxOptions{}
+	if readOnly {
+		opts = &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}
+	}
+
+	tx, err := ps.db.BeginTx(context.Background(), opts)
+	if err != nil {
+		return err
+	}
+
+	if err := f(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// runWriteTx runs f in a new write transaction. The write transactions are
+// serialized by locking the row of the indexer state, as the bolt database
+// allows a single writer
+func (ps *PostgresStore) runWriteTx(f func(tx *sql.Tx) error) error {
+	return ps.runTx(false, func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`SELECT id FROM indexer_state WHERE id = 1 FOR UPDATE`); err != nil {
+			return err
+		}
+
+		return f(tx)
+	})
+}
+
+func (ps *PostgresStore) GetConfirmedTvl() (uint64, error) {
+	var confirmedTvl int64
+
+	err := ps.view(func(tx *sql.Tx) error {
+		return tx.QueryRow(`SELECT confirmed_tvl FROM indexer_state WHERE id = 1`).Scan(&confirmedTvl)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return uint64(confirmedTvl), nil
+}
+
+func pgIncrementConfirmedTvl(tx *sql.Tx, tvlIncrement uint64) error {
+	_, err := tx.Exec(`UPDATE indexer_state SET confirmed_tvl = confirmed_tvl + $1 WHERE id = 1`,
+		int64(tvlIncrement))
+
+	return err
+}
+
+// pgSubtractConfirmedTvl subtracts from the confirmed tvl
+// it returns ErrNegativeTvl if the confirmed tvl is lower than tvlSubtract
+func pgSubtractConfirmedTvl(tx *sql.Tx, tvlSubtract uint64) error {
+	res, err := tx.Exec(`UPDATE indexer_state SET confirmed_tvl = confirmed_tvl - $1
+		WHERE id = 1 AND confirmed_tvl >= $1`, int64(tvlSubtract))
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNegativeTvl
+	}
+
+	return nil
+}
+
+func (ps *PostgresStore) SaveLastProcessedHeight(height uint64) error {
+	return ps.update(func(tx *sql.Tx) error {
+		return pgPutLastProcessedHeight(tx, height)
+	})
+}
+
+func pgPutLastProcessedHeight(tx *sql.Tx, height uint64) error {
+	_, err := tx.Exec(`UPDATE indexer_state SET last_processed_height = $1 WHERE id = 1`, int64(height))
+
+	return err
+}
+
+func (ps *PostgresStore) GetLastProcessedHeight() (uint64, error) {
+	var lastProcessedHeight uint64
+
+	err := ps.view(func(tx *sql.Tx) error {
+		height, err := pgGetLastProcessedHeight(tx)
+		if err != nil {
+			return err
+		}
+
+		lastProcessedHeight = height
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return lastProcessedHeight, nil
+}
+
+func pgGetLastProcessedHeight(tx *sql.Tx) (uint64, error) {
+	var height sql.NullInt64
+	if err := tx.QueryRow(`SELECT last_processed_height FROM indexer_state WHERE id = 1`).Scan(&height); err != nil {
+		return 0, err
+	}
+	if !height.Valid {
+		return 0, ErrLastProcessedHeightNotFound
+	}
+
+	return uint64(height.Int64), nil
+}
+
+// SaveProcessedBlock records the hash of the processed block and saves the
+// block height as the last processed height
+func (ps *PostgresStore) SaveProcessedBlock(height uint64, blockHash *chainhash.Hash) error {
+	return ps.update(func(tx *sql.Tx) error {
+		return pgSaveProcessedBlock(tx, height, blockHash)
+	})
+}
+
+func pgSaveProcessedBlock(tx *sql.Tx, height uint64, blockHash *chainhash.Hash) error {
+	if _, err := tx.Exec(`INSERT INTO blocks (height, block_hash) VALUES ($1, $2)
+		ON CONFLICT (height) DO UPDATE SET block_hash = excluded.block_hash`,
+		int64(height), blockHash.CloneBytes()); err != nil {
+		return err
+	}
+
+	return pgPutLastProcessedHeight(tx, height)
+}
+
+// GetBlockJournal returns the state changes applied when processing the block
+// at the given height, in the order they were applied
+// it returns ErrBlockJournalNotFound if the block has not been processed
+func (ps *PostgresStore) GetBlockJournal(height uint64) ([]*JournalEntry, error) {
+	var entries []*JournalEntry
+
+	err := ps.view(func(tx *sql.Tx) error {
+		if err := pgCheckBlockExists(tx, height); err != nil {
+			return err
+		}
+
+		// the staking tx hash of an entry is the one of the tx it records,
+		// except for the unbonding, withdrawal, and slashing txs
+		rows, err := tx.Query(fmt.Sprintf(`SELECT j.tx_type, j.tx_hash, u.staking_tx_hash, w.staking_tx_hash, s.staking_tx_hash
+			FROM block_journal_entries j
+			LEFT JOIN unbonding_transactions u ON j.tx_type = %d AND u.tx_hash = j.tx_hash
+			LEFT JOIN withdrawal_transactions w ON j.tx_type = %d AND w.tx_hash = j.tx_hash
+			LEFT JOIN slashing_transactions s ON j.tx_type = %d AND s.tx_hash = j.tx_hash
+			WHERE j.height = $1
+			ORDER BY j.idx`, UnbondingTxType, WithdrawalTxType, SlashingTxType), int64(height))
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var (
+				txType                                int64
+				txHashBytes                           []byte
+				byUnbonding, byWithdrawal, bySlashing []byte
+			)
+			if err := rows.Scan(&txType, &txHashBytes, &byUnbonding, &byWithdrawal, &bySlashing); err != nil {
+				return err
+			}
+
+			txHash, err := chainhash.NewHash(txHashBytes)
+			if err != nil {
+				return ErrCorruptedStateDb
+			}
+			entry := &JournalEntry{
+				TxType:        TxType(txType),
+				TxHash:        txHash,
+				StakingTxHash: txHash,
+			}
+
+			var stakingTxHashBytes []byte
+			switch entry.TxType {
+			case UnbondingTxType:
+				stakingTxHashBytes = byUnbonding
+			case WithdrawalTxType:
+				stakingTxHashBytes = byWithdrawal
+			case SlashingTxType:
+				stakingTxHashBytes = bySlashing
+			}
+			if entry.TxType == UnbondingTxType || entry.TxType == WithdrawalTxType || entry.TxType == SlashingTxType {
+				if stakingTxHashBytes == nil {
+					return ErrTransactionNotFound
+				}
+				stakingTxHash, err := chainhash.NewHash(stakingTxHashBytes)
+				if err != nil {
+					return ErrCorruptedTransactionsDb
+				}
+				entry.StakingTxHash = stakingTxHash
+			}
+
+			entries = append(entries, entry)
+		}
+
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// GetBlockHash returns the hash of the processed block at the given height
+// it returns ErrBlockJournalNotFound if the block has not been processed
+func (ps *PostgresStore) GetBlockHash(height uint64) (*chainhash.Hash, error) {
+	var blockHash *chainhash.Hash
+
+	err := ps.view(func(tx *sql.Tx) error {
+		var blockHashBytes []byte
+		err := tx.QueryRow(`SELECT block_hash FROM blocks WHERE height = $1`, int64(height)).Scan(&blockHashBytes)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrBlockJournalNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		blockHash, err = chainhash.NewHash(blockHashBytes)
+		if err != nil {
+			return ErrCorruptedStateDb
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return blockHash, nil
+}
+
+// RollbackBlock undoes all the state changes applied when processing the block
+// at the given height, which must be the last processed block. The last
+// processed height is set to height - 1 afterwards.
+func (ps *PostgresStore) RollbackBlock(height uint64) error {
+	return ps.update(func(tx *sql.Tx) error {
+		lastProcessedHeight, err := pgGetLastProcessedHeight(tx)
+		if err != nil {
+			return err
+		}
+		if lastProcessedHeight != height {
+			return fmt.Errorf("%w: expected the last processed height %d, got %d",
+				ErrInvalidRollbackHeight, lastProcessedHeight, height)
+		}
+
+		if err := pgCheckBlockExists(tx, height); err != nil {
+			return err
+		}
+
+		// undo the entries in the reverse order they were applied
+		rows, err := tx.Query(`SELECT tx_type, tx_hash FROM block_journal_entries
+			WHERE height = $1 ORDER BY idx DESC`, int64(height))
+		if err != nil {
+			return err
+		}
+		type journalEntry struct {
+			txType      TxType
+			txHashBytes []byte
+		}
+		var entries []journalEntry
+		for rows.Next() {
+			var e journalEntry
+			if err := rows.Scan(&e.txType, &e.txHashBytes); err != nil {
+				rows.Close()
+				return err
+			}
+			entries = append(entries, e)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, e := range entries {
+			if err := pgUndoJournalEntry(tx, e.txType, e.txHashBytes); err != nil {
+				return err
+			}
+		}
+
+		if _, err := tx.Exec(`DELETE FROM block_journal_entries WHERE height = $1`, int64(height)); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`DELETE FROM blocks WHERE height = $1`, int64(height)); err != nil {
+			return err
+		}
+
+		return pgPutLastProcessedHeight(tx, height-1)
+	})
+}
+
+func pgUndoJournalEntry(tx *sql.Tx, txType TxType, txHashBytes []byte) error {
+	switch txType {
+	case StakingTxType:
+		var inclusionHeight, stakingTime, stakingValue int64
+		var isOverflow bool
+		err := tx.QueryRow(`SELECT inclusion_height, staking_time, staking_value, is_overflow
+			FROM staking_transactions WHERE tx_hash = $1`, txHashBytes).
+			Scan(&inclusionHeight, &stakingTime, &stakingValue, &isOverflow)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrTransactionNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(`DELETE FROM delegation_state_transitions WHERE staking_tx_hash = $1`, txHashBytes); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`DELETE FROM staking_transactions WHERE tx_hash = $1`, txHashBytes); err != nil {
+			return err
+		}
+		if err := pgDeleteTimelockExpiry(tx, uint64(inclusionHeight+stakingTime), txHashBytes); err != nil {
+			return err
+		}
+
+		// overflow staking txs were never counted in the confirmed tvl
+		if isOverflow {
+			return nil
+		}
+		return pgSubtractConfirmedTvl(tx, uint64(stakingValue))
+
+	case UnbondingTxType:
+		var stakingTxHashBytes []byte
+		var inclusionHeight, unbondingTime int64
+		err := tx.QueryRow(`SELECT staking_tx_hash, inclusion_height, unbonding_time
+			FROM unbonding_transactions WHERE tx_hash = $1`, txHashBytes).
+			Scan(&stakingTxHashBytes, &inclusionHeight, &unbondingTime)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrTransactionNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(`DELETE FROM unbonding_transactions WHERE tx_hash = $1`, txHashBytes); err != nil {
+			return err
+		}
+		if err := pgDeleteTimelockExpiry(tx, uint64(inclusionHeight+unbondingTime), txHashBytes); err != nil {
+			return err
+		}
+
+		// the unbonding of an overflow staking tx never changed the confirmed tvl
+		return pgRestoreConfirmedStake(tx, stakingTxHashBytes)
+
+	case InvalidStakingTxType:
+		return pgDeleteTx(tx, "invalid_staking_transactions", txHashBytes)
+
+	case WithdrawalTxType:
+		// withdrawal txs never changed the confirmed tvl
+		return pgDeleteTx(tx, "withdrawal_transactions", txHashBytes)
+
+	case SlashingTxType:
+		var stakingTxHashBytes, unbondingTxHashBytes []byte
+		err := tx.QueryRow(`SELECT staking_tx_hash, unbonding_tx_hash
+			FROM slashing_transactions WHERE tx_hash = $1`, txHashBytes).
+			Scan(&stakingTxHashBytes, &unbondingTxHashBytes)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrTransactionNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(`DELETE FROM slashing_transactions WHERE tx_hash = $1`, txHashBytes); err != nil {
+			return err
+		}
+
+		// the slashing of an unbonding tx never changed the confirmed tvl
+		if unbondingTxHashBytes != nil {
+			return nil
+		}
+
+		// the slashing of an overflow staking tx never changed the confirmed tvl
+		return pgRestoreConfirmedStake(tx, stakingTxHashBytes)
+
+	case StateTransitionType:
+		return pgUndoStateTransition(tx, txHashBytes)
+
+	default:
+		return fmt.Errorf("%w: unknown journal entry type %d", ErrCorruptedStateDb, txType)
+	}
+}
+
+// pgRestoreConfirmedStake adds the value of the given staking tx back to the
+// confirmed tvl, unless it is an overflow
+func pgRestoreConfirmedStake(tx *sql.Tx, stakingTxHashBytes []byte) error {
+	var stakingValue int64
+	var isOverflow bool
+	err := tx.QueryRow(`SELECT staking_value, is_overflow FROM staking_transactions WHERE tx_hash = $1`,
+		stakingTxHashBytes).Scan(&stakingValue, &isOverflow)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrTransactionNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if isOverflow {
+		return nil
+	}
+	return pgIncrementConfirmedTvl(tx, uint64(stakingValue))
+}
+
+// pgDeleteTx deletes the tx with the given hash from the given table
+// it returns ErrTransactionNotFound if the tx is not stored
+func pgDeleteTx(tx *sql.Tx, table string, txHashBytes []byte) error {
+	res, err := tx.Exec(fmt.Sprintf(`DELETE FROM %s WHERE tx_hash = $1`, table), txHashBytes)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrTransactionNotFound
+	}
+
+	return nil
+}
+
+// pgAppendJournalEntry records a state change in the journal of the block at
+// the given height
+func pgAppendJournalEntry(tx *sql.Tx, height uint64, txType TxType, txHashBytes []byte) error {
+	if _, err := tx.Exec(`INSERT INTO blocks (height) VALUES ($1) ON CONFLICT (height) DO NOTHING`,
+		int64(height)); err != nil {
+		return err
+	}
+
+	var numEntries int64
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM block_journal_entries WHERE height = $1`,
+		int64(height)).Scan(&numEntries); err != nil {
+		return err
+	}
+
+	_, err := tx.Exec(`INSERT INTO block_journal_entries (height, idx, tx_type, tx_hash) VALUES ($1, $2, $3, $4)`,
+		int64(height), numEntries, int64(txType), txHashBytes)
+
+	return err
+}
+
+// pgCheckBlockExists returns ErrBlockJournalNotFound if the block at the
+// given height has no journal
+func pgCheckBlockExists(tx *sql.Tx, height uint64) error {
+	var exists bool
+	if err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM blocks WHERE height = $1)`,
+		int64(height)).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return ErrBlockJournalNotFound
+	}
+
+	return nil
+}
+
+// AddOutboxEntry appends an event caused by the block with the given height
+// and hash to the outbox. The event is committed together with the state
+// changes if the store is bound to a transaction
+func (ps *PostgresStore) AddOutboxEntry(
+	eventType uint32,
+	payload []byte,
+	blockHeight uint64,
+	blockHash *chainhash.Hash,
+) error {
+	return ps.update(func(tx *sql.Tx) error {
+		// the sequence is kept in the indexer state rather than in a
+		// Postgres sequence, so that it has no gap if the transaction fails
+		var seq int64
+		if err := tx.QueryRow(`UPDATE indexer_state SET outbox_sequence = outbox_sequence + 1
+			WHERE id = 1 RETURNING outbox_sequence`).Scan(&seq); err != nil {
+			return err
+		}
+
+		var blockHashBytes []byte
+		if blockHash != nil {
+			blockHashBytes = blockHash.CloneBytes()
+		}
+		if payload == nil {
+			payload = []byte{}
+		}
+
+		_, err := tx.Exec(`INSERT INTO outbox (sequence, event_type, payload, block_height, block_hash, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6)`,
+			seq, int64(eventType), payload, int64(blockHeight), blockHashBytes, time.Now().Unix())
+
+		return err
+	})
+}
+
+// GetUndeliveredOutboxEntries returns at most limit events that are not
+// delivered yet, in the order they were added
+func (ps *PostgresStore) GetUndeliveredOutboxEntries(limit int) ([]*OutboxEntry, error) {
+	var entries []*OutboxEntry
+	if limit <= 0 {
+		return nil, nil
+	}
+
+	err := ps.view(func(tx *sql.Tx) error {
+		var err error
+		entries, err = pgQueryOutboxEntries(tx, fmt.Sprintf(`WHERE NOT delivered AND sequence >
+			(SELECT last_delivered_outbox_sequence FROM indexer_state WHERE id = 1)
+			ORDER BY sequence LIMIT %d`, limit))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// GetOutboxEntries returns at most limit events starting from the one with
+// the given sequence, in the order they were added, whether they are
+// delivered or not
+func (ps *PostgresStore) GetOutboxEntries(fromSeq uint64, limit int) ([]*OutboxEntry, error) {
+	var entries []*OutboxEntry
+	if limit <= 0 {
+		return nil, nil
+	}
+
+	err := ps.view(func(tx *sql.Tx) error {
+		var err error
+		entries, err = pgQueryOutboxEntries(tx,
+			fmt.Sprintf(`WHERE sequence >= $1 ORDER BY sequence LIMIT %d`, limit), int64(fromSeq))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// GetOutboxSequenceFromHeight returns the sequence of the first event in the
+// outbox caused by a block at or above the given height, or the sequence of
+// the next event if there is no such event
+func (ps *PostgresStore) GetOutboxSequenceFromHeight(height uint64) (uint64, error) {
+	var seq int64
+
+	err := ps.view(func(tx *sql.Tx) error {
+		return tx.QueryRow(`SELECT COALESCE(
+			(SELECT MIN(sequence) FROM outbox WHERE block_height >= $1), outbox_sequence + 1)
+			FROM indexer_state WHERE id = 1`, int64(height)).Scan(&seq)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return uint64(seq), nil
+}
+
+// GetOutboxStats returns the number of the undelivered events and the time
+// the oldest of them was written. As the events are delivered in order, the
+// undelivered events are the ones after the last delivered event
+func (ps *PostgresStore) GetOutboxStats() (*OutboxStats, error) {
+	var stats *OutboxStats
+
+	err := ps.view(func(tx *sql.Tx) error {
+		var lastDelivered int64
+		var lastSeq sql.NullInt64
+		if err := tx.QueryRow(`SELECT last_delivered_outbox_sequence, (SELECT MAX(sequence) FROM outbox)
+			FROM indexer_state WHERE id = 1`).Scan(&lastDelivered, &lastSeq); err != nil {
+			return err
+		}
+
+		stats = &OutboxStats{}
+		if !lastSeq.Valid || lastSeq.Int64 <= lastDelivered {
+			return nil
+		}
+		stats.NumUndelivered = uint64(lastSeq.Int64 - lastDelivered)
+
+		var createdAt int64
+		err := tx.QueryRow(`SELECT created_at FROM outbox WHERE sequence > $1 ORDER BY sequence LIMIT 1`,
+			lastDelivered).Scan(&createdAt)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if createdAt > 0 {
+			stats.OldestUndeliveredAt = time.Unix(createdAt, 0)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// MarkOutboxEntryDelivered marks the event with the given sequence delivered
+// it returns ErrOutboxEntryNotFound if the event does not exist
+func (ps *PostgresStore) MarkOutboxEntryDelivered(seq uint64) error {
+	return ps.update(func(tx *sql.Tx) error {
+		res, err := tx.Exec(`UPDATE outbox SET delivered = TRUE WHERE sequence = $1`, int64(seq))
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return ErrOutboxEntryNotFound
+		}
+
+		// the events are delivered in order, so the scan of undelivered
+		// events can start after the last delivered one
+		_, err = tx.Exec(`UPDATE indexer_state SET last_delivered_outbox_sequence = $1
+			WHERE id = 1 AND last_delivered_outbox_sequence < $1`, int64(seq))
+
+		return err
+	})
+}
+
+// PruneOutboxEntries deletes the delivered events caused by the blocks below
+// the given height, and returns the number of the deleted events. Only the
+// oldest events are deleted, i.e., the ones before the first event that is
+// not delivered or is caused by a block at or above the height, so the
+// events kept always follow each other
+func (ps *PostgresStore) PruneOutboxEntries(belowHeight uint64) (uint64, error) {
+	var numPruned uint64
+
+	err := ps.update(func(tx *sql.Tx) error {
+		res, err := tx.Exec(`DELETE FROM outbox
+			WHERE sequence <= (SELECT last_delivered_outbox_sequence FROM indexer_state WHERE id = 1)
+			AND sequence < COALESCE(
+				(SELECT MIN(sequence) FROM outbox WHERE block_height >= $1 OR NOT delivered),
+				9223372036854775807
+			)`, int64(belowHeight))
+		if err != nil {
+			return err
+		}
+
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		numPruned = uint64(n)
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return numPruned, nil
+}
+
+// pgQueryOutboxEntries returns the outbox entries selected by the given
+// clauses
+func pgQueryOutboxEntries(tx *sql.Tx, clauses string, args ...interface{}) ([]*OutboxEntry, error) {
+	rows, err := tx.Query(`SELECT sequence, event_type, payload, delivered, block_height, block_hash, created_at
+		FROM outbox `+clauses, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []*OutboxEntry
+	for rows.Next() {
+		var (
+			seq, eventType, blockHeight, createdAt int64
+			payload, blockHashBytes                []byte
+			delivered                              bool
+		)
+		if err := rows.Scan(&seq, &eventType, &payload, &delivered, &blockHeight, &blockHashBytes, &createdAt); err != nil {
+			return nil, err
+		}
+
+		entry := &OutboxEntry{
+			Sequence:    uint64(seq),
+			EventType:   uint32(eventType),
+			Payload:     payload,
+			Delivered:   delivered,
+			BlockHeight: uint64(blockHeight),
+		}
+		if createdAt > 0 {
+			entry.CreatedAt = time.Unix(createdAt, 0)
+		}
+		if len(blockHashBytes) > 0 {
+			blockHash, err := chainhash.NewHash(blockHashBytes)
+			if err != nil {
+				return nil, ErrCorruptedStateDb
+			}
+			entry.BlockHash = blockHash
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
@@ -0,0 +1,1277 @@
+package indexerstore
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+
+	"github.com/babylonlabs-io/staking-indexer/utils"
+)
+
+// pgStakingTxColumns are the columns of staking_transactions read into a
+// StoredStakingTransaction, in the order of pgScanStakingTx
+var pgStakingTxColumns = []string{
+	"tx_hash", "tx_bytes", "staking_output_idx", "inclusion_height", "staker_pk",
+	"staking_time", "finality_provider_pk", "staking_value", "is_overflow", "state",
+}
+
+func (ps *PostgresStore) AddStakingTransaction(
+	tx *wire.MsgTx,
+	stakingOutputIdx uint32,
+	inclusionHeight uint64,
+	stakerPk *btcec.PublicKey,
+	stakingTime uint32,
+	fpPk *btcec.PublicKey,
+	stakingValue uint64,
+	isOverflow bool,
+) error {
+	txHash := tx.TxHash()
+	serializedTx, err := utils.SerializeBtcTransaction(tx)
+	if err != nil {
+		return err
+	}
+
+	return ps.update(func(sqlTx *sql.Tx) error {
+		if err := pgCheckNotStored(sqlTx, "staking_transactions", txHash[:]); err != nil {
+			return err
+		}
+
+		state := initialState(isOverflow)
+		if _, err := sqlTx.Exec(`INSERT INTO staking_transactions (`+strings.Join(pgStakingTxColumns, ", ")+`)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+			txHash[:], serializedTx, int64(stakingOutputIdx), int64(inclusionHeight),
+			schnorr.SerializePubKey(stakerPk), int64(stakingTime), schnorr.SerializePubKey(fpPk),
+			int64(stakingValue), isOverflow, int64(state)); err != nil {
+			return err
+		}
+		if _, err := sqlTx.Exec(`INSERT INTO delegation_state_transitions (staking_tx_hash, idx, state, height, tx_hash)
+			VALUES ($1, 0, $2, $3, $1)`, txHash[:], int64(state), int64(inclusionHeight)); err != nil {
+			return err
+		}
+
+		if err := pgAppendJournalEntry(sqlTx, inclusionHeight, StakingTxType, txHash[:]); err != nil {
+			return err
+		}
+
+		if err := pgPutTimelockExpiry(
+			sqlTx, inclusionHeight+uint64(stakingTime), txHash[:], txHash[:],
+		); err != nil {
+			return err
+		}
+
+		// if the staking tx is an overflow, we don't increment the confirmed tvl
+		if isOverflow {
+			return nil
+		}
+		return pgIncrementConfirmedTvl(sqlTx, stakingValue)
+	})
+}
+
+// GetStakingTransaction retrieves the stored staking transaction by the given hash
+// it returns (nil, nil) if the transaction is not found
+func (ps *PostgresStore) GetStakingTransaction(txHash *chainhash.Hash) (*StoredStakingTransaction, error) {
+	var storedTx *StoredStakingTransaction
+
+	err := ps.view(func(tx *sql.Tx) error {
+		storedTxs, err := pgQueryStakingTxs(tx, "tx_hash = $1", []string{"tx_hash"}, 1, txHash.CloneBytes())
+		if err != nil {
+			return err
+		}
+		if len(storedTxs) == 1 {
+			storedTx = storedTxs[0]
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return storedTx, nil
+}
+
+// ScanStoredStakingTransactions iterates through all stored staking
+// transactions in the order of their hashes
+func (ps *PostgresStore) ScanStoredStakingTransactions(callback func(*StoredStakingTransaction) error) error {
+	return ps.view(func(tx *sql.Tx) error {
+		afterTxHash := []byte{}
+		for {
+			storedTxs, err := pgQueryStakingTxs(tx, "tx_hash > $1", []string{"tx_hash"}, postgresScanBatchSize, afterTxHash)
+			if err != nil {
+				return err
+			}
+
+			for _, storedTx := range storedTxs {
+				if err := callback(storedTx); err != nil {
+					return err
+				}
+			}
+
+			if len(storedTxs) < postgresScanBatchSize {
+				return nil
+			}
+			lastTxHash := storedTxs[len(storedTxs)-1].Tx.TxHash()
+			afterTxHash = lastTxHash[:]
+		}
+	})
+}
+
+// GetStakingTransactionsByStakerPk returns at most limit staking transactions
+// of the staker with the given public key in the order of their hashes,
+// starting after the transaction with afterTxHash. It starts from the first
+// transaction if afterTxHash is nil
+func (ps *PostgresStore) GetStakingTransactionsByStakerPk(
+	stakerPk *btcec.PublicKey,
+	afterTxHash *chainhash.Hash,
+	limit int,
+) ([]*StoredStakingTransaction, error) {
+	var storedTxs []*StoredStakingTransaction
+	if limit <= 0 {
+		return nil, nil
+	}
+
+	afterTxHashBytes := []byte{}
+	if afterTxHash != nil {
+		afterTxHashBytes = afterTxHash.CloneBytes()
+	}
+
+	err := ps.view(func(tx *sql.Tx) error {
+		var err error
+		storedTxs, err = pgQueryStakingTxs(tx, "staker_pk = $1 AND tx_hash > $2", []string{"tx_hash"}, limit,
+			schnorr.SerializePubKey(stakerPk), afterTxHashBytes)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return storedTxs, nil
+}
+
+// ScanStakingTransactionsByHeightRange iterates through the staking
+// transactions included in [startHeight, endHeight] in the order of their
+// inclusion heights, and of their hashes at the same height
+func (ps *PostgresStore) ScanStakingTransactionsByHeightRange(
+	startHeight, endHeight uint64,
+	callback func(*StoredStakingTransaction) error,
+) error {
+	return ps.view(func(tx *sql.Tx) error {
+		afterHeight, afterTxHash := startHeight, []byte{}
+		for {
+			storedTxs, err := pgQueryStakingTxsByHeight(tx, endHeight, afterHeight, afterTxHash, postgresScanBatchSize)
+			if err != nil {
+				return err
+			}
+
+			for _, storedTx := range storedTxs {
+				if err := callback(storedTx); err != nil {
+					return err
+				}
+			}
+
+			if len(storedTxs) < postgresScanBatchSize {
+				return nil
+			}
+			last := storedTxs[len(storedTxs)-1]
+			lastTxHash := last.Tx.TxHash()
+			afterHeight, afterTxHash = last.InclusionHeight, lastTxHash[:]
+		}
+	})
+}
+
+// GetStakingTransactionsByHeightRange returns at most limit staking
+// transactions included in [startHeight, endHeight] in the order of their
+// inclusion heights, and of their hashes at the same height, starting after
+// the transaction with afterTxHash. It starts from the first transaction if
+// afterTxHash is nil
+// it returns ErrTransactionNotFound if the transaction with afterTxHash is
+// not stored
+func (ps *PostgresStore) GetStakingTransactionsByHeightRange(
+	startHeight, endHeight uint64,
+	afterTxHash *chainhash.Hash,
+	limit int,
+) ([]*StoredStakingTransaction, error) {
+	var storedTxs []*StoredStakingTransaction
+
+	err := ps.view(func(tx *sql.Tx) error {
+		afterHeight, afterTxHashBytes := startHeight, []byte{}
+		if afterTxHash != nil {
+			var inclusionHeight int64
+			err := tx.QueryRow(`SELECT inclusion_height FROM staking_transactions WHERE tx_hash = $1`,
+				afterTxHash.CloneBytes()).Scan(&inclusionHeight)
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrTransactionNotFound
+			}
+			if err != nil {
+				return err
+			}
+
+			// the transactions before the start height are skipped anyway
+			if uint64(inclusionHeight) >= startHeight {
+				afterHeight, afterTxHashBytes = uint64(inclusionHeight), afterTxHash.CloneBytes()
+			}
+		}
+		if limit <= 0 {
+			return nil
+		}
+
+		var err error
+		storedTxs, err = pgQueryStakingTxsByHeight(tx, endHeight, afterHeight, afterTxHashBytes, limit)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return storedTxs, nil
+}
+
+// pgQueryStakingTxsByHeight returns at most limit staking txs included up to
+// the end height, after the tx with the given height and hash in the order of
+// the height index. An empty hash selects the txs from the given height
+func pgQueryStakingTxsByHeight(
+	tx *sql.Tx,
+	endHeight uint64,
+	afterHeight uint64,
+	afterTxHashBytes []byte,
+	limit int,
+) ([]*StoredStakingTransaction, error) {
+	return pgQueryStakingTxs(tx,
+		"inclusion_height <= $1 AND (inclusion_height > $2 OR (inclusion_height = $2 AND tx_hash > $3))",
+		[]string{"inclusion_height", "tx_hash"}, limit,
+		int64(endHeight), int64(afterHeight), afterTxHashBytes)
+}
+
+// pgQueryStakingTxs returns at most limit staking txs matching the given
+// condition in the given order of the columns, together with their state
+// transitions
+func pgQueryStakingTxs(
+	tx *sql.Tx,
+	cond string,
+	orderColumns []string,
+	limit int,
+	args ...interface{},
+) ([]*StoredStakingTransaction, error) {
+	query := fmt.Sprintf(`SELECT s.%s, t.state, t.height, t.tx_hash
+		FROM (SELECT %s FROM staking_transactions WHERE %s ORDER BY %s LIMIT %d) s
+		LEFT JOIN delegation_state_transitions t ON t.staking_tx_hash = s.tx_hash
+		ORDER BY s.%s, t.idx`,
+		strings.Join(pgStakingTxColumns, ", s."),
+		strings.Join(pgStakingTxColumns, ", "),
+		cond,
+		strings.Join(orderColumns, ", "),
+		limit,
+		strings.Join(orderColumns, ", s."),
+	)
+
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var (
+		storedTxs    []*StoredStakingTransaction
+		lastTxHash   []byte
+		lastStoredTx *StoredStakingTransaction
+	)
+	for rows.Next() {
+		var (
+			txHash, txBytes, stakerPk, fpPk                       []byte
+			stakingOutputIdx, inclusionHeight, stakingTime, value int64
+			isOverflow                                            bool
+			state                                                 int64
+			transitionState, transitionHeight                     sql.NullInt64
+			transitionTxHash                                      []byte
+		)
+		if err := rows.Scan(
+			&txHash, &txBytes, &stakingOutputIdx, &inclusionHeight, &stakerPk,
+			&stakingTime, &fpPk, &value, &isOverflow, &state,
+			&transitionState, &transitionHeight, &transitionTxHash,
+		); err != nil {
+			return nil, err
+		}
+
+		// the rows of a staking tx follow each other, one per transition
+		if lastStoredTx == nil || !bytes.Equal(txHash, lastTxHash) {
+			var stakingTx wire.MsgTx
+			if err := stakingTx.Deserialize(bytes.NewReader(txBytes)); err != nil {
+				return nil, fmt.Errorf("invalid staking tx: %w", err)
+			}
+			stakerPubKey, err := schnorr.ParsePubKey(stakerPk)
+			if err != nil {
+				return nil, fmt.Errorf("invalid staker pk: %w", err)
+			}
+			fpPubKey, err := schnorr.ParsePubKey(fpPk)
+			if err != nil {
+				return nil, fmt.Errorf("invalid finality provider pk: %w", err)
+			}
+
+			lastStoredTx = &StoredStakingTransaction{
+				Tx:                 &stakingTx,
+				StakingOutputIdx:   uint32(stakingOutputIdx),
+				InclusionHeight:    uint64(inclusionHeight),
+				StakerPk:           stakerPubKey,
+				StakingTime:        uint32(stakingTime),
+				FinalityProviderPk: fpPubKey,
+				IsOverflow:         isOverflow,
+				StakingValue:       uint64(value),
+				State:              DelegationState(state),
+				StateTransitions:   []*StateTransition{},
+			}
+			lastTxHash = txHash
+			storedTxs = append(storedTxs, lastStoredTx)
+		}
+
+		if !transitionState.Valid {
+			continue
+		}
+		transitionTx, err := chainhash.NewHash(transitionTxHash)
+		if err != nil {
+			return nil, fmt.Errorf("invalid state transition tx hash: %w", err)
+		}
+		lastStoredTx.StateTransitions = append(lastStoredTx.StateTransitions, &StateTransition{
+			State:  DelegationState(transitionState.Int64),
+			Height: uint64(transitionHeight.Int64),
+			TxHash: transitionTx,
+		})
+	}
+
+	return storedTxs, rows.Err()
+}
+
+// AddUnbondingTransaction saves an unbonding tx that spends the output of the
+// given staking tx. The unbonding output can be withdrawn unbondingTime
+// blocks after the inclusion height
+func (ps *PostgresStore) AddUnbondingTransaction(
+	tx *wire.MsgTx,
+	stakingTxHash *chainhash.Hash,
+	inclusionHeight uint64,
+	unbondingTime uint32,
+) error {
+	txHash := tx.TxHash()
+	serializedTx, err := utils.SerializeBtcTransaction(tx)
+	if err != nil {
+		return err
+	}
+	stakingTxHashBytes := stakingTxHash.CloneBytes()
+
+	return ps.update(func(sqlTx *sql.Tx) error {
+		// we need to ensure the staking tx already exists
+		var stakingValue int64
+		var isOverflow bool
+		err := sqlTx.QueryRow(`SELECT staking_value, is_overflow FROM staking_transactions WHERE tx_hash = $1`,
+			stakingTxHashBytes).Scan(&stakingValue, &isOverflow)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrTransactionNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := pgCheckNotStored(sqlTx, "unbonding_transactions", txHash[:]); err != nil {
+			return err
+		}
+
+		if _, err := sqlTx.Exec(`INSERT INTO unbonding_transactions
+			(tx_hash, tx_bytes, staking_tx_hash, inclusion_height, unbonding_time) VALUES ($1, $2, $3, $4, $5)`,
+			txHash[:], serializedTx, stakingTxHashBytes, int64(inclusionHeight), int64(unbondingTime)); err != nil {
+			return err
+		}
+
+		if err := pgAppendJournalEntry(sqlTx, inclusionHeight, UnbondingTxType, txHash[:]); err != nil {
+			return err
+		}
+
+		if err := pgTransitionState(
+			sqlTx, stakingTxHashBytes, StateUnbonding, inclusionHeight, txHash[:],
+		); err != nil {
+			return err
+		}
+
+		if err := pgPutTimelockExpiry(
+			sqlTx, inclusionHeight+uint64(unbondingTime), txHash[:], stakingTxHashBytes,
+		); err != nil {
+			return err
+		}
+
+		// if the staking tx is an overflow, we don't decrement the confirmed tvl
+		// as it was never added
+		if isOverflow {
+			return nil
+		}
+		return pgSubtractConfirmedTvl(sqlTx, uint64(stakingValue))
+	})
+}
+
+// GetUnbondingTransaction retrieves the stored unbonding transaction by the given hash
+// it returns (nil, nil) if the transaction is not found
+func (ps *PostgresStore) GetUnbondingTransaction(txHash *chainhash.Hash) (*StoredUnbondingTransaction, error) {
+	var storedTx *StoredUnbondingTransaction
+
+	err := ps.view(func(tx *sql.Tx) error {
+		storedTxs, err := pgQueryUnbondingTxs(tx, "tx_hash = $1", 1, txHash.CloneBytes())
+		if err != nil {
+			return err
+		}
+		if len(storedTxs) == 1 {
+			storedTx = storedTxs[0]
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return storedTx, nil
+}
+
+// GetUnbondingTransactionByStakingTxHash retrieves the stored unbonding
+// transaction spending the staking transaction with the given hash
+// it returns (nil, nil) if the staking transaction is not unbonded early
+func (ps *PostgresStore) GetUnbondingTransactionByStakingTxHash(stakingTxHash *chainhash.Hash) (*StoredUnbondingTransaction, error) {
+	var storedTx *StoredUnbondingTransaction
+
+	err := ps.view(func(tx *sql.Tx) error {
+		// the unbonding tx is the one moving the delegation to unbonding
+		var unbondingTxHash []byte
+		err := tx.QueryRow(`SELECT tx_hash FROM delegation_state_transitions
+			WHERE staking_tx_hash = $1 AND state = $2 ORDER BY idx DESC LIMIT 1`,
+			stakingTxHash.CloneBytes(), int64(StateUnbonding)).Scan(&unbondingTxHash)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		storedTxs, err := pgQueryUnbondingTxs(tx, "tx_hash = $1", 1, unbondingTxHash)
+		if err != nil {
+			return err
+		}
+		if len(storedTxs) == 1 {
+			storedTx = storedTxs[0]
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return storedTx, nil
+}
+
+// ScanStoredUnbondingTransactions iterates through all stored unbonding
+// transactions in the order of their hashes
+func (ps *PostgresStore) ScanStoredUnbondingTransactions(callback func(*StoredUnbondingTransaction) error) error {
+	return ps.view(func(tx *sql.Tx) error {
+		afterTxHash := []byte{}
+		for {
+			storedTxs, err := pgQueryUnbondingTxs(tx, "tx_hash > $1", postgresScanBatchSize, afterTxHash)
+			if err != nil {
+				return err
+			}
+
+			for _, storedTx := range storedTxs {
+				if err := callback(storedTx); err != nil {
+					return err
+				}
+			}
+
+			if len(storedTxs) < postgresScanBatchSize {
+				return nil
+			}
+			lastTxHash := storedTxs[len(storedTxs)-1].Tx.TxHash()
+			afterTxHash = lastTxHash[:]
+		}
+	})
+}
+
+// pgQueryUnbondingTxs returns at most limit unbonding txs matching the given
+// condition in the order of their hashes
+func pgQueryUnbondingTxs(tx *sql.Tx, cond string, limit int, args ...interface{}) ([]*StoredUnbondingTransaction, error) {
+	rows, err := tx.Query(fmt.Sprintf(`SELECT tx_bytes, staking_tx_hash, inclusion_height, unbonding_time
+		FROM unbonding_transactions WHERE %s ORDER BY tx_hash LIMIT %d`, cond, limit), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var storedTxs []*StoredUnbondingTransaction
+	for rows.Next() {
+		var txBytes, stakingTxHashBytes []byte
+		var inclusionHeight, unbondingTime int64
+		if err := rows.Scan(&txBytes, &stakingTxHashBytes, &inclusionHeight, &unbondingTime); err != nil {
+			return nil, err
+		}
+
+		var unbondingTx wire.MsgTx
+		if err := unbondingTx.Deserialize(bytes.NewReader(txBytes)); err != nil {
+			return nil, fmt.Errorf("invalid unbonding tx: %w", err)
+		}
+		stakingTxHash, err := chainhash.NewHash(stakingTxHashBytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid staking tx hash")
+		}
+
+		storedTxs = append(storedTxs, &StoredUnbondingTransaction{
+			Tx:              &unbondingTx,
+			StakingTxHash:   stakingTxHash,
+			InclusionHeight: uint64(inclusionHeight),
+			UnbondingTime:   uint32(unbondingTime),
+		})
+	}
+
+	return storedTxs, rows.Err()
+}
+
+// AddWithdrawalTransaction saves a withdrawal tx that spends the output of the
+// given staking tx, or of the given unbonding tx if it is not nil
+func (ps *PostgresStore) AddWithdrawalTransaction(
+	tx *wire.MsgTx,
+	stakingTxHash *chainhash.Hash,
+	unbondingTxHash *chainhash.Hash,
+	inclusionHeight uint64,
+) error {
+	txHash := tx.TxHash()
+	serializedTx, err := utils.SerializeBtcTransaction(tx)
+	if err != nil {
+		return err
+	}
+	stakingTxHashBytes := stakingTxHash.CloneBytes()
+	var unbondingTxHashBytes []byte
+	if unbondingTxHash != nil {
+		unbondingTxHashBytes = unbondingTxHash.CloneBytes()
+	}
+
+	return ps.update(func(sqlTx *sql.Tx) error {
+		// we need to ensure the spent txs already exist
+		if err := pgCheckStored(sqlTx, "staking_transactions", stakingTxHashBytes); err != nil {
+			return err
+		}
+		if unbondingTxHashBytes != nil {
+			if err := pgCheckStored(sqlTx, "unbonding_transactions", unbondingTxHashBytes); err != nil {
+				return err
+			}
+		}
+
+		if err := pgCheckNotStored(sqlTx, "withdrawal_transactions", txHash[:]); err != nil {
+			return err
+		}
+
+		if _, err := sqlTx.Exec(`INSERT INTO withdrawal_transactions
+			(tx_hash, tx_bytes, staking_tx_hash, unbonding_tx_hash, inclusion_height) VALUES ($1, $2, $3, $4, $5)`,
+			txHash[:], serializedTx, stakingTxHashBytes, unbondingTxHashBytes, int64(inclusionHeight)); err != nil {
+			return err
+		}
+
+		if err := pgAppendJournalEntry(sqlTx, inclusionHeight, WithdrawalTxType, txHash[:]); err != nil {
+			return err
+		}
+
+		// withdrawal txs do not change the confirmed tvl. The output of an
+		// unbonding tx was subtracted by the unbonding tx, while the stake of a
+		// withdrawn staking output stays counted as the timelock expiry never
+		// changes the confirmed tvl
+		return pgTransitionState(sqlTx, stakingTxHashBytes, StateWithdrawn, inclusionHeight, txHash[:])
+	})
+}
+
+// GetWithdrawalTransaction retrieves the stored withdrawal transaction by the given hash
+// it returns (nil, nil) if the transaction is not found
+func (ps *PostgresStore) GetWithdrawalTransaction(txHash *chainhash.Hash) (*StoredWithdrawalTransaction, error) {
+	var storedTx *StoredWithdrawalTransaction
+
+	err := ps.view(func(tx *sql.Tx) error {
+		storedTxs, err := pgQueryWithdrawalTxs(tx, "tx_hash = $1", 1, txHash.CloneBytes())
+		if err != nil {
+			return err
+		}
+		if len(storedTxs) == 1 {
+			storedTx = storedTxs[0]
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return storedTx, nil
+}
+
+// GetWithdrawalTransactionByStakingTxHash retrieves the stored withdrawal
+// transaction of the delegation with the given staking tx hash
+// it returns (nil, nil) if the delegation has not been withdrawn
+func (ps *PostgresStore) GetWithdrawalTransactionByStakingTxHash(stakingTxHash *chainhash.Hash) (*StoredWithdrawalTransaction, error) {
+	var storedTx *StoredWithdrawalTransaction
+
+	err := ps.view(func(tx *sql.Tx) error {
+		storedTxs, err := pgQueryWithdrawalTxs(tx, "staking_tx_hash = $1", 1, stakingTxHash.CloneBytes())
+		if err != nil {
+			return err
+		}
+		if len(storedTxs) == 1 {
+			storedTx = storedTxs[0]
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return storedTx, nil
+}
+
+// ScanStoredWithdrawalTransactions iterates through all stored withdrawal
+// transactions in the order of their hashes
+func (ps *PostgresStore) ScanStoredWithdrawalTransactions(callback func(*StoredWithdrawalTransaction) error) error {
+	return ps.view(func(tx *sql.Tx) error {
+		afterTxHash := []byte{}
+		for {
+			storedTxs, err := pgQueryWithdrawalTxs(tx, "tx_hash > $1", postgresScanBatchSize, afterTxHash)
+			if err != nil {
+				return err
+			}
+
+			for _, storedTx := range storedTxs {
+				if err := callback(storedTx); err != nil {
+					return err
+				}
+			}
+
+			if len(storedTxs) < postgresScanBatchSize {
+				return nil
+			}
+			lastTxHash := storedTxs[len(storedTxs)-1].Tx.TxHash()
+			afterTxHash = lastTxHash[:]
+		}
+	})
+}
+
+// pgQueryWithdrawalTxs returns at most limit withdrawal txs matching the
+// given condition in the order of their hashes
+func pgQueryWithdrawalTxs(tx *sql.Tx, cond string, limit int, args ...interface{}) ([]*StoredWithdrawalTransaction, error) {
+	rows, err := tx.Query(fmt.Sprintf(`SELECT tx_bytes, staking_tx_hash, unbonding_tx_hash, inclusion_height
+		FROM withdrawal_transactions WHERE %s ORDER BY tx_hash LIMIT %d`, cond, limit), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var storedTxs []*StoredWithdrawalTransaction
+	for rows.Next() {
+		var txBytes, stakingTxHashBytes, unbondingTxHashBytes []byte
+		var inclusionHeight int64
+		if err := rows.Scan(&txBytes, &stakingTxHashBytes, &unbondingTxHashBytes, &inclusionHeight); err != nil {
+			return nil, err
+		}
+
+		var withdrawalTx wire.MsgTx
+		if err := withdrawalTx.Deserialize(bytes.NewReader(txBytes)); err != nil {
+			return nil, fmt.Errorf("invalid withdrawal tx: %w", err)
+		}
+		stakingTxHash, unbondingTxHash, err := pgSpentTxHashes(stakingTxHashBytes, unbondingTxHashBytes)
+		if err != nil {
+			return nil, err
+		}
+
+		storedTxs = append(storedTxs, &StoredWithdrawalTransaction{
+			Tx:              &withdrawalTx,
+			StakingTxHash:   stakingTxHash,
+			UnbondingTxHash: unbondingTxHash,
+			InclusionHeight: uint64(inclusionHeight),
+		})
+	}
+
+	return storedTxs, rows.Err()
+}
+
+// AddSlashingTransaction saves a slashing tx that spends the output of the
+// given staking tx, or of the given unbonding tx if it is not nil.
+// The confirmed tvl is decremented if the slashing tx spends the output of
+// a staking tx that is not overflow, while the slashing of an unbonding
+// tx does not change the confirmed tvl as it was decremented by unbonding
+func (ps *PostgresStore) AddSlashingTransaction(
+	tx *wire.MsgTx,
+	stakingTxHash *chainhash.Hash,
+	unbondingTxHash *chainhash.Hash,
+	inclusionHeight uint64,
+) error {
+	txHash := tx.TxHash()
+	serializedTx, err := utils.SerializeBtcTransaction(tx)
+	if err != nil {
+		return err
+	}
+	stakingTxHashBytes := stakingTxHash.CloneBytes()
+	var unbondingTxHashBytes []byte
+	if unbondingTxHash != nil {
+		unbondingTxHashBytes = unbondingTxHash.CloneBytes()
+	}
+
+	return ps.update(func(sqlTx *sql.Tx) error {
+		// we need to ensure the staking tx already exists
+		var stakingValue int64
+		var isOverflow bool
+		err := sqlTx.QueryRow(`SELECT staking_value, is_overflow FROM staking_transactions WHERE tx_hash = $1`,
+			stakingTxHashBytes).Scan(&stakingValue, &isOverflow)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrTransactionNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		isFromUnbonding := unbondingTxHashBytes != nil
+		if isFromUnbonding {
+			if err := pgCheckStored(sqlTx, "unbonding_transactions", unbondingTxHashBytes); err != nil {
+				return err
+			}
+		}
+
+		if err := pgCheckNotStored(sqlTx, "slashing_transactions", txHash[:]); err != nil {
+			return err
+		}
+
+		if _, err := sqlTx.Exec(`INSERT INTO slashing_transactions
+			(tx_hash, tx_bytes, staking_tx_hash, unbonding_tx_hash, inclusion_height) VALUES ($1, $2, $3, $4, $5)`,
+			txHash[:], serializedTx, stakingTxHashBytes, unbondingTxHashBytes, int64(inclusionHeight)); err != nil {
+			return err
+		}
+
+		if err := pgAppendJournalEntry(sqlTx, inclusionHeight, SlashingTxType, txHash[:]); err != nil {
+			return err
+		}
+
+		if err := pgTransitionState(
+			sqlTx, stakingTxHashBytes, StateSlashed, inclusionHeight, txHash[:],
+		); err != nil {
+			return err
+		}
+
+		// the tvl was already decremented when the staking tx was unbonded,
+		// or was never incremented if the staking tx is an overflow
+		if isFromUnbonding || isOverflow {
+			return nil
+		}
+		return pgSubtractConfirmedTvl(sqlTx, uint64(stakingValue))
+	})
+}
+
+// GetSlashingTransaction retrieves the stored slashing transaction by the given hash
+// it returns (nil, nil) if the transaction is not found
+func (ps *PostgresStore) GetSlashingTransaction(txHash *chainhash.Hash) (*StoredSlashingTransaction, error) {
+	var storedTx *StoredSlashingTransaction
+
+	err := ps.view(func(tx *sql.Tx) error {
+		var txBytes, stakingTxHashBytes, unbondingTxHashBytes []byte
+		var inclusionHeight int64
+		err := tx.QueryRow(`SELECT tx_bytes, staking_tx_hash, unbonding_tx_hash, inclusion_height
+			FROM slashing_transactions WHERE tx_hash = $1`, txHash.CloneBytes()).
+			Scan(&txBytes, &stakingTxHashBytes, &unbondingTxHashBytes, &inclusionHeight)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var slashingTx wire.MsgTx
+		if err := slashingTx.Deserialize(bytes.NewReader(txBytes)); err != nil {
+			return fmt.Errorf("invalid slashing tx: %w", err)
+		}
+		stakingTxHash, unbondingTxHash, err := pgSpentTxHashes(stakingTxHashBytes, unbondingTxHashBytes)
+		if err != nil {
+			return err
+		}
+
+		storedTx = &StoredSlashingTransaction{
+			Tx:              &slashingTx,
+			StakingTxHash:   stakingTxHash,
+			UnbondingTxHash: unbondingTxHash,
+			InclusionHeight: uint64(inclusionHeight),
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return storedTx, nil
+}
+
+// pgSpentTxHashes parses the hashes of the txs spent by a withdrawal or
+// slashing tx, the unbonding tx hash is nil if it spends the staking tx
+func pgSpentTxHashes(stakingTxHashBytes, unbondingTxHashBytes []byte) (*chainhash.Hash, *chainhash.Hash, error) {
+	stakingTxHash, err := chainhash.NewHash(stakingTxHashBytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid staking tx hash")
+	}
+
+	if unbondingTxHashBytes == nil {
+		return stakingTxHash, nil, nil
+	}
+	unbondingTxHash, err := chainhash.NewHash(unbondingTxHashBytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid unbonding tx hash")
+	}
+
+	return stakingTxHash, unbondingTxHash, nil
+}
+
+// AddInvalidStakingTransaction saves a staking tx that does not follow the
+// global parameters together with the reason why it is invalid
+// it returns ErrDuplicateTransaction if the tx is already stored
+func (ps *PostgresStore) AddInvalidStakingTransaction(
+	tx *wire.MsgTx,
+	stakingOutputIdx uint32,
+	inclusionHeight uint64,
+	stakerPk *btcec.PublicKey,
+	stakingTime uint32,
+	fpPk *btcec.PublicKey,
+	stakingValue uint64,
+	reason InvalidStakingReason,
+) error {
+	txHash := tx.TxHash()
+	serializedTx, err := utils.SerializeBtcTransaction(tx)
+	if err != nil {
+		return err
+	}
+
+	return ps.update(func(sqlTx *sql.Tx) error {
+		if err := pgCheckNotStored(sqlTx, "invalid_staking_transactions", txHash[:]); err != nil {
+			return err
+		}
+
+		if _, err := sqlTx.Exec(`INSERT INTO invalid_staking_transactions (tx_hash, tx_bytes, staking_output_idx,
+			inclusion_height, staker_pk, staking_time, finality_provider_pk, staking_value, reason)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+			txHash[:], serializedTx, int64(stakingOutputIdx), int64(inclusionHeight),
+			schnorr.SerializePubKey(stakerPk), int64(stakingTime), schnorr.SerializePubKey(fpPk),
+			int64(stakingValue), int64(reason)); err != nil {
+			return err
+		}
+
+		// invalid staking txs never change the confirmed tvl
+		return pgAppendJournalEntry(sqlTx, inclusionHeight, InvalidStakingTxType, txHash[:])
+	})
+}
+
+// GetInvalidStakingTransaction retrieves the stored invalid staking transaction
+// by the given hash
+// it returns (nil, nil) if the transaction is not found
+func (ps *PostgresStore) GetInvalidStakingTransaction(txHash *chainhash.Hash) (*StoredInvalidStakingTransaction, error) {
+	var storedTx *StoredInvalidStakingTransaction
+
+	err := ps.view(func(tx *sql.Tx) error {
+		var (
+			txBytes, stakerPk, fpPk                                       []byte
+			stakingOutputIdx, inclusionHeight, stakingTime, value, reason int64
+		)
+		err := tx.QueryRow(`SELECT tx_bytes, staking_output_idx, inclusion_height, staker_pk, staking_time,
+			finality_provider_pk, staking_value, reason FROM invalid_staking_transactions WHERE tx_hash = $1`,
+			txHash.CloneBytes()).Scan(
+			&txBytes, &stakingOutputIdx, &inclusionHeight, &stakerPk, &stakingTime, &fpPk, &value, &reason,
+		)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var stakingTx wire.MsgTx
+		if err := stakingTx.Deserialize(bytes.NewReader(txBytes)); err != nil {
+			return fmt.Errorf("invalid staking tx: %w", err)
+		}
+		stakerPubKey, err := schnorr.ParsePubKey(stakerPk)
+		if err != nil {
+			return fmt.Errorf("invalid staker pk: %w", err)
+		}
+		fpPubKey, err := schnorr.ParsePubKey(fpPk)
+		if err != nil {
+			return fmt.Errorf("invalid finality provider pk: %w", err)
+		}
+
+		storedTx = &StoredInvalidStakingTransaction{
+			Tx:                 &stakingTx,
+			StakingOutputIdx:   uint32(stakingOutputIdx),
+			InclusionHeight:    uint64(inclusionHeight),
+			StakerPk:           stakerPubKey,
+			StakingTime:        uint32(stakingTime),
+			FinalityProviderPk: fpPubKey,
+			StakingValue:       uint64(value),
+			Reason:             InvalidStakingReason(reason),
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return storedTx, nil
+}
+
+// TxExists returns whether the tx with the given hash is stored in any of
+// the transaction tables
+func (ps *PostgresStore) TxExists(txHash *chainhash.Hash) (bool, error) {
+	var exists bool
+
+	err := ps.view(func(tx *sql.Tx) error {
+		return tx.QueryRow(`SELECT
+			EXISTS (SELECT 1 FROM staking_transactions WHERE tx_hash = $1) OR
+			EXISTS (SELECT 1 FROM unbonding_transactions WHERE tx_hash = $1) OR
+			EXISTS (SELECT 1 FROM withdrawal_transactions WHERE tx_hash = $1) OR
+			EXISTS (SELECT 1 FROM slashing_transactions WHERE tx_hash = $1) OR
+			EXISTS (SELECT 1 FROM invalid_staking_transactions WHERE tx_hash = $1)`,
+			txHash.CloneBytes()).Scan(&exists)
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return exists, nil
+}
+
+// pgCheckStored returns ErrTransactionNotFound if the tx with the given hash
+// is not in the given table
+func pgCheckStored(tx *sql.Tx, table string, txHashBytes []byte) error {
+	exists, err := pgTxStored(tx, table, txHashBytes)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrTransactionNotFound
+	}
+
+	return nil
+}
+
+// pgCheckNotStored returns ErrDuplicateTransaction if the tx with the given
+// hash is already in the given table
+func pgCheckNotStored(tx *sql.Tx, table string, txHashBytes []byte) error {
+	exists, err := pgTxStored(tx, table, txHashBytes)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return ErrDuplicateTransaction
+	}
+
+	return nil
+}
+
+func pgTxStored(tx *sql.Tx, table string, txHashBytes []byte) (bool, error) {
+	var exists bool
+	err := tx.QueryRow(fmt.Sprintf(`SELECT EXISTS (SELECT 1 FROM %s WHERE tx_hash = $1)`, table),
+		txHashBytes).Scan(&exists)
+
+	return exists, err
+}
+
+// TransitionDelegationState moves the delegation of the given staking tx to
+// the next state because of the given tx included at the given height
+// it returns InvalidStateTransitionError if the move is invalid
+func (ps *PostgresStore) TransitionDelegationState(
+	stakingTxHash *chainhash.Hash,
+	next DelegationState,
+	height uint64,
+	txHash *chainhash.Hash,
+) error {
+	return ps.update(func(tx *sql.Tx) error {
+		return pgTransitionState(tx, stakingTxHash.CloneBytes(), next, height, txHash.CloneBytes())
+	})
+}
+
+// CheckDelegationStateTransition checks whether the delegation of the given
+// staking tx can move to the next state because of the given tx
+// it returns nil if the move caused by the tx is already recorded, and
+// InvalidStateTransitionError if the move is invalid
+func (ps *PostgresStore) CheckDelegationStateTransition(
+	stakingTxHash *chainhash.Hash,
+	next DelegationState,
+	txHash *chainhash.Hash,
+) error {
+	return ps.view(func(tx *sql.Tx) error {
+		current, err := pgGetDelegationState(tx, stakingTxHash.CloneBytes())
+		if err != nil {
+			return err
+		}
+
+		var recorded bool
+		if err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM delegation_state_transitions
+			WHERE staking_tx_hash = $1 AND state = $2 AND tx_hash = $3)`,
+			stakingTxHash.CloneBytes(), int64(next), txHash.CloneBytes()).Scan(&recorded); err != nil {
+			return err
+		}
+		if recorded {
+			return nil
+		}
+
+		if !current.CanTransitionTo(next) {
+			return &InvalidStateTransitionError{
+				StakingTxHash: stakingTxHash,
+				From:          current,
+				To:            next,
+			}
+		}
+
+		return nil
+	})
+}
+
+// pgTransitionState moves the delegation of the given staking tx to the next
+// state and records the move in the journal of the block at the given height
+func pgTransitionState(
+	tx *sql.Tx,
+	stakingTxHashBytes []byte,
+	next DelegationState,
+	height uint64,
+	txHashBytes []byte,
+) error {
+	current, err := pgGetDelegationState(tx, stakingTxHashBytes)
+	if err != nil {
+		return err
+	}
+
+	if !current.CanTransitionTo(next) {
+		stakingTxHash, err := chainhash.NewHash(stakingTxHashBytes)
+		if err != nil {
+			return err
+		}
+		return &InvalidStateTransitionError{
+			StakingTxHash: stakingTxHash,
+			From:          current,
+			To:            next,
+		}
+	}
+
+	if _, err := tx.Exec(`UPDATE staking_transactions SET state = $1 WHERE tx_hash = $2`,
+		int64(next), stakingTxHashBytes); err != nil {
+		return err
+	}
+
+	var numTransitions int64
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM delegation_state_transitions WHERE staking_tx_hash = $1`,
+		stakingTxHashBytes).Scan(&numTransitions); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`INSERT INTO delegation_state_transitions (staking_tx_hash, idx, state, height, tx_hash)
+		VALUES ($1, $2, $3, $4, $5)`,
+		stakingTxHashBytes, numTransitions, int64(next), int64(height), txHashBytes); err != nil {
+		return err
+	}
+
+	return pgAppendJournalEntry(tx, height, StateTransitionType, stakingTxHashBytes)
+}
+
+// pgUndoStateTransition moves the delegation of the given staking tx back
+// to the state before its last move
+func pgUndoStateTransition(tx *sql.Tx, stakingTxHashBytes []byte) error {
+	rows, err := tx.Query(`SELECT idx, state FROM delegation_state_transitions
+		WHERE staking_tx_hash = $1 ORDER BY idx DESC LIMIT 2`, stakingTxHashBytes)
+	if err != nil {
+		return err
+	}
+	var idxs, states []int64
+	for rows.Next() {
+		var idx, state int64
+		if err := rows.Scan(&idx, &state); err != nil {
+			rows.Close()
+			return err
+		}
+		idxs = append(idxs, idx)
+		states = append(states, state)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// the first transition is the initial state
+	if len(idxs) == 0 {
+		return ErrTransactionNotFound
+	}
+	if len(idxs) < 2 {
+		return fmt.Errorf("%w: no state transition to undo", ErrCorruptedStateDb)
+	}
+
+	if _, err := tx.Exec(`DELETE FROM delegation_state_transitions WHERE staking_tx_hash = $1 AND idx = $2`,
+		stakingTxHashBytes, idxs[0]); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`UPDATE staking_transactions SET state = $1 WHERE tx_hash = $2`,
+		states[1], stakingTxHashBytes)
+
+	return err
+}
+
+// pgGetDelegationState returns the lifecycle state of the given staking tx
+// it returns ErrTransactionNotFound if the staking tx is not stored
+func pgGetDelegationState(tx *sql.Tx, stakingTxHashBytes []byte) (DelegationState, error) {
+	var state int64
+	err := tx.QueryRow(`SELECT state FROM staking_transactions WHERE tx_hash = $1`,
+		stakingTxHashBytes).Scan(&state)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrTransactionNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return DelegationState(state), nil
+}
+
+// GetTimelockExpiries returns the timelocks of the stored staking and
+// unbonding txs that expire at the given height
+func (ps *PostgresStore) GetTimelockExpiries(height uint64) ([]*TimelockExpiry, error) {
+	var expiries []*TimelockExpiry
+
+	err := ps.view(func(tx *sql.Tx) error {
+		rows, err := tx.Query(`SELECT spent_tx_hash, staking_tx_hash FROM timelock_expiries
+			WHERE expiry_height = $1 ORDER BY spent_tx_hash`, int64(height))
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var spentTxHashBytes, stakingTxHashBytes []byte
+			if err := rows.Scan(&spentTxHashBytes, &stakingTxHashBytes); err != nil {
+				return err
+			}
+			spentTxHash, err := chainhash.NewHash(spentTxHashBytes)
+			if err != nil {
+				return ErrCorruptedStateDb
+			}
+			stakingTxHash, err := chainhash.NewHash(stakingTxHashBytes)
+			if err != nil {
+				return ErrCorruptedStateDb
+			}
+
+			expiry := &TimelockExpiry{StakingTxHash: stakingTxHash}
+			if !spentTxHash.IsEqual(stakingTxHash) {
+				expiry.UnbondingTxHash = spentTxHash
+			}
+			expiries = append(expiries, expiry)
+		}
+
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return expiries, nil
+}
+
+// pgPutTimelockExpiry records the expiry of the output of the spent tx, which
+// is either the staking tx or its unbonding tx
+func pgPutTimelockExpiry(tx *sql.Tx, expiryHeight uint64, spentTxHashBytes []byte, stakingTxHashBytes []byte) error {
+	_, err := tx.Exec(`INSERT INTO timelock_expiries (expiry_height, spent_tx_hash, staking_tx_hash) VALUES ($1, $2, $3)
+		ON CONFLICT (expiry_height, spent_tx_hash) DO UPDATE SET staking_tx_hash = excluded.staking_tx_hash`,
+		int64(expiryHeight), spentTxHashBytes, stakingTxHashBytes)
+
+	return err
+}
+
+func pgDeleteTimelockExpiry(tx *sql.Tx, expiryHeight uint64, spentTxHashBytes []byte) error {
+	_, err := tx.Exec(`DELETE FROM timelock_expiries WHERE expiry_height = $1 AND spent_tx_hash = $2`,
+		int64(expiryHeight), spentTxHashBytes)
+
+	return err
+}
+
+// GetFinalityProviderStats returns the totals of the delegations to the
+// finality provider with the given public key
+// it returns (nil, nil) if there is no delegation to the finality provider
+func (ps *PostgresStore) GetFinalityProviderStats(fpPk *btcec.PublicKey) (*FinalityProviderStats, error) {
+	var stats *FinalityProviderStats
+
+	err := ps.view(func(tx *sql.Tx) error {
+		var activeStake, overflowStake, activeDelegations, unbondedDelegations int64
+		err := tx.QueryRow(`SELECT active_stake, overflow_stake, active_delegations, unbonded_delegations
+			FROM finality_provider_stats WHERE finality_provider_pk = $1`, schnorr.SerializePubKey(fpPk)).
+			Scan(&activeStake, &overflowStake, &activeDelegations, &unbondedDelegations)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		stats = &FinalityProviderStats{
+			FinalityProviderPk:  fpPk,
+			ActiveStake:         uint64(activeStake),
+			OverflowStake:       uint64(overflowStake),
+			ActiveDelegations:   uint64(activeDelegations),
+			UnbondedDelegations: uint64(unbondedDelegations),
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// ScanFinalityProviderStats iterates through the totals of the delegations
+// of all the finality providers
+func (ps *PostgresStore) ScanFinalityProviderStats(callback func(*FinalityProviderStats) error) error {
+	var allStats []*FinalityProviderStats
+
+	err := ps.view(func(tx *sql.Tx) error {
+		rows, err := tx.Query(`SELECT finality_provider_pk, active_stake, overflow_stake,
+			active_delegations, unbonded_delegations FROM finality_provider_stats ORDER BY finality_provider_pk`)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var fpPkBytes []byte
+			var activeStake, overflowStake, activeDelegations, unbondedDelegations int64
+			if err := rows.Scan(&fpPkBytes, &activeStake, &overflowStake, &activeDelegations, &unbondedDelegations); err != nil {
+				return err
+			}
+			fpPk, err := schnorr.ParsePubKey(fpPkBytes)
+			if err != nil {
+				return fmt.Errorf("invalid finality provider pk: %w", err)
+			}
+
+			allStats = append(allStats, &FinalityProviderStats{
+				FinalityProviderPk:  fpPk,
+				ActiveStake:         uint64(activeStake),
+				OverflowStake:       uint64(overflowStake),
+				ActiveDelegations:   uint64(activeDelegations),
+				UnbondedDelegations: uint64(unbondedDelegations),
+			})
+		}
+
+		return rows.Err()
+	})
+	if err != nil {
+		return err
+	}
+
+	// the number of the finality providers is small, so their totals are
+	// read at once and the callback can use the store
+	for _, stats := range allStats {
+		if err := callback(stats); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
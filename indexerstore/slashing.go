@@ -0,0 +1,192 @@
+package indexerstore
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/lightningnetwork/lnd/kvdb"
+	pm "google.golang.org/protobuf/proto"
+
+	"github.com/babylonlabs-io/staking-indexer/proto"
+	"github.com/babylonlabs-io/staking-indexer/utils"
+)
+
+type StoredSlashingTransaction struct {
+	Tx            *wire.MsgTx
+	StakingTxHash *chainhash.Hash
+	// UnbondingTxHash is nil if the slashing tx spends the staking tx
+	UnbondingTxHash *chainhash.Hash
+	InclusionHeight uint64
+}
+
+// IsFromUnbonding returns whether the slashing tx spends the unbonding output
+func (st *StoredSlashingTransaction) IsFromUnbonding() bool {
+	return st.UnbondingTxHash != nil
+}
+
+// AddSlashingTransaction saves a slashing tx that spends the output of the
+// given staking tx, or of the given unbonding tx if it is not nil.
+// The confirmed tvl is decremented if the slashing tx spends the output of
+// a staking tx that is not overflow, while the slashing of an unbonding
+// tx does not change the confirmed tvl as it was decremented by unbonding
+func (is *IndexerStore) AddSlashingTransaction(
+	tx *wire.MsgTx,
+	stakingTxHash *chainhash.Hash,
+	unbondingTxHash *chainhash.Hash,
+	inclusionHeight uint64,
+) error {
+	txHash := tx.TxHash()
+	serializedTx, err := utils.SerializeBtcTransaction(tx)
+	if err != nil {
+		return err
+	}
+
+	msg := proto.SlashingTransaction{
+		TransactionBytes: serializedTx,
+		StakingTxHash:    stakingTxHash.CloneBytes(),
+		InclusionHeight:  inclusionHeight,
+	}
+	if unbondingTxHash != nil {
+		msg.UnbondingTxHash = unbondingTxHash.CloneBytes()
+	}
+
+	return is.addSlashingTransaction(txHash[:], &msg)
+}
+
+func (is *IndexerStore) addSlashingTransaction(
+	txHashBytes []byte,
+	st *proto.SlashingTransaction,
+) error {
+	return is.update(func(tx kvdb.RwTx) error {
+		stakingTxBucket := tx.ReadWriteBucket(stakingTxBucketName)
+		if stakingTxBucket == nil {
+			return ErrCorruptedTransactionsDb
+		}
+
+		// we need to ensure the staking tx already exists
+		storedStakingTxProto, err := getStakingTxProto(stakingTxBucket, st.StakingTxHash)
+		if err != nil {
+			return err
+		}
+
+		isFromUnbonding := len(st.UnbondingTxHash) != 0
+		if isFromUnbonding {
+			unbondingTxBucket := tx.ReadWriteBucket(unbondingTxBucketName)
+			if unbondingTxBucket == nil {
+				return ErrCorruptedTransactionsDb
+			}
+			if unbondingTxBucket.Get(st.UnbondingTxHash) == nil {
+				return ErrTransactionNotFound
+			}
+		}
+
+		slashingTxBucket := tx.ReadWriteBucket(slashingTxBucketName)
+		if slashingTxBucket == nil {
+			return ErrCorruptedTransactionsDb
+		}
+
+		// check duplicate
+		maybeTx := slashingTxBucket.Get(txHashBytes)
+		if maybeTx != nil {
+			return ErrDuplicateTransaction
+		}
+
+		marshalled, err := pm.Marshal(st)
+		if err != nil {
+			return err
+		}
+
+		if err := slashingTxBucket.Put(txHashBytes, marshalled); err != nil {
+			return err
+		}
+
+		if err := is.appendJournalEntry(
+			tx, st.InclusionHeight, SlashingTxType, txHashBytes,
+		); err != nil {
+			return err
+		}
+
+		if err := is.transitionState(
+			tx, st.StakingTxHash, StateSlashed, st.InclusionHeight, txHashBytes,
+		); err != nil {
+			return err
+		}
+
+		// the tvl was already decremented when the staking tx was unbonded,
+		// or was never incremented if the staking tx is an overflow
+		if isFromUnbonding || storedStakingTxProto.IsOverflow {
+			return nil
+		}
+
+		return is.subtractConfirmedTvl(tx, storedStakingTxProto.StakingValue)
+	})
+}
+
+// GetSlashingTransaction retrieves the stored slashing transaction by the given hash
+// it returns (nil, nil) if the transaction is not found
+func (is *IndexerStore) GetSlashingTransaction(txHash *chainhash.Hash) (*StoredSlashingTransaction, error) {
+	var storedTx *StoredSlashingTransaction
+	txHashBytes := txHash.CloneBytes()
+
+	err := is.view(func(tx kvdb.RTx) error {
+		txBucket := tx.ReadBucket(slashingTxBucketName)
+		if txBucket == nil {
+			return ErrCorruptedTransactionsDb
+		}
+
+		maybeTx := txBucket.Get(txHashBytes)
+		if maybeTx == nil {
+			return ErrTransactionNotFound
+		}
+
+		var storedTxProto proto.SlashingTransaction
+		if err := pm.Unmarshal(maybeTx, &storedTxProto); err != nil {
+			return ErrCorruptedTransactionsDb
+		}
+
+		txFromDb, err := protoSlashingTxToStoredSlashingTx(&storedTxProto)
+		if err != nil {
+			return err
+		}
+
+		storedTx = txFromDb
+		return nil
+	}, func() {})
+
+	if err != nil && !errors.Is(err, ErrTransactionNotFound) {
+		return nil, err
+	}
+
+	return storedTx, nil
+}
+
+func protoSlashingTxToStoredSlashingTx(protoTx *proto.SlashingTransaction) (*StoredSlashingTransaction, error) {
+	var slashingTx wire.MsgTx
+	err := slashingTx.Deserialize(bytes.NewReader(protoTx.TransactionBytes))
+	if err != nil {
+		return nil, fmt.Errorf("invalid slashing tx: %w", err)
+	}
+
+	stakingTxHash, err := chainhash.NewHash(protoTx.StakingTxHash)
+	if err != nil {
+		return nil, fmt.Errorf("invalid staking tx hash")
+	}
+
+	var unbondingTxHash *chainhash.Hash
+	if len(protoTx.UnbondingTxHash) != 0 {
+		unbondingTxHash, err = chainhash.NewHash(protoTx.UnbondingTxHash)
+		if err != nil {
+			return nil, fmt.Errorf("invalid unbonding tx hash")
+		}
+	}
+
+	return &StoredSlashingTransaction{
+		Tx:              &slashingTx,
+		StakingTxHash:   stakingTxHash,
+		UnbondingTxHash: unbondingTxHash,
+		InclusionHeight: protoTx.InclusionHeight,
+	}, nil
+}
@@ -0,0 +1,362 @@
+package indexerstore
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+
+	"github.com/lightningnetwork/lnd/kvdb"
+)
+
+// A snapshot is laid out as follows, all the integers are big-endian
+//   - the snapshotMagic bytes
+//   - the length of the header as uint32, followed by the JSON encoded header
+//   - the records of the buckets, each starting with its record type, the
+//     bucket record is followed by the length of the bucket name as uint32
+//     and the name, and the key-value record by the length of the key as
+//     uint32, the key, the length of the value as uint32, and the value. The
+//     key-value records belong to the last bucket record before them
+//   - the end record
+//   - the sha256 checksum of all the bytes before it
+const (
+	// SnapshotFormatVersion is the version of the layout of the snapshots
+	// written by this binary
+	SnapshotFormatVersion = 1
+
+	snapshotRecordEnd      = byte(0)
+	snapshotRecordBucket   = byte(1)
+	snapshotRecordKeyValue = byte(2)
+
+	// maxSnapshotFieldSize bounds the length of a field read from a snapshot,
+	// so that a corrupted length does not exhaust the memory
+	maxSnapshotFieldSize = 1 << 26
+)
+
+var snapshotMagic = []byte("sidsnap\x00")
+
+// SnapshotHeader describes the data of a snapshot
+type SnapshotHeader struct {
+	FormatVersion uint32 `json:"format_version"`
+	// SchemaVersion is the schema version of the database the snapshot is
+	// created from
+	SchemaVersion uint64 `json:"schema_version"`
+	// LastProcessedHeight is the height of the last block processed by the
+	// database the snapshot is created from
+	LastProcessedHeight uint64 `json:"last_processed_height"`
+	// ParamsHash is the hex encoded sha256 hash of the global params file the
+	// indexer used
+	ParamsHash string `json:"params_hash"`
+}
+
+// CreateSnapshot writes a snapshot of all the buckets of db to w, from a
+// single read transaction so that the snapshot is consistent at the last
+// processed height. The given params hash is the sha256 hash of the global
+// params file, the snapshot can only be restored with the same params.
+// The snapshot is refused while events are waiting in the outbox, as the
+// indexer restored from it would publish them again. With all the events
+// delivered, the restored indexer only publishes the events of the blocks
+// it processes, while the delivered ones are kept for the subscriptions
+// it returns ErrLastProcessedHeightNotFound if no block is processed yet
+// it returns ErrOutboxNotDrained if the outbox has undelivered events
+func CreateSnapshot(db kvdb.Backend, w io.Writer, paramsHash []byte) (*SnapshotHeader, error) {
+	var header *SnapshotHeader
+
+	err := db.View(func(tx kvdb.RTx) error {
+		version, err := getSchemaVersion(tx)
+		if err != nil {
+			return err
+		}
+		if err := checkSchemaVersion(version); err != nil {
+			return err
+		}
+
+		stateBucket := tx.ReadBucket(indexerStateBucketName)
+		if stateBucket == nil {
+			return ErrLastProcessedHeightNotFound
+		}
+		v := stateBucket.Get(getLastProcessedHeightKey())
+		if v == nil {
+			return ErrLastProcessedHeightNotFound
+		}
+		lastProcessedHeight, err := uint64FromBytes(v)
+		if err != nil {
+			return ErrCorruptedStateDb
+		}
+
+		numUndelivered, err := getNumUndeliveredOutboxEntries(tx)
+		if err != nil {
+			return err
+		}
+		if numUndelivered > 0 {
+			return fmt.Errorf("%w: %d events are waiting to be delivered", ErrOutboxNotDrained, numUndelivered)
+		}
+
+		header = &SnapshotHeader{
+			FormatVersion:       SnapshotFormatVersion,
+			SchemaVersion:       version,
+			LastProcessedHeight: lastProcessedHeight,
+			ParamsHash:          hex.EncodeToString(paramsHash),
+		}
+
+		return writeSnapshot(tx, w, header)
+	}, func() {
+		header = nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return header, nil
+}
+
+func writeSnapshot(tx kvdb.RTx, w io.Writer, header *SnapshotHeader) error {
+	sw := &snapshotWriter{w: bufio.NewWriter(w), hash: sha256.New()}
+
+	headerBytes, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+	sw.write(snapshotMagic)
+	sw.writeField(headerBytes)
+
+	err = tx.ForEachBucket(func(bucketName []byte) error {
+		bucket := tx.ReadBucket(bucketName)
+		if bucket == nil {
+			return ErrCorruptedStateDb
+		}
+
+		sw.write([]byte{snapshotRecordBucket})
+		sw.writeField(bucketName)
+
+		return bucket.ForEach(func(k, v []byte) error {
+			// the buckets of the store are not nested
+			if v == nil && bucket.NestedReadBucket(k) != nil {
+				return fmt.Errorf("%w: nested bucket %x in bucket %s", ErrCorruptedStateDb, k, bucketName)
+			}
+
+			sw.write([]byte{snapshotRecordKeyValue})
+			sw.writeField(k)
+			sw.writeField(v)
+			return sw.err
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	sw.write([]byte{snapshotRecordEnd})
+	checksum := sw.hash.Sum(nil)
+	sw.write(checksum)
+	if sw.err != nil {
+		return sw.err
+	}
+
+	return sw.w.Flush()
+}
+
+// snapshotWriter writes the snapshot and computes its checksum, it keeps the
+// first write error so that the writes do not need to be checked one by one
+type snapshotWriter struct {
+	w    *bufio.Writer
+	hash hash.Hash
+	err  error
+}
+
+func (sw *snapshotWriter) write(b []byte) {
+	if sw.err != nil {
+		return
+	}
+	sw.hash.Write(b)
+	_, sw.err = sw.w.Write(b)
+}
+
+func (sw *snapshotWriter) writeField(b []byte) {
+	length := make([]byte, 4)
+	binary.BigEndian.PutUint32(length, uint32(len(b)))
+	sw.write(length)
+	sw.write(b)
+}
+
+// ReadSnapshotHeader reads the header of the snapshot from r, without
+// validating the rest of the snapshot
+func ReadSnapshotHeader(r io.Reader) (*SnapshotHeader, error) {
+	sr := &snapshotReader{r: bufio.NewReader(r), hash: sha256.New()}
+
+	return sr.readHeader()
+}
+
+// RestoreSnapshot installs the snapshot read from r into db, which must be
+// empty. The given params hash is the sha256 hash of the global params file
+// the indexer is going to use, which must be the one the snapshot is created
+// with. The snapshot is installed in a single write transaction, which is
+// only committed if the checksum of the snapshot is valid, so db is left
+// empty if the snapshot is invalid
+// it returns ErrSchemaTooNew if the snapshot is created by a newer binary
+func RestoreSnapshot(db kvdb.Backend, r io.Reader, paramsHash []byte) (*SnapshotHeader, error) {
+	sr := &snapshotReader{r: bufio.NewReader(r), hash: sha256.New()}
+
+	header, err := sr.readHeader()
+	if err != nil {
+		return nil, err
+	}
+	if header.ParamsHash != hex.EncodeToString(paramsHash) {
+		return nil, fmt.Errorf("%w: the snapshot is created with params %s, but the params are %x",
+			ErrSnapshotParamsMismatch, header.ParamsHash, paramsHash)
+	}
+
+	err = kvdb.Update(db, func(tx kvdb.RwTx) error {
+		err := tx.ForEachBucket(func(bucketName []byte) error {
+			return fmt.Errorf("%w: found bucket %s", ErrDbNotEmpty, bucketName)
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := sr.restoreBuckets(tx); err != nil {
+			return err
+		}
+
+		// the installed state must be the one described by the header
+		version, err := getSchemaVersion(tx)
+		if err != nil {
+			return err
+		}
+		lastProcessedHeight, err := getLastProcessedHeight(tx)
+		if err != nil {
+			return err
+		}
+		if version != header.SchemaVersion || lastProcessedHeight != header.LastProcessedHeight {
+			return fmt.Errorf("%w: the data does not match the header", ErrInvalidSnapshot)
+		}
+
+		return nil
+	}, func() {})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return header, nil
+}
+
+// snapshotReader reads the snapshot and computes its checksum
+type snapshotReader struct {
+	r    *bufio.Reader
+	hash hash.Hash
+}
+
+func (sr *snapshotReader) read(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := io.ReadFull(sr.r, b); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, fmt.Errorf("%w: unexpected end of snapshot", ErrInvalidSnapshot)
+		}
+		return nil, err
+	}
+	sr.hash.Write(b)
+
+	return b, nil
+}
+
+func (sr *snapshotReader) readField() ([]byte, error) {
+	length, err := sr.read(4)
+	if err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(length)
+	if n > maxSnapshotFieldSize {
+		return nil, fmt.Errorf("%w: field of %d bytes", ErrInvalidSnapshot, n)
+	}
+
+	return sr.read(int(n))
+}
+
+func (sr *snapshotReader) readHeader() (*SnapshotHeader, error) {
+	magic, err := sr.read(len(snapshotMagic))
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(magic, snapshotMagic) {
+		return nil, fmt.Errorf("%w: not a snapshot", ErrInvalidSnapshot)
+	}
+
+	headerBytes, err := sr.readField()
+	if err != nil {
+		return nil, err
+	}
+	var header SnapshotHeader
+	if err := json.Unmarshal(headerBytes, &header); err != nil {
+		return nil, fmt.Errorf("%w: invalid header: %v", ErrInvalidSnapshot, err)
+	}
+
+	if header.FormatVersion != SnapshotFormatVersion {
+		return nil, fmt.Errorf("%w: unsupported format version %d", ErrInvalidSnapshot, header.FormatVersion)
+	}
+	if err := checkSchemaVersion(header.SchemaVersion); err != nil {
+		return nil, err
+	}
+
+	return &header, nil
+}
+
+// restoreBuckets writes the records of the snapshot to tx until the end
+// record, and checks the checksum following it
+func (sr *snapshotReader) restoreBuckets(tx kvdb.RwTx) error {
+	var bucket kvdb.RwBucket
+	for {
+		recordType, err := sr.read(1)
+		if err != nil {
+			return err
+		}
+
+		switch recordType[0] {
+		case snapshotRecordBucket:
+			bucketName, err := sr.readField()
+			if err != nil {
+				return err
+			}
+			bucket, err = tx.CreateTopLevelBucket(bucketName)
+			if err != nil {
+				return err
+			}
+
+		case snapshotRecordKeyValue:
+			if bucket == nil {
+				return fmt.Errorf("%w: key-value record out of bucket", ErrInvalidSnapshot)
+			}
+			k, err := sr.readField()
+			if err != nil {
+				return err
+			}
+			v, err := sr.readField()
+			if err != nil {
+				return err
+			}
+			if err := bucket.Put(k, v); err != nil {
+				return err
+			}
+
+		case snapshotRecordEnd:
+			expected := sr.hash.Sum(nil)
+			checksum := make([]byte, len(expected))
+			if _, err := io.ReadFull(sr.r, checksum); err != nil {
+				return fmt.Errorf("%w: missing checksum", ErrInvalidSnapshot)
+			}
+			if !bytes.Equal(checksum, expected) {
+				return ErrSnapshotChecksumMismatch
+			}
+			return nil
+
+		default:
+			return fmt.Errorf("%w: unknown record type %d", ErrInvalidSnapshot, recordType[0])
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package indexerstore
+
+import (
+	"bytes"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/lightningnetwork/lnd/kvdb"
+	pm "google.golang.org/protobuf/proto"
+
+	"github.com/babylonlabs-io/staking-indexer/proto"
+)
+
+// GetStakingTransactionsByStakerPk returns at most limit staking transactions
+// of the staker with the given public key in the order of their hashes,
+// starting after the transaction with afterTxHash. It starts from the first
+// transaction if afterTxHash is nil, so that the transactions can be
+// paginated by passing the hash of the last transaction of the previous page
+func (is *IndexerStore) GetStakingTransactionsByStakerPk(
+	stakerPk *btcec.PublicKey,
+	afterTxHash *chainhash.Hash,
+	limit int,
+) ([]*StoredStakingTransaction, error) {
+	var storedTxs []*StoredStakingTransaction
+	prefix := schnorr.SerializePubKey(stakerPk)
+
+	err := is.view(func(tx kvdb.RTx) error {
+		indexBucket := tx.ReadBucket(stakingTxByStakerBucketName)
+		if indexBucket == nil {
+			return ErrCorruptedTransactionsDb
+		}
+		txBucket := tx.ReadBucket(stakingTxBucketName)
+		if txBucket == nil {
+			return ErrCorruptedTransactionsDb
+		}
+
+		c := indexBucket.ReadCursor()
+		k, _ := c.Seek(prefix)
+		if afterTxHash != nil {
+			afterKey := stakerIndexKey(prefix, afterTxHash.CloneBytes())
+			k, _ = c.Seek(afterKey)
+			if k != nil && bytes.Equal(k, afterKey) {
+				k, _ = c.Next()
+			}
+		}
+
+		for ; k != nil && bytes.HasPrefix(k, prefix) && len(storedTxs) < limit; k, _ = c.Next() {
+			storedTxProto, err := getStakingTxProto(txBucket, k[len(prefix):])
+			if err != nil {
+				return err
+			}
+
+			storedTx, err := protoStakingTxToStoredStakingTx(storedTxProto)
+			if err != nil {
+				return err
+			}
+			storedTxs = append(storedTxs, storedTx)
+		}
+
+		return nil
+	}, func() {
+		storedTxs = nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return storedTxs, nil
+}
+
+// putStakerIndex indexes the staking tx by the public key of its staker
+func putStakerIndex(tx kvdb.RwTx, stakerPkBytes []byte, txHashBytes []byte) error {
+	indexBucket := tx.ReadWriteBucket(stakingTxByStakerBucketName)
+	if indexBucket == nil {
+		return ErrCorruptedTransactionsDb
+	}
+
+	return indexBucket.Put(stakerIndexKey(stakerPkBytes, txHashBytes), []byte{})
+}
+
+func deleteStakerIndex(tx kvdb.RwTx, stakerPkBytes []byte, txHashBytes []byte) error {
+	indexBucket := tx.ReadWriteBucket(stakingTxByStakerBucketName)
+	if indexBucket == nil {
+		return ErrCorruptedTransactionsDb
+	}
+
+	return indexBucket.Delete(stakerIndexKey(stakerPkBytes, txHashBytes))
+}
+
+// stakerIndexKey is the serialized staker public key followed by the staking
+// tx hash so that the staking txs of a staker share the same prefix
+func stakerIndexKey(stakerPkBytes []byte, txHashBytes []byte) []byte {
+	key := make([]byte, 0, len(stakerPkBytes)+len(txHashBytes))
+	key = append(key, stakerPkBytes...)
+	return append(key, txHashBytes...)
+}
+
+// migrateStakerIndex creates the staker index and backfills it with the
+// staking txs stored before the index was introduced. It does nothing if the
+// index already exists, as it is then maintained with the staking txs
+func migrateStakerIndex(tx kvdb.RwTx) error {
+	if tx.ReadWriteBucket(stakingTxByStakerBucketName) != nil {
+		return nil
+	}
+
+	if _, err := tx.CreateTopLevelBucket(stakingTxByStakerBucketName); err != nil {
+		return err
+	}
+
+	txBucket := tx.ReadWriteBucket(stakingTxBucketName)
+	if txBucket == nil {
+		return ErrCorruptedTransactionsDb
+	}
+
+	return txBucket.ForEach(func(k, v []byte) error {
+		var storedTxProto proto.StakingTransaction
+		if err := pm.Unmarshal(v, &storedTxProto); err != nil {
+			return ErrCorruptedTransactionsDb
+		}
+
+		return putStakerIndex(tx, storedTxProto.StakerPk, k)
+	})
+}
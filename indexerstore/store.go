@@ -0,0 +1,127 @@
+package indexerstore
+
+import (
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// Store is the storage of the indexer state. It is implemented by
+// IndexerStore on top of a bolt database, and by PostgresStore on top of a
+// Postgres database, which behave the same
+type Store interface {
+	// UpdateBlock applies all the state changes of processing the block at
+	// the given height atomically, together with saving the block as the
+	// last processed one. The store passed to fn observes the changes not
+	// committed yet, and must not be used after fn returns
+	UpdateBlock(height uint64, blockHash *chainhash.Hash, fn func(bs Store) error) error
+	// Update runs fn atomically, the store passed to fn observes the changes
+	// not committed yet, and must not be used after fn returns
+	Update(fn func(bs Store) error) error
+
+	AddStakingTransaction(
+		tx *wire.MsgTx,
+		stakingOutputIdx uint32,
+		inclusionHeight uint64,
+		stakerPk *btcec.PublicKey,
+		stakingTime uint32,
+		fpPk *btcec.PublicKey,
+		stakingValue uint64,
+		isOverflow bool,
+	) error
+	GetStakingTransaction(txHash *chainhash.Hash) (*StoredStakingTransaction, error)
+	ScanStoredStakingTransactions(callback func(*StoredStakingTransaction) error) error
+	GetStakingTransactionsByStakerPk(
+		stakerPk *btcec.PublicKey,
+		afterTxHash *chainhash.Hash,
+		limit int,
+	) ([]*StoredStakingTransaction, error)
+	ScanStakingTransactionsByHeightRange(
+		startHeight, endHeight uint64,
+		callback func(*StoredStakingTransaction) error,
+	) error
+	GetStakingTransactionsByHeightRange(
+		startHeight, endHeight uint64,
+		afterTxHash *chainhash.Hash,
+		limit int,
+	) ([]*StoredStakingTransaction, error)
+
+	AddUnbondingTransaction(
+		tx *wire.MsgTx,
+		stakingTxHash *chainhash.Hash,
+		inclusionHeight uint64,
+		unbondingTime uint32,
+	) error
+	GetUnbondingTransaction(txHash *chainhash.Hash) (*StoredUnbondingTransaction, error)
+	GetUnbondingTransactionByStakingTxHash(stakingTxHash *chainhash.Hash) (*StoredUnbondingTransaction, error)
+	ScanStoredUnbondingTransactions(callback func(*StoredUnbondingTransaction) error) error
+
+	AddWithdrawalTransaction(
+		tx *wire.MsgTx,
+		stakingTxHash *chainhash.Hash,
+		unbondingTxHash *chainhash.Hash,
+		inclusionHeight uint64,
+	) error
+	GetWithdrawalTransaction(txHash *chainhash.Hash) (*StoredWithdrawalTransaction, error)
+	GetWithdrawalTransactionByStakingTxHash(stakingTxHash *chainhash.Hash) (*StoredWithdrawalTransaction, error)
+	ScanStoredWithdrawalTransactions(callback func(*StoredWithdrawalTransaction) error) error
+
+	AddSlashingTransaction(
+		tx *wire.MsgTx,
+		stakingTxHash *chainhash.Hash,
+		unbondingTxHash *chainhash.Hash,
+		inclusionHeight uint64,
+	) error
+	GetSlashingTransaction(txHash *chainhash.Hash) (*StoredSlashingTransaction, error)
+
+	AddInvalidStakingTransaction(
+		tx *wire.MsgTx,
+		stakingOutputIdx uint32,
+		inclusionHeight uint64,
+		stakerPk *btcec.PublicKey,
+		stakingTime uint32,
+		fpPk *btcec.PublicKey,
+		stakingValue uint64,
+		reason InvalidStakingReason,
+	) error
+	GetInvalidStakingTransaction(txHash *chainhash.Hash) (*StoredInvalidStakingTransaction, error)
+
+	TxExists(txHash *chainhash.Hash) (bool, error)
+	GetConfirmedTvl() (uint64, error)
+
+	TransitionDelegationState(
+		stakingTxHash *chainhash.Hash,
+		next DelegationState,
+		height uint64,
+		txHash *chainhash.Hash,
+	) error
+	CheckDelegationStateTransition(
+		stakingTxHash *chainhash.Hash,
+		next DelegationState,
+		txHash *chainhash.Hash,
+	) error
+	GetTimelockExpiries(height uint64) ([]*TimelockExpiry, error)
+
+	GetFinalityProviderStats(fpPk *btcec.PublicKey) (*FinalityProviderStats, error)
+	ScanFinalityProviderStats(callback func(*FinalityProviderStats) error) error
+
+	SaveLastProcessedHeight(height uint64) error
+	GetLastProcessedHeight() (uint64, error)
+	SaveProcessedBlock(height uint64, blockHash *chainhash.Hash) error
+	GetBlockJournal(height uint64) ([]*JournalEntry, error)
+	GetBlockHash(height uint64) (*chainhash.Hash, error)
+	RollbackBlock(height uint64) error
+
+	AddOutboxEntry(eventType uint32, payload []byte, blockHeight uint64, blockHash *chainhash.Hash) error
+	GetUndeliveredOutboxEntries(limit int) ([]*OutboxEntry, error)
+	GetOutboxEntries(fromSeq uint64, limit int) ([]*OutboxEntry, error)
+	GetOutboxSequenceFromHeight(height uint64) (uint64, error)
+	GetOutboxStats() (*OutboxStats, error)
+	MarkOutboxEntryDelivered(seq uint64) error
+	PruneOutboxEntries(belowHeight uint64) (uint64, error)
+}
+
+var (
+	_ Store = (*IndexerStore)(nil)
+	_ Store = (*PostgresStore)(nil)
+)
@@ -0,0 +1,190 @@
+package indexerstore
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/lightningnetwork/lnd/kvdb"
+	pm "google.golang.org/protobuf/proto"
+
+	"github.com/babylonlabs-io/staking-indexer/proto"
+)
+
+// TimelockExpiry is the expiry of the timelock of a staking output, or of an
+// unbonding output if UnbondingTxHash is not nil
+type TimelockExpiry struct {
+	StakingTxHash   *chainhash.Hash
+	UnbondingTxHash *chainhash.Hash
+}
+
+// IsFromUnbonding returns whether the expired timelock is of the unbonding output
+func (te *TimelockExpiry) IsFromUnbonding() bool {
+	return te.UnbondingTxHash != nil
+}
+
+// GetTimelockExpiries returns the timelocks of the stored staking and
+// unbonding txs that expire at the given height
+func (is *IndexerStore) GetTimelockExpiries(height uint64) ([]*TimelockExpiry, error) {
+	var expiries []*TimelockExpiry
+	prefix := uint64ToBytes(height)
+
+	err := is.view(func(tx kvdb.RTx) error {
+		expiryBucket := tx.ReadBucket(timelockExpiryBucketName)
+		if expiryBucket == nil {
+			return ErrCorruptedStateDb
+		}
+
+		c := expiryBucket.ReadCursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			spentTxHash, err := chainhash.NewHash(k[len(prefix):])
+			if err != nil {
+				return ErrCorruptedStateDb
+			}
+			stakingTxHash, err := chainhash.NewHash(v)
+			if err != nil {
+				return ErrCorruptedStateDb
+			}
+
+			expiry := &TimelockExpiry{StakingTxHash: stakingTxHash}
+			if !spentTxHash.IsEqual(stakingTxHash) {
+				expiry.UnbondingTxHash = spentTxHash
+			}
+			expiries = append(expiries, expiry)
+		}
+
+		return nil
+	}, func() {
+		expiries = nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return expiries, nil
+}
+
+// putTimelockExpiry indexes the expiry of the output of the spent tx, which
+// is either the staking tx or its unbonding tx, by the expiry height
+func putTimelockExpiry(tx kvdb.RwTx, expiryHeight uint64, spentTxHashBytes []byte, stakingTxHashBytes []byte) error {
+	expiryBucket := tx.ReadWriteBucket(timelockExpiryBucketName)
+	if expiryBucket == nil {
+		return ErrCorruptedStateDb
+	}
+
+	return expiryBucket.Put(timelockExpiryKey(expiryHeight, spentTxHashBytes), stakingTxHashBytes)
+}
+
+func deleteTimelockExpiry(tx kvdb.RwTx, expiryHeight uint64, spentTxHashBytes []byte) error {
+	expiryBucket := tx.ReadWriteBucket(timelockExpiryBucketName)
+	if expiryBucket == nil {
+		return ErrCorruptedStateDb
+	}
+
+	return expiryBucket.Delete(timelockExpiryKey(expiryHeight, spentTxHashBytes))
+}
+
+// timelockExpiryKey is the big-endian expiry height followed by the spent tx
+// hash so that the expiries are ordered by height
+func timelockExpiryKey(expiryHeight uint64, spentTxHashBytes []byte) []byte {
+	return append(uint64ToBytes(expiryHeight), spentTxHashBytes...)
+}
+
+// expiredTimelock is the timelock of an output that expired at or below the
+// last processed height
+type expiredTimelock struct {
+	height             uint64
+	spentTxHashBytes   []byte
+	stakingTxHashBytes []byte
+}
+
+// migrateTimelockExpiries indexes the expiries of the unspent staking and
+// unbonding outputs stored before the timelock expiry index was introduced.
+// The timelocks that expired at or below the last processed height moved the
+// delegations to unbonded in the processed blocks, so the moves are recorded
+// at the expiry heights without emitting events, as migrateDelegationStates
+// does. It does nothing if the index is not empty, as the expiries are then
+// indexed with the txs
+func migrateTimelockExpiries(tx kvdb.RwTx) error {
+	expiryBucket := tx.ReadWriteBucket(timelockExpiryBucketName)
+	if expiryBucket == nil {
+		return ErrCorruptedStateDb
+	}
+	if k, _ := expiryBucket.ReadCursor().First(); k != nil {
+		return nil
+	}
+
+	lastProcessedHeight, err := getLastProcessedHeight(tx)
+	if err != nil && !errors.Is(err, ErrLastProcessedHeightNotFound) {
+		return err
+	}
+	hasProcessedHeight := err == nil
+
+	stakingTxBucket := tx.ReadBucket(stakingTxBucketName)
+	unbondingTxBucket := tx.ReadBucket(unbondingTxBucketName)
+	if stakingTxBucket == nil || unbondingTxBucket == nil {
+		return ErrCorruptedTransactionsDb
+	}
+
+	// the staking txs are updated after the scans, as a bucket cannot be
+	// modified while it is iterated
+	var expired []*expiredTimelock
+	putExpiry := func(expiryHeight uint64, spentTxHashBytes, stakingTxHashBytes []byte) error {
+		if hasProcessedHeight && expiryHeight <= lastProcessedHeight {
+			expired = append(expired, &expiredTimelock{
+				height:             expiryHeight,
+				spentTxHashBytes:   spentTxHashBytes,
+				stakingTxHashBytes: stakingTxHashBytes,
+			})
+			return nil
+		}
+
+		return putTimelockExpiry(tx, expiryHeight, spentTxHashBytes, stakingTxHashBytes)
+	}
+
+	// the staking output is only unspent before unbonding
+	if err := stakingTxBucket.ForEach(func(k, v []byte) error {
+		var stakingTxProto proto.StakingTransaction
+		if err := pm.Unmarshal(v, &stakingTxProto); err != nil {
+			return ErrCorruptedTransactionsDb
+		}
+		state := currentState(&stakingTxProto)
+		if state != StateActive && state != StateOverflow {
+			return nil
+		}
+
+		expiryHeight := stakingTxProto.InclusionHeight + uint64(stakingTxProto.StakingTime)
+		return putExpiry(expiryHeight, k, k)
+	}); err != nil {
+		return err
+	}
+
+	// the unbonding output is only unspent while unbonding
+	if err := unbondingTxBucket.ForEach(func(k, v []byte) error {
+		var unbondingTxProto proto.UnbondingTransaction
+		if err := pm.Unmarshal(v, &unbondingTxProto); err != nil {
+			return ErrCorruptedTransactionsDb
+		}
+		stakingTxProto, err := getStakingTxProto(stakingTxBucket, unbondingTxProto.StakingTxHash)
+		if err != nil {
+			return err
+		}
+		if currentState(stakingTxProto) != StateUnbonding {
+			return nil
+		}
+
+		expiryHeight := unbondingTxProto.InclusionHeight + uint64(unbondingTxProto.UnbondingTime)
+		return putExpiry(expiryHeight, k, unbondingTxProto.StakingTxHash)
+	}); err != nil {
+		return err
+	}
+
+	for _, e := range expired {
+		if err := putStateTransition(tx, e.stakingTxHashBytes, StateUnbonded, e.height, e.spentTxHashBytes); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,273 @@
+package indexerstore
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/lightningnetwork/lnd/kvdb"
+	pm "google.golang.org/protobuf/proto"
+
+	"github.com/babylonlabs-io/staking-indexer/proto"
+)
+
+// the checks of the integrity verification
+const (
+	// CheckDecode the stored record cannot be decoded
+	CheckDecode = "decode"
+	// CheckConfirmedTvl the stored confirmed tvl differs from the one
+	// computed from the stored transactions
+	CheckConfirmedTvl = "confirmed_tvl"
+	// CheckStakingTxReference the staking tx referenced by a tx is not stored
+	CheckStakingTxReference = "staking_tx_reference"
+	// CheckUnbondingTxReference the unbonding tx referenced by a tx is not
+	// stored
+	CheckUnbondingTxReference = "unbonding_tx_reference"
+	// CheckInclusionHeight the inclusion height of a tx is above the last
+	// processed height
+	CheckInclusionHeight = "inclusion_height"
+)
+
+// VerifyIssue is an inconsistency found in the stored data
+type VerifyIssue struct {
+	Check string `json:"check"`
+	// TxHash is the hash of the tx with the issue, it is empty if the issue
+	// is not about a tx
+	TxHash  string `json:"tx_hash,omitempty"`
+	Message string `json:"message"`
+}
+
+// VerifyReport is the result of checking the integrity of the stored data
+type VerifyReport struct {
+	SchemaVersion uint64 `json:"schema_version"`
+	// LastProcessedHeight is nil if no block is processed yet
+	LastProcessedHeight  *uint64        `json:"last_processed_height"`
+	StoredConfirmedTvl   uint64         `json:"stored_confirmed_tvl"`
+	ComputedConfirmedTvl uint64         `json:"computed_confirmed_tvl"`
+	NumStakingTxs        uint64         `json:"num_staking_txs"`
+	NumUnbondingTxs      uint64         `json:"num_unbonding_txs"`
+	NumSlashingTxs       uint64         `json:"num_slashing_txs"`
+	NumWithdrawalTxs     uint64         `json:"num_withdrawal_txs"`
+	Issues               []*VerifyIssue `json:"issues"`
+}
+
+// OK returns whether no issue is found
+func (r *VerifyReport) OK() bool {
+	return len(r.Issues) == 0
+}
+
+func (r *VerifyReport) addIssue(check string, txHashBytes []byte, format string, args ...interface{}) {
+	issue := &VerifyIssue{
+		Check:   check,
+		Message: fmt.Sprintf(format, args...),
+	}
+	if txHash, err := chainhash.NewHash(txHashBytes); err == nil {
+		issue.TxHash = txHash.String()
+	}
+	r.Issues = append(r.Issues, issue)
+}
+
+// checkInclusionHeight reports the tx if it is included above the last
+// processed height, as the txs are only stored when their block is processed
+func (r *VerifyReport) checkInclusionHeight(txHashBytes []byte, inclusionHeight uint64) {
+	if r.LastProcessedHeight == nil {
+		r.addIssue(CheckInclusionHeight, txHashBytes,
+			"the tx is included at height %d while no block is processed", inclusionHeight)
+		return
+	}
+	if inclusionHeight > *r.LastProcessedHeight {
+		r.addIssue(CheckInclusionHeight, txHashBytes,
+			"the tx is included at height %d above the last processed height %d",
+			inclusionHeight, *r.LastProcessedHeight)
+	}
+}
+
+// VerifyDb checks the integrity of the data stored in db without changing
+// it. It recomputes the confirmed tvl from the stored staking, unbonding, and
+// slashing txs, checks that the txs referenced by the unbonding, slashing,
+// and withdrawal txs are stored, and that no tx is included above the last
+// processed height. The inconsistencies are returned as the issues of the
+// report, while an error is only returned if the check cannot be done
+// it returns ErrSchemaTooNew if the database is written by a newer binary
+func VerifyDb(db kvdb.Backend) (*VerifyReport, error) {
+	var report *VerifyReport
+
+	err := db.View(func(tx kvdb.RTx) error {
+		version, err := getSchemaVersion(tx)
+		if err != nil {
+			return err
+		}
+		if err := checkSchemaVersion(version); err != nil {
+			return err
+		}
+
+		report = &VerifyReport{SchemaVersion: version, Issues: []*VerifyIssue{}}
+		return verifyDb(tx, report)
+	}, func() {
+		report = nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+func verifyDb(tx kvdb.RTx, report *VerifyReport) error {
+	// there is nothing to check if the database is never opened by the
+	// indexer
+	stateBucket := tx.ReadBucket(indexerStateBucketName)
+	if stateBucket == nil {
+		return nil
+	}
+	if v := stateBucket.Get(getLastProcessedHeightKey()); v != nil {
+		height, err := uint64FromBytes(v)
+		if err != nil {
+			return ErrCorruptedStateDb
+		}
+		report.LastProcessedHeight = &height
+	}
+
+	tvlBucket := tx.ReadBucket(confirmedTvlBucketName)
+	if tvlBucket == nil {
+		return ErrCorruptedStateDb
+	}
+	if v := tvlBucket.Get(getConfirmedTvlKey()); v != nil {
+		tvl, err := uint64FromBytes(v)
+		if err != nil {
+			return ErrCorruptedStateDb
+		}
+		report.StoredConfirmedTvl = tvl
+	}
+
+	stakingTxBucket := tx.ReadBucket(stakingTxBucketName)
+	unbondingTxBucket := tx.ReadBucket(unbondingTxBucketName)
+	slashingTxBucket := tx.ReadBucket(slashingTxBucketName)
+	withdrawalTxBucket := tx.ReadBucket(withdrawalTxBucketName)
+	if stakingTxBucket == nil || unbondingTxBucket == nil || slashingTxBucket == nil || withdrawalTxBucket == nil {
+		return ErrCorruptedTransactionsDb
+	}
+
+	// the values of the staking txs counted in the confirmed tvl, i.e., the
+	// staking txs that are not overflow, by their hashes. The unbonded and
+	// slashed ones are removed as the unbonding and slashing txs are walked
+	confirmedStakes := make(map[chainhash.Hash]uint64)
+	err := stakingTxBucket.ForEach(func(k, v []byte) error {
+		report.NumStakingTxs++
+
+		var stakingTxProto proto.StakingTransaction
+		if err := pm.Unmarshal(v, &stakingTxProto); err != nil {
+			report.addIssue(CheckDecode, k, "invalid staking tx: %v", err)
+			return nil
+		}
+		report.checkInclusionHeight(k, stakingTxProto.InclusionHeight)
+
+		txHash, err := chainhash.NewHash(k)
+		if err != nil {
+			report.addIssue(CheckDecode, nil, "invalid staking tx hash %x: %v", k, err)
+			return nil
+		}
+		if !stakingTxProto.IsOverflow {
+			confirmedStakes[*txHash] = stakingTxProto.StakingValue
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = unbondingTxBucket.ForEach(func(k, v []byte) error {
+		report.NumUnbondingTxs++
+
+		var unbondingTxProto proto.UnbondingTransaction
+		if err := pm.Unmarshal(v, &unbondingTxProto); err != nil {
+			report.addIssue(CheckDecode, k, "invalid unbonding tx: %v", err)
+			return nil
+		}
+		report.checkInclusionHeight(k, unbondingTxProto.InclusionHeight)
+
+		if stakingTxBucket.Get(unbondingTxProto.StakingTxHash) == nil {
+			report.addIssue(CheckStakingTxReference, k,
+				"the unbonding tx spends the staking tx %x that is not stored", unbondingTxProto.StakingTxHash)
+			return nil
+		}
+
+		// the unbonded stake leaves the confirmed tvl
+		if stakingTxHash, err := chainhash.NewHash(unbondingTxProto.StakingTxHash); err == nil {
+			delete(confirmedStakes, *stakingTxHash)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = slashingTxBucket.ForEach(func(k, v []byte) error {
+		report.NumSlashingTxs++
+
+		var slashingTxProto proto.SlashingTransaction
+		if err := pm.Unmarshal(v, &slashingTxProto); err != nil {
+			report.addIssue(CheckDecode, k, "invalid slashing tx: %v", err)
+			return nil
+		}
+		report.checkInclusionHeight(k, slashingTxProto.InclusionHeight)
+
+		if stakingTxBucket.Get(slashingTxProto.StakingTxHash) == nil {
+			report.addIssue(CheckStakingTxReference, k,
+				"the slashing tx belongs to the staking tx %x that is not stored", slashingTxProto.StakingTxHash)
+			return nil
+		}
+		if len(slashingTxProto.UnbondingTxHash) != 0 {
+			if unbondingTxBucket.Get(slashingTxProto.UnbondingTxHash) == nil {
+				report.addIssue(CheckUnbondingTxReference, k,
+					"the slashing tx spends the unbonding tx %x that is not stored", slashingTxProto.UnbondingTxHash)
+			}
+			// the stake already left the confirmed tvl when it was unbonded
+			return nil
+		}
+
+		// the slashed stake leaves the confirmed tvl
+		if stakingTxHash, err := chainhash.NewHash(slashingTxProto.StakingTxHash); err == nil {
+			delete(confirmedStakes, *stakingTxHash)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = withdrawalTxBucket.ForEach(func(k, v []byte) error {
+		report.NumWithdrawalTxs++
+
+		var withdrawalTxProto proto.WithdrawalTransaction
+		if err := pm.Unmarshal(v, &withdrawalTxProto); err != nil {
+			report.addIssue(CheckDecode, k, "invalid withdrawal tx: %v", err)
+			return nil
+		}
+		report.checkInclusionHeight(k, withdrawalTxProto.InclusionHeight)
+
+		if stakingTxBucket.Get(withdrawalTxProto.StakingTxHash) == nil {
+			report.addIssue(CheckStakingTxReference, k,
+				"the withdrawal tx belongs to the staking tx %x that is not stored", withdrawalTxProto.StakingTxHash)
+		}
+		if len(withdrawalTxProto.UnbondingTxHash) != 0 && unbondingTxBucket.Get(withdrawalTxProto.UnbondingTxHash) == nil {
+			report.addIssue(CheckUnbondingTxReference, k,
+				"the withdrawal tx spends the unbonding tx %x that is not stored", withdrawalTxProto.UnbondingTxHash)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, stake := range confirmedStakes {
+		report.ComputedConfirmedTvl += stake
+	}
+	if report.ComputedConfirmedTvl != report.StoredConfirmedTvl {
+		report.addIssue(CheckConfirmedTvl, nil,
+			"the stored confirmed tvl %d differs from the computed one %d",
+			report.StoredConfirmedTvl, report.ComputedConfirmedTvl)
+	}
+
+	return nil
+}
@@ -20,6 +20,7 @@ import (
 
 	"github.com/babylonlabs-io/staking-indexer/cmd/sid/cli"
 	"github.com/babylonlabs-io/staking-indexer/config"
+	"github.com/babylonlabs-io/staking-indexer/consumer"
 	"github.com/babylonlabs-io/staking-indexer/testutils"
 	"github.com/babylonlabs-io/staking-indexer/testutils/datagen"
 )
@@ -73,6 +74,21 @@ func TestQueueConsumer(t *testing.T) {
 		err = queueConsumer.StakingQueue.DeleteMessage(stakingEventBytes.Receipt)
 		require.NoError(t, err)
 	}
+
+	// the envelopes are published as they are to the queue of the wrapped
+	// events
+	env, err := consumer.WrapEvent(1, 100, hex.EncodeToString(bbndatagen.GenRandomByteArray(r, 32)), stakingEventList[0])
+	require.NoError(t, err)
+	err = queueConsumer.PushEventEnvelope(env)
+	require.NoError(t, err)
+
+	stakingEventBytes := <-stakingChan
+	var receivedEnv consumer.EventEnvelope
+	err = json.Unmarshal([]byte(stakingEventBytes.Body), &receivedEnv)
+	require.NoError(t, err)
+	require.Equal(t, env, &receivedEnv)
+	err = queueConsumer.StakingQueue.DeleteMessage(stakingEventBytes.Receipt)
+	require.NoError(t, err)
 }
 
 // TestStakingLifeCycle covers the following life cycle
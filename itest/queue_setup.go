@@ -6,15 +6,15 @@ import (
 	"testing"
 
 	"github.com/babylonlabs-io/staking-queue-client/client"
-	"github.com/babylonlabs-io/staking-queue-client/queuemngr"
 	"github.com/rabbitmq/amqp091-go"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 
 	"github.com/babylonlabs-io/staking-indexer/config"
+	"github.com/babylonlabs-io/staking-indexer/consumer"
 )
 
-func setupTestQueueConsumer(t *testing.T, cfg *config.QueueConfig) (*queuemngr.QueueManager, error) {
+func setupTestQueueConsumer(t *testing.T, cfg *config.QueueConfig) (*consumer.QueueConsumer, error) {
 	amqpURI := fmt.Sprintf("amqp://%s:%s@%s", cfg.User, cfg.Password, cfg.Url)
 	conn, err := amqp091.Dial(amqpURI)
 	if err != nil {
@@ -32,7 +32,7 @@ func setupTestQueueConsumer(t *testing.T, cfg *config.QueueConfig) (*queuemngr.Q
 
 	validQueueCfg, err := cfg.ToQueueClientConfig()
 	require.NoError(t, err)
-	queues, err := queuemngr.NewQueueManager(validQueueCfg, zap.NewNop())
+	queues, err := consumer.NewQueueConsumer(validQueueCfg, cfg.BareEvents, zap.NewNop())
 	require.NoError(t, err)
 
 	return queues, nil
@@ -0,0 +1,21 @@
+#!/bin/bash
+
+# Check if the Postgres container is already running
+POSTGRES_CONTAINER_NAME="postgres"
+if [ $(docker ps -q -f name=^/${POSTGRES_CONTAINER_NAME}$) ]; then
+    echo "Postgres container already running. Skipping Postgres startup."
+else
+    echo "Starting Postgres"
+    # Start Postgres
+    docker compose up -d postgres
+fi
+
+# Wait until Postgres accepts connections
+for i in $(seq 1 30); do
+    if docker exec ${POSTGRES_CONTAINER_NAME} pg_isready -U sid -d sid_test > /dev/null 2>&1; then
+        exit 0
+    fi
+    sleep 1
+done
+echo "Postgres is not ready"
+exit 1
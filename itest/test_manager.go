@@ -3,6 +3,7 @@ package e2etest
 import (
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"math/rand"
 	"os"
 	"path/filepath"
@@ -13,7 +14,6 @@ import (
 	"github.com/babylonlabs-io/babylon/btcstaking"
 	"github.com/babylonlabs-io/networks/parameters/parser"
 	queuecli "github.com/babylonlabs-io/staking-queue-client/client"
-	"github.com/babylonlabs-io/staking-queue-client/queuemngr"
 	"github.com/btcsuite/btcd/btcec/v2"
 	"github.com/btcsuite/btcd/btcec/v2/schnorr"
 	"github.com/btcsuite/btcd/btcutil"
@@ -29,6 +29,7 @@ import (
 	"github.com/babylonlabs-io/staking-indexer/btcclient"
 	"github.com/babylonlabs-io/staking-indexer/btcscanner"
 	"github.com/babylonlabs-io/staking-indexer/config"
+	"github.com/babylonlabs-io/staking-indexer/consumer"
 	"github.com/babylonlabs-io/staking-indexer/indexer"
 	"github.com/babylonlabs-io/staking-indexer/indexerstore"
 	"github.com/babylonlabs-io/staking-indexer/log"
@@ -50,7 +51,7 @@ type TestManager struct {
 	WalletClient           *rpcclient.Client
 	MinerAddr              btcutil.Address
 	DirPath                string
-	QueueConsumer          *queuemngr.QueueManager
+	QueueConsumer          *consumer.QueueConsumer
 	StakingEventChan       <-chan queuecli.QueueMessage
 	UnbondingEventChan     <-chan queuecli.QueueMessage
 	WithdrawEventChan      <-chan queuecli.QueueMessage
@@ -132,7 +133,11 @@ func StartWithBitcoinHandler(t *testing.T, h *BitcoindTestHandler, minerAddress
 	require.NoError(t, err)
 	versionedParams := paramsRetriever.VersionedParams()
 	require.NoError(t, err)
-	scanner, err := btcscanner.NewBTCScanner(versionedParams.Versions[0].ConfirmationDepth, logger, btcClient, btcNotifier)
+	db, err := cfg.DatabaseConfig.GetDbBackend()
+	require.NoError(t, err)
+	is, err := indexerstore.NewIndexerStore(db)
+	require.NoError(t, err)
+	scanner, err := btcscanner.NewBTCScanner(versionedParams.Versions[0].ConfirmationDepth, logger, btcClient, btcNotifier, &blockHashStore{is: is})
 	require.NoError(t, err)
 
 	// create event consumer
@@ -150,10 +155,7 @@ func StartWithBitcoinHandler(t *testing.T, h *BitcoindTestHandler, minerAddress
 	confirmedInfoEventChan, err := queueConsumer.ConfirmedInfoQueue.ReceiveMessages()
 	require.NoError(t, err)
 
-	db, err := cfg.DatabaseConfig.GetDbBackend()
-	require.NoError(t, err)
-	si, err := indexer.NewStakingIndexer(cfg, logger, queueConsumer, db, versionedParams, scanner)
-	require.NoError(t, err)
+	si := indexer.NewStakingIndexerWithStore(cfg, logger, queueConsumer, is, versionedParams, scanner)
 
 	interceptor, err := signal.Intercept()
 	require.NoError(t, err)
@@ -280,6 +282,21 @@ func (tm *TestManager) SendTxWithNConfirmations(t *testing.T, tx *wire.MsgTx, n
 	t.Logf("sent tx %s with %d confirmations", txHash.String(), n)
 }
 
+// blockHashStore gives the BTC scanner the hashes of the processed blocks in
+// the indexer store, as the sid start command does
+type blockHashStore struct {
+	is indexerstore.Store
+}
+
+func (s *blockHashStore) GetBlockHash(height uint64) (*chainhash.Hash, error) {
+	blockHash, err := s.is.GetBlockHash(height)
+	if errors.Is(err, indexerstore.ErrBlockJournalNotFound) {
+		return nil, btcscanner.ErrBlockHashNotFound
+	}
+
+	return blockHash, err
+}
+
 func retrieveTransactionFromMempool(t *testing.T, client *rpcclient.Client, hashes []*chainhash.Hash) []*btcutil.Tx {
 	var txes []*btcutil.Tx
 	for _, txHash := range hashes {
@@ -311,8 +328,7 @@ func (tm *TestManager) WaitForNConfirmations(t *testing.T, n int) {
 func (tm *TestManager) CheckNextStakingEvent(t *testing.T, stakingTxHash chainhash.Hash) {
 	stakingEventBytes := <-tm.StakingEventChan
 	var activeStakingEvent queuecli.ActiveStakingEvent
-	err := json.Unmarshal([]byte(stakingEventBytes.Body), &activeStakingEvent)
-	require.NoError(t, err)
+	tm.decodeEvent(t, stakingEventBytes.Body, &activeStakingEvent)
 
 	storedStakingTx, err := tm.Si.GetStakingTxByHash(&stakingTxHash)
 	require.NotNil(t, storedStakingTx)
@@ -343,8 +359,7 @@ func (tm *TestManager) CheckNoStakingEvent(t *testing.T) {
 func (tm *TestManager) CheckNextUnbondingEvent(t *testing.T, unbondingTxHash chainhash.Hash) {
 	unbondingEventBytes := <-tm.UnbondingEventChan
 	var unbondingEvent queuecli.UnbondingStakingEvent
-	err := json.Unmarshal([]byte(unbondingEventBytes.Body), &unbondingEvent)
-	require.NoError(t, err)
+	tm.decodeEvent(t, unbondingEventBytes.Body, &unbondingEvent)
 	require.Equal(t, unbondingTxHash.String(), unbondingEvent.UnbondingTxHashHex)
 
 	storedUnbondingTx, err := tm.Si.GetUnbondingTxByHash(&unbondingTxHash)
@@ -360,11 +375,10 @@ func (tm *TestManager) CheckNextUnbondingEvent(t *testing.T, unbondingTxHash cha
 func (tm *TestManager) CheckNextWithdrawEvent(t *testing.T, stakingTxHash chainhash.Hash) {
 	withdrawEventBytes := <-tm.WithdrawEventChan
 	var withdrawEvent queuecli.WithdrawStakingEvent
-	err := json.Unmarshal([]byte(withdrawEventBytes.Body), &withdrawEvent)
-	require.NoError(t, err)
+	tm.decodeEvent(t, withdrawEventBytes.Body, &withdrawEvent)
 	require.Equal(t, stakingTxHash.String(), withdrawEvent.StakingTxHashHex)
 
-	err = tm.QueueConsumer.WithdrawQueue.DeleteMessage(withdrawEventBytes.Receipt)
+	err := tm.QueueConsumer.WithdrawQueue.DeleteMessage(withdrawEventBytes.Receipt)
 	require.NoError(t, err)
 }
 
@@ -375,8 +389,7 @@ func (tm *TestManager) CheckConfirmedInfoEvent(t *testing.T, height, tvl uint64)
 		confirmedInfoEventBytes := <-tm.ConfirmedInfoEventChan
 		err := tm.QueueConsumer.ConfirmedInfoQueue.DeleteMessage(confirmedInfoEventBytes.Receipt)
 		require.NoError(t, err)
-		err = json.Unmarshal([]byte(confirmedInfoEventBytes.Body), &confirmedInfoEv)
-		require.NoError(t, err)
+		tm.decodeEvent(t, confirmedInfoEventBytes.Body, &confirmedInfoEv)
 		if height != confirmedInfoEv.Height {
 			continue
 		}
@@ -392,8 +405,7 @@ func (tm *TestManager) CheckNextUnconfirmedEvent(t *testing.T, confirmedTvl, tot
 		btcInfoEventBytes := <-tm.BtcInfoEventChan
 		err := tm.QueueConsumer.BtcInfoQueue.DeleteMessage(btcInfoEventBytes.Receipt)
 		require.NoError(t, err)
-		err = json.Unmarshal([]byte(btcInfoEventBytes.Body), &btcInfoEvent)
-		require.NoError(t, err)
+		tm.decodeEvent(t, btcInfoEventBytes.Body, &btcInfoEvent)
 		if confirmedTvl != btcInfoEvent.ConfirmedTvl {
 			continue
 		}
@@ -405,6 +417,22 @@ func (tm *TestManager) CheckNextUnconfirmedEvent(t *testing.T, confirmedTvl, tot
 	}
 }
 
+// decodeEvent unmarshals the event wrapped in the envelope of the message
+// body, or the message body itself if the events are published bare
+func (tm *TestManager) decodeEvent(t *testing.T, body string, ev interface{}) {
+	eventBytes := []byte(body)
+	if !tm.Config.QueueConfig.BareEvents {
+		var env consumer.EventEnvelope
+		err := json.Unmarshal(eventBytes, &env)
+		require.NoError(t, err)
+		require.Equal(t, consumer.EventSchemaVersion, env.SchemaVersion)
+		eventBytes = env.Event
+	}
+
+	err := json.Unmarshal(eventBytes, ev)
+	require.NoError(t, err)
+}
+
 func (tm *TestManager) WaitForStakingTxStored(t *testing.T, txHash chainhash.Hash) *indexerstore.StoredStakingTransaction {
 	var storedTx indexerstore.StoredStakingTransaction
 	require.Eventually(t, func() bool {
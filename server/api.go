@@ -0,0 +1,364 @@
+package server
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"go.uber.org/zap"
+
+	"github.com/babylonlabs-io/staking-indexer/indexerstore"
+	"github.com/babylonlabs-io/staking-indexer/utils"
+)
+
+const (
+	// defaultPageLimit is the number of the staking txs in a page if the
+	// limit is not given
+	defaultPageLimit = 100
+	// maxPageLimit caps the number of the staking txs in a page
+	maxPageLimit = 1000
+)
+
+// QuerySource is the source of the data served by the query API. It is
+// implemented by the staking indexer
+type QuerySource interface {
+	GetStakingTxByHash(hash *chainhash.Hash) (*indexerstore.StoredStakingTransaction, error)
+	GetUnbondingTxByStakingTxHash(stakingTxHash *chainhash.Hash) (*indexerstore.StoredUnbondingTransaction, error)
+	GetStakingTxsByHeightRange(startHeight, endHeight uint64, afterTxHash *chainhash.Hash, limit int) ([]*indexerstore.StoredStakingTransaction, error)
+	GetConfirmedTvl() (uint64, error)
+	GetLastProcessedHeight() (uint64, error)
+}
+
+type StateTransitionResponse struct {
+	State  string `json:"state"`
+	Height uint64 `json:"height"`
+	TxHash string `json:"tx_hash,omitempty"`
+}
+
+type StakingTransactionResponse struct {
+	TxHash                string                     `json:"tx_hash"`
+	TxHex                 string                     `json:"tx_hex"`
+	StakingOutputIndex    uint32                     `json:"staking_output_index"`
+	InclusionHeight       uint64                     `json:"inclusion_height"`
+	StakerPkHex           string                     `json:"staker_pk_hex"`
+	FinalityProviderPkHex string                     `json:"finality_provider_pk_hex"`
+	StakingTime           uint32                     `json:"staking_time"`
+	StakingValue          uint64                     `json:"staking_value"`
+	IsOverflow            bool                       `json:"is_overflow"`
+	State                 string                     `json:"state"`
+	StateTransitions      []*StateTransitionResponse `json:"state_transitions"`
+}
+
+type UnbondingTransactionResponse struct {
+	TxHash          string `json:"tx_hash"`
+	TxHex           string `json:"tx_hex"`
+	StakingTxHash   string `json:"staking_tx_hash"`
+	InclusionHeight uint64 `json:"inclusion_height"`
+	UnbondingTime   uint32 `json:"unbonding_time"`
+}
+
+type StakingTransactionsResponse struct {
+	Transactions []*StakingTransactionResponse `json:"transactions"`
+	// NextPageToken is passed as page_token to get the next page, it is
+	// empty on the last page
+	NextPageToken string `json:"next_page_token,omitempty"`
+}
+
+type ConfirmedTvlResponse struct {
+	ConfirmedTvl uint64 `json:"confirmed_tvl"`
+}
+
+type LastProcessedHeightResponse struct {
+	LastProcessedHeight uint64 `json:"last_processed_height"`
+}
+
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// queryHandler answers the read-only queries of the indexed data
+type queryHandler struct {
+	source QuerySource
+
+	logger *zap.Logger
+}
+
+// NewQueryHandler returns the handler of the query API, with the routes
+//   - GET /v1/staking-transactions?start_height=&end_height=&limit=&page_token=
+//   - GET /v1/staking-transactions/{tx_hash}
+//   - GET /v1/staking-transactions/{tx_hash}/unbonding
+//   - GET /v1/tvl
+//   - GET /v1/last-processed-height
+func NewQueryHandler(source QuerySource, logger *zap.Logger) http.Handler {
+	qh := &queryHandler{
+		source: source,
+		logger: logger.With(zap.String("module", "query api")),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/staking-transactions", qh.listStakingTransactions)
+	mux.HandleFunc("GET /v1/staking-transactions/{tx_hash}", qh.getStakingTransaction)
+	mux.HandleFunc("GET /v1/staking-transactions/{tx_hash}/unbonding", qh.getUnbondingTransaction)
+	mux.HandleFunc("GET /v1/tvl", qh.getConfirmedTvl)
+	mux.HandleFunc("GET /v1/last-processed-height", qh.getLastProcessedHeight)
+
+	return mux
+}
+
+func (qh *queryHandler) getStakingTransaction(w http.ResponseWriter, r *http.Request) {
+	txHash, err := chainhash.NewHashFromStr(r.PathValue("tx_hash"))
+	if err != nil {
+		qh.writeError(w, http.StatusBadRequest, fmt.Errorf("invalid tx hash: %w", err))
+		return
+	}
+
+	stakingTx, err := qh.source.GetStakingTxByHash(txHash)
+	if err != nil {
+		qh.writeInternalError(w, err)
+		return
+	}
+	if stakingTx == nil {
+		qh.writeError(w, http.StatusNotFound, indexerstore.ErrTransactionNotFound)
+		return
+	}
+
+	resp, err := newStakingTransactionResponse(stakingTx)
+	if err != nil {
+		qh.writeInternalError(w, err)
+		return
+	}
+
+	qh.writeJSON(w, http.StatusOK, resp)
+}
+
+func (qh *queryHandler) getUnbondingTransaction(w http.ResponseWriter, r *http.Request) {
+	stakingTxHash, err := chainhash.NewHashFromStr(r.PathValue("tx_hash"))
+	if err != nil {
+		qh.writeError(w, http.StatusBadRequest, fmt.Errorf("invalid tx hash: %w", err))
+		return
+	}
+
+	unbondingTx, err := qh.source.GetUnbondingTxByStakingTxHash(stakingTxHash)
+	if err != nil {
+		qh.writeInternalError(w, err)
+		return
+	}
+	if unbondingTx == nil {
+		qh.writeError(w, http.StatusNotFound, indexerstore.ErrTransactionNotFound)
+		return
+	}
+
+	txBytes, err := utils.SerializeBtcTransaction(unbondingTx.Tx)
+	if err != nil {
+		qh.writeInternalError(w, err)
+		return
+	}
+
+	qh.writeJSON(w, http.StatusOK, &UnbondingTransactionResponse{
+		TxHash:          unbondingTx.Tx.TxHash().String(),
+		TxHex:           hex.EncodeToString(txBytes),
+		StakingTxHash:   unbondingTx.StakingTxHash.String(),
+		InclusionHeight: unbondingTx.InclusionHeight,
+		UnbondingTime:   unbondingTx.UnbondingTime,
+	})
+}
+
+func (qh *queryHandler) listStakingTransactions(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	startHeight, err := strconv.ParseUint(query.Get("start_height"), 10, 64)
+	if err != nil {
+		qh.writeError(w, http.StatusBadRequest, fmt.Errorf("invalid start_height: %w", err))
+		return
+	}
+	endHeight, err := strconv.ParseUint(query.Get("end_height"), 10, 64)
+	if err != nil {
+		qh.writeError(w, http.StatusBadRequest, fmt.Errorf("invalid end_height: %w", err))
+		return
+	}
+	if startHeight > endHeight {
+		qh.writeError(w, http.StatusBadRequest,
+			fmt.Errorf("start_height %d should not be greater than end_height %d", startHeight, endHeight))
+		return
+	}
+
+	limit := defaultPageLimit
+	if limitStr := query.Get("limit"); limitStr != "" {
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 || limit > maxPageLimit {
+			qh.writeError(w, http.StatusBadRequest,
+				fmt.Errorf("invalid limit %s, it should be in [1, %d]", limitStr, maxPageLimit))
+			return
+		}
+	}
+
+	// the page token is the hash of the last tx of the previous page
+	var afterTxHash *chainhash.Hash
+	if pageToken := query.Get("page_token"); pageToken != "" {
+		afterTxHash, err = chainhash.NewHashFromStr(pageToken)
+		if err != nil {
+			qh.writeError(w, http.StatusBadRequest, fmt.Errorf("invalid page_token: %w", err))
+			return
+		}
+	}
+
+	// one more tx is read to know whether there is a next page
+	stakingTxs, err := qh.source.GetStakingTxsByHeightRange(startHeight, endHeight, afterTxHash, limit+1)
+	if errors.Is(err, indexerstore.ErrTransactionNotFound) {
+		qh.writeError(w, http.StatusBadRequest, fmt.Errorf("invalid page_token: %w", err))
+		return
+	}
+	if err != nil {
+		qh.writeInternalError(w, err)
+		return
+	}
+
+	resp := &StakingTransactionsResponse{
+		Transactions: make([]*StakingTransactionResponse, 0, min(len(stakingTxs), limit)),
+	}
+	for i, stakingTx := range stakingTxs {
+		if i == limit {
+			resp.NextPageToken = stakingTxs[i-1].Tx.TxHash().String()
+			break
+		}
+
+		txResp, err := newStakingTransactionResponse(stakingTx)
+		if err != nil {
+			qh.writeInternalError(w, err)
+			return
+		}
+		resp.Transactions = append(resp.Transactions, txResp)
+	}
+
+	qh.writeJSON(w, http.StatusOK, resp)
+}
+
+func (qh *queryHandler) getConfirmedTvl(w http.ResponseWriter, _ *http.Request) {
+	confirmedTvl, err := qh.source.GetConfirmedTvl()
+	if err != nil {
+		qh.writeInternalError(w, err)
+		return
+	}
+
+	qh.writeJSON(w, http.StatusOK, &ConfirmedTvlResponse{ConfirmedTvl: confirmedTvl})
+}
+
+func (qh *queryHandler) getLastProcessedHeight(w http.ResponseWriter, _ *http.Request) {
+	height, err := qh.source.GetLastProcessedHeight()
+	if err != nil {
+		if errors.Is(err, indexerstore.ErrLastProcessedHeightNotFound) {
+			qh.writeError(w, http.StatusNotFound, err)
+			return
+		}
+		qh.writeInternalError(w, err)
+		return
+	}
+
+	qh.writeJSON(w, http.StatusOK, &LastProcessedHeightResponse{LastProcessedHeight: height})
+}
+
+func (qh *queryHandler) writeJSON(w http.ResponseWriter, statusCode int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		qh.logger.Error("failed to write the response", zap.Error(err))
+	}
+}
+
+func (qh *queryHandler) writeError(w http.ResponseWriter, statusCode int, err error) {
+	qh.writeJSON(w, statusCode, &ErrorResponse{Error: err.Error()})
+}
+
+// writeInternalError logs the error and hides it from the client
+func (qh *queryHandler) writeInternalError(w http.ResponseWriter, err error) {
+	qh.logger.Error("failed to answer the query", zap.Error(err))
+	qh.writeError(w, http.StatusInternalServerError, errors.New("internal error"))
+}
+
+func newStakingTransactionResponse(stakingTx *indexerstore.StoredStakingTransaction) (*StakingTransactionResponse, error) {
+	txBytes, err := utils.SerializeBtcTransaction(stakingTx.Tx)
+	if err != nil {
+		return nil, err
+	}
+
+	transitions := make([]*StateTransitionResponse, len(stakingTx.StateTransitions))
+	for i, transition := range stakingTx.StateTransitions {
+		transitions[i] = &StateTransitionResponse{
+			State:  transition.State.String(),
+			Height: transition.Height,
+		}
+		if transition.TxHash != nil {
+			transitions[i].TxHash = transition.TxHash.String()
+		}
+	}
+
+	return &StakingTransactionResponse{
+		TxHash:                stakingTx.Tx.TxHash().String(),
+		TxHex:                 hex.EncodeToString(txBytes),
+		StakingOutputIndex:    stakingTx.StakingOutputIdx,
+		InclusionHeight:       stakingTx.InclusionHeight,
+		StakerPkHex:           hex.EncodeToString(schnorr.SerializePubKey(stakingTx.StakerPk)),
+		FinalityProviderPkHex: hex.EncodeToString(schnorr.SerializePubKey(stakingTx.FinalityProviderPk)),
+		StakingTime:           stakingTx.StakingTime,
+		StakingValue:          stakingTx.StakingValue,
+		IsOverflow:            stakingTx.IsOverflow,
+		State:                 stakingTx.State.String(),
+		StateTransitions:      transitions,
+	}, nil
+}
+
+// APIServer serves the query API over HTTP
+type APIServer struct {
+	svr *http.Server
+
+	logger *zap.Logger
+}
+
+func NewAPIServer(addr string, source QuerySource, logger *zap.Logger) *APIServer {
+	return &APIServer{
+		svr: &http.Server{
+			Handler:           NewQueryHandler(source, logger),
+			Addr:              addr,
+			ReadTimeout:       5 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			IdleTimeout:       30 * time.Second,
+			ReadHeaderTimeout: 2 * time.Second,
+		},
+		logger: logger,
+	}
+}
+
+func (as *APIServer) Start() {
+	as.logger.Info("Starting query API server",
+		zap.String("address", as.svr.Addr))
+
+	if err := as.svr.ListenAndServe(); err != nil {
+		if errors.Is(err, http.ErrServerClosed) {
+			// the query API server is shutdown
+			return
+		}
+		as.logger.Fatal("failed to start query API server",
+			zap.Error(err))
+	}
+}
+
+func (as *APIServer) Stop() {
+	as.logger.Info("Stopping query API server")
+
+	if err := as.svr.Shutdown(context.Background()); err != nil {
+		as.logger.Error("failed to stop the query API server",
+			zap.Error(err))
+		as.logger.Info("force stopping the query API server")
+		if err = as.svr.Close(); err != nil {
+			as.logger.Error("failed to force stopping the query API server",
+				zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package server_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	bbndatagen "github.com/babylonlabs-io/babylon/testutil/datagen"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/babylonlabs-io/staking-indexer/config"
+	"github.com/babylonlabs-io/staking-indexer/indexer"
+	"github.com/babylonlabs-io/staking-indexer/server"
+	"github.com/babylonlabs-io/staking-indexer/testutils/datagen"
+	"github.com/babylonlabs-io/staking-indexer/testutils/mocks"
+	"github.com/babylonlabs-io/staking-indexer/types"
+)
+
+// FuzzQueryAPI tests that the query API answers with the indexed data
+func FuzzQueryAPI(f *testing.F) {
+	bbndatagen.AddRandomSeedsToFuzzer(f, 5)
+
+	f.Fuzz(func(t *testing.T, seed int64) {
+		r := rand.New(rand.NewSource(seed))
+
+		homePath := filepath.Join(t.TempDir(), "indexer")
+		cfg := config.DefaultConfigWithHome(homePath)
+
+		sysParamsVersions := datagen.GenerateGlobalParamsVersions(r, t)
+
+		ctl := gomock.NewController(t)
+		mockedConsumer := mocks.NewMockEventConsumer(ctl)
+		mockedConsumer.EXPECT().PushStakingEvent(gomock.Any()).Return(nil).AnyTimes()
+		mockedConsumer.EXPECT().PushUnbondingEvent(gomock.Any()).Return(nil).AnyTimes()
+
+		db, err := cfg.DatabaseConfig.GetDbBackend()
+		require.NoError(t, err)
+		stakingIndexer, err := indexer.NewStakingIndexer(cfg, zap.NewNop(), mockedConsumer, db, sysParamsVersions, mocks.NewMockBtcScanner(ctl))
+		require.NoError(t, err)
+		defer func() {
+			err = db.Close()
+			require.NoError(t, err)
+		}()
+
+		apiServer := httptest.NewServer(server.NewQueryHandler(stakingIndexer, zap.NewNop()))
+		defer apiServer.Close()
+
+		get := func(path string, v interface{}) int {
+			resp, err := http.Get(apiServer.URL + path)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+			if v != nil && resp.StatusCode == http.StatusOK {
+				err = json.NewDecoder(resp.Body).Decode(v)
+				require.NoError(t, err)
+			}
+			return resp.StatusCode
+		}
+
+		// nothing is processed yet
+		require.Equal(t, http.StatusNotFound, get("/v1/last-processed-height", nil))
+
+		// stake in a block, and unbond some of the delegations in the next
+		height := sysParamsVersions.Versions[0].ActivationHeight + 1
+		params := sysParamsVersions.GetVersionedGlobalParamsByHeight(height)
+		require.NotNil(t, params)
+		numStakingTxs := r.Intn(8) + 1
+		stakingTxs := make([]*btcutil.Tx, numStakingTxs)
+		var unbondingTxs []*btcutil.Tx
+		unbondingTxByStakingTx := make(map[string]*btcutil.Tx)
+		for i := range stakingTxs {
+			stakingData := datagen.GenerateTestStakingData(t, r, params)
+			_, stakingTxs[i] = datagen.GenerateStakingTxFromTestData(t, r, params, stakingData)
+			if r.Intn(2) == 0 {
+				unbondingTx := datagen.GenerateUnbondingTxFromStaking(t, params, stakingData, stakingTxs[i].Hash(), 0)
+				unbondingTxs = append(unbondingTxs, unbondingTx)
+				unbondingTxByStakingTx[stakingTxs[i].Hash().String()] = unbondingTx
+			}
+		}
+		blocks := []*types.IndexedBlock{
+			{
+				Height: int32(height),
+				Header: &wire.BlockHeader{Timestamp: time.Now()},
+				Txs:    stakingTxs,
+			},
+			{
+				Height: int32(height + 1),
+				Header: &wire.BlockHeader{Timestamp: time.Now()},
+				Txs:    unbondingTxs,
+			},
+		}
+		for _, b := range blocks {
+			err := stakingIndexer.HandleConfirmedBlock(b)
+			require.NoError(t, err)
+		}
+
+		var heightResp server.LastProcessedHeightResponse
+		require.Equal(t, http.StatusOK, get("/v1/last-processed-height", &heightResp))
+		require.Equal(t, height+1, heightResp.LastProcessedHeight)
+
+		var tvlResp server.ConfirmedTvlResponse
+		require.Equal(t, http.StatusOK, get("/v1/tvl", &tvlResp))
+		confirmedTvl, err := stakingIndexer.GetConfirmedTvl()
+		require.NoError(t, err)
+		require.Equal(t, confirmedTvl, tvlResp.ConfirmedTvl)
+
+		for _, stakingTx := range stakingTxs {
+			txHash := stakingTx.Hash().String()
+
+			var txResp server.StakingTransactionResponse
+			require.Equal(t, http.StatusOK, get("/v1/staking-transactions/"+txHash, &txResp))
+			require.Equal(t, txHash, txResp.TxHash)
+			require.Equal(t, height, txResp.InclusionHeight)
+
+			var unbondingResp server.UnbondingTransactionResponse
+			unbondingTx, unbonded := unbondingTxByStakingTx[txHash]
+			if !unbonded {
+				require.Contains(t, []string{"active", "overflow"}, txResp.State)
+				require.Equal(t, http.StatusNotFound, get("/v1/staking-transactions/"+txHash+"/unbonding", nil))
+				continue
+			}
+			require.Equal(t, "unbonding", txResp.State)
+			require.Len(t, txResp.StateTransitions, 2)
+			require.Equal(t, unbondingTx.Hash().String(), txResp.StateTransitions[1].TxHash)
+			require.Equal(t, http.StatusOK, get("/v1/staking-transactions/"+txHash+"/unbonding", &unbondingResp))
+			require.Equal(t, unbondingTx.Hash().String(), unbondingResp.TxHash)
+			require.Equal(t, txHash, unbondingResp.StakingTxHash)
+			require.Equal(t, height+1, unbondingResp.InclusionHeight)
+		}
+
+		unknownTxHash := bbndatagen.GenRandomBtcdHash(r)
+		require.Equal(t, http.StatusNotFound, get("/v1/staking-transactions/"+unknownTxHash.String(), nil))
+		require.Equal(t, http.StatusBadRequest, get("/v1/staking-transactions/invalid", nil))
+
+		// the pages cover all the staking txs in the range
+		limit := r.Intn(numStakingTxs) + 1
+		listed := make(map[string]bool)
+		pageToken := ""
+		for {
+			var pageResp server.StakingTransactionsResponse
+			path := fmt.Sprintf("/v1/staking-transactions?start_height=%d&end_height=%d&limit=%d&page_token=%s",
+				height, height+1, limit, pageToken)
+			require.Equal(t, http.StatusOK, get(path, &pageResp))
+			require.LessOrEqual(t, len(pageResp.Transactions), limit)
+			for _, txResp := range pageResp.Transactions {
+				require.False(t, listed[txResp.TxHash])
+				listed[txResp.TxHash] = true
+			}
+			if pageResp.NextPageToken == "" {
+				break
+			}
+			require.Len(t, pageResp.Transactions, limit)
+			pageToken = pageResp.NextPageToken
+		}
+		require.Len(t, listed, numStakingTxs)
+		for _, stakingTx := range stakingTxs {
+			require.True(t, listed[stakingTx.Hash().String()])
+		}
+
+		var pageResp server.StakingTransactionsResponse
+		path := fmt.Sprintf("/v1/staking-transactions?start_height=%d&end_height=%d", height+1, height+1)
+		require.Equal(t, http.StatusOK, get(path, &pageResp))
+		require.Empty(t, pageResp.Transactions)
+		require.Empty(t, pageResp.NextPageToken)
+
+		path = fmt.Sprintf("/v1/staking-transactions?start_height=%d&end_height=%d", height+1, height)
+		require.Equal(t, http.StatusBadRequest, get(path, nil))
+		path = fmt.Sprintf("/v1/staking-transactions?start_height=%d&end_height=%d&limit=0", height, height)
+		require.Equal(t, http.StatusBadRequest, get(path, nil))
+		path = fmt.Sprintf("/v1/staking-transactions?start_height=%d&end_height=%d&page_token=%s", height, height, unknownTxHash)
+		require.Equal(t, http.StatusBadRequest, get(path, nil))
+	})
+}
@@ -0,0 +1,171 @@
+package server
+
+import (
+	"github.com/babylonlabs-io/staking-queue-client/client"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/babylonlabs-io/staking-indexer/consumer"
+	"github.com/babylonlabs-io/staking-indexer/proto"
+)
+
+const (
+	// eventBatchSize is the max number of stored events read at once
+	eventBatchSize = 100
+)
+
+var _ proto.EventServiceServer = (*EventService)(nil)
+
+// EventSource is the source of the events streamed to the subscribers. It is
+// implemented by the staking indexer
+type EventSource interface {
+	// GetOutboxEvents returns at most limit events in the outbox starting
+	// from the one with the given sequence
+	GetOutboxEvents(fromSeq uint64, limit int) ([]*consumer.EventEnvelope, error)
+	// GetOutboxSequenceFromHeight returns the sequence of the first event in
+	// the outbox caused by a block at or above the given height, or the
+	// sequence of the next event if there is no such event
+	GetOutboxSequenceFromHeight(height uint64) (uint64, error)
+	// EventUpdates returns a channel closed once there are new events
+	EventUpdates() <-chan struct{}
+	// LatestBtcInfoEvent returns the latest btc info event, or nil if there
+	// is none
+	LatestBtcInfoEvent() *consumer.EventEnvelope
+}
+
+// EventService is the implementation of the gRPC service streaming the events
+// of the indexer. The events in the outbox are streamed in the order of their
+// sequences, starting from the stored ones and following the new ones as they
+// are committed, so that there is no gap between the history and the live
+// events. A subscription from a sequence or a height pruned from the outbox is
+// refused.
+// The btc info events, which are not written to the outbox, are
+// streamed live in unsequenced envelopes after the history
+type EventService struct {
+	proto.UnimplementedEventServiceServer
+
+	source EventSource
+
+	logger *zap.Logger
+
+	quit <-chan struct{}
+}
+
+func NewEventService(source EventSource, logger *zap.Logger, quit <-chan struct{}) *EventService {
+	return &EventService{
+		source: source,
+		logger: logger.With(zap.String("module", "event service")),
+		quit:   quit,
+	}
+}
+
+func (es *EventService) SubscribeEvents(req *proto.SubscribeEventsRequest, stream proto.EventService_SubscribeEventsServer) error {
+	if req.FromSequence != 0 && req.FromHeight != 0 {
+		return status.Error(codes.InvalidArgument, "from_sequence and from_height cannot be set together")
+	}
+
+	accepted := make(map[client.EventType]bool)
+	for _, eventType := range req.EventTypes {
+		if _, err := consumer.EventQueueName(client.EventType(eventType)); err != nil {
+			return status.Errorf(codes.InvalidArgument, "unknown event type %d", eventType)
+		}
+		accepted[client.EventType(eventType)] = true
+	}
+	accepts := func(eventType client.EventType) bool {
+		return len(accepted) == 0 || accepted[eventType]
+	}
+
+	startSeq := req.FromSequence
+	if req.FromHeight != 0 {
+		var err error
+		startSeq, err = es.heightStartSequence(req.FromHeight)
+		if err != nil {
+			return err
+		}
+	}
+	nextSeq := max(startSeq, 1)
+	var lastBtcInfo *consumer.EventEnvelope
+
+	es.logger.Debug("new subscription",
+		zap.Uint64("from_sequence", req.FromSequence),
+		zap.Uint64("from_height", req.FromHeight))
+
+	for {
+		// obtain the notification channel before reading the events, so
+		// that the events committed after the read wake up the stream
+		updated := es.source.EventUpdates()
+
+		envelopes, err := es.source.GetOutboxEvents(nextSeq, eventBatchSize)
+		if err != nil {
+			es.logger.Error("failed to get the stored events", zap.Error(err))
+			return status.Error(codes.Internal, "failed to get the stored events")
+		}
+
+		// the delivered events older than the retention of the outbox are
+		// pruned, so the stream cannot start from them without a gap
+		if nextSeq == startSeq && len(envelopes) > 0 && envelopes[0].Sequence > nextSeq {
+			return status.Errorf(codes.OutOfRange,
+				"the events before sequence %d are pruned from the outbox", envelopes[0].Sequence)
+		}
+
+		for _, env := range envelopes {
+			nextSeq = env.Sequence + 1
+
+			if !accepts(env.EventType) {
+				continue
+			}
+
+			if err := stream.Send(env.ToProto()); err != nil {
+				return err
+			}
+		}
+
+		if len(envelopes) == eventBatchSize {
+			// keep reading the stored events
+			continue
+		}
+
+		// the history is streamed, follow the btc info events as well
+		if btcInfo := es.source.LatestBtcInfoEvent(); btcInfo != nil && btcInfo != lastBtcInfo {
+			lastBtcInfo = btcInfo
+			if accepts(btcInfo.EventType) {
+				if err := stream.Send(btcInfo.ToProto()); err != nil {
+					return err
+				}
+			}
+		}
+
+		select {
+		case <-updated:
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		case <-es.quit:
+			return status.Error(codes.Unavailable, "the server is shutting down")
+		}
+	}
+}
+
+// heightStartSequence returns the sequence of the first event caused by a
+// block at or above the height. The subscription is refused if the oldest
+// event kept in the outbox is caused by a block above the height while the
+// older events are pruned, as the events from the height may be pruned
+func (es *EventService) heightStartSequence(height uint64) (uint64, error) {
+	oldest, err := es.source.GetOutboxEvents(0, 1)
+	if err != nil {
+		es.logger.Error("failed to get the stored events", zap.Error(err))
+		return 0, status.Error(codes.Internal, "failed to get the stored events")
+	}
+	if len(oldest) > 0 && oldest[0].Sequence > 1 && oldest[0].BlockHeight > height {
+		return 0, status.Errorf(codes.OutOfRange,
+			"the events before height %d are pruned from the outbox", oldest[0].BlockHeight)
+	}
+
+	seq, err := es.source.GetOutboxSequenceFromHeight(height)
+	if err != nil {
+		es.logger.Error("failed to get the sequence of the height", zap.Error(err))
+		return 0, status.Error(codes.Internal, "failed to get the stored events")
+	}
+
+	return seq, nil
+}
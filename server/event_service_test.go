@@ -0,0 +1,280 @@
+package server_test
+
+import (
+	"context"
+	"math/rand"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	queuecli "github.com/babylonlabs-io/staking-queue-client/client"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/babylonlabs-io/staking-indexer/btcscanner"
+	"github.com/babylonlabs-io/staking-indexer/config"
+	"github.com/babylonlabs-io/staking-indexer/consumer"
+	"github.com/babylonlabs-io/staking-indexer/indexer"
+	"github.com/babylonlabs-io/staking-indexer/proto"
+	"github.com/babylonlabs-io/staking-indexer/server"
+	"github.com/babylonlabs-io/staking-indexer/testutils/datagen"
+	"github.com/babylonlabs-io/staking-indexer/testutils/mocks"
+	"github.com/babylonlabs-io/staking-indexer/types"
+)
+
+// TestSubscribeEvents tests that a subscription streams the stored events
+// from the given position, and then the live events without a gap
+func TestSubscribeEvents(t *testing.T) {
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	homePath := filepath.Join(t.TempDir(), "indexer")
+	cfg := config.DefaultConfigWithHome(homePath)
+	// a confirmed info event is written for every block
+	cfg.ExtraEventEnabled = true
+
+	sysParamsVersions := datagen.GenerateGlobalParamsVersions(r, t)
+
+	ctl := gomock.NewController(t)
+	mockedConsumer := mocks.NewMockEventConsumer(ctl)
+	mockedConsumer.EXPECT().PushStakingEvent(gomock.Any()).Return(nil).AnyTimes()
+	mockedConsumer.EXPECT().PushConfirmedInfoEvent(gomock.Any()).Return(nil).AnyTimes()
+	mockedConsumer.EXPECT().PushBtcInfoEvent(gomock.Any()).Return(nil).AnyTimes()
+
+	chainUpdateInfoChan := make(chan *btcscanner.ChainUpdateInfo)
+	mockBtcScanner := mocks.NewMockBtcScanner(ctl)
+	mockBtcScanner.EXPECT().Start(gomock.Any(), gomock.Any()).Return(nil).AnyTimes()
+	mockBtcScanner.EXPECT().ChainUpdateInfoChan().Return(chainUpdateInfoChan).AnyTimes()
+	mockBtcScanner.EXPECT().Stop().Return(nil).AnyTimes()
+
+	db, err := cfg.DatabaseConfig.GetDbBackend()
+	require.NoError(t, err)
+	stakingIndexer, err := indexer.NewStakingIndexer(cfg, zap.NewNop(), mockedConsumer, db, sysParamsVersions, mockBtcScanner)
+	require.NoError(t, err)
+
+	initialHeight := stakingIndexer.GetStartHeight()
+	err = stakingIndexer.Start(initialHeight)
+	require.NoError(t, err)
+	defer func() {
+		err := stakingIndexer.Stop()
+		require.NoError(t, err)
+		err = db.Close()
+		require.NoError(t, err)
+	}()
+
+	genBlock := func(height uint64) *types.IndexedBlock {
+		params := sysParamsVersions.GetVersionedGlobalParamsByHeight(height)
+		require.NotNil(t, params)
+		stakingTxs := make([]*btcutil.Tx, r.Intn(3))
+		for i := range stakingTxs {
+			stakingData := datagen.GenerateTestStakingData(t, r, params)
+			_, stakingTxs[i] = datagen.GenerateStakingTxFromTestData(t, r, params, stakingData)
+		}
+		return &types.IndexedBlock{
+			Height: int32(height),
+			Header: &wire.BlockHeader{Timestamp: time.Now(), Nonce: r.Uint32()},
+			Txs:    stakingTxs,
+		}
+	}
+
+	// index some blocks before subscribing
+	numBlocks := r.Intn(5) + 1
+	confirmedBlocks := make([]*types.IndexedBlock, numBlocks)
+	for i := range confirmedBlocks {
+		confirmedBlocks[i] = genBlock(initialHeight + uint64(i))
+	}
+	chainUpdateInfoChan <- &btcscanner.ChainUpdateInfo{
+		ConfirmedBlocks: confirmedBlocks,
+	}
+	require.Eventually(t, func() bool {
+		return stakingIndexer.GetStartHeight() == initialHeight+uint64(numBlocks)
+	}, 10*time.Second, 100*time.Millisecond)
+
+	client := serveEvents(t, stakingIndexer)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// the position cannot be given by both a sequence and a height
+	stream, err := client.SubscribeEvents(ctx, &proto.SubscribeEventsRequest{FromSequence: 1, FromHeight: 1})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	// the stored events from the height are streamed first
+	fromHeight := initialHeight + uint64(r.Intn(numBlocks))
+	stream, err = client.SubscribeEvents(ctx, &proto.SubscribeEventsRequest{FromHeight: fromHeight})
+	require.NoError(t, err)
+	storedEvents, err := stakingIndexer.GetOutboxEvents(1, 1000)
+	require.NoError(t, err)
+	var expected []*consumer.EventEnvelope
+	for _, env := range storedEvents {
+		if env.BlockHeight >= fromHeight {
+			expected = append(expected, env)
+		}
+	}
+	require.NotEmpty(t, expected)
+	for _, env := range expected {
+		envProto, err := stream.Recv()
+		require.NoError(t, err)
+		require.Equal(t, env, consumer.EventEnvelopeFromProto(envProto))
+	}
+
+	// only the confirmed info events are streamed to a filtered subscription
+	filteredStream, err := client.SubscribeEvents(ctx, &proto.SubscribeEventsRequest{
+		FromSequence: expected[0].Sequence,
+		EventTypes:   []uint32{uint32(queuecli.ConfirmedInfoEventType)},
+	})
+	require.NoError(t, err)
+	for height := fromHeight; height < initialHeight+uint64(numBlocks); height++ {
+		envProto, err := filteredStream.Recv()
+		require.NoError(t, err)
+		require.Equal(t, uint32(queuecli.ConfirmedInfoEventType), envProto.EventType)
+		require.Equal(t, height, envProto.BlockHeight)
+	}
+
+	// the live events follow, and the btc info event comes after the
+	// events of the confirmed block
+	lastSeq := storedEvents[len(storedEvents)-1].Sequence
+	confirmedBlock := genBlock(initialHeight + uint64(numBlocks))
+	unconfirmedBlock := genBlock(initialHeight + uint64(numBlocks) + 1)
+	chainUpdateInfoChan <- &btcscanner.ChainUpdateInfo{
+		ConfirmedBlocks:   []*types.IndexedBlock{confirmedBlock},
+		UnconfirmedBlocks: []*types.IndexedBlock{unconfirmedBlock},
+	}
+	for i := 0; i < len(confirmedBlock.Txs)+1; i++ {
+		envProto, err := stream.Recv()
+		require.NoError(t, err)
+		require.Equal(t, lastSeq+uint64(i)+1, envProto.Sequence)
+		require.Equal(t, uint64(confirmedBlock.Height), envProto.BlockHeight)
+	}
+	envProto, err := stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, uint32(queuecli.BtcInfoEventType), envProto.EventType)
+	require.Zero(t, envProto.Sequence)
+	require.True(t, envProto.Unsequenced)
+	require.Equal(t, uint64(unconfirmedBlock.Height), envProto.BlockHeight)
+
+	envProto, err = filteredStream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, uint32(queuecli.ConfirmedInfoEventType), envProto.EventType)
+	require.Equal(t, uint64(confirmedBlock.Height), envProto.BlockHeight)
+}
+
+// TestSubscribePrunedEvents tests that a subscription from a sequence or a
+// height pruned from the outbox is refused, while the one from a kept event
+// or without a position is served
+func TestSubscribePrunedEvents(t *testing.T) {
+	r := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	oldestSeq := uint64(r.Intn(100) + 2)
+	oldestHeight := uint64(r.Intn(100) + 2)
+	source := &prunedEventSource{updates: make(chan struct{})}
+	numEvents := r.Intn(10) + 1
+	for i := 0; i < numEvents; i++ {
+		source.envelopes = append(source.envelopes, consumer.NewEventEnvelope(
+			oldestSeq+uint64(i), queuecli.ConfirmedInfoEventType, oldestHeight+uint64(i), "", []byte("{}"),
+		))
+	}
+	client := serveEvents(t, source)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	stream, err := client.SubscribeEvents(ctx, &proto.SubscribeEventsRequest{FromSequence: oldestSeq - 1})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	require.Equal(t, codes.OutOfRange, status.Code(err))
+
+	for _, fromSeq := range []uint64{0, oldestSeq} {
+		stream, err := client.SubscribeEvents(ctx, &proto.SubscribeEventsRequest{FromSequence: fromSeq})
+		require.NoError(t, err)
+		envProto, err := stream.Recv()
+		require.NoError(t, err)
+		require.Equal(t, oldestSeq, envProto.Sequence)
+	}
+
+	// the events of the blocks below the oldest kept event are pruned
+	stream, err = client.SubscribeEvents(ctx, &proto.SubscribeEventsRequest{FromHeight: oldestHeight - 1})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	require.Equal(t, codes.OutOfRange, status.Code(err))
+
+	// the stream from a kept height starts from the first event of the height
+	i := r.Intn(numEvents)
+	stream, err = client.SubscribeEvents(ctx, &proto.SubscribeEventsRequest{FromHeight: oldestHeight + uint64(i)})
+	require.NoError(t, err)
+	envProto, err := stream.Recv()
+	require.NoError(t, err)
+	require.Equal(t, oldestSeq+uint64(i), envProto.Sequence)
+	require.Equal(t, oldestHeight+uint64(i), envProto.BlockHeight)
+}
+
+// prunedEventSource is an EventSource whose outbox starts after the pruned
+// events
+type prunedEventSource struct {
+	envelopes []*consumer.EventEnvelope
+	updates   chan struct{}
+}
+
+func (s *prunedEventSource) GetOutboxEvents(fromSeq uint64, limit int) ([]*consumer.EventEnvelope, error) {
+	var envelopes []*consumer.EventEnvelope
+	for _, env := range s.envelopes {
+		if env.Sequence >= fromSeq && len(envelopes) < limit {
+			envelopes = append(envelopes, env)
+		}
+	}
+	return envelopes, nil
+}
+
+func (s *prunedEventSource) GetOutboxSequenceFromHeight(height uint64) (uint64, error) {
+	for _, env := range s.envelopes {
+		if env.BlockHeight >= height {
+			return env.Sequence, nil
+		}
+	}
+	return s.envelopes[len(s.envelopes)-1].Sequence + 1, nil
+}
+
+func (s *prunedEventSource) EventUpdates() <-chan struct{} {
+	return s.updates
+}
+
+func (s *prunedEventSource) LatestBtcInfoEvent() *consumer.EventEnvelope {
+	return nil
+}
+
+// serveEvents serves the events of the given source through an in-memory
+// connection, and returns the client connected to it
+func serveEvents(t *testing.T, source server.EventSource) proto.EventServiceClient {
+	lis := bufconn.Listen(1024 * 1024)
+	quit := make(chan struct{})
+	grpcServer := grpc.NewServer()
+	proto.RegisterEventServiceServer(grpcServer, server.NewEventService(source, zap.NewNop(), quit))
+	go func() {
+		_ = grpcServer.Serve(lis)
+	}()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		conn.Close()
+		close(quit)
+		grpcServer.Stop()
+	})
+
+	return proto.NewEventServiceClient(conn)
+}
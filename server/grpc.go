@@ -0,0 +1,62 @@
+package server
+
+import (
+	"net"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+
+	"github.com/babylonlabs-io/staking-indexer/proto"
+)
+
+type GRPCServer struct {
+	svr  *grpc.Server
+	addr string
+
+	logger *zap.Logger
+
+	quit chan struct{}
+}
+
+func NewGRPCServer(addr string, source EventSource, logger *zap.Logger) *GRPCServer {
+	quit := make(chan struct{})
+
+	svr := grpc.NewServer()
+	proto.RegisterEventServiceServer(svr, NewEventService(source, logger, quit))
+
+	return &GRPCServer{
+		svr:    svr,
+		addr:   addr,
+		logger: logger,
+		quit:   quit,
+	}
+}
+
+// Start listens on the address and serves the gRPC requests in the
+// background
+func (gs *GRPCServer) Start() error {
+	lis, err := net.Listen("tcp", gs.addr)
+	if err != nil {
+		return err
+	}
+
+	gs.logger.Info("Starting gRPC server",
+		zap.String("address", gs.addr))
+
+	go func() {
+		if err := gs.svr.Serve(lis); err != nil {
+			gs.logger.Error("the gRPC server stopped with an error",
+				zap.Error(err))
+		}
+	}()
+
+	return nil
+}
+
+// Stop ends the subscriptions and stops the gRPC server
+func (gs *GRPCServer) Stop() {
+	gs.logger.Info("Stopping gRPC server")
+
+	close(gs.quit)
+	gs.svr.GracefulStop()
+}
@@ -2,10 +2,10 @@ package server
 
 import (
 	"fmt"
+	"io"
 	"sync/atomic"
 
 	notifier "github.com/lightningnetwork/lnd/chainntnfs"
-	"github.com/lightningnetwork/lnd/kvdb"
 	"github.com/lightningnetwork/lnd/signal"
 	"go.uber.org/zap"
 
@@ -23,7 +23,8 @@ type Server struct {
 	btcNotifier notifier.ChainNotifier
 	ec          consumer.EventConsumer
 
-	db kvdb.Backend
+	// db is the database of the indexer store, whichever its backend
+	db io.Closer
 
 	cfg    *config.Config
 	logger *zap.Logger
@@ -35,7 +36,7 @@ type Server struct {
 func NewStakingIndexerServer(
 	cfg *config.Config,
 	ec consumer.EventConsumer,
-	db kvdb.Backend,
+	db io.Closer,
 	btcNotifier notifier.ChainNotifier,
 	si *indexer.StakingIndexer,
 	l *zap.Logger,
@@ -114,6 +115,37 @@ func (s *Server) RunUntilShutdown(startHeight uint64) error {
 		}
 	}()
 
+	if s.cfg.APIConfig.Enabled {
+		apiAddr, err := s.cfg.APIConfig.Address()
+		if err != nil {
+			return err
+		}
+
+		as := NewAPIServer(apiAddr, s.si, s.logger)
+		defer func() {
+			as.Stop()
+			s.logger.Info("Shutdown query API server complete")
+		}()
+
+		go as.Start()
+	}
+
+	if s.cfg.GRPCConfig.Enabled {
+		grpcAddr, err := s.cfg.GRPCConfig.Address()
+		if err != nil {
+			return err
+		}
+
+		gs := NewGRPCServer(grpcAddr, s.si, s.logger)
+		if err := gs.Start(); err != nil {
+			return fmt.Errorf("failed to start the gRPC server: %w", err)
+		}
+		defer func() {
+			gs.Stop()
+			s.logger.Info("Shutdown gRPC server complete")
+		}()
+	}
+
 	s.logger.Info("Staking Indexer service is fully active!")
 	// Wait for shutdown signal from either a graceful server stop or from
 	// the interrupt handler.
@@ -171,6 +171,54 @@ func GenerateWithdrawalTxFromUnbonding(t *testing.T, r *rand.Rand, params *parse
 	return btcutil.NewTx(withdrawalTx)
 }
 
+func GenerateSlashingTxFromStaking(t *testing.T, r *rand.Rand, params *parser.ParsedVersionedGlobalParams, stakingData *TestStakingData, stakingTxHash *chainhash.Hash, stakingOutputIdx uint32) *btcutil.Tx {
+	stakingInfo, err := btcstaking.BuildV0IdentifiableStakingOutputs(
+		params.Tag,
+		stakingData.StakerKey,
+		stakingData.FinalityProviderKey,
+		params.CovenantPks,
+		params.CovenantQuorum,
+		stakingData.StakingTime,
+		stakingData.StakingAmount,
+		&chaincfg.SigNetParams,
+	)
+	require.NoError(t, err)
+
+	slashingSpendInfo, err := stakingInfo.SlashingPathSpendInfo()
+	require.NoError(t, err)
+
+	return genSlashingTx(t, r, slashingSpendInfo, wire.NewOutPoint(stakingTxHash, stakingOutputIdx))
+}
+
+func GenerateSlashingTxFromUnbonding(t *testing.T, r *rand.Rand, params *parser.ParsedVersionedGlobalParams, stakingData *TestStakingData, unbondingTxHash *chainhash.Hash) *btcutil.Tx {
+	unbondingInfo, err := btcstaking.BuildUnbondingInfo(
+		stakingData.StakerKey,
+		[]*btcec.PublicKey{stakingData.FinalityProviderKey},
+		params.CovenantPks,
+		params.CovenantQuorum,
+		params.UnbondingTime,
+		stakingData.StakingAmount-params.UnbondingFee,
+		&chaincfg.SigNetParams,
+	)
+	require.NoError(t, err)
+
+	slashingSpendInfo, err := unbondingInfo.SlashingPathSpendInfo()
+	require.NoError(t, err)
+
+	return genSlashingTx(t, r, slashingSpendInfo, wire.NewOutPoint(unbondingTxHash, 0))
+}
+
+func genSlashingTx(t *testing.T, r *rand.Rand, slashingSpendInfo *btcstaking.SpendInfo, spentOutput *wire.OutPoint) *btcutil.Tx {
+	slashingTx := wire.NewMsgTx(2)
+	witness, err := btcstaking.CreateWitness(slashingSpendInfo, [][]byte{})
+	require.NoError(t, err)
+	slashingTx.AddTxIn(wire.NewTxIn(spentOutput, nil, witness))
+	// add a dump output to the slashing address
+	slashingTx.AddTxOut(wire.NewTxOut(r.Int63n(1000)+1, bbndatagen.GenRandomByteArray(r, 32)))
+
+	return btcutil.NewTx(slashingTx)
+}
+
 func GenNStoredStakingTxs(t *testing.T, r *rand.Rand, n int, maxStakingTime uint16) []*indexerstore.StoredStakingTransaction {
 	storedTxs := make([]*indexerstore.StoredStakingTransaction, n)
 
@@ -189,7 +237,32 @@ func GenStoredUnbondingTxs(r *rand.Rand, stakingTxs []*indexerstore.StoredStakin
 
 	for i := 0; i < n; i++ {
 		stakingHash := stakingTxs[i].Tx.TxHash()
-		storedTxs[i] = genStoredUnbondingTx(r, &stakingHash)
+		// the unbonding tx is included after the staking tx
+		inclusionHeight := stakingTxs[i].InclusionHeight + uint64(r.Int63n(100)+1)
+		storedTxs[i] = genStoredUnbondingTx(r, &stakingHash, inclusionHeight)
+	}
+
+	return storedTxs
+}
+
+// GenStoredWithdrawalTxs generates a withdrawal tx for each of the given
+// unbonding txs, which randomly spends the unbonding tx or its staking tx
+func GenStoredWithdrawalTxs(r *rand.Rand, unbondingTxs []*indexerstore.StoredUnbondingTransaction) []*indexerstore.StoredWithdrawalTransaction {
+	n := len(unbondingTxs)
+	storedTxs := make([]*indexerstore.StoredWithdrawalTransaction, n)
+
+	for i := 0; i < n; i++ {
+		var unbondingTxHash *chainhash.Hash
+		if r.Intn(2) == 0 {
+			hash := unbondingTxs[i].Tx.TxHash()
+			unbondingTxHash = &hash
+		}
+		storedTxs[i] = &indexerstore.StoredWithdrawalTransaction{
+			Tx:              GenRandomTx(r),
+			StakingTxHash:   unbondingTxs[i].StakingTxHash,
+			UnbondingTxHash: unbondingTxHash,
+			InclusionHeight: unbondingTxs[i].InclusionHeight + uint64(r.Int63n(100)+1),
+		}
 	}
 
 	return storedTxs
@@ -248,11 +321,13 @@ func genStoredStakingTx(t *testing.T, r *rand.Rand, maxStakingTime uint16, inclu
 	}
 }
 
-func genStoredUnbondingTx(r *rand.Rand, stakingTxHash *chainhash.Hash) *indexerstore.StoredUnbondingTransaction {
+func genStoredUnbondingTx(r *rand.Rand, stakingTxHash *chainhash.Hash, inclusionHeight uint64) *indexerstore.StoredUnbondingTransaction {
 	btcTx := GenRandomTx(r)
 
 	return &indexerstore.StoredUnbondingTransaction{
-		Tx:            btcTx,
-		StakingTxHash: stakingTxHash,
+		Tx:              btcTx,
+		StakingTxHash:   stakingTxHash,
+		InclusionHeight: inclusionHeight,
+		UnbondingTime:   uint32(r.Int31n(1000) + 1),
 	}
 }
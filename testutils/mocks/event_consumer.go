@@ -7,6 +7,7 @@ package mocks
 import (
 	reflect "reflect"
 
+	consumer "github.com/babylonlabs-io/staking-indexer/consumer"
 	client "github.com/babylonlabs-io/staking-queue-client/client"
 	gomock "github.com/golang/mock/gomock"
 )
@@ -35,7 +36,7 @@ func (m *MockEventConsumer) EXPECT() *MockEventConsumerMockRecorder {
 }
 
 // PushBtcInfoEvent mocks base method.
-func (m *MockEventConsumer) PushBtcInfoEvent(ev *client.BtcInfoEvent) error {
+func (m *MockEventConsumer) PushBtcInfoEvent(ev *consumer.BtcInfoEvent) error {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "PushBtcInfoEvent", ev)
 	ret0, _ := ret[0].(error)
@@ -62,6 +63,62 @@ func (mr *MockEventConsumerMockRecorder) PushConfirmedInfoEvent(ev interface{})
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PushConfirmedInfoEvent", reflect.TypeOf((*MockEventConsumer)(nil).PushConfirmedInfoEvent), ev)
 }
 
+// PushFinalityProviderInfoEvent mocks base method.
+func (m *MockEventConsumer) PushFinalityProviderInfoEvent(ev *consumer.FinalityProviderInfoEvent) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PushFinalityProviderInfoEvent", ev)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PushFinalityProviderInfoEvent indicates an expected call of PushFinalityProviderInfoEvent.
+func (mr *MockEventConsumerMockRecorder) PushFinalityProviderInfoEvent(ev interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PushFinalityProviderInfoEvent", reflect.TypeOf((*MockEventConsumer)(nil).PushFinalityProviderInfoEvent), ev)
+}
+
+// PushInvalidStakingEvent mocks base method.
+func (m *MockEventConsumer) PushInvalidStakingEvent(ev *consumer.InvalidStakingEvent) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PushInvalidStakingEvent", ev)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PushInvalidStakingEvent indicates an expected call of PushInvalidStakingEvent.
+func (mr *MockEventConsumerMockRecorder) PushInvalidStakingEvent(ev interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PushInvalidStakingEvent", reflect.TypeOf((*MockEventConsumer)(nil).PushInvalidStakingEvent), ev)
+}
+
+// PushRollbackEvent mocks base method.
+func (m *MockEventConsumer) PushRollbackEvent(ev *consumer.RollbackEvent) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PushRollbackEvent", ev)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PushRollbackEvent indicates an expected call of PushRollbackEvent.
+func (mr *MockEventConsumerMockRecorder) PushRollbackEvent(ev interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PushRollbackEvent", reflect.TypeOf((*MockEventConsumer)(nil).PushRollbackEvent), ev)
+}
+
+// PushSlashingEvent mocks base method.
+func (m *MockEventConsumer) PushSlashingEvent(ev *consumer.SlashingEvent) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PushSlashingEvent", ev)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PushSlashingEvent indicates an expected call of PushSlashingEvent.
+func (mr *MockEventConsumerMockRecorder) PushSlashingEvent(ev interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PushSlashingEvent", reflect.TypeOf((*MockEventConsumer)(nil).PushSlashingEvent), ev)
+}
+
 // PushStakingEvent mocks base method.
 func (m *MockEventConsumer) PushStakingEvent(ev *client.ActiveStakingEvent) error {
 	m.ctrl.T.Helper()
@@ -76,6 +133,20 @@ func (mr *MockEventConsumerMockRecorder) PushStakingEvent(ev interface{}) *gomoc
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PushStakingEvent", reflect.TypeOf((*MockEventConsumer)(nil).PushStakingEvent), ev)
 }
 
+// PushTimelockExpiredEvent mocks base method.
+func (m *MockEventConsumer) PushTimelockExpiredEvent(ev *consumer.TimelockExpiredEvent) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PushTimelockExpiredEvent", ev)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PushTimelockExpiredEvent indicates an expected call of PushTimelockExpiredEvent.
+func (mr *MockEventConsumerMockRecorder) PushTimelockExpiredEvent(ev interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PushTimelockExpiredEvent", reflect.TypeOf((*MockEventConsumer)(nil).PushTimelockExpiredEvent), ev)
+}
+
 // PushUnbondingEvent mocks base method.
 func (m *MockEventConsumer) PushUnbondingEvent(ev *client.UnbondingStakingEvent) error {
 	m.ctrl.T.Helper()
@@ -131,3 +202,427 @@ func (mr *MockEventConsumerMockRecorder) Stop() *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Stop", reflect.TypeOf((*MockEventConsumer)(nil).Stop))
 }
+
+// MockEnvelopeConsumer is a mock of EnvelopeConsumer interface.
+type MockEnvelopeConsumer struct {
+	ctrl     *gomock.Controller
+	recorder *MockEnvelopeConsumerMockRecorder
+}
+
+// MockEnvelopeConsumerMockRecorder is the mock recorder for MockEnvelopeConsumer.
+type MockEnvelopeConsumerMockRecorder struct {
+	mock *MockEnvelopeConsumer
+}
+
+// NewMockEnvelopeConsumer creates a new mock instance.
+func NewMockEnvelopeConsumer(ctrl *gomock.Controller) *MockEnvelopeConsumer {
+	mock := &MockEnvelopeConsumer{ctrl: ctrl}
+	mock.recorder = &MockEnvelopeConsumerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockEnvelopeConsumer) EXPECT() *MockEnvelopeConsumerMockRecorder {
+	return m.recorder
+}
+
+// PushBtcInfoEvent mocks base method.
+func (m *MockEnvelopeConsumer) PushBtcInfoEvent(ev *consumer.BtcInfoEvent) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PushBtcInfoEvent", ev)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PushBtcInfoEvent indicates an expected call of PushBtcInfoEvent.
+func (mr *MockEnvelopeConsumerMockRecorder) PushBtcInfoEvent(ev interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PushBtcInfoEvent", reflect.TypeOf((*MockEnvelopeConsumer)(nil).PushBtcInfoEvent), ev)
+}
+
+// PushConfirmedInfoEvent mocks base method.
+func (m *MockEnvelopeConsumer) PushConfirmedInfoEvent(ev *client.ConfirmedInfoEvent) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PushConfirmedInfoEvent", ev)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PushConfirmedInfoEvent indicates an expected call of PushConfirmedInfoEvent.
+func (mr *MockEnvelopeConsumerMockRecorder) PushConfirmedInfoEvent(ev interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PushConfirmedInfoEvent", reflect.TypeOf((*MockEnvelopeConsumer)(nil).PushConfirmedInfoEvent), ev)
+}
+
+// PushEventEnvelope mocks base method.
+func (m *MockEnvelopeConsumer) PushEventEnvelope(env *consumer.EventEnvelope) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PushEventEnvelope", env)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PushEventEnvelope indicates an expected call of PushEventEnvelope.
+func (mr *MockEnvelopeConsumerMockRecorder) PushEventEnvelope(env interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PushEventEnvelope", reflect.TypeOf((*MockEnvelopeConsumer)(nil).PushEventEnvelope), env)
+}
+
+// PushFinalityProviderInfoEvent mocks base method.
+func (m *MockEnvelopeConsumer) PushFinalityProviderInfoEvent(ev *consumer.FinalityProviderInfoEvent) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PushFinalityProviderInfoEvent", ev)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PushFinalityProviderInfoEvent indicates an expected call of PushFinalityProviderInfoEvent.
+func (mr *MockEnvelopeConsumerMockRecorder) PushFinalityProviderInfoEvent(ev interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PushFinalityProviderInfoEvent", reflect.TypeOf((*MockEnvelopeConsumer)(nil).PushFinalityProviderInfoEvent), ev)
+}
+
+// PushInvalidStakingEvent mocks base method.
+func (m *MockEnvelopeConsumer) PushInvalidStakingEvent(ev *consumer.InvalidStakingEvent) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PushInvalidStakingEvent", ev)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PushInvalidStakingEvent indicates an expected call of PushInvalidStakingEvent.
+func (mr *MockEnvelopeConsumerMockRecorder) PushInvalidStakingEvent(ev interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PushInvalidStakingEvent", reflect.TypeOf((*MockEnvelopeConsumer)(nil).PushInvalidStakingEvent), ev)
+}
+
+// PushRollbackEvent mocks base method.
+func (m *MockEnvelopeConsumer) PushRollbackEvent(ev *consumer.RollbackEvent) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PushRollbackEvent", ev)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PushRollbackEvent indicates an expected call of PushRollbackEvent.
+func (mr *MockEnvelopeConsumerMockRecorder) PushRollbackEvent(ev interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PushRollbackEvent", reflect.TypeOf((*MockEnvelopeConsumer)(nil).PushRollbackEvent), ev)
+}
+
+// PushSlashingEvent mocks base method.
+func (m *MockEnvelopeConsumer) PushSlashingEvent(ev *consumer.SlashingEvent) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PushSlashingEvent", ev)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PushSlashingEvent indicates an expected call of PushSlashingEvent.
+func (mr *MockEnvelopeConsumerMockRecorder) PushSlashingEvent(ev interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PushSlashingEvent", reflect.TypeOf((*MockEnvelopeConsumer)(nil).PushSlashingEvent), ev)
+}
+
+// PushStakingEvent mocks base method.
+func (m *MockEnvelopeConsumer) PushStakingEvent(ev *client.ActiveStakingEvent) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PushStakingEvent", ev)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PushStakingEvent indicates an expected call of PushStakingEvent.
+func (mr *MockEnvelopeConsumerMockRecorder) PushStakingEvent(ev interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PushStakingEvent", reflect.TypeOf((*MockEnvelopeConsumer)(nil).PushStakingEvent), ev)
+}
+
+// PushTimelockExpiredEvent mocks base method.
+func (m *MockEnvelopeConsumer) PushTimelockExpiredEvent(ev *consumer.TimelockExpiredEvent) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PushTimelockExpiredEvent", ev)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PushTimelockExpiredEvent indicates an expected call of PushTimelockExpiredEvent.
+func (mr *MockEnvelopeConsumerMockRecorder) PushTimelockExpiredEvent(ev interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PushTimelockExpiredEvent", reflect.TypeOf((*MockEnvelopeConsumer)(nil).PushTimelockExpiredEvent), ev)
+}
+
+// PushUnbondingEvent mocks base method.
+func (m *MockEnvelopeConsumer) PushUnbondingEvent(ev *client.UnbondingStakingEvent) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PushUnbondingEvent", ev)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PushUnbondingEvent indicates an expected call of PushUnbondingEvent.
+func (mr *MockEnvelopeConsumerMockRecorder) PushUnbondingEvent(ev interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PushUnbondingEvent", reflect.TypeOf((*MockEnvelopeConsumer)(nil).PushUnbondingEvent), ev)
+}
+
+// PushWithdrawEvent mocks base method.
+func (m *MockEnvelopeConsumer) PushWithdrawEvent(ev *client.WithdrawStakingEvent) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PushWithdrawEvent", ev)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PushWithdrawEvent indicates an expected call of PushWithdrawEvent.
+func (mr *MockEnvelopeConsumerMockRecorder) PushWithdrawEvent(ev interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PushWithdrawEvent", reflect.TypeOf((*MockEnvelopeConsumer)(nil).PushWithdrawEvent), ev)
+}
+
+// Start mocks base method.
+func (m *MockEnvelopeConsumer) Start() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Start")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Start indicates an expected call of Start.
+func (mr *MockEnvelopeConsumerMockRecorder) Start() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Start", reflect.TypeOf((*MockEnvelopeConsumer)(nil).Start))
+}
+
+// Stop mocks base method.
+func (m *MockEnvelopeConsumer) Stop() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Stop")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Stop indicates an expected call of Stop.
+func (mr *MockEnvelopeConsumerMockRecorder) Stop() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Stop", reflect.TypeOf((*MockEnvelopeConsumer)(nil).Stop))
+}
+
+// MockAckingConsumer is a mock of AckingConsumer interface.
+type MockAckingConsumer struct {
+	ctrl     *gomock.Controller
+	recorder *MockAckingConsumerMockRecorder
+}
+
+// MockAckingConsumerMockRecorder is the mock recorder for MockAckingConsumer.
+type MockAckingConsumerMockRecorder struct {
+	mock *MockAckingConsumer
+}
+
+// NewMockAckingConsumer creates a new mock instance.
+func NewMockAckingConsumer(ctrl *gomock.Controller) *MockAckingConsumer {
+	mock := &MockAckingConsumer{ctrl: ctrl}
+	mock.recorder = &MockAckingConsumerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockAckingConsumer) EXPECT() *MockAckingConsumerMockRecorder {
+	return m.recorder
+}
+
+// DeliveredSequence mocks base method.
+func (m *MockAckingConsumer) DeliveredSequence() uint64 {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeliveredSequence")
+	ret0, _ := ret[0].(uint64)
+	return ret0
+}
+
+// DeliveredSequence indicates an expected call of DeliveredSequence.
+func (mr *MockAckingConsumerMockRecorder) DeliveredSequence() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeliveredSequence", reflect.TypeOf((*MockAckingConsumer)(nil).DeliveredSequence))
+}
+
+// PushBtcInfoEvent mocks base method.
+func (m *MockAckingConsumer) PushBtcInfoEvent(ev *consumer.BtcInfoEvent) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PushBtcInfoEvent", ev)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PushBtcInfoEvent indicates an expected call of PushBtcInfoEvent.
+func (mr *MockAckingConsumerMockRecorder) PushBtcInfoEvent(ev interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PushBtcInfoEvent", reflect.TypeOf((*MockAckingConsumer)(nil).PushBtcInfoEvent), ev)
+}
+
+// PushConfirmedInfoEvent mocks base method.
+func (m *MockAckingConsumer) PushConfirmedInfoEvent(ev *client.ConfirmedInfoEvent) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PushConfirmedInfoEvent", ev)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PushConfirmedInfoEvent indicates an expected call of PushConfirmedInfoEvent.
+func (mr *MockAckingConsumerMockRecorder) PushConfirmedInfoEvent(ev interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PushConfirmedInfoEvent", reflect.TypeOf((*MockAckingConsumer)(nil).PushConfirmedInfoEvent), ev)
+}
+
+// PushEventEnvelope mocks base method.
+func (m *MockAckingConsumer) PushEventEnvelope(env *consumer.EventEnvelope) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PushEventEnvelope", env)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PushEventEnvelope indicates an expected call of PushEventEnvelope.
+func (mr *MockAckingConsumerMockRecorder) PushEventEnvelope(env interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PushEventEnvelope", reflect.TypeOf((*MockAckingConsumer)(nil).PushEventEnvelope), env)
+}
+
+// PushFinalityProviderInfoEvent mocks base method.
+func (m *MockAckingConsumer) PushFinalityProviderInfoEvent(ev *consumer.FinalityProviderInfoEvent) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PushFinalityProviderInfoEvent", ev)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PushFinalityProviderInfoEvent indicates an expected call of PushFinalityProviderInfoEvent.
+func (mr *MockAckingConsumerMockRecorder) PushFinalityProviderInfoEvent(ev interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PushFinalityProviderInfoEvent", reflect.TypeOf((*MockAckingConsumer)(nil).PushFinalityProviderInfoEvent), ev)
+}
+
+// PushInvalidStakingEvent mocks base method.
+func (m *MockAckingConsumer) PushInvalidStakingEvent(ev *consumer.InvalidStakingEvent) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PushInvalidStakingEvent", ev)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PushInvalidStakingEvent indicates an expected call of PushInvalidStakingEvent.
+func (mr *MockAckingConsumerMockRecorder) PushInvalidStakingEvent(ev interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PushInvalidStakingEvent", reflect.TypeOf((*MockAckingConsumer)(nil).PushInvalidStakingEvent), ev)
+}
+
+// PushRollbackEvent mocks base method.
+func (m *MockAckingConsumer) PushRollbackEvent(ev *consumer.RollbackEvent) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PushRollbackEvent", ev)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PushRollbackEvent indicates an expected call of PushRollbackEvent.
+func (mr *MockAckingConsumerMockRecorder) PushRollbackEvent(ev interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PushRollbackEvent", reflect.TypeOf((*MockAckingConsumer)(nil).PushRollbackEvent), ev)
+}
+
+// PushSlashingEvent mocks base method.
+func (m *MockAckingConsumer) PushSlashingEvent(ev *consumer.SlashingEvent) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PushSlashingEvent", ev)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PushSlashingEvent indicates an expected call of PushSlashingEvent.
+func (mr *MockAckingConsumerMockRecorder) PushSlashingEvent(ev interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PushSlashingEvent", reflect.TypeOf((*MockAckingConsumer)(nil).PushSlashingEvent), ev)
+}
+
+// PushStakingEvent mocks base method.
+func (m *MockAckingConsumer) PushStakingEvent(ev *client.ActiveStakingEvent) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PushStakingEvent", ev)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PushStakingEvent indicates an expected call of PushStakingEvent.
+func (mr *MockAckingConsumerMockRecorder) PushStakingEvent(ev interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PushStakingEvent", reflect.TypeOf((*MockAckingConsumer)(nil).PushStakingEvent), ev)
+}
+
+// PushTimelockExpiredEvent mocks base method.
+func (m *MockAckingConsumer) PushTimelockExpiredEvent(ev *consumer.TimelockExpiredEvent) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PushTimelockExpiredEvent", ev)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PushTimelockExpiredEvent indicates an expected call of PushTimelockExpiredEvent.
+func (mr *MockAckingConsumerMockRecorder) PushTimelockExpiredEvent(ev interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PushTimelockExpiredEvent", reflect.TypeOf((*MockAckingConsumer)(nil).PushTimelockExpiredEvent), ev)
+}
+
+// PushUnbondingEvent mocks base method.
+func (m *MockAckingConsumer) PushUnbondingEvent(ev *client.UnbondingStakingEvent) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PushUnbondingEvent", ev)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PushUnbondingEvent indicates an expected call of PushUnbondingEvent.
+func (mr *MockAckingConsumerMockRecorder) PushUnbondingEvent(ev interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PushUnbondingEvent", reflect.TypeOf((*MockAckingConsumer)(nil).PushUnbondingEvent), ev)
+}
+
+// PushWithdrawEvent mocks base method.
+func (m *MockAckingConsumer) PushWithdrawEvent(ev *client.WithdrawStakingEvent) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PushWithdrawEvent", ev)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PushWithdrawEvent indicates an expected call of PushWithdrawEvent.
+func (mr *MockAckingConsumerMockRecorder) PushWithdrawEvent(ev interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PushWithdrawEvent", reflect.TypeOf((*MockAckingConsumer)(nil).PushWithdrawEvent), ev)
+}
+
+// Start mocks base method.
+func (m *MockAckingConsumer) Start() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Start")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Start indicates an expected call of Start.
+func (mr *MockAckingConsumerMockRecorder) Start() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Start", reflect.TypeOf((*MockAckingConsumer)(nil).Start))
+}
+
+// Stop mocks base method.
+func (m *MockAckingConsumer) Stop() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Stop")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Stop indicates an expected call of Stop.
+func (mr *MockAckingConsumerMockRecorder) Stop() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Stop", reflect.TypeOf((*MockAckingConsumer)(nil).Stop))
+}
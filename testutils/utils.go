@@ -2,8 +2,11 @@ package testutils
 
 import (
 	"bytes"
+	"database/sql"
 	"encoding/hex"
 	"fmt"
+	"math/rand"
+	"os"
 	"sort"
 	"testing"
 
@@ -19,6 +22,8 @@ import (
 	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcwallet/wallet/txauthor"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/stdlib"
 	"github.com/lightningnetwork/lnd/kvdb"
 	"github.com/stretchr/testify/require"
 
@@ -72,6 +77,41 @@ func MakeTestBackend(t *testing.T) kvdb.Backend {
 	return backend
 }
 
+// TestPostgresDSNEnv is the environment variable with the connection string
+// of the Postgres server the tests of the Postgres backend run against, they
+// are skipped if it is not set
+const TestPostgresDSNEnv = "SID_TEST_POSTGRES_DSN"
+
+// MakeTestPostgresDb returns a connection to a new schema of the Postgres
+// server given by TestPostgresDSNEnv, which is dropped at the end of the test
+func MakeTestPostgresDb(t *testing.T) *sql.DB {
+	dsn := os.Getenv(TestPostgresDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", TestPostgresDSNEnv)
+	}
+
+	adminDb, err := sql.Open("pgx", dsn)
+	require.NoError(t, err)
+	schema := fmt.Sprintf("sid_test_%d", rand.Uint64())
+	_, err = adminDb.Exec("CREATE SCHEMA " + schema)
+	require.NoError(t, err)
+
+	// all the tables of the store are created in the schema of the test
+	connCfg, err := pgx.ParseConfig(dsn)
+	require.NoError(t, err)
+	connCfg.RuntimeParams["search_path"] = schema
+	db := stdlib.OpenDB(*connCfg)
+
+	t.Cleanup(func() {
+		require.NoError(t, db.Close())
+		_, err := adminDb.Exec("DROP SCHEMA " + schema + " CASCADE")
+		require.NoError(t, err)
+		require.NoError(t, adminDb.Close())
+	})
+
+	return db
+}
+
 func PubKeysSliceEqual(pk1, pk2 []*btcec.PublicKey) bool {
 	if len(pk1) != len(pk2) {
 		return false